@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/nats"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// newNatsConfig builds a JetStream client configuration from the shared
+// connection flags for use by client subcommands. Client subcommands never
+// create or update the audit, dead-letter or quarantine streams.
+func newNatsConfig(c *cli.Command) (nats.Config, error) {
+	natsAddr := c.String("audit-nats-addr")
+	if natsAddr == "" {
+		return nats.Config{}, errors.New("NATS address is required, set --audit-nats-addr")
+	}
+
+	config := nats.DefaultConfig()
+	config.URL = natsAddr
+	config.Subject = c.String("audit-topic")
+	config.StreamName = c.String("audit-stream-name")
+	config.ConsumerName = c.String("audit-consumer-name")
+	config.DurableName = c.String("audit-durable-name")
+	config.Timeout = constants.DefaultTimeout
+	config.CreateStream = false
+	config.ReadOnly = true
+	config.StreamReplicas = c.Int("audit-stream-replicas")
+	config.DeadLetterStream = c.String("audit-dlq-stream")
+	config.DeadLetterSubject = c.String("audit-dlq-subject")
+	config.DeadLetterMaxAge = c.Duration("audit-dlq-max-age")
+
+	config.Auth = newNatsAuth(c)
+
+	return config, nil
+}
+
+// newNatsAuth reads the NATS credential and TLS flags.
+func newNatsAuth(c *cli.Command) nats.Auth {
+	return nats.Auth{
+		CredsFile:     c.String("audit-nats-creds"),
+		NKeySeedFile:  c.String("audit-nats-nkey"),
+		TokenFile:     c.String("audit-nats-token-file"),
+		UserFile:      c.String("audit-nats-user-file"),
+		PasswordFile:  c.String("audit-nats-password-file"),
+		TLSCertFile:   c.String("audit-nats-tls-cert"),
+		TLSKeyFile:    c.String("audit-nats-tls-key"),
+		TLSCAFile:     c.String("audit-nats-tls-ca"),
+		TLSServerName: c.String("audit-nats-tls-server-name"),
+	}
+}
+
+// connectClient connects a JetStream client for a client subcommand.
+func connectClient(ctx context.Context, config nats.Config) (*nats.Client, error) {
+	client, err := nats.NewClient(config, logrus.StandardLogger())
+	if err != nil {
+		return nil, err
+	}
+
+	if connectErr := client.Connect(ctx); connectErr != nil {
+		return nil, connectErr
+	}
+
+	return client, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"events-audit/internal/nats"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// connectDeadLetterClient connects a client with dead-lettering configured.
+func connectDeadLetterClient(ctx context.Context, c *cli.Command) (*nats.Client, error) {
+	config, err := newNatsConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	if config.DeadLetterStream == "" {
+		return nil, errors.New("dead-letter stream is not configured, set --audit-dlq-stream")
+	}
+
+	return connectClient(ctx, config)
+}
+
+func dlqListAction(ctx context.Context, c *cli.Command) error {
+	client, err := connectDeadLetterClient(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	letters, err := client.ListDeadLetters(c.Uint64("from"), c.Int("limit"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Root().Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tDEAD-LETTERED\tSUBJECT\tSTREAM SEQ\tDELIVERED\tERROR")
+	for _, dl := range letters {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\n",
+			dl.Sequence,
+			dl.DeadLetteredAt.Format(time.RFC3339),
+			dl.OriginalSubject,
+			dl.OriginalSequence,
+			dl.Delivered,
+			dl.Error,
+		)
+	}
+	return w.Flush()
+}
+
+func dlqInspectAction(ctx context.Context, c *cli.Command) error {
+	client, err := connectDeadLetterClient(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	dl, err := client.GetDeadLetter(c.Uint64("seq"))
+	if err != nil {
+		return err
+	}
+
+	view := map[string]any{
+		"sequence":          dl.Sequence,
+		"subject":           dl.Subject,
+		"original_subject":  dl.OriginalSubject,
+		"original_stream":   dl.OriginalStream,
+		"original_sequence": dl.OriginalSequence,
+		"delivered":         dl.Delivered,
+		"error":             dl.Error,
+		"dead_lettered_at":  dl.DeadLetteredAt,
+		"header":            dl.Header,
+	}
+	if utf8.Valid(dl.Data) {
+		view["data"] = string(dl.Data)
+	} else {
+		view["data_base64"] = dl.Data
+	}
+
+	encoder := json.NewEncoder(c.Root().Writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(view)
+}
+
+func dlqRedriveAction(ctx context.Context, c *cli.Command) error {
+	client, err := connectDeadLetterClient(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	seqs := c.Uint64Slice("seq")
+	if c.Bool("all") {
+		letters, listErr := client.ListDeadLetters(0, 0)
+		if listErr != nil {
+			return listErr
+		}
+		for _, dl := range letters {
+			seqs = append(seqs, dl.Sequence)
+		}
+	}
+	if len(seqs) == 0 {
+		return errors.New("nothing to redrive, pass --seq or --all")
+	}
+
+	var failed []string
+	for _, seq := range seqs {
+		if _, redriveErr := client.RedriveDeadLetter(seq); redriveErr != nil {
+			logrus.WithError(redriveErr).WithField("dlq_sequence", seq).Error("Failed to redrive dead-letter message")
+			failed = append(failed, strconv.FormatUint(seq, 10))
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"redriven": len(seqs) - len(failed),
+		"failed":   len(failed),
+	}).Info("Dead-letter redrive finished")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to redrive dead-letter messages %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func createDeadLetterCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "dlq",
+		Usage: "list, inspect and re-drive messages in the dead-letter stream",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list dead-lettered messages",
+				Action: dlqListAction,
+				Flags: []cli.Flag{
+					&cli.Uint64Flag{
+						Name:  "from",
+						Usage: "first dead-letter stream sequence to list `SEQ`",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "maximum number of messages to list, 0 for all `COUNT`",
+						Value: 100,
+					},
+				},
+			},
+			{
+				Name:   "inspect",
+				Usage:  "show a dead-lettered message with headers and payload",
+				Action: dlqInspectAction,
+				Flags: []cli.Flag{
+					&cli.Uint64Flag{
+						Name:     "seq",
+						Usage:    "dead-letter stream sequence `SEQ`",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "redrive",
+				Usage:  "republish dead-lettered messages to their original subject and remove them from the dead-letter stream",
+				Action: dlqRedriveAction,
+				Flags: []cli.Flag{
+					&cli.Uint64SliceFlag{
+						Name:  "seq",
+						Usage: "dead-letter stream sequence to redrive, may be repeated `SEQ`",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "redrive every dead-lettered message",
+					},
+				},
+			},
+		},
+	}
+}
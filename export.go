@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"events-audit/internal/export"
+	"events-audit/internal/nats"
+	"events-audit/internal/source"
+	"events-audit/internal/store"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// errExportDone stops reading the archive past the end of the time range.
+var errExportDone = errors.New("export done")
+
+func exportAction(ctx context.Context, c *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := export.Options{
+		Format:      c.String("format"),
+		Compression: c.String("compression"),
+		Source:      c.String("source"),
+		Subject:     c.String("subject"),
+		From:        c.Timestamp("from"),
+		To:          c.Timestamp("to"),
+	}
+	if mapping := c.String("columns"); mapping != "" {
+		columns, err := export.ParseColumns(mapping)
+		if err != nil {
+			return err
+		}
+		opts.Columns = columns
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	redactor, err := loadRedactor(c)
+	if err != nil {
+		return err
+	}
+	opts.Redactor = redactor
+	if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+		return errors.New("--from must be before --to")
+	}
+
+	var read func(write func(rec *store.Record) error) error
+	switch opts.Source {
+	case export.SourceStream:
+		client, err := connectReader(ctx, c)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		read = func(write func(rec *store.Record) error) error {
+			readOpts := nats.ReadOptions{Subject: opts.Subject, StartTime: opts.From, EndTime: opts.To}
+			return client.Read(ctx, readOpts, func(msg *source.Msg) error {
+				rec, err := store.RecordFromMsg(msg)
+				if err != nil {
+					return err
+				}
+				return write(rec)
+			})
+		}
+	case export.SourceArchive:
+		dir := c.String("audit-store-dir")
+		if dir == "" {
+			return errors.New("archive directory is required, set --audit-store-dir")
+		}
+		filter := store.Filter{Subject: opts.Subject}
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+		read = func(write func(rec *store.Record) error) error {
+			err := store.ReadDir(dir, func(rec *store.Record) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if !opts.To.IsZero() && !rec.Timestamp.Before(opts.To) {
+					return errExportDone
+				}
+				if rec.Timestamp.Before(opts.From) || !filter.Matches(rec) {
+					return nil
+				}
+				return write(rec)
+			})
+			if errors.Is(err, errExportDone) {
+				return nil
+			}
+			return err
+		}
+	default:
+		return errors.Errorf("unknown source %q, supported %s or %s", opts.Source, export.SourceStream, export.SourceArchive)
+	}
+
+	output := c.String("output")
+	writer, err := export.Create(output, opts)
+	if err != nil {
+		return err
+	}
+	if err = read(writer.Write); err != nil {
+		_, _ = writer.Close()
+		return err
+	}
+	manifest, err := writer.Close()
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file":      output,
+		"manifest":  export.ManifestPath(output),
+		"format":    manifest.Format,
+		"records":   manifest.Records,
+		"first_seq": manifest.FirstSeq,
+		"last_seq":  manifest.LastSeq,
+		"bytes":     manifest.Bytes,
+		"sha256":    manifest.SHA256,
+	}).Info("Export finished")
+	return nil
+}
+
+func importAction(ctx context.Context, c *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	path := c.Args().First()
+	if path == "" {
+		return errors.New("export file is required")
+	}
+
+	reader, err := export.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+	switch manifest := reader.Manifest(); {
+	case manifest == nil:
+		logrus.WithField("file", path).Warn("Export has no manifest, its checksum and record count are not verified")
+	case manifest.Redacted && !c.Bool("allow-redacted"):
+		return errors.New("export was redacted, use --allow-redacted to republish the redacted message bodies")
+	case manifest.Redacted:
+		logrus.WithField("file", path).Warn("Export was redacted, the redacted message bodies are republished")
+	}
+
+	client, err := connectReader(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	published, duplicates := 0, 0
+	for ctx.Err() == nil {
+		line, nextErr := reader.Next()
+		if errors.Is(nextErr, io.EOF) {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+
+		ack, restoreErr := client.Restore(&nats.RestoredMessage{
+			Subject:   line.Subject,
+			Header:    line.Header,
+			Data:      line.Message,
+			Stream:    line.Stream,
+			Sequence:  line.Sequence,
+			Timestamp: line.Timestamp,
+		})
+		if restoreErr != nil {
+			return restoreErr
+		}
+		if ack.Duplicate {
+			duplicates++
+			continue
+		}
+		published++
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file":       path,
+		"stream":     c.String("audit-stream-name"),
+		"published":  published,
+		"duplicates": duplicates,
+	}).Info("Import finished")
+	return ctx.Err()
+}
+
+func createExportCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "export",
+		Usage:  "export a time and subject range of the audit stream or archive to a JSONL, CSV or Parquet file with a manifest",
+		Action: exportAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "source",
+				Usage: "read from the JetStream stream or the local archive in --audit-store-dir `SOURCE`",
+				Value: export.SourceStream,
+			},
+			&cli.StringFlag{
+				Name:  "subject",
+				Usage: "export only messages of the subject, NATS wildcards allowed `SUBJECT`",
+			},
+			&cli.TimestampFlag{
+				Name:  "from",
+				Usage: "export messages published at or after the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.TimestampFlag{
+				Name:  "to",
+				Usage: "export messages published before the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "output format, jsonl, csv or parquet `FORMAT`",
+				Value: export.FormatJSONL,
+			},
+			&cli.StringFlag{
+				Name:  "compression",
+				Usage: "output compression, none, gzip or zstd `COMPRESSION`",
+				Value: export.CompressionNone,
+			},
+			&cli.StringFlag{
+				Name:  "columns",
+				Usage: "comma separated CSV and Parquet columns, each a field or name=field with fields stream_seq, timestamp, subject, id, type, source, data or data.<path> `COLUMNS`",
+			},
+			&cli.StringFlag{
+				Name:     "output",
+				Usage:    "export file, the manifest is written next to it with the .manifest.json suffix `FILE`",
+				Required: true,
+			},
+		},
+	}
+}
+
+func createImportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "republish the messages of a JSONL export to the audit stream for restore or migration",
+		ArgsUsage: "FILE",
+		Action:    importAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "allow-redacted",
+				Usage: "import an export written with redaction rules, republishing its redacted message bodies",
+			},
+		},
+	}
+}
@@ -0,0 +1,73 @@
+// Package api serves the archive over authenticated HTTP endpoints.
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// LoadTokens reads the bearer tokens accepted by the API from a file with
+// one token per line. Empty lines and lines starting with # are ignored.
+func LoadTokens(file string) ([][]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %w", err)
+	}
+
+	var tokens [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, []byte(line))
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("API tokens file has no tokens")
+	}
+	return tokens, nil
+}
+
+// Authenticate returns a middleware rejecting requests without one of the
+// bearer tokens in the Authorization header. Since browsers can not set
+// headers on EventSource and WebSocket requests, the token may also be
+// passed in the access_token query parameter.
+func Authenticate(tokens [][]byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				token = r.URL.Query().Get("access_token")
+			}
+			if token == "" || !validToken(tokens, []byte(token)) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="events-audit"`)
+				writeError(w, r, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validToken compares the token with every accepted token in constant time.
+func validToken(tokens [][]byte, token []byte) bool {
+	valid := 0
+	for _, accepted := range tokens {
+		valid |= subtle.ConstantTimeCompare(accepted, token)
+	}
+	return valid == 1
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"events-audit/internal/cloudevent"
+	"events-audit/internal/constants"
+	"events-audit/internal/redact"
+	"events-audit/internal/store"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// Response formats of the search endpoint.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// HeaderNextCursor carries the cursor of the next page in every format.
+const HeaderNextCursor = "X-Next-Cursor"
+
+// dataParamPrefix marks query parameters filtering on event data fields.
+const dataParamPrefix = "data."
+
+// Event is an archived or live event in API responses.
+type Event struct {
+	Sequence  uint64              `json:"stream_seq"`
+	Timestamp time.Time           `json:"timestamp"`
+	Subject   string              `json:"subject"`
+	ID        string              `json:"id,omitempty"`
+	Type      string              `json:"type,omitempty"`
+	Source    string              `json:"source,omitempty"`
+	Header    map[string][]string `json:"header,omitempty"`
+	Data      json.RawMessage     `json:"data,omitempty"`
+	// RawData holds the message body of events without JSON data.
+	RawData []byte `json:"raw_data,omitempty"`
+}
+
+// NewEvent returns the API representation of a record.
+func NewEvent(rec *store.Record) Event {
+	envelope := rec.Envelope()
+	event := Event{
+		Sequence:  rec.StreamSeq,
+		Timestamp: rec.Timestamp,
+		Subject:   rec.Subject,
+		ID:        envelope.ID,
+		Type:      envelope.Type,
+		Source:    envelope.Source,
+		Header:    rec.Header,
+		Data:      envelope.Data,
+	}
+	if envelope.Data == nil {
+		event.RawData = rec.Data
+	}
+	return event
+}
+
+// RedactRecord returns a copy of the record with its headers and body
+// redacted by the redactor, so that API responses and exports do not
+// reveal what the logs redact. The archived record is left untouched; a
+// nil redactor returns the record itself.
+func RedactRecord(redactor *redact.Engine, rec *store.Record) *store.Record {
+	if redactor == nil {
+		return rec
+	}
+	redacted := *rec
+	redacted.Header, redacted.Data = redactor.RedactMessage(rec.Header, rec.Data)
+	redacted.Event = nil
+	if event, err := cloudevent.Parse(redacted.Header, redacted.Data); err == nil {
+		redacted.Event = &event.Attributes
+	}
+	return &redacted
+}
+
+// SearchHandler serves archived events matching the query parameters:
+// from and to (RFC 3339), subject (NATS wildcards allowed), type, source,
+// id and data.<path> filters, order, limit, cursor and format. Events are
+// redacted by the redactor, which may be nil.
+func SearchHandler(searcher store.Searcher, redactor *redact.Engine, logger *logrus.Logger) http.HandlerFunc {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		format := params.Get("format")
+		switch format {
+		case "":
+			format = FormatJSON
+		case FormatJSON, FormatNDJSON, FormatCSV:
+		default:
+			writeError(w, r, http.StatusBadRequest,
+				fmt.Errorf("unknown format %q, supported %s, %s or %s", format, FormatJSON, FormatNDJSON, FormatCSV))
+			return
+		}
+
+		query, err := parseQuery(params)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err = query.Validate(); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		page, err := searcher.Search(query)
+		if err != nil {
+			logger.WithError(err).Error("Failed to search the archive")
+			writeError(w, r, http.StatusInternalServerError, errors.New("failed to search the archive"))
+			return
+		}
+
+		events := make([]Event, 0, len(page.Records))
+		for _, rec := range page.Records {
+			redacted := RedactRecord(redactor, rec)
+			// Filters on redacted values must not reveal them.
+			if redacted != rec && !query.Filter.Matches(redacted) {
+				continue
+			}
+			events = append(events, NewEvent(redacted))
+		}
+		next := ""
+		if page.Next != 0 {
+			next = encodeCursor(page.Next)
+			w.Header().Set(HeaderNextCursor, next)
+		}
+
+		switch format {
+		case FormatNDJSON:
+			err = writeNDJSON(w, events)
+		case FormatCSV:
+			err = writeCSV(w, events)
+		default:
+			render.JSON(w, r, map[string]any{"events": events, "next_cursor": next})
+		}
+		if err != nil {
+			logger.WithError(err).Warn("Failed to write search response")
+		}
+	}
+}
+
+// parseFilter builds a record filter from the subject, type, source, id and
+// data.<path> request parameters.
+func parseFilter(params url.Values) (store.Filter, error) {
+	filter := store.Filter{
+		Subject: params.Get("subject"),
+		Type:    params.Get("type"),
+		Source:  params.Get("source"),
+		ID:      params.Get("id"),
+	}
+
+	for name, values := range params {
+		path, ok := strings.CutPrefix(name, dataParamPrefix)
+		if !ok {
+			continue
+		}
+		if path == "" || len(values) != 1 {
+			return filter, fmt.Errorf("invalid data filter %q", name)
+		}
+		if filter.Data == nil {
+			filter.Data = make(map[string]string)
+		}
+		filter.Data[path] = values[0]
+	}
+
+	return filter, filter.Validate()
+}
+
+// parseQuery builds a store query from the request parameters.
+func parseQuery(params url.Values) (store.Query, error) {
+	query := store.Query{
+		Order: params.Get("order"),
+		Limit: constants.DefaultQueryLimit,
+	}
+
+	var err error
+	if query.Filter, err = parseFilter(params); err != nil {
+		return query, err
+	}
+	if query.From, err = parseTime(params, "from"); err != nil {
+		return query, err
+	}
+	if query.To, err = parseTime(params, "to"); err != nil {
+		return query, err
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, parseErr := strconv.Atoi(value)
+		if parseErr != nil || limit <= 0 {
+			return query, fmt.Errorf("invalid limit %q", value)
+		}
+		query.Limit = min(limit, constants.MaxQueryLimit)
+	}
+
+	if value := params.Get("cursor"); value != "" {
+		if query.After, err = decodeCursor(value); err != nil {
+			return query, err
+		}
+	}
+
+	return query, nil
+}
+
+func parseTime(params url.Values, name string) (time.Time, error) {
+	value := params.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q, expected RFC 3339", name, value)
+	}
+	return t, nil
+}
+
+// encodeCursor returns the opaque cursor continuing after seq.
+func encodeCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		var seq uint64
+		if seq, err = strconv.ParseUint(string(raw), 10, 64); err == nil && seq > 0 {
+			return seq, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
+
+func writeNDJSON(w http.ResponseWriter, events []Event) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeCSV writes a row per event. The data column holds the JSON data, or
+// the base64 encoded body of events without JSON data.
+func writeCSV(w http.ResponseWriter, events []Event) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"stream_seq", "timestamp", "subject", "id", "type", "source", "data"})
+	for _, event := range events {
+		data := string(event.Data)
+		if event.Data == nil {
+			data = base64.StdEncoding.EncodeToString(event.RawData)
+		}
+		_ = writer.Write([]string{
+			strconv.FormatUint(event.Sequence, 10),
+			event.Timestamp.Format(time.RFC3339Nano),
+			event.Subject,
+			event.ID,
+			event.Type,
+			event.Source,
+			data,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
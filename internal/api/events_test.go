@@ -0,0 +1,224 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"events-audit/internal/api"
+	"events-audit/internal/redact"
+	"events-audit/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "secret-token"
+
+var start = time.Date(2025, 1, 11, 10, 0, 0, 0, time.UTC) //nolint:gochecknoglobals // shared test fixture
+
+func newAPI(t *testing.T, redactor *redact.Engine) http.Handler {
+	t.Helper()
+	logger, _ := test.NewNullLogger()
+
+	st, err := store.Open(store.Config{Dir: t.TempDir(), IndexFields: []string{"user.id"}}, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		eventType := "user.created"
+		if seq%2 == 0 {
+			eventType = "user.deleted"
+		}
+		require.NoError(t, st.Append(&store.Record{
+			Stream:    "EVENTS",
+			StreamSeq: seq,
+			Timestamp: start.Add(time.Duration(seq) * time.Minute),
+			Subject:   "audit.users",
+			Data:      []byte(fmt.Sprintf(`{"id":"event-%d","type":%q,"source":"svc","data":{"user":{"id":%d}}}`, seq, eventType, seq%2)),
+		}))
+	}
+	require.NoError(t, st.Append(&store.Record{StreamSeq: 6, Timestamp: start.Add(6 * time.Minute), Subject: "audit.raw", Data: []byte("plain text")}))
+
+	r := chi.NewRouter()
+	r.Use(api.Authenticate([][]byte{[]byte("other"), []byte(token)}))
+	r.Get("/events", api.SearchHandler(st, redactor, logger))
+	return r
+}
+
+func get(t *testing.T, handler http.Handler, params url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/events?"+params.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+type searchResponse struct {
+	Events     []api.Event `json:"events"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+func sequences(events []api.Event) []uint64 {
+	seqs := make([]uint64, 0, len(events))
+	for _, event := range events {
+		seqs = append(seqs, event.Sequence)
+	}
+	return seqs
+}
+
+func TestSearchHandler_RequiresToken(t *testing.T) {
+	handler := newAPI(t, nil)
+
+	for _, header := range []string{"", "Bearer wrong", "Basic " + token} {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, header)
+		assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestSearchHandler_JSONPages(t *testing.T) {
+	handler := newAPI(t, nil)
+
+	params := url.Values{"type": {"user.created"}, "order": {"desc"}, "limit": {"2"}}
+	var pages [][]uint64
+	for {
+		rec := get(t, handler, params)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp searchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		pages = append(pages, sequences(resp.Events))
+		assert.Equal(t, resp.NextCursor, rec.Header().Get(api.HeaderNextCursor))
+		if resp.NextCursor == "" {
+			break
+		}
+		params.Set("cursor", resp.NextCursor)
+	}
+	assert.Equal(t, [][]uint64{{5, 3}, {1}}, pages)
+}
+
+func TestSearchHandler_Filters(t *testing.T) {
+	handler := newAPI(t, nil)
+
+	rec := get(t, handler, url.Values{
+		"subject":      {"audit.*"},
+		"data.user.id": {"0"},
+		"from":         {start.Add(3 * time.Minute).Format(time.RFC3339)},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp searchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, []uint64{4}, sequences(resp.Events))
+	event := resp.Events[0]
+	assert.Equal(t, "event-4", event.ID)
+	assert.Equal(t, "user.deleted", event.Type)
+	assert.Equal(t, "svc", event.Source)
+	assert.JSONEq(t, `{"user":{"id":0}}`, string(event.Data))
+}
+
+func TestSearchHandler_NDJSONAndCSV(t *testing.T) {
+	handler := newAPI(t, nil)
+
+	rec := get(t, handler, url.Values{"format": {"ndjson"}, "from": {start.Add(5 * time.Minute).Format(time.RFC3339)}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	var raw api.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &raw))
+	assert.Equal(t, "plain text", string(raw.RawData))
+	assert.Nil(t, raw.Data)
+
+	rec = get(t, handler, url.Values{"format": {"csv"}, "id": {"event-2"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"stream_seq", "timestamp", "subject", "id", "type", "source", "data"}, rows[0])
+	assert.Equal(t, []string{"2", "2025-01-11T10:02:00Z", "audit.users", "event-2", "user.deleted", "svc", `{"user":{"id":0}}`}, rows[1])
+}
+
+func TestSearchHandler_Redacts(t *testing.T) {
+	redactor, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.user.id"}, Mode: redact.ModeMask},
+		{Pattern: "plain", Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+	handler := newAPI(t, redactor)
+
+	rec := get(t, handler, url.Values{"id": {"event-1"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp searchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Events, 1)
+	assert.JSONEq(t, `{"user":{"id":"[REDACTED]"}}`, string(resp.Events[0].Data))
+
+	rec = get(t, handler, url.Values{"format": {"ndjson"}, "subject": {"audit.raw"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var raw api.Event
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+	assert.Equal(t, "[REDACTED] text", string(raw.RawData))
+
+	rec = get(t, handler, url.Values{"format": {"csv"}, "id": {"event-2"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, `{"user":{"id":"[REDACTED]"}}`, rows[1][6])
+
+	// Filtering on a redacted value does not reveal it.
+	rec = get(t, handler, url.Values{"data.user.id": {"1"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Events)
+}
+
+func TestSearchHandler_InvalidParameters(t *testing.T) {
+	handler := newAPI(t, nil)
+
+	tests := map[string]url.Values{
+		"unknown format":  {"format": {"xml"}},
+		"invalid from":    {"from": {"yesterday"}},
+		"invalid limit":   {"limit": {"0"}},
+		"invalid cursor":  {"cursor": {"!"}},
+		"unknown order":   {"order": {"up"}},
+		"invalid subject": {"subject": {"audit.>.users"}},
+		"must be before":  {"from": {start.Format(time.RFC3339)}, "to": {start.Format(time.RFC3339)}},
+	}
+	for message, params := range tests {
+		rec := get(t, handler, params)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, message)
+		assert.Contains(t, rec.Body.String(), message)
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(file, []byte("# readers\none\n\n  two  \n"), 0o600))
+
+	tokens, err := api.LoadTokens(file)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("one"), []byte("two")}, tokens)
+
+	require.NoError(t, os.WriteFile(file, []byte("# none\n"), 0o600))
+	_, err = api.LoadTokens(file)
+	require.ErrorContains(t, err, "has no tokens")
+}
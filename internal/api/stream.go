@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"events-audit/internal/metrics"
+	"events-audit/internal/redact"
+	"events-audit/internal/source"
+	"events-audit/internal/store"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/sirupsen/logrus"
+)
+
+// Types of live stream messages.
+const (
+	MessageEvent   = "event"
+	MessageDropped = "dropped"
+)
+
+// streamKeepalive is the idle time after which a keepalive is sent, so that
+// proxies do not close quiet streams.
+const streamKeepalive = 15 * time.Second
+
+// StreamMessage is a message of the live event stream: a handled event, or
+// the number of events dropped because the client did not keep up.
+type StreamMessage struct {
+	Type    string `json:"type"`
+	Event   *Event `json:"event,omitempty"`
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// Hub fans handled events out to the live stream subscriptions. Publish
+// never blocks: an event is dropped for a subscription whose buffer is full
+// and the drop is reported to it, so slow clients can not slow down the
+// handling of messages.
+type Hub struct {
+	buffer   int
+	logger   *logrus.Logger
+	metrics  *metrics.Metrics
+	redactor *redact.Engine
+
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// NewHub creates a hub buffering up to buffer events per subscription.
+func NewHub(buffer int, logger *logrus.Logger) *Hub {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Hub{
+		buffer:        max(buffer, 1),
+		logger:        logger,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// SetMetrics sets the collectors the hub reports to.
+func (h *Hub) SetMetrics(m *metrics.Metrics) {
+	h.metrics = m
+}
+
+// SetRedactor sets the engine redacting events before they are streamed.
+func (h *Hub) SetRedactor(engine *redact.Engine) {
+	h.redactor = engine
+}
+
+// Publish offers a handled message to every subscription it matches,
+// redacted the same way as the logged event.
+func (h *Hub) Publish(msg *source.Msg) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.subscriptions) == 0 {
+		return
+	}
+
+	rec, err := store.RecordFromMsg(msg)
+	if err != nil {
+		// Messages of other sources carry no JetStream metadata.
+		rec = &store.Record{
+			Timestamp: time.Now().UTC(),
+			Subject:   msg.Subject,
+			Header:    msg.Header,
+			Data:      msg.Data,
+		}
+	}
+	// Filters see the redacted event, so they can not reveal hidden values.
+	rec = RedactRecord(h.redactor, rec)
+
+	var event *Event
+	for sub := range h.subscriptions {
+		if !sub.filter.Matches(rec) {
+			continue
+		}
+		if event == nil {
+			converted := NewEvent(rec)
+			event = &converted
+		}
+		select {
+		case sub.events <- *event:
+		default:
+			sub.dropped.Add(1)
+			h.metrics.StreamDropped()
+		}
+	}
+}
+
+// Subscribe starts receiving the events matching filter.
+func (h *Hub) Subscribe(filter store.Filter) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan Event, h.buffer),
+	}
+
+	h.mu.Lock()
+	h.subscriptions[sub] = struct{}{}
+	h.mu.Unlock()
+	h.metrics.StreamClientConnected()
+
+	return sub
+}
+
+// Subscription receives the live events matching its filter.
+type Subscription struct {
+	hub     *Hub
+	filter  store.Filter
+	events  chan Event
+	dropped atomic.Uint64
+}
+
+// Next waits for the next message. Buffered events come first; events
+// dropped after them are reported before the events that follow.
+func (s *Subscription) Next(ctx context.Context) (StreamMessage, error) {
+	select {
+	case event := <-s.events:
+		return StreamMessage{Type: MessageEvent, Event: &event}, nil
+	default:
+	}
+
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		return StreamMessage{Type: MessageDropped, Dropped: dropped}, nil
+	}
+
+	select {
+	case event := <-s.events:
+		return StreamMessage{Type: MessageEvent, Event: &event}, nil
+	case <-ctx.Done():
+		return StreamMessage{}, ctx.Err() //nolint:wrapcheck // the caller checks for cancellation
+	}
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	_, subscribed := s.hub.subscriptions[s]
+	delete(s.hub.subscriptions, s)
+	s.hub.mu.Unlock()
+
+	if subscribed {
+		s.hub.metrics.StreamClientDisconnected()
+	}
+}
+
+// StreamHandler streams the live events matching the subject, type,
+// source, id and data.<path> parameters over WebSocket to upgrade requests
+// and as Server-Sent Events otherwise.
+func (h *Hub) StreamHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		transport := "sse"
+		serve := h.serveSSE
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			transport = "websocket"
+			serve = h.serveWebSocket
+		}
+
+		entry := h.logger.WithFields(logrus.Fields{
+			"transport": transport,
+			"remote":    r.RemoteAddr,
+			"subject":   filter.Subject,
+		})
+		entry.Info("Live stream client connected")
+		err = serve(w, r, filter)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			entry.WithError(err).Warn("Live stream client failed")
+			return
+		}
+		entry.Info("Live stream client disconnected")
+	}
+}
+
+// serveSSE writes each message as an event named after its type.
+func (h *Hub) serveSSE(w http.ResponseWriter, r *http.Request, filter store.Filter) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return errors.New("response writer does not support flushing")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return h.stream(r.Context(), filter, func(msg *StreamMessage) error {
+		if msg == nil {
+			_, err := fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+			return err //nolint:wrapcheck // reported by the caller
+		}
+
+		var payload any = msg.Event
+		if msg.Type == MessageDropped {
+			payload = map[string]uint64{"dropped": msg.Dropped}
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode stream message: %w", err)
+		}
+		if msg.Type == MessageEvent && msg.Event.Sequence > 0 {
+			_, err = fmt.Fprintf(w, "id: %d\n", msg.Event.Sequence)
+		}
+		if err == nil {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+		}
+		flusher.Flush()
+		return err //nolint:wrapcheck // reported by the caller
+	})
+}
+
+// serveWebSocket writes each message as a JSON text message.
+func (h *Hub) serveWebSocket(w http.ResponseWriter, r *http.Request, filter store.Filter) error {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return fmt.Errorf("failed to accept websocket: %w", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	// Messages from the client are not expected; reading handles close frames.
+	ctx := conn.CloseRead(r.Context())
+	err = h.stream(ctx, filter, func(msg *StreamMessage) error {
+		writeCtx, cancel := context.WithTimeout(ctx, streamKeepalive)
+		defer cancel()
+		if msg == nil {
+			return conn.Ping(writeCtx) //nolint:wrapcheck // reported by the caller
+		}
+		return wsjson.Write(writeCtx, conn, msg) //nolint:wrapcheck // reported by the caller
+	})
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	_ = conn.Close(websocket.StatusNormalClosure, "")
+	return err
+}
+
+// stream sends the messages of a new subscription until ctx ends or a send
+// fails. A nil message asks for a keepalive.
+func (h *Hub) stream(ctx context.Context, filter store.Filter, send func(msg *StreamMessage) error) error {
+	sub := h.Subscribe(filter)
+	defer sub.Close()
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, streamKeepalive)
+		msg, err := sub.Next(waitCtx)
+		cancel()
+
+		switch {
+		case err == nil:
+			err = send(&msg)
+		case ctx.Err() != nil:
+			return ctx.Err() //nolint:wrapcheck // cancellation ends the stream
+		default:
+			err = send(nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"events-audit/internal/api"
+	"events-audit/internal/redact"
+	"events-audit/internal/source"
+	"events-audit/internal/store"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func liveMsg(subject, eventType string) *source.Msg {
+	return &source.Msg{
+		Subject: subject,
+		Data:    []byte(`{"id":"1","type":"` + eventType + `","source":"svc","data":{"user":{"id":7}}}`),
+	}
+}
+
+func startStream(t *testing.T, hub *api.Hub) *httptest.Server {
+	t.Helper()
+
+	r := chi.NewRouter()
+	r.Use(api.Authenticate([][]byte{[]byte(token)}))
+	r.Get("/events/stream", hub.StreamHandler())
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHub_ReportsDroppedEvents(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	hub := api.NewHub(2, logger)
+	sub := hub.Subscribe(store.Filter{Type: "user.created"})
+	defer sub.Close()
+
+	for range 5 {
+		hub.Publish(liveMsg("audit.users", "user.created"))
+	}
+	hub.Publish(liveMsg("audit.users", "user.deleted"))
+
+	var types []string
+	for range 3 {
+		msg, err := sub.Next(context.Background())
+		require.NoError(t, err)
+		types = append(types, msg.Type)
+		if msg.Type == api.MessageDropped {
+			assert.Equal(t, uint64(3), msg.Dropped)
+		}
+	}
+	assert.Equal(t, []string{api.MessageEvent, api.MessageEvent, api.MessageDropped}, types)
+
+	hub.Publish(liveMsg("audit.users", "user.created"))
+	msg, err := sub.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "user.created", msg.Event.Type)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = sub.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestHub_RedactsEvents(t *testing.T) {
+	redactor, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.user.id"}, Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+	logger, _ := test.NewNullLogger()
+	hub := api.NewHub(16, logger)
+	hub.SetRedactor(redactor)
+
+	sub := hub.Subscribe(store.Filter{Type: "user.created"})
+	defer sub.Close()
+	// Filtering on a redacted value does not reveal it.
+	probe := hub.Subscribe(store.Filter{Data: map[string]string{"user.id": "7"}})
+	defer probe.Close()
+
+	msg := liveMsg("audit.users", "user.created")
+	hub.Publish(msg)
+
+	streamed, err := sub.Next(context.Background())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user":{"id":"[REDACTED]"}}`, string(streamed.Event.Data))
+	assert.Contains(t, string(msg.Data), `"id":7`, "the handled message is not modified")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = probe.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStreamHandler_ServerSentEvents(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	hub := api.NewHub(16, logger)
+	srv := startStream(t, hub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		srv.URL+"/events/stream?access_token="+token+"&subject=audit.>&data.user.id=7", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan [2]string, 16)
+	go func() {
+		var name string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				name = value
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				events <- [2]string{name, value}
+			}
+		}
+	}()
+
+	// The subscription starts after the response headers are sent, so
+	// probes are published until one arrives.
+	require.Eventually(t, func() bool {
+		hub.Publish(liveMsg("audit.probe", "probe"))
+		select {
+		case <-events:
+			return true
+		default:
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+	hub.Publish(liveMsg("billing.invoices", "user.created"))
+	hub.Publish(liveMsg("audit.users", "user.created"))
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			require.Equal(t, api.MessageEvent, event[0])
+			var received api.Event
+			require.NoError(t, json.Unmarshal([]byte(event[1]), &received))
+			if received.Subject == "audit.probe" {
+				continue
+			}
+			assert.Equal(t, "audit.users", received.Subject)
+			assert.Equal(t, "user.created", received.Type)
+			return
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestStreamHandler_WebSocket(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	hub := api.NewHub(16, logger)
+	srv := startStream(t, hub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, srv.URL+"/events/stream?type=user.deleted", &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer " + token}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = conn.CloseNow() }()
+
+	received := make(chan api.StreamMessage, 16)
+	go func() {
+		for {
+			var msg api.StreamMessage
+			if readErr := wsjson.Read(ctx, conn, &msg); readErr != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	require.Eventually(t, func() bool {
+		hub.Publish(liveMsg("audit.users", "user.deleted"))
+		select {
+		case msg := <-received:
+			return msg.Type == api.MessageEvent && msg.Event.Type == "user.deleted"
+		default:
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, conn.Close(websocket.StatusNormalClosure, ""))
+}
+
+func TestStreamHandler_RejectsInvalidFilter(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	srv := startStream(t, api.NewHub(1, logger))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events/stream?subject=audit.>.users", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
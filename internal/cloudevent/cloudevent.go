@@ -0,0 +1,288 @@
+package cloudevent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SpecVersion is the supported CloudEvents specification version.
+const SpecVersion = "1.0"
+
+// Content modes a CloudEvent is carried in.
+const (
+	ModeStructured = "structured"
+	ModeBinary     = "binary"
+)
+
+const (
+	// ContentType is the media type of structured mode JSON events.
+	ContentType = "application/cloudevents+json"
+	// HeaderPrefix marks binary mode attribute headers.
+	HeaderPrefix = "ce-"
+
+	contentTypeHeader = "content-type"
+)
+
+// ErrNotCloudEvent is returned by Parse for messages that are not
+// CloudEvents.
+var ErrNotCloudEvent = errors.New("message is not a CloudEvent")
+
+// Attributes are the context attributes of a CloudEvent.
+type Attributes struct {
+	Mode            string         `json:"mode"`
+	ID              string         `json:"id"`
+	Source          string         `json:"source"`
+	SpecVersion     string         `json:"specversion"`
+	Type            string         `json:"type"`
+	Subject         string         `json:"subject,omitempty"`
+	DataContentType string         `json:"datacontenttype,omitempty"`
+	DataSchema      string         `json:"dataschema,omitempty"`
+	Time            *time.Time     `json:"time,omitempty"`
+	Extensions      map[string]any `json:"extensions,omitempty"`
+}
+
+// Event is a CloudEvent decoded from a message.
+type Event struct {
+	Attributes
+
+	// Data is the message body in binary mode and the data member, or the
+	// decoded data_base64 member, in structured mode.
+	Data []byte
+}
+
+// IsJSON reports whether the event data is JSON. Data without a content
+// type is JSON by default.
+func (e *Event) IsJSON() bool {
+	if e.DataContentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(e.DataContentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Parse decodes a CloudEvent from message headers and body. Events with
+// ce- prefixed headers are in binary mode; a JSON body with a specversion
+// member, or the application/cloudevents+json content type, is in
+// structured mode. Anything else yields ErrNotCloudEvent.
+func Parse(header map[string][]string, body []byte) (*Event, error) {
+	binary, contentType := binaryAttributes(header)
+	if _, ok := binary["specversion"]; ok {
+		return parseBinary(binary, contentType, body)
+	}
+
+	structured := strings.HasPrefix(contentType, ContentType)
+	if !structured && !hasSpecVersion(body) {
+		return nil, ErrNotCloudEvent
+	}
+	return parseStructured(body)
+}
+
+// binaryAttributes collects ce- headers by lower-cased attribute name, as
+// header names are case-insensitive.
+func binaryAttributes(header map[string][]string) (map[string]string, string) {
+	attrs := make(map[string]string)
+	var contentType string
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		name := strings.ToLower(key)
+		switch {
+		case strings.HasPrefix(name, HeaderPrefix):
+			attrs[strings.TrimPrefix(name, HeaderPrefix)] = values[0]
+		case name == contentTypeHeader:
+			contentType = values[0]
+		}
+	}
+	return attrs, contentType
+}
+
+func hasSpecVersion(body []byte) bool {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' {
+		return false
+	}
+	var probe struct {
+		SpecVersion *json.RawMessage `json:"specversion"`
+	}
+	return json.Unmarshal(body, &probe) == nil && probe.SpecVersion != nil
+}
+
+func parseBinary(attrs map[string]string, contentType string, body []byte) (*Event, error) {
+	event := &Event{
+		Attributes: Attributes{Mode: ModeBinary, DataContentType: contentType},
+		Data:       body,
+	}
+
+	for name, value := range attrs {
+		if err := event.setAttribute(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func parseStructured(body []byte) (*Event, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, fmt.Errorf("invalid structured CloudEvent: %w", err)
+	}
+
+	event := &Event{Attributes: Attributes{Mode: ModeStructured}}
+
+	data, hasData := members["data"]
+	encoded, hasBase64 := members["data_base64"]
+	if hasData && hasBase64 {
+		return nil, errors.New("CloudEvent must not have both data and data_base64")
+	}
+	delete(members, "data")
+	delete(members, "data_base64")
+
+	for name, raw := range members {
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent attribute %q: %w", name, err)
+		}
+		if value == nil {
+			continue
+		}
+		if err := event.setAttribute(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case hasBase64:
+		var text string
+		if err := json.Unmarshal(encoded, &text); err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent data_base64: %w", err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent data_base64: %w", err)
+		}
+		event.Data = decoded
+	case hasData:
+		event.Data = data
+		// Non-JSON data is carried as a JSON string.
+		var text string
+		if !event.IsJSON() && json.Unmarshal(data, &text) == nil {
+			event.Data = []byte(text)
+		}
+	}
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// setAttribute assigns a context attribute; unknown names are extensions.
+func (e *Event) setAttribute(name string, value any) error {
+	text, isString := value.(string)
+	switch name {
+	case "id", "source", "specversion", "type", "subject", "datacontenttype", "dataschema", "time":
+		if !isString {
+			return fmt.Errorf("CloudEvent attribute %q must be a string", name)
+		}
+	}
+
+	switch name {
+	case "id":
+		e.ID = text
+	case "source":
+		e.Source = text
+	case "specversion":
+		e.SpecVersion = text
+	case "type":
+		e.Type = text
+	case "subject":
+		e.Subject = text
+	case "datacontenttype":
+		e.DataContentType = text
+	case "dataschema":
+		e.DataSchema = text
+	case "time":
+		ts, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return fmt.Errorf("invalid CloudEvent time %q: %w", text, err)
+		}
+		e.Time = &ts
+	default:
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]any)
+		}
+		e.Extensions[name] = value
+	}
+	return nil
+}
+
+// Validate checks the required attributes and the format of the optional
+// ones.
+func (e *Event) Validate() error {
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf("unsupported CloudEvent specversion %q, supported %s", e.SpecVersion, SpecVersion)
+	}
+
+	var missing []string
+	for _, attr := range []struct{ name, value string }{
+		{"id", e.ID},
+		{"source", e.Source},
+		{"type", e.Type},
+	} {
+		if attr.value == "" {
+			missing = append(missing, attr.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("CloudEvent is missing required attributes: %s", strings.Join(missing, ", "))
+	}
+
+	if _, err := url.Parse(e.Source); err != nil {
+		return fmt.Errorf("invalid CloudEvent source %q: %w", e.Source, err)
+	}
+	if e.DataSchema != "" {
+		schema, err := url.Parse(e.DataSchema)
+		if err != nil || !schema.IsAbs() {
+			return fmt.Errorf("CloudEvent dataschema %q must be an absolute URI", e.DataSchema)
+		}
+	}
+	if e.DataContentType != "" {
+		if _, _, err := mime.ParseMediaType(e.DataContentType); err != nil {
+			return fmt.Errorf("invalid CloudEvent datacontenttype %q: %w", e.DataContentType, err)
+		}
+	}
+
+	for name := range e.Extensions {
+		if !validExtensionName(name) {
+			return fmt.Errorf("invalid CloudEvent extension name %q, must be lower-case letters or digits", name)
+		}
+	}
+	return nil
+}
+
+func validExtensionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package cloudevent_test
+
+import (
+	"testing"
+	"time"
+
+	"events-audit/internal/cloudevent"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Structured(t *testing.T) {
+	body := []byte(`{
+		"specversion": "1.0",
+		"id": "evt-1",
+		"source": "/users",
+		"type": "user.created",
+		"subject": "user/42",
+		"time": "2025-01-11T10:30:00Z",
+		"datacontenttype": "application/json",
+		"dataschema": "https://schemas.example.com/user.created.json",
+		"tenant": "acme",
+		"priority": 3,
+		"data": {"user_id": "42"}
+	}`)
+
+	event, err := cloudevent.Parse(nil, body)
+	require.NoError(t, err)
+	assert.Equal(t, cloudevent.ModeStructured, event.Mode)
+	assert.Equal(t, "evt-1", event.ID)
+	assert.Equal(t, "/users", event.Source)
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.Equal(t, "user.created", event.Type)
+	assert.Equal(t, "user/42", event.Subject)
+	assert.Equal(t, "application/json", event.DataContentType)
+	assert.Equal(t, "https://schemas.example.com/user.created.json", event.DataSchema)
+	require.NotNil(t, event.Time)
+	assert.True(t, time.Date(2025, 1, 11, 10, 30, 0, 0, time.UTC).Equal(*event.Time))
+	assert.Equal(t, map[string]any{"tenant": "acme", "priority": float64(3)}, event.Extensions)
+	assert.JSONEq(t, `{"user_id": "42"}`, string(event.Data))
+	assert.True(t, event.IsJSON())
+}
+
+func TestParse_StructuredData(t *testing.T) {
+	event, err := cloudevent.Parse(nil, []byte(`{"specversion":"1.0","id":"1","source":"s","type":"t","data_base64":"aGVsbG8="}`))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(event.Data))
+
+	event, err = cloudevent.Parse(nil, []byte(`{"specversion":"1.0","id":"1","source":"s","type":"t","datacontenttype":"text/plain","data":"hello"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(event.Data))
+	assert.False(t, event.IsJSON())
+
+	_, err = cloudevent.Parse(nil, []byte(`{"specversion":"1.0","id":"1","source":"s","type":"t","data":1,"data_base64":"aGVsbG8="}`))
+	require.ErrorContains(t, err, "both data and data_base64")
+}
+
+func TestParse_StructuredContentType(t *testing.T) {
+	header := map[string][]string{"Content-Type": {"application/cloudevents+json; charset=utf-8"}}
+
+	_, err := cloudevent.Parse(header, []byte(`{"id":"1","source":"s","type":"t"}`))
+	require.ErrorContains(t, err, "specversion")
+}
+
+func TestParse_Binary(t *testing.T) {
+	header := map[string][]string{
+		"Ce-Specversion": {"1.0"},
+		"Ce-Id":          {"evt-2"},
+		"Ce-Source":      {"urn:service:billing"},
+		"Ce-Type":        {"invoice.paid"},
+		"ce-subject":     {"invoice/7"},
+		"Ce-Dataschema":  {"https://schemas.example.com/invoice.paid.json"},
+		"Ce-Traceparent": {"00-abc-def-01"},
+		"Content-Type":   {"application/json"},
+		"Nats-Msg-Id":    {"dedup-1"},
+	}
+	body := []byte(`{"invoice":7}`)
+
+	event, err := cloudevent.Parse(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, cloudevent.ModeBinary, event.Mode)
+	assert.Equal(t, "evt-2", event.ID)
+	assert.Equal(t, "urn:service:billing", event.Source)
+	assert.Equal(t, "invoice.paid", event.Type)
+	assert.Equal(t, "invoice/7", event.Subject)
+	assert.Equal(t, "application/json", event.DataContentType)
+	assert.Equal(t, "https://schemas.example.com/invoice.paid.json", event.DataSchema)
+	assert.Equal(t, map[string]any{"traceparent": "00-abc-def-01"}, event.Extensions)
+	assert.Equal(t, body, event.Data)
+}
+
+func TestParse_NotCloudEvent(t *testing.T) {
+	for _, body := range []string{
+		``,
+		`not json`,
+		`{"id":"1","type":"user.created","source":"svc","timestamp":"2025-01-11T10:30:00Z"}`,
+		`[{"specversion":"1.0"}]`,
+	} {
+		_, err := cloudevent.Parse(map[string][]string{"Ce-Type": {"t"}}, []byte(body))
+		require.ErrorIs(t, err, cloudevent.ErrNotCloudEvent, body)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		`{"specversion":"0.3","id":"1","source":"s","type":"t"}`:                               "unsupported CloudEvent specversion",
+		`{"specversion":"1.0","source":"s"}`:                                                   "missing required attributes: id, type",
+		`{"specversion":"1.0","id":1,"source":"s","type":"t"}`:                                 `"id" must be a string`,
+		`{"specversion":"1.0","id":"1","source":"s","type":"t","time":"yesterday"}`:            "invalid CloudEvent time",
+		`{"specversion":"1.0","id":"1","source":"s","type":"t","dataschema":"schemas/a.json"}`: "absolute URI",
+		`{"specversion":"1.0","id":"1","source":"s","type":"t","datacontenttype":"a/b; =x"}`:   "invalid CloudEvent datacontenttype",
+		`{"specversion":"1.0","id":"1","source":"s","type":"t","Tenant":"acme"}`:               "invalid CloudEvent extension name",
+		`{"specversion":"1.0","id":"1","source":"s","type":"t","data_base64":"%%%"}`:           "invalid CloudEvent data_base64",
+	}
+
+	for body, message := range tests {
+		_, err := cloudevent.Parse(nil, []byte(body))
+		require.ErrorContains(t, err, message, body)
+	}
+
+	_, err := cloudevent.Parse(map[string][]string{"Ce-Specversion": {"1.0"}, "Ce-Id": {"1"}}, nil)
+	require.ErrorContains(t, err, "missing required attributes: source, type")
+}
@@ -12,14 +12,23 @@ const (
 	DefaultPullTimeout        = DefaultPullTimeoutSeconds * time.Second
 	DefaultStreamMaxAgeHours  = 24
 	DefaultStreamMaxAge       = DefaultStreamMaxAgeHours * time.Hour
+	DefaultLagPollSeconds     = 15
+	DefaultLagPollInterval    = DefaultLagPollSeconds * time.Second
+	DefaultFetchStaleSeconds  = 30
+	DefaultFetchStaleAfter    = DefaultFetchStaleSeconds * time.Second
+	DefaultDuplicateMinutes   = 2
+	DefaultDuplicateWindow    = DefaultDuplicateMinutes * time.Minute
 )
 
 // Default delivery and message limits.
 const (
-	DefaultMaxDeliver      = 3
-	DefaultPullMaxMessages = 10
-	DefaultStreamReplicas  = 1
-	DefaultStreamMaxMsgs   = 1000000 // 1M messages
+	DefaultMaxDeliver       = 3
+	DefaultPullMaxMessages  = 10
+	DefaultStreamReplicas   = 1
+	DefaultStreamMaxMsgs    = 1000000 // 1M messages
+	DefaultStreamMaxMsgSize = -1      // unlimited
+	DefaultMaxAckPending    = 1000
+	DefaultWorkers          = 1
 )
 
 // Default storage limits.
@@ -27,3 +36,68 @@ const (
 	DefaultStreamMaxBytesGB = 1024 * 1024 * 1024 // 1GB
 	DefaultStreamMaxBytes   = DefaultStreamMaxBytesGB
 )
+
+// Default local archive limits.
+const (
+	DefaultStoreSegmentMaxBytes    = 64 * 1024 * 1024 // 64MB
+	DefaultStoreSegmentMaxAgeHours = 1
+	DefaultStoreSegmentMaxAge      = DefaultStoreSegmentMaxAgeHours * time.Hour
+	DefaultStoreCheckpointInterval = 1000
+)
+
+// Default dead-letter stream settings.
+const (
+	DefaultDeadLetterStream       = "EVENTS_DLQ"
+	DefaultDeadLetterSubject      = "audit.dlq"
+	DefaultDeadLetterMaxAgeHours  = 7 * 24
+	DefaultDeadLetterMaxAge       = DefaultDeadLetterMaxAgeHours * time.Hour
+	DefaultDeadLetterRetrySeconds = 5
+	DefaultDeadLetterRetryDelay   = DefaultDeadLetterRetrySeconds * time.Second
+	DefaultDeadLetterRetries      = 3
+)
+
+// Default schema quarantine stream settings.
+const (
+	DefaultQuarantineStream      = "EVENTS_QUARANTINE"
+	DefaultQuarantineSubject     = "audit.quarantine"
+	DefaultQuarantineMaxAgeHours = 7 * 24
+	DefaultQuarantineMaxAge      = DefaultQuarantineMaxAgeHours * time.Hour
+)
+
+// Default embedded NATS server settings.
+const (
+	DefaultEmbeddedAddr     = "127.0.0.1:4222"
+	DefaultEmbeddedStoreDir = "nats-data"
+)
+
+// Default NSQ consumer settings.
+const (
+	DefaultNSQChannel             = "events-audit"
+	DefaultNSQMaxInFlight         = 100
+	DefaultNSQRequeueDelaySeconds = 1
+	DefaultNSQRequeueDelay        = DefaultNSQRequeueDelaySeconds * time.Second
+	DefaultNSQMaxRequeueMinutes   = 1
+	DefaultNSQMaxRequeueDelay     = DefaultNSQMaxRequeueMinutes * time.Minute
+	// DefaultNSQTouchSeconds is half of the default nsqd message timeout.
+	DefaultNSQTouchSeconds  = 30
+	DefaultNSQTouchInterval = DefaultNSQTouchSeconds * time.Second
+)
+
+// Default output sink settings.
+const (
+	DefaultSinkTimeoutSeconds = 10
+	DefaultSinkTimeout        = DefaultSinkTimeoutSeconds * time.Second
+)
+
+// Default archive query settings.
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+	// MaxQueryScan bounds the search terms entries read by a single query.
+	MaxQueryScan = 100000
+)
+
+// Default live event stream settings.
+const (
+	DefaultStreamBuffer = 256
+)
@@ -0,0 +1,112 @@
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serverName   = "events-audit-embedded"
+	readyTimeout = 10 * time.Second
+)
+
+// Config holds the in-process NATS server settings.
+type Config struct {
+	// Addr is the client listen address; a port of -1 picks a random one.
+	Addr string
+	// StoreDir is the JetStream storage directory.
+	StoreDir string
+}
+
+// Server is an in-process NATS server with JetStream enabled.
+type Server struct {
+	srv    *server.Server
+	logger logrus.FieldLogger
+}
+
+// Start runs an in-process NATS server and waits until it accepts
+// connections.
+func Start(config Config, logger logrus.FieldLogger) (*Server, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if config.StoreDir == "" {
+		return nil, errors.New("embedded NATS server requires a store directory")
+	}
+
+	host, portStr, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded NATS address %q: %w", config.Addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded NATS port %q: %w", portStr, err)
+	}
+
+	opts := &server.Options{
+		ServerName: serverName,
+		Host:       host,
+		Port:       port,
+		JetStream:  true,
+		StoreDir:   config.StoreDir,
+		// Signals are handled by the audit server.
+		NoSigs: true,
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS server: %w", err)
+	}
+
+	logger = logger.WithField("component", "nats-server")
+	srv.SetLoggerV2(serverLogger{logger}, false, false, false)
+
+	srv.Start()
+	if !srv.ReadyForConnections(readyTimeout) {
+		srv.Shutdown()
+		return nil, fmt.Errorf("embedded NATS server not ready after %s", readyTimeout)
+	}
+	if !srv.JetStreamEnabled() {
+		srv.Shutdown()
+		return nil, errors.New("embedded NATS server started without JetStream")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"url":       srv.ClientURL(),
+		"store_dir": config.StoreDir,
+	}).Info("Embedded NATS server started")
+
+	return &Server{srv: srv, logger: logger}, nil
+}
+
+// ClientURL returns the URL clients connect to.
+func (s *Server) ClientURL() string {
+	return s.srv.ClientURL()
+}
+
+// Shutdown stops the server and waits for it to exit.
+func (s *Server) Shutdown() {
+	s.srv.Shutdown()
+	s.srv.WaitForShutdown()
+	s.logger.Info("Embedded NATS server stopped")
+}
+
+// serverLogger routes nats-server logs to logrus.
+type serverLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l serverLogger) Noticef(format string, v ...any) { l.logger.Infof(format, v...) }
+func (l serverLogger) Warnf(format string, v ...any)   { l.logger.Warnf(format, v...) }
+func (l serverLogger) Errorf(format string, v ...any)  { l.logger.Errorf(format, v...) }
+func (l serverLogger) Debugf(format string, v ...any)  { l.logger.Debugf(format, v...) }
+func (l serverLogger) Tracef(format string, v ...any)  { l.logger.Debugf(format, v...) }
+
+// Fatalf logs at error level; the embedded server must not exit the process.
+func (l serverLogger) Fatalf(format string, v ...any) { l.logger.Errorf(format, v...) }
@@ -0,0 +1,47 @@
+package embedded_test
+
+import (
+	"testing"
+
+	"events-audit/internal/embedded"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	dir := t.TempDir()
+
+	srv, err := embedded.Start(embedded.Config{Addr: "127.0.0.1:-1", StoreDir: dir}, logger)
+	require.NoError(t, err)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	_, err = js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"test.>"}})
+	require.NoError(t, err)
+	ack, err := js.Publish("test.one", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), ack.Sequence)
+}
+
+func TestStart_InvalidConfig(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+
+	_, err := embedded.Start(embedded.Config{Addr: "127.0.0.1:-1"}, logger)
+	require.ErrorContains(t, err, "store directory")
+
+	_, err = embedded.Start(embedded.Config{Addr: "localhost", StoreDir: t.TempDir()}, logger)
+	require.ErrorContains(t, err, "invalid embedded NATS address")
+
+	_, err = embedded.Start(embedded.Config{Addr: "127.0.0.1:port", StoreDir: t.TempDir()}, logger)
+	require.ErrorContains(t, err, "invalid embedded NATS port")
+}
@@ -0,0 +1,162 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"events-audit/internal/api"
+)
+
+// Column fields of the events.
+const (
+	FieldStreamSeq = "stream_seq"
+	FieldTimestamp = "timestamp"
+	FieldSubject   = "subject"
+	FieldID        = "id"
+	FieldType      = "type"
+	FieldSource    = "source"
+	FieldData      = "data"
+
+	dataPrefix = "data."
+)
+
+// Column maps an event field to a CSV or Parquet column. Field is one of the
+// Field constants or data.<path>, a dot separated path into the JSON data.
+type Column struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+}
+
+// DefaultColumns returns the columns of the search API CSV format.
+func DefaultColumns() []Column {
+	fields := []string{FieldStreamSeq, FieldTimestamp, FieldSubject, FieldID, FieldType, FieldSource, FieldData}
+	columns := make([]Column, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, Column{Name: field, Field: field})
+	}
+	return columns
+}
+
+// ParseColumns parses a comma separated column mapping. Each entry is a
+// field, or name=field to set the column name; columns of data.<path>
+// fields are named after the path with dots replaced by underscores.
+func ParseColumns(mapping string) ([]Column, error) {
+	var columns []Column
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, field, found := strings.Cut(entry, "=")
+		if !found {
+			field = name
+			name = strings.ReplaceAll(strings.TrimPrefix(field, dataPrefix), ".", "_")
+		}
+		name, field = strings.TrimSpace(name), strings.TrimSpace(field)
+
+		switch field {
+		case FieldStreamSeq, FieldTimestamp, FieldSubject, FieldID, FieldType, FieldSource, FieldData:
+		default:
+			path := strings.TrimPrefix(field, dataPrefix)
+			if path == field || path == "" || slices.Contains(strings.Split(path, "."), "") {
+				return nil, fmt.Errorf("unknown column field %q, supported %s, %s, %s, %s, %s, %s, %s or data.<path>",
+					field, FieldStreamSeq, FieldTimestamp, FieldSubject, FieldID, FieldType, FieldSource, FieldData)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("empty column name for field %q", field)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		names[name] = true
+		columns = append(columns, Column{Name: name, Field: field})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns in %q", mapping)
+	}
+	return columns, nil
+}
+
+// values looks up the column values of an event. The JSON data is decoded
+// once, on the first data.<path> lookup.
+type values struct {
+	event   *api.Event
+	decoded bool
+	data    any
+}
+
+func newValues(event *api.Event) *values {
+	return &values{event: event}
+}
+
+// get returns the text value of the column, false when the field is missing.
+// The data column holds the JSON data, or the base64 encoded body of events
+// without JSON data.
+func (v *values) get(column Column) (string, bool) {
+	event := v.event
+	switch column.Field {
+	case FieldStreamSeq:
+		return strconv.FormatUint(event.Sequence, 10), true
+	case FieldTimestamp:
+		return event.Timestamp.Format(time.RFC3339Nano), true
+	case FieldSubject:
+		return event.Subject, true
+	case FieldID:
+		return event.ID, event.ID != ""
+	case FieldType:
+		return event.Type, event.Type != ""
+	case FieldSource:
+		return event.Source, event.Source != ""
+	case FieldData:
+		if event.Data == nil {
+			return base64.StdEncoding.EncodeToString(event.RawData), true
+		}
+		return string(event.Data), true
+	}
+
+	if !v.decoded {
+		v.decoded = true
+		if event.Data != nil {
+			decoder := json.NewDecoder(bytes.NewReader(event.Data))
+			decoder.UseNumber()
+			_ = decoder.Decode(&v.data)
+		}
+	}
+
+	value := v.data
+	for _, key := range strings.Split(strings.TrimPrefix(column.Field, dataPrefix), ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return strconv.FormatBool(value), true
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	}
+}
@@ -0,0 +1,352 @@
+// Package export writes archived or streamed audit events to JSONL, CSV or
+// Parquet files with a manifest, and reads JSONL exports back for import.
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"events-audit/internal/api"
+	"events-audit/internal/redact"
+	"events-audit/internal/store"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Export formats.
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Export compressions. Parquet files are compressed per page, the other
+// formats as a whole.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Export sources recorded in the manifest.
+const (
+	SourceStream  = "stream"
+	SourceArchive = "archive"
+)
+
+const (
+	manifestVersion = 1
+	manifestSuffix  = ".manifest.json"
+	filePerm        = 0o640
+)
+
+// Options configure an export.
+type Options struct {
+	Format      string
+	Compression string
+	// Columns of CSV and Parquet exports, DefaultColumns when empty.
+	Columns []Column
+	// Source, Subject, From and To describe the exported range in the
+	// manifest.
+	Source  string
+	Subject string
+	From    time.Time
+	To      time.Time
+	// Redactor redacts the exported events and message bodies as the search
+	// API does; nil exports them as archived.
+	Redactor *redact.Engine
+}
+
+// Validate checks the format, compression and columns.
+func (o Options) Validate() error {
+	switch o.Format {
+	case FormatJSONL, FormatCSV, FormatParquet:
+	default:
+		return fmt.Errorf("unknown format %q, supported %s, %s or %s", o.Format, FormatJSONL, FormatCSV, FormatParquet)
+	}
+	switch o.Compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("unknown compression %q, supported %s, %s or %s",
+			o.Compression, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	if o.Format == FormatJSONL && len(o.Columns) > 0 {
+		return errors.New("columns are only supported by the csv and parquet formats")
+	}
+	return nil
+}
+
+// Line is a line of a JSONL export: the event as returned by the search API
+// together with the original message body, which is republished on import.
+type Line struct {
+	api.Event
+	Stream  string `json:"stream"`
+	Message []byte `json:"message"`
+}
+
+// Manifest describes an export file. It is written next to the file with
+// the .manifest.json suffix.
+type Manifest struct {
+	Version     int        `json:"version"`
+	File        string     `json:"file"`
+	Format      string     `json:"format"`
+	Compression string     `json:"compression"`
+	Columns     []Column   `json:"columns,omitempty"`
+	Source      string     `json:"source,omitempty"`
+	Stream      string     `json:"stream,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	Records     int64      `json:"records"`
+	FirstSeq    uint64     `json:"first_seq"`
+	LastSeq     uint64     `json:"last_seq"`
+	// Subjects and Types count the records per subject and event type.
+	Subjects map[string]int64 `json:"subjects"`
+	Types    map[string]int64 `json:"types"`
+	// Redacted is set when the records were redacted, so an import
+	// republishes the redacted bodies.
+	Redacted bool `json:"redacted,omitempty"`
+	// Bytes and SHA256 cover the file as written, ContentSHA256 the
+	// uncompressed content.
+	Bytes         int64     `json:"bytes"`
+	SHA256        string    `json:"sha256"`
+	ContentSHA256 string    `json:"content_sha256"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ManifestPath returns the manifest path of an export file.
+func ManifestPath(path string) string {
+	return path + manifestSuffix
+}
+
+// ReadManifest reads the manifest of an export file.
+func ReadManifest(path string) (*Manifest, error) {
+	content, err := os.ReadFile(ManifestPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// encoder encodes records in an export format.
+type encoder interface {
+	encode(rec *store.Record, event *api.Event) error
+	close() error
+}
+
+// Writer writes an export file.
+type Writer struct {
+	path        string
+	file        *os.File
+	buffered    *bufio.Writer
+	compressor  io.WriteCloser
+	fileHash    hash.Hash
+	contentHash hash.Hash
+	counter     *countingWriter
+	encoder     encoder
+	redactor    *redact.Engine
+	manifest    Manifest
+}
+
+// Create creates the export file at path.
+func Create(path string, opts Options) (*Writer, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Compression == "" {
+		opts.Compression = CompressionNone
+	}
+	if opts.Format != FormatJSONL && len(opts.Columns) == 0 {
+		opts.Columns = DefaultColumns()
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	w := &Writer{
+		path:        path,
+		file:        file,
+		buffered:    bufio.NewWriter(file),
+		fileHash:    sha256.New(),
+		contentHash: sha256.New(),
+		counter:     &countingWriter{},
+		redactor:    opts.Redactor,
+		manifest: Manifest{
+			Version:     manifestVersion,
+			File:        filepath.Base(path),
+			Format:      opts.Format,
+			Compression: opts.Compression,
+			Columns:     opts.Columns,
+			Source:      opts.Source,
+			Subject:     opts.Subject,
+			Subjects:    make(map[string]int64),
+			Types:       make(map[string]int64),
+			Redacted:    opts.Redactor != nil,
+		},
+	}
+	if !opts.From.IsZero() {
+		from := opts.From.UTC()
+		w.manifest.From = &from
+	}
+	if !opts.To.IsZero() {
+		to := opts.To.UTC()
+		w.manifest.To = &to
+	}
+
+	out := io.MultiWriter(w.buffered, w.fileHash, w.counter)
+	if opts.Format != FormatParquet {
+		switch opts.Compression {
+		case CompressionGzip:
+			w.compressor = gzip.NewWriter(out)
+		case CompressionZstd:
+			if w.compressor, err = zstd.NewWriter(out); err != nil {
+				_ = file.Close()
+				return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+			}
+		}
+		if w.compressor != nil {
+			out = w.compressor
+		}
+	}
+	content := io.MultiWriter(out, w.contentHash)
+
+	switch opts.Format {
+	case FormatJSONL:
+		w.encoder = &jsonlEncoder{encoder: json.NewEncoder(content)}
+	case FormatCSV:
+		w.encoder, err = newCSVEncoder(content, opts.Columns)
+	case FormatParquet:
+		w.encoder, err = newParquetEncoder(content, opts.Columns, opts.Compression)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a record to the export, redacted when a redactor is set.
+func (w *Writer) Write(rec *store.Record) error {
+	rec = api.RedactRecord(w.redactor, rec)
+	event := api.NewEvent(rec)
+	if err := w.encoder.encode(rec, &event); err != nil {
+		return err
+	}
+
+	m := &w.manifest
+	if m.Records == 0 {
+		m.FirstSeq = rec.StreamSeq
+		m.Stream = rec.Stream
+	}
+	m.Records++
+	m.LastSeq = rec.StreamSeq
+	m.Subjects[rec.Subject]++
+	if event.Type != "" {
+		m.Types[event.Type]++
+	}
+	return nil
+}
+
+// Close finishes the export file and writes its manifest.
+func (w *Writer) Close() (*Manifest, error) {
+	err := w.encoder.close()
+	if w.compressor != nil {
+		err = errors.Join(err, w.compressor.Close())
+	}
+	err = errors.Join(err, w.buffered.Flush(), w.file.Sync(), w.file.Close())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	w.manifest.Bytes = w.counter.n
+	w.manifest.SHA256 = hex.EncodeToString(w.fileHash.Sum(nil))
+	w.manifest.ContentSHA256 = hex.EncodeToString(w.contentHash.Sum(nil))
+	w.manifest.CreatedAt = time.Now().UTC()
+
+	content, err := json.MarshalIndent(&w.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err = os.WriteFile(ManifestPath(w.path), append(content, '\n'), filePerm); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return &w.manifest, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlEncoder) encode(rec *store.Record, event *api.Event) error {
+	line := Line{Event: *event, Stream: rec.Stream, Message: rec.Data}
+	if err := e.encoder.Encode(&line); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+func (e *jsonlEncoder) close() error {
+	return nil
+}
+
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []Column
+	row     []string
+}
+
+func newCSVEncoder(w io.Writer, columns []Column) (*csvEncoder, error) {
+	e := &csvEncoder{writer: csv.NewWriter(w), columns: columns, row: make([]string, len(columns))}
+	for i, column := range columns {
+		e.row[i] = column.Name
+	}
+	if err := e.writer.Write(e.row); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) encode(_ *store.Record, event *api.Event) error {
+	values := newValues(event)
+	for i, column := range e.columns {
+		e.row[i], _ = values.get(column)
+	}
+	if err := e.writer.Write(e.row); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+func (e *csvEncoder) close() error {
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package export_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/export"
+	"events-audit/internal/redact"
+	"events-audit/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecords() []*store.Record {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []*store.Record{
+		{
+			Stream:    "EVENTS",
+			StreamSeq: 7,
+			Timestamp: start,
+			Subject:   "events.user",
+			Header:    map[string][]string{"Trace-Id": {"abc"}},
+			Data:      []byte(`{"id":"1","type":"user.created","source":"svc","data":{"user":{"name":"ann"},"count":3}}`),
+		},
+		{
+			Stream:    "EVENTS",
+			StreamSeq: 8,
+			Timestamp: start.Add(time.Second),
+			Subject:   "events.user",
+			Data:      []byte(`{"id":"2","type":"user.deleted","source":"svc","data":{"user":{"id":5}}}`),
+		},
+		{
+			Stream:    "EVENTS",
+			StreamSeq: 9,
+			Timestamp: start.Add(2 * time.Second),
+			Subject:   "events.raw",
+			Data:      []byte("not json"),
+		},
+	}
+}
+
+func writeExport(t *testing.T, path string, opts export.Options) *export.Manifest {
+	t.Helper()
+
+	writer, err := export.Create(path, opts)
+	require.NoError(t, err)
+	for _, rec := range testRecords() {
+		require.NoError(t, writer.Write(rec))
+	}
+	manifest, err := writer.Close()
+	require.NoError(t, err)
+	return manifest
+}
+
+func readLines(t *testing.T, path string) ([]*export.Line, error) {
+	t.Helper()
+
+	reader, err := export.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+
+	var lines []*export.Line
+	for {
+		line, nextErr := reader.Next()
+		if errors.Is(nextErr, io.EOF) {
+			return lines, nil
+		}
+		if nextErr != nil {
+			return lines, nextErr
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestJSONL_RoundTrip(t *testing.T) {
+	for _, compression := range []string{export.CompressionNone, export.CompressionGzip, export.CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.jsonl")
+			manifest := writeExport(t, path, export.Options{
+				Format:      export.FormatJSONL,
+				Compression: compression,
+				Source:      export.SourceArchive,
+			})
+
+			assert.Equal(t, int64(3), manifest.Records)
+			assert.Equal(t, uint64(7), manifest.FirstSeq)
+			assert.Equal(t, uint64(9), manifest.LastSeq)
+			assert.Equal(t, "EVENTS", manifest.Stream)
+			assert.Equal(t, map[string]int64{"events.user": 2, "events.raw": 1}, manifest.Subjects)
+			assert.Equal(t, map[string]int64{"user.created": 1, "user.deleted": 1}, manifest.Types)
+
+			stored, err := export.ReadManifest(path)
+			require.NoError(t, err)
+			assert.Equal(t, manifest.SHA256, stored.SHA256)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, info.Size(), stored.Bytes)
+
+			lines, err := readLines(t, path)
+			require.NoError(t, err)
+			require.Len(t, lines, 3)
+			for i, rec := range testRecords() {
+				assert.Equal(t, rec.StreamSeq, lines[i].Sequence)
+				assert.Equal(t, rec.Subject, lines[i].Subject)
+				assert.Equal(t, rec.Stream, lines[i].Stream)
+				assert.Equal(t, rec.Data, lines[i].Message)
+				assert.True(t, rec.Timestamp.Equal(lines[i].Timestamp))
+			}
+			assert.Equal(t, "user.created", lines[0].Type)
+			assert.Equal(t, []string{"abc"}, lines[0].Header["Trace-Id"])
+		})
+	}
+}
+
+func TestOpen_VerifiesManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	manifest := writeExport(t, path, export.Options{Format: export.FormatJSONL})
+
+	// The record count is checked before the first line is returned.
+	manifest.Records++
+	encoded, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(export.ManifestPath(path), encoded, 0o600))
+	_, err = export.Open(path)
+	require.ErrorContains(t, err, "manifest lists 4")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append(content, '\n'), 0o600))
+	_, err = export.Open(path)
+	require.ErrorContains(t, err, "does not match manifest checksum")
+
+	// Without a manifest nothing is verified.
+	require.NoError(t, os.Remove(export.ManifestPath(path)))
+	lines, err := readLines(t, path)
+	require.NoError(t, err)
+	assert.Len(t, lines, 3)
+}
+
+func TestOpen_RejectsOtherFormats(t *testing.T) {
+	for _, format := range []string{export.FormatCSV, export.FormatParquet} {
+		path := filepath.Join(t.TempDir(), "events."+format)
+		writeExport(t, path, export.Options{Format: format})
+
+		_, err := export.Open(path)
+		require.ErrorContains(t, err, "can not import "+format)
+
+		require.NoError(t, os.Remove(export.ManifestPath(path)))
+		if format == export.FormatParquet {
+			_, err = export.Open(path)
+			require.ErrorContains(t, err, "can not import "+format)
+		}
+	}
+}
+
+func TestCSV_ColumnMapping(t *testing.T) {
+	columns, err := export.ParseColumns("stream_seq, user=data.user.name, data.user.id, kind=type, data")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "events.csv")
+	writeExport(t, path, export.Options{Format: export.FormatCSV, Columns: columns})
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	rows, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"stream_seq", "user", "user_id", "kind", "data"},
+		{"7", "ann", "", "user.created", `{"user":{"name":"ann"},"count":3}`},
+		{"8", "", "5", "user.deleted", `{"user":{"id":5}}`},
+		{"9", "", "", "", "bm90IGpzb24="},
+	}, rows)
+}
+
+func TestWriter_Redacts(t *testing.T) {
+	redactor, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.user.name"}, Mode: redact.ModeMask},
+		{Pattern: "(json)", Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	manifest := writeExport(t, path, export.Options{Format: export.FormatJSONL, Redactor: redactor})
+	assert.True(t, manifest.Redacted)
+
+	lines, err := readLines(t, path)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"user":{"name":"[REDACTED]"},"count":3}`, string(lines[0].Data))
+	assert.NotContains(t, string(lines[0].Message), "ann", "the republished body is redacted")
+	assert.Equal(t, "not [REDACTED]", string(lines[2].Message))
+
+	columns, err := export.ParseColumns("user=data.user.name, data")
+	require.NoError(t, err)
+	path = filepath.Join(t.TempDir(), "events.csv")
+	writeExport(t, path, export.Options{Format: export.FormatCSV, Columns: columns, Redactor: redactor})
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	rows, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "[REDACTED]", rows[1][0])
+}
+
+func TestParseColumns_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field": "stream_seq,payload",
+		"empty path":    "data.",
+		"empty segment": "data.user..name",
+		"duplicate":     "type,type",
+		"empty name":    "=type",
+		"no columns":    " , ",
+	}
+	for name, mapping := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := export.ParseColumns(mapping)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	require.NoError(t, export.Options{Format: export.FormatParquet, Compression: export.CompressionZstd}.Validate())
+	require.ErrorContains(t, export.Options{Format: "xml"}.Validate(), "unknown format")
+	require.ErrorContains(t, export.Options{Format: export.FormatCSV, Compression: "lz4"}.Validate(), "unknown compression")
+	require.ErrorContains(t, export.Options{
+		Format:  export.FormatJSONL,
+		Columns: export.DefaultColumns(),
+	}.Validate(), "columns are only supported")
+}
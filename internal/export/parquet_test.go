@@ -0,0 +1,126 @@
+package export_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/export"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readParquet opens an export with an independent Parquet implementation.
+func readParquet(t *testing.T, path string) *parquet.File {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = file.Close() })
+	info, err := file.Stat()
+	require.NoError(t, err)
+
+	pf, err := parquet.OpenFile(file, info.Size())
+	require.NoError(t, err)
+	return pf
+}
+
+func convertedType(t *testing.T, field parquet.Field) deprecated.ConvertedType {
+	t.Helper()
+	converted := field.Type().ConvertedType()
+	require.NotNil(t, converted, field.Name())
+	return *converted
+}
+
+func TestParquet_RoundTrip(t *testing.T) {
+	columns, err := export.ParseColumns("stream_seq,timestamp,subject,id,user=data.user.name,data")
+	require.NoError(t, err)
+
+	for _, compression := range []string{export.CompressionNone, export.CompressionGzip, export.CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.parquet")
+			writeExport(t, path, export.Options{
+				Format:      export.FormatParquet,
+				Compression: compression,
+				Columns:     columns,
+			})
+
+			file := readParquet(t, path)
+			assert.Equal(t, int64(3), file.NumRows())
+			assert.Equal(t, "events-audit", file.Metadata().CreatedBy)
+
+			var names []string
+			for _, field := range file.Schema().Fields() {
+				names = append(names, field.Name())
+			}
+			assert.Equal(t, []string{"stream_seq", "timestamp", "subject", "id", "user", "data"}, names)
+
+			fields := file.Schema().Fields()
+			assert.Equal(t, parquet.Int64Type.Kind(), fields[0].Type().Kind())
+			assert.True(t, fields[0].Required())
+			assert.Equal(t, deprecated.TimestampMicros, convertedType(t, fields[1]))
+			for _, field := range fields[2:] {
+				assert.True(t, field.Optional(), field.Name())
+				assert.Equal(t, deprecated.UTF8, convertedType(t, field), field.Name())
+			}
+
+			rows := make([]parquet.Row, 4)
+			reader := parquet.NewReader(file)
+			defer func() { _ = reader.Close() }()
+			n, err := reader.ReadRows(rows)
+			if n < 3 {
+				require.NoError(t, err)
+			}
+			require.Equal(t, 3, n)
+
+			records := testRecords()
+			for i, row := range rows[:n] {
+				values := make(map[string]parquet.Value, len(row))
+				for _, value := range row {
+					values[names[value.Column()]] = value
+				}
+				assert.Equal(t, int64(records[i].StreamSeq), values["stream_seq"].Int64()) //nolint:gosec // small test sequences
+				assert.Equal(t, records[i].Timestamp, time.UnixMicro(values["timestamp"].Int64()).UTC())
+				assert.Equal(t, records[i].Subject, string(values["subject"].ByteArray()))
+			}
+
+			assert.Equal(t, "1", string(rows[0][3].ByteArray()))
+			assert.Equal(t, "ann", string(rows[0][4].ByteArray()))
+			assert.JSONEq(t, `{"user":{"name":"ann"},"count":3}`, string(rows[0][5].ByteArray()))
+			assert.True(t, rows[1][4].IsNull(), "missing fields are null")
+			assert.True(t, rows[2][3].IsNull())
+			assert.Equal(t, "bm90IGpzb24=", string(rows[2][5].ByteArray()))
+		})
+	}
+}
+
+func TestParquet_RowGroups(t *testing.T) {
+	const rows = 64*1024 + 10
+
+	path := filepath.Join(t.TempDir(), "events.parquet")
+	writer, err := export.Create(path, export.Options{Format: export.FormatParquet, Compression: export.CompressionZstd})
+	require.NoError(t, err)
+	rec := testRecords()[0]
+	for seq := uint64(1); seq <= rows; seq++ {
+		rec.StreamSeq = seq
+		require.NoError(t, writer.Write(rec))
+	}
+	_, err = writer.Close()
+	require.NoError(t, err)
+
+	file := readParquet(t, path)
+	assert.Len(t, file.RowGroups(), 2)
+	assert.Equal(t, int64(rows), file.NumRows())
+
+	reader := parquet.NewReader(file)
+	defer func() { _ = reader.Close() }()
+	require.NoError(t, reader.SeekToRow(rows-1))
+	last := make([]parquet.Row, 1)
+	n, _ := reader.ReadRows(last)
+	require.Equal(t, 1, n)
+	assert.Equal(t, int64(rows), last[0][0].Int64())
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+//nolint:gochecknoglobals // read-only magic numbers
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Reader reads the lines of a JSONL export, compressed or not. When the
+// export has a manifest, the file checksum and record count are verified
+// when it is opened, before any line is read.
+type Reader struct {
+	file         *os.File
+	decompressor io.Closer
+	lines        *bufio.Reader
+	manifest     *Manifest
+	line         int
+}
+
+// Open opens a JSONL export for reading. CSV and Parquet exports can not be
+// imported since they do not hold the original messages.
+func Open(path string) (*Reader, error) {
+	manifest, err := ReadManifest(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		manifest = nil
+	case err != nil:
+		return nil, err
+	case manifest.Format != FormatJSONL:
+		return nil, fmt.Errorf("can not import %s export, only %s exports hold the original messages",
+			manifest.Format, FormatJSONL)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	r := &Reader{file: file, manifest: manifest}
+	if manifest != nil {
+		if err = r.verify(); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to rewind export file: %w", err)
+		}
+	}
+
+	content, decompressor, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	r.lines, r.decompressor = bufio.NewReader(content), decompressor
+	return r, nil
+}
+
+// decompress returns the content of an export read from buffered, detecting
+// its compression by the magic number. The closer is nil for uncompressed
+// exports.
+func decompress(buffered *bufio.Reader) (io.Reader, io.Closer, error) {
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, []byte(parquetMagic)):
+		return nil, nil, fmt.Errorf("can not import %s export, only %s exports hold the original messages", FormatParquet, FormatJSONL)
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open export file: %w", err)
+		}
+		return gz, gz, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open export file: %w", err)
+		}
+		return zr, zr.IOReadCloser(), nil
+	default:
+		return buffered, nil, nil
+	}
+}
+
+// Manifest returns the manifest of the export, nil when it has none.
+func (r *Reader) Manifest() *Manifest {
+	return r.manifest
+}
+
+// Next returns the next line of the export, io.EOF after the last one.
+func (r *Reader) Next() (*Line, error) {
+	for {
+		content, err := r.lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(content)) == 0 {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read export file: %w", err)
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read export file: %w", err)
+		}
+
+		r.line++
+		var line Line
+		if decodeErr := json.Unmarshal(content, &line); decodeErr != nil {
+			return nil, fmt.Errorf("failed to decode line %d: %w", r.line, decodeErr)
+		}
+		return &line, nil
+	}
+}
+
+// verify reads the whole export once and checks its checksum and record
+// count against the manifest, so a damaged export is refused before any
+// of its messages is republished.
+func (r *Reader) verify() error {
+	fileHash := sha256.New()
+	buffered := bufio.NewReader(io.TeeReader(r.file, fileHash))
+	content, decompressor, err := decompress(buffered)
+	if err != nil {
+		return err
+	}
+	if decompressor != nil {
+		defer decompressor.Close()
+	}
+
+	// A damaged compressed export fails to decompress; its checksum is
+	// still compared first as the more telling error.
+	records, countErr := countLines(content)
+	if _, err = io.Copy(io.Discard, buffered); err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+
+	if sum := hex.EncodeToString(fileHash.Sum(nil)); sum != r.manifest.SHA256 {
+		return fmt.Errorf("export file checksum %s does not match manifest checksum %s", sum, r.manifest.SHA256)
+	}
+	if countErr != nil {
+		return countErr
+	}
+	if records != r.manifest.Records {
+		return fmt.Errorf("export holds %d records, manifest lists %d", records, r.manifest.Records)
+	}
+	return nil
+}
+
+// countLines counts the non-blank lines of the export content.
+func countLines(content io.Reader) (int64, error) {
+	lines := bufio.NewReader(content)
+	var count int64
+	for {
+		line, err := lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read export file: %w", err)
+		}
+	}
+}
+
+// Close closes the export file.
+func (r *Reader) Close() error {
+	if r.decompressor != nil {
+		_ = r.decompressor.Close()
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close export file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
+// Check is a named readiness check. Run returns nil when the checked
+// dependency is ready.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all readiness checks.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs readiness checks.
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker creates a checker running the given checks.
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		timeout: defaultCheckTimeout,
+	}
+}
+
+// Add registers additional checks.
+func (h *Checker) Add(checks ...Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, checks...)
+}
+
+// Run executes every check and reports overall readiness.
+func (h *Checker) Run(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]Check(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(checks)),
+	}
+
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+		start := time.Now()
+		err := check.Run(checkCtx)
+		cancel()
+
+		result := Result{Status: StatusOK, Duration: time.Since(start).String()}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks[check.Name] = result
+	}
+
+	return report
+}
+
+// ReadyHandler serves the readiness report, responding 503 when any check fails.
+func (h *Checker) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.Run(r.Context())
+		if report.Status != StatusOK {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+		render.JSON(w, r, report)
+	}
+}
+
+// LiveHandler reports that the process is alive and serving requests.
+func LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]any{
+			"Status": "OK",
+		})
+	}
+}
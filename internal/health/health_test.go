@@ -0,0 +1,66 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"events-audit/internal/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okCheck(name string) health.Check {
+	return health.Check{Name: name, Run: func(context.Context) error { return nil }}
+}
+
+func failingCheck(name string) health.Check {
+	return health.Check{Name: name, Run: func(context.Context) error { return errors.New("not connected") }}
+}
+
+func serveReady(t *testing.T, checker *health.Checker) (int, health.Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestChecker_AllChecksPass(t *testing.T) {
+	checker := health.NewChecker(okCheck("nats"), okCheck("stream"))
+
+	code, report := serveReady(t, checker)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, health.StatusOK, report.Checks["stream"].Status)
+}
+
+func TestChecker_FailingCheck(t *testing.T) {
+	checker := health.NewChecker(okCheck("stream"))
+	checker.Add(failingCheck("nats"))
+
+	code, report := serveReady(t, checker)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks["stream"].Status)
+	assert.Equal(t, health.StatusFail, report.Checks["nats"].Status)
+	assert.Equal(t, "not connected", report.Checks["nats"].Error)
+}
+
+func TestLiveHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	health.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"Status":"OK"}`, rec.Body.String())
+}
@@ -0,0 +1,336 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "events_audit"
+
+// Metrics holds the Prometheus collectors of the audit service. All methods
+// are safe to call on a nil receiver, in which case they do nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	fetched       *prometheus.CounterVec
+	acked         *prometheus.CounterVec
+	nacked        *prometheus.CounterVec
+	terminated    *prometheus.CounterVec
+	deadLettered  *prometheus.CounterVec
+	schemaInvalid *prometheus.CounterVec
+	sinkWrites    *prometheus.CounterVec
+	sinkWriteTime *prometheus.HistogramVec
+	spoolDepth    *prometheus.GaugeVec
+	spoolBytes    *prometheus.GaugeVec
+	spoolRejected *prometheus.CounterVec
+	streamClients prometheus.Gauge
+	streamDropped prometheus.Counter
+	handlerTime   *prometheus.HistogramVec
+	fetchBatch    prometheus.Histogram
+	fetchErrors   prometheus.Counter
+	fetchTimeouts prometheus.Counter
+	reconnects    prometheus.Counter
+	pending       *prometheus.GaugeVec
+	ackPending    *prometheus.GaugeVec
+}
+
+// New creates the audit metrics on a dedicated registry that also exposes
+// Go runtime and process metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		fetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_fetched_total",
+			Help:      "Messages fetched from JetStream.",
+		}, []string{"subject"}),
+		acked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_acked_total",
+			Help:      "Messages acknowledged after successful handling.",
+		}, []string{"subject"}),
+		nacked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_nacked_total",
+			Help:      "Messages negatively acknowledged for redelivery.",
+		}, []string{"subject"}),
+		terminated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_terminated_total",
+			Help:      "Messages terminated after exceeding max delivery attempts.",
+		}, []string{"subject"}),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_dead_lettered_total",
+			Help:      "Messages republished to the dead-letter stream.",
+		}, []string{"subject"}),
+		schemaInvalid: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "schema_invalid_total",
+			Help:      "Events that failed schema validation, by outcome.",
+		}, []string{"subject", "outcome"}),
+		sinkWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sink_writes_total",
+			Help:      "Event writes to output sinks, by result.",
+		}, []string{"sink", "result"}),
+		sinkWriteTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sink_write_seconds",
+			Help:      "Time spent writing an event to an output sink.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 16), //nolint:mnd // 0.5ms to ~16s
+		}, []string{"sink"}),
+		spoolDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sink_spool_events",
+			Help:      "Events spooled on disk and not yet delivered to a sink.",
+		}, []string{"sink"}),
+		spoolBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sink_spool_bytes",
+			Help:      "Size of the events spooled on disk for a sink.",
+		}, []string{"sink"}),
+		spoolRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sink_spool_full_total",
+			Help:      "Events that found the spool of a sink full, by policy.",
+		}, []string{"sink", "policy"}),
+		streamClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stream_clients",
+			Help:      "Clients connected to the live event stream.",
+		}),
+		streamDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_dropped_events_total",
+			Help:      "Events not sent to live stream clients because their buffer was full.",
+		}),
+		handlerTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "message_processing_seconds",
+			Help:      "Time spent handling and acknowledging a message.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 16), //nolint:mnd // 0.5ms to ~16s
+		}, []string{"subject"}),
+		fetchBatch: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fetch_batch_messages",
+			Help:      "Number of messages returned by a single fetch.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 11), //nolint:mnd // 1 to 1024
+		}),
+		fetchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_errors_total",
+			Help:      "Failed fetch requests, excluding timeouts.",
+		}),
+		fetchTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_timeouts_total",
+			Help:      "Fetch requests that returned no messages before the pull timeout.",
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "nats_reconnects_total",
+			Help:      "NATS reconnections.",
+		}),
+		pending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "consumer_pending_messages",
+			Help:      "Messages in the stream not yet delivered to the consumer.",
+		}, []string{"stream", "consumer"}),
+		ackPending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "consumer_ack_pending_messages",
+			Help:      "Messages delivered to the consumer and awaiting acknowledgment.",
+		}, []string{"stream", "consumer"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.fetched,
+		m.acked,
+		m.nacked,
+		m.terminated,
+		m.deadLettered,
+		m.schemaInvalid,
+		m.sinkWrites,
+		m.sinkWriteTime,
+		m.spoolDepth,
+		m.spoolBytes,
+		m.spoolRejected,
+		m.streamClients,
+		m.streamDropped,
+		m.handlerTime,
+		m.fetchBatch,
+		m.fetchErrors,
+		m.fetchTimeouts,
+		m.reconnects,
+		m.pending,
+		m.ackPending,
+	)
+
+	return m
+}
+
+// Registry returns the registry holding the audit metrics.
+func (m *Metrics) Registry() *prometheus.Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus
+// exposition format, or a handler answering 404 when metrics are disabled.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Fetched records a batch of fetched messages.
+func (m *Metrics) Fetched(subjects []string) {
+	if m == nil {
+		return
+	}
+	m.fetchBatch.Observe(float64(len(subjects)))
+	for _, subject := range subjects {
+		m.fetched.WithLabelValues(subject).Inc()
+	}
+}
+
+// FetchError records a failed fetch.
+func (m *Metrics) FetchError() {
+	if m == nil {
+		return
+	}
+	m.fetchErrors.Inc()
+}
+
+// FetchTimeout records a fetch that timed out without messages.
+func (m *Metrics) FetchTimeout() {
+	if m == nil {
+		return
+	}
+	m.fetchTimeouts.Inc()
+}
+
+// Acked records a successfully handled message.
+func (m *Metrics) Acked(subject string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.acked.WithLabelValues(subject).Inc()
+	m.handlerTime.WithLabelValues(subject).Observe(elapsed.Seconds())
+}
+
+// Nacked records a message handed back for redelivery.
+func (m *Metrics) Nacked(subject string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.nacked.WithLabelValues(subject).Inc()
+	m.handlerTime.WithLabelValues(subject).Observe(elapsed.Seconds())
+}
+
+// Terminated records a message terminated after its last delivery attempt.
+func (m *Metrics) Terminated(subject string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.terminated.WithLabelValues(subject).Inc()
+	m.handlerTime.WithLabelValues(subject).Observe(elapsed.Seconds())
+}
+
+// DeadLettered records a message republished to the dead-letter stream.
+func (m *Metrics) DeadLettered(subject string) {
+	if m == nil {
+		return
+	}
+	m.deadLettered.WithLabelValues(subject).Inc()
+}
+
+// SchemaInvalid records an event that failed schema validation and the
+// outcome applied to it.
+func (m *Metrics) SchemaInvalid(subject, outcome string) {
+	if m == nil {
+		return
+	}
+	m.schemaInvalid.WithLabelValues(subject, outcome).Inc()
+}
+
+// SinkWrite records a write to an output sink.
+func (m *Metrics) SinkWrite(sink string, elapsed time.Duration, ok bool) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if !ok {
+		result = "error"
+	}
+	m.sinkWrites.WithLabelValues(sink, result).Inc()
+	m.sinkWriteTime.WithLabelValues(sink).Observe(elapsed.Seconds())
+}
+
+// SetSpoolDepth records the events and bytes spooled for a sink.
+func (m *Metrics) SetSpoolDepth(sink string, events int, bytes int64) {
+	if m == nil {
+		return
+	}
+	m.spoolDepth.WithLabelValues(sink).Set(float64(events))
+	m.spoolBytes.WithLabelValues(sink).Set(float64(bytes))
+}
+
+// SpoolFull records an event that found the spool of a sink full.
+func (m *Metrics) SpoolFull(sink, policy string) {
+	if m == nil {
+		return
+	}
+	m.spoolRejected.WithLabelValues(sink, policy).Inc()
+}
+
+// StreamClientConnected records a client joining the live event stream.
+func (m *Metrics) StreamClientConnected() {
+	if m == nil {
+		return
+	}
+	m.streamClients.Inc()
+}
+
+// StreamClientDisconnected records a client leaving the live event stream.
+func (m *Metrics) StreamClientDisconnected() {
+	if m == nil {
+		return
+	}
+	m.streamClients.Dec()
+}
+
+// StreamDropped records an event dropped for a slow live stream client.
+func (m *Metrics) StreamDropped() {
+	if m == nil {
+		return
+	}
+	m.streamDropped.Inc()
+}
+
+// Reconnected records a NATS reconnection.
+func (m *Metrics) Reconnected() {
+	if m == nil {
+		return
+	}
+	m.reconnects.Inc()
+}
+
+// SetConsumerLag updates the consumer pending and ack pending gauges.
+func (m *Metrics) SetConsumerLag(stream, consumer string, pending uint64, ackPending int) {
+	if m == nil {
+		return
+	}
+	m.pending.WithLabelValues(stream, consumer).Set(float64(pending))
+	m.ackPending.WithLabelValues(stream, consumer).Set(float64(ackPending))
+}
@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"events-audit/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_RecordsMessageOutcomes(t *testing.T) {
+	m := metrics.New()
+
+	m.Fetched([]string{"events.a", "events.a", "events.b"})
+	m.Acked("events.a", 10*time.Millisecond)
+	m.Nacked("events.a", time.Millisecond)
+	m.Terminated("events.b", time.Millisecond)
+	m.DeadLettered("events.b")
+	m.FetchTimeout()
+	m.FetchError()
+	m.Reconnected()
+	m.SetConsumerLag("EVENTS", "events-audit-durable", 42, 3)
+	m.SinkWrite("file", time.Millisecond, true)
+	m.SinkWrite("file", time.Millisecond, false)
+	m.SetSpoolDepth("webhook", 3, 1024)
+	m.SpoolFull("webhook", "reject")
+	m.StreamClientConnected()
+	m.StreamDropped()
+
+	expected := `
+# HELP events_audit_messages_fetched_total Messages fetched from JetStream.
+# TYPE events_audit_messages_fetched_total counter
+events_audit_messages_fetched_total{subject="events.a"} 2
+events_audit_messages_fetched_total{subject="events.b"} 1
+# HELP events_audit_consumer_pending_messages Messages in the stream not yet delivered to the consumer.
+# TYPE events_audit_consumer_pending_messages gauge
+events_audit_consumer_pending_messages{consumer="events-audit-durable",stream="EVENTS"} 42
+`
+	require.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"events_audit_messages_fetched_total", "events_audit_consumer_pending_messages"))
+
+	count, err := testutil.GatherAndCount(m.Registry(),
+		"events_audit_messages_acked_total",
+		"events_audit_messages_nacked_total",
+		"events_audit_messages_terminated_total",
+		"events_audit_messages_dead_lettered_total",
+		"events_audit_fetch_timeouts_total",
+		"events_audit_fetch_errors_total",
+		"events_audit_nats_reconnects_total",
+		"events_audit_sink_writes_total",
+		"events_audit_sink_spool_events",
+		"events_audit_sink_spool_bytes",
+		"events_audit_sink_spool_full_total",
+		"events_audit_stream_clients",
+		"events_audit_stream_dropped_events_total",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 14, count)
+}
+
+func TestMetrics_NilReceiverIsNoop(t *testing.T) {
+	var m *metrics.Metrics
+
+	assert.NotPanics(t, func() {
+		m.Fetched([]string{"events.a"})
+		m.Acked("events.a", time.Millisecond)
+		m.Reconnected()
+		m.SetConsumerLag("EVENTS", "durable", 1, 1)
+		m.SinkWrite("file", time.Millisecond, true)
+		m.SetSpoolDepth("webhook", 1, 1)
+		m.SpoolFull("webhook", "block")
+		m.StreamClientConnected()
+		m.StreamDropped()
+	})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := metrics.New()
+	m.Acked("events.a", time.Millisecond)
+
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `events_audit_message_processing_seconds_count{subject="events.a"} 1`)
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,27 @@
+package nats
+
+import (
+	"fmt"
+
+	"events-audit/internal/store"
+
+	"events-audit/internal/source"
+)
+
+// ArchiveHandler returns a handler that durably persists every message to
+// the store before passing it to next. Since the client acknowledges only
+// after the handler succeeds, a message is never acked before it is synced.
+func ArchiveHandler(st store.Store, next EventHandler) EventHandler {
+	return func(msg *source.Msg) error {
+		rec, err := store.RecordFromMsg(msg)
+		if err != nil {
+			return err
+		}
+
+		if appendErr := st.Append(rec); appendErr != nil {
+			return fmt.Errorf("failed to archive message: %w", appendErr)
+		}
+
+		return next(msg)
+	}
+}
@@ -0,0 +1,167 @@
+package nats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// redactedUserInfo replaces credentials embedded in logged URLs.
+const redactedUserInfo = "redacted"
+
+// Auth holds NATS credential and TLS file locations.
+type Auth struct {
+	CredsFile     string
+	NKeySeedFile  string
+	TokenFile     string
+	UserFile      string
+	PasswordFile  string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSCAFile     string
+	TLSServerName string
+}
+
+// authOptions builds the NATS authentication and TLS options from the
+// configured credential files.
+func (c *Client) authOptions() ([]nats.Option, error) {
+	auth := c.config.Auth
+	var opts []nats.Option
+
+	methods := 0
+	if auth.CredsFile != "" {
+		methods++
+		opts = append(opts, nats.UserCredentials(auth.CredsFile))
+	}
+
+	if auth.NKeySeedFile != "" {
+		methods++
+		opt, err := nats.NkeyOptionFromSeed(auth.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NKey seed: %w", err)
+		}
+		opts = append(opts, opt)
+	}
+
+	if auth.TokenFile != "" {
+		methods++
+		token, err := readSecret(auth.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.Token(token))
+	}
+
+	if auth.UserFile != "" || auth.PasswordFile != "" {
+		methods++
+		if auth.UserFile == "" || auth.PasswordFile == "" {
+			return nil, errors.New("both user and password files are required for user/password authentication")
+		}
+		user, err := readSecret(auth.UserFile)
+		if err != nil {
+			return nil, err
+		}
+		password, err := readSecret(auth.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.UserInfo(user, password))
+	}
+
+	if methods > 1 {
+		return nil, errors.New("only one of creds, NKey, token or user/password authentication may be configured")
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	return opts, nil
+}
+
+// tlsConfig builds the client TLS configuration, or returns nil when no TLS
+// option is set.
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	cfg := c.config.Auth
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.TLSCAFile == "" && cfg.TLSServerName == "" {
+		return nil, nil //nolint:nilnil // TLS is optional
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.TLSServerName,
+	}
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, errors.New("both TLS certificate and key files are required for client certificates")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(cfg.TLSCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TLS CA bundle %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// readSecret reads a secret from a file, trimming surrounding whitespace.
+func readSecret(path string) (string, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	secret := strings.TrimSpace(string(raw))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// RedactURL removes user info from every URL in a comma separated NATS
+// server list so it can be logged safely. Everything between the scheme and
+// the last '@' of an entry is treated as user info, so scheme-less entries
+// such as "user:pass@host:4222" and passwords holding '/' or '@' are
+// redacted as well.
+func RedactURL(servers string) string {
+	parts := strings.Split(servers, ",")
+	for i, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		at := strings.LastIndex(trimmed, "@")
+		if at < 0 {
+			parts[i] = trimmed
+			continue
+		}
+
+		scheme := ""
+		if sep := strings.Index(trimmed, "://"); sep >= 0 && sep < at {
+			scheme = trimmed[:sep+len("://")]
+		}
+		parts[i] = scheme + redactedUserInfo + trimmed[at:]
+	}
+	return strings.Join(parts, ",")
+}
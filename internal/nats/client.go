@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"events-audit/internal/constants"
+	"events-audit/internal/embedded"
+	"events-audit/internal/health"
+	"events-audit/internal/metrics"
+	"events-audit/internal/source"
+	"events-audit/internal/subject"
 
 	"github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
@@ -22,15 +28,70 @@ type Config struct {
 	Timeout         time.Duration
 	MaxDeliver      int
 	AckWait         time.Duration
-	DeliverPolicy   int
-	ReplayPolicy    int
+	DeliverPolicy   nats.DeliverPolicy
+	ReplayPolicy    nats.ReplayPolicy
 	PullMaxMessages int
 	PullTimeout     time.Duration
+	MaxAckPending   int
+	Workers         int
+	PartitionKey    string
 	CreateStream    bool
 	StreamMaxAge    time.Duration
 	StreamMaxBytes  int64
 	StreamMaxMsgs   int64
 	StreamReplicas  int
+
+	// StreamSubjects defaults to Subject when empty.
+	StreamSubjects        []string
+	StreamStorage         nats.StorageType
+	StreamRetention       nats.RetentionPolicy
+	StreamDiscard         nats.DiscardPolicy
+	StreamDuplicateWindow time.Duration
+	StreamMaxMsgSize      int32
+	StreamCompression     nats.StoreCompression
+	StreamDenyDelete      bool
+	StreamDenyPurge       bool
+	// StreamMode is one of the StreamMode* constants.
+	StreamMode string
+
+	// ConsumerRecreate allows deleting and recreating an existing consumer
+	// whose non-editable settings differ from the configuration.
+	ConsumerRecreate bool
+
+	// DeliverStartSeq and DeliverStartTime are the start position for the
+	// by-start-sequence and by-start-time deliver policies.
+	DeliverStartSeq  uint64
+	DeliverStartTime time.Time
+
+	DeadLetterStream  string
+	DeadLetterSubject string
+	DeadLetterMaxAge  time.Duration
+	// DeadLetterRetryDelay is the redelivery delay of a message whose
+	// dead-letter publish failed, and DeadLetterRetries the number of
+	// deliveries after MaxDeliver left for retrying the publish.
+	DeadLetterRetryDelay time.Duration
+	DeadLetterRetries    int
+
+	// QuarantineStream holds events that failed schema validation; it is
+	// only created when set.
+	QuarantineStream  string
+	QuarantineSubject string
+	QuarantineMaxAge  time.Duration
+
+	// ReadOnly clients never create or update the dead-letter and quarantine
+	// streams; the audit stream is only created when CreateStream is set.
+	ReadOnly bool
+
+	// Embedded, when set, runs an in-process NATS server that the client
+	// connects to instead of URL.
+	Embedded *embedded.Config
+
+	// FetchStaleAfter and MaxConsumerLag are readiness thresholds; a zero
+	// MaxConsumerLag disables the lag check.
+	FetchStaleAfter time.Duration
+	MaxConsumerLag  uint64
+
+	Auth Auth
 }
 
 // DefaultConfig returns default JetStream configuration.
@@ -44,15 +105,38 @@ func DefaultConfig() Config {
 		Timeout:         constants.DefaultTimeout,
 		MaxDeliver:      constants.DefaultMaxDeliver,
 		AckWait:         constants.DefaultAckWait,
-		DeliverPolicy:   0, // DeliverAllPolicy
-		ReplayPolicy:    0, // ReplayInstantPolicy
+		DeliverPolicy:   nats.DeliverAllPolicy,
+		ReplayPolicy:    nats.ReplayInstantPolicy,
 		PullMaxMessages: constants.DefaultPullMaxMessages,
 		PullTimeout:     constants.DefaultPullTimeout,
+		MaxAckPending:   constants.DefaultMaxAckPending,
+		Workers:         constants.DefaultWorkers,
+		PartitionKey:    PartitionSubject,
 		CreateStream:    true,
 		StreamMaxAge:    constants.DefaultStreamMaxAge,
 		StreamMaxBytes:  constants.DefaultStreamMaxBytes,
 		StreamMaxMsgs:   constants.DefaultStreamMaxMsgs,
 		StreamReplicas:  constants.DefaultStreamReplicas,
+
+		StreamStorage:         nats.FileStorage,
+		StreamRetention:       nats.LimitsPolicy,
+		StreamDiscard:         nats.DiscardOld,
+		StreamDuplicateWindow: constants.DefaultDuplicateWindow,
+		StreamMaxMsgSize:      constants.DefaultStreamMaxMsgSize,
+		StreamCompression:     nats.NoCompression,
+		StreamMode:            StreamModeReconcile,
+
+		DeadLetterStream:  constants.DefaultDeadLetterStream,
+		DeadLetterSubject: constants.DefaultDeadLetterSubject,
+		DeadLetterMaxAge:  constants.DefaultDeadLetterMaxAge,
+
+		DeadLetterRetryDelay: constants.DefaultDeadLetterRetryDelay,
+		DeadLetterRetries:    constants.DefaultDeadLetterRetries,
+
+		QuarantineSubject: constants.DefaultQuarantineSubject,
+		QuarantineMaxAge:  constants.DefaultQuarantineMaxAge,
+
+		FetchStaleAfter: constants.DefaultFetchStaleAfter,
 	}
 }
 
@@ -64,10 +148,43 @@ type Client struct {
 	logger       *logrus.Logger
 	subscription *nats.Subscription
 	consumer     nats.ConsumerInfo
+	metrics      *metrics.Metrics
+	lastFetch    atomic.Int64
+	embedded     *embedded.Server
+
+	// ready is set once Connect has initialized the connection and
+	// JetStream context, which readiness checks read concurrently.
+	ready atomic.Bool
 }
 
 // EventHandler defines the function signature for handling JetStream events.
-type EventHandler func(msg *nats.Msg) error
+type EventHandler = source.Handler
+
+// toSourceMsg converts a JetStream message with its metadata, which may be
+// nil, to the message type of the handler chain. Headers and data are
+// shared, not copied.
+func toSourceMsg(msg *nats.Msg, meta *nats.MsgMetadata) *source.Msg {
+	converted := &source.Msg{
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Header:  source.Header(msg.Header),
+		Data:    msg.Data,
+	}
+	if meta != nil {
+		converted.Meta = &source.Metadata{
+			Stream:   meta.Stream,
+			Consumer: meta.Consumer,
+			Sequence: source.SequencePair{
+				Consumer: meta.Sequence.Consumer,
+				Stream:   meta.Sequence.Stream,
+			},
+			NumDelivered: meta.NumDelivered,
+			NumPending:   meta.NumPending,
+			Timestamp:    meta.Timestamp,
+		}
+	}
+	return converted
+}
 
 // NewClient creates a new NATS JetStream client.
 func NewClient(config Config, logger *logrus.Logger) (*Client, error) {
@@ -83,8 +200,39 @@ func NewClient(config Config, logger *logrus.Logger) (*Client, error) {
 	return client, nil
 }
 
-// Connect establishes connection to NATS server and initializes JetStream.
+// SetMetrics sets the collectors the client reports to.
+func (c *Client) SetMetrics(m *metrics.Metrics) {
+	c.metrics = m
+}
+
+// Connect establishes connection to NATS server and initializes JetStream,
+// starting the embedded server first when one is configured.
 func (c *Client) Connect(_ context.Context) error {
+	url := c.config.URL
+	if c.config.Embedded != nil {
+		srv, err := embedded.Start(*c.config.Embedded, c.logger)
+		if err != nil {
+			return err
+		}
+		c.embedded = srv
+		url = srv.ClientURL()
+	}
+
+	if err := c.connect(url); err != nil {
+		c.shutdownEmbedded()
+		return err
+	}
+
+	c.ready.Store(true)
+	return nil
+}
+
+func (c *Client) connect(url string) error {
+	authOpts, err := c.authOptions()
+	if err != nil {
+		return fmt.Errorf("failed to configure NATS authentication: %w", err)
+	}
+
 	opts := []nats.Option{
 		nats.Name("events-audit-jetstream-listener"),
 		nats.Timeout(c.config.Timeout),
@@ -94,7 +242,8 @@ func (c *Client) Connect(_ context.Context) error {
 			}
 		}),
 		nats.ReconnectHandler(func(nc *nats.Conn) {
-			c.logger.WithField("url", nc.ConnectedUrl()).Info("NATS reconnected")
+			c.logger.WithField("url", nc.ConnectedUrlRedacted()).Info("NATS reconnected")
+			c.metrics.Reconnected()
 			// Reinitialize JetStream context after reconnect
 			if js, jsErr := nc.JetStream(); jsErr != nil {
 				c.logger.WithError(jsErr).Error("Failed to reinitialize JetStream after reconnect")
@@ -111,13 +260,15 @@ func (c *Client) Connect(_ context.Context) error {
 		}),
 	}
 
-	conn, err := nats.Connect(c.config.URL, opts...)
+	opts = append(opts, authOpts...)
+
+	conn, err := nats.Connect(url, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to connect to NATS: %w", err)
 	}
 
 	c.conn = conn
-	c.logger.WithField("url", c.config.URL).Info("Connected to NATS")
+	c.logger.WithField("url", RedactURL(url)).Info("Connected to NATS")
 
 	// Initialize JetStream context
 	js, err := conn.JetStream()
@@ -129,98 +280,61 @@ func (c *Client) Connect(_ context.Context) error {
 	c.js = js
 	c.logger.Info("JetStream context initialized")
 
-	// Create or update stream if required
-	if c.config.CreateStream {
+	if subjectErr := c.checkSideSubjects(); subjectErr != nil {
+		c.conn.Close()
+		return subjectErr
+	}
+
+	// Create or update stream if required. Verify-only never changes the
+	// stream, so its drift check runs even when creation is disabled.
+	if c.config.CreateStream || c.config.StreamMode == StreamModeVerifyOnly {
 		if streamErr := c.ensureStream(); streamErr != nil {
 			c.conn.Close()
 			return fmt.Errorf("failed to ensure stream: %w", streamErr)
 		}
 	}
 
-	return nil
-}
-
-// ensureStream creates or updates the JetStream stream.
-func (c *Client) ensureStream() error {
-	streamConfig := &nats.StreamConfig{
-		Name:      c.config.StreamName,
-		Subjects:  []string{c.config.Subject},
-		MaxAge:    c.config.StreamMaxAge,
-		MaxBytes:  c.config.StreamMaxBytes,
-		MaxMsgs:   c.config.StreamMaxMsgs,
-		Replicas:  c.config.StreamReplicas,
-		Storage:   nats.FileStorage,
-		Retention: nats.LimitsPolicy,
-		Discard:   nats.DiscardOld,
-	}
-
-	streamInfo, err := c.getOrCreateStream(streamConfig)
-	if err != nil {
-		return err
-	}
-
-	c.logStreamReady(streamInfo)
-	return nil
-}
-
-// getOrCreateStream gets existing stream or creates a new one.
-func (c *Client) getOrCreateStream(streamConfig *nats.StreamConfig) (*nats.StreamInfo, error) {
-	streamInfo, err := c.js.StreamInfo(c.config.StreamName)
-	if err != nil {
-		return c.handleStreamNotFound(err, streamConfig)
+	if c.config.ReadOnly {
+		return nil
 	}
 
-	return c.handleExistingStream(streamInfo, streamConfig)
-}
-
-// handleStreamNotFound creates a new stream if it doesn't exist.
-func (c *Client) handleStreamNotFound(err error, streamConfig *nats.StreamConfig) (*nats.StreamInfo, error) {
-	if !errors.Is(err, nats.ErrStreamNotFound) {
-		return nil, fmt.Errorf("failed to get stream info: %w", err)
+	if c.config.DeadLetterStream != "" {
+		if dlqErr := c.ensureDeadLetterStream(); dlqErr != nil {
+			c.conn.Close()
+			return fmt.Errorf("failed to ensure dead-letter stream: %w", dlqErr)
+		}
 	}
 
-	streamInfo, createErr := c.js.AddStream(streamConfig)
-	if createErr != nil {
-		return nil, fmt.Errorf("failed to create stream %s: %w", c.config.StreamName, createErr)
+	if c.config.QuarantineStream != "" {
+		if quarantineErr := c.ensureQuarantineStream(); quarantineErr != nil {
+			c.conn.Close()
+			return fmt.Errorf("failed to ensure quarantine stream: %w", quarantineErr)
+		}
 	}
 
-	c.logger.WithFields(logrus.Fields{
-		"stream":   c.config.StreamName,
-		"subjects": streamConfig.Subjects,
-	}).Info("Created JetStream stream")
-
-	return streamInfo, nil
+	return nil
 }
 
-// handleExistingStream updates stream if necessary.
-func (c *Client) handleExistingStream(
-	streamInfo *nats.StreamInfo,
-	streamConfig *nats.StreamConfig,
-) (*nats.StreamInfo, error) {
-	if c.needsStreamUpdate(streamInfo) {
-		return c.updateStream(streamConfig)
+// checkSideSubjects rejects dead-letter and quarantine subjects that overlap
+// the audit subjects: messages moved there would be consumed again and
+// moved once more, in a loop.
+func (c *Client) checkSideSubjects() error {
+	audit := append([]string{c.config.Subject}, c.streamConfig().Subjects...)
+	sides := []struct{ name, stream, subject string }{
+		{"dead-letter", c.config.DeadLetterStream, c.deadLetterSubject(">")},
+		{"quarantine", c.config.QuarantineStream, c.quarantineSubject(">")},
 	}
-
-	c.logger.WithField("stream", c.config.StreamName).Info("JetStream stream already exists and is up to date")
-	return streamInfo, nil
-}
-
-// needsStreamUpdate checks if stream configuration needs updating.
-func (c *Client) needsStreamUpdate(streamInfo *nats.StreamInfo) bool {
-	return streamInfo.Config.MaxAge != c.config.StreamMaxAge ||
-		streamInfo.Config.MaxBytes != c.config.StreamMaxBytes ||
-		streamInfo.Config.MaxMsgs != c.config.StreamMaxMsgs
-}
-
-// updateStream updates the stream configuration.
-func (c *Client) updateStream(streamConfig *nats.StreamConfig) (*nats.StreamInfo, error) {
-	streamInfo, err := c.js.UpdateStream(streamConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update stream %s: %w", c.config.StreamName, err)
+	for _, side := range sides {
+		if side.stream == "" {
+			continue
+		}
+		for _, pattern := range audit {
+			if subject.Overlap(side.subject, pattern) {
+				return fmt.Errorf("%s subject %s overlaps audit subject %s", side.name, side.subject, pattern)
+			}
+		}
 	}
-
-	c.logger.WithField("stream", c.config.StreamName).Info("Updated JetStream stream")
-	return streamInfo, nil
+	return nil
 }
 
 // logStreamReady logs stream readiness information.
@@ -233,7 +347,8 @@ func (c *Client) logStreamReady(streamInfo *nats.StreamInfo) {
 	}).Info("JetStream stream ready")
 }
 
-// ensureConsumer creates or recreates consumer with proper configuration.
+// ensureConsumer creates the pull consumer or reconciles an existing one
+// with the desired configuration.
 func (c *Client) ensureConsumer(consumerConfig *nats.ConsumerConfig) (*nats.ConsumerInfo, error) {
 	// Try to get existing consumer
 	existingConsumer, err := c.js.ConsumerInfo(c.config.StreamName, c.config.DurableName)
@@ -243,23 +358,25 @@ func (c *Client) ensureConsumer(consumerConfig *nats.ConsumerConfig) (*nats.Cons
 
 	// If consumer exists, check if it's configured correctly for pull-based subscription
 	if existingConsumer != nil {
-		if existingConsumer.Config.DeliverSubject != "" {
-			// Consumer has DeliverSubject, it's push-based, need to delete and recreate
-			c.logger.WithField("consumer", c.config.DurableName).Warn("Found push-based consumer, deleting to recreate as pull-based")
+		if existingConsumer.Config.DeliverSubject == "" {
+			return c.reconcileConsumer(existingConsumer, consumerConfig)
+		}
 
-			if deleteErr := c.js.DeleteConsumer(c.config.StreamName, c.config.DurableName); deleteErr != nil {
-				return nil, fmt.Errorf("failed to delete existing push-based consumer: %w", deleteErr)
-			}
+		// Consumer has DeliverSubject, it's push-based, need to delete and recreate
+		c.logger.WithField("consumer", c.config.DurableName).Warn("Found push-based consumer, deleting to recreate as pull-based")
 
-			c.logger.WithField("consumer", c.config.DurableName).Info("Deleted existing push-based consumer")
-		} else {
-			// Consumer is already pull-based, can reuse it
-			c.logger.WithField("consumer", c.config.DurableName).Info("Found existing pull-based consumer, reusing")
-			return existingConsumer, nil
+		if deleteErr := c.js.DeleteConsumer(c.config.StreamName, c.config.DurableName); deleteErr != nil {
+			return nil, fmt.Errorf("failed to delete existing push-based consumer: %w", deleteErr)
 		}
+
+		c.logger.WithField("consumer", c.config.DurableName).Info("Deleted existing push-based consumer")
 	}
 
-	// Create new pull-based consumer
+	return c.createConsumer(consumerConfig)
+}
+
+// createConsumer creates a new pull-based consumer.
+func (c *Client) createConsumer(consumerConfig *nats.ConsumerConfig) (*nats.ConsumerInfo, error) {
 	consumerInfo, err := c.js.AddConsumer(c.config.StreamName, consumerConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pull-based consumer: %w", err)
@@ -275,15 +392,27 @@ func (c *Client) Subscribe(ctx context.Context, handler EventHandler) error {
 		return errors.New("JetStream context not initialized")
 	}
 
-	// Consumer configuration for pull-based subscription
+	if err := ValidateStartPosition(c.config.DeliverPolicy, c.config.DeliverStartSeq, c.config.DeliverStartTime); err != nil {
+		return err
+	}
+	partition, err := ParsePartitionKey(c.config.PartitionKey)
+	if err != nil {
+		return err
+	}
+
+	// Consumer configuration for pull-based subscription. The server
+	// requires the consumer name to match the durable name, so the durable
+	// name identifies the consumer and ConsumerName is only used in logs.
 	consumerConfig := &nats.ConsumerConfig{
-		Name:          c.config.ConsumerName,
 		Durable:       c.config.DurableName,
-		DeliverPolicy: nats.DeliverPolicy(c.config.DeliverPolicy),
-		ReplayPolicy:  nats.ReplayPolicy(c.config.ReplayPolicy),
+		DeliverPolicy: c.config.DeliverPolicy,
+		OptStartSeq:   c.config.DeliverStartSeq,
+		OptStartTime:  startTimePtr(c.config.DeliverStartTime),
+		ReplayPolicy:  c.config.ReplayPolicy,
 		AckPolicy:     nats.AckExplicitPolicy,
 		AckWait:       c.config.AckWait,
-		MaxDeliver:    c.config.MaxDeliver,
+		MaxDeliver:    c.consumerMaxDeliver(),
+		MaxAckPending: c.config.MaxAckPending,
 		FilterSubject: c.config.Subject,
 		// DeliverSubject removed for pull-based subscription
 	}
@@ -313,13 +442,35 @@ func (c *Client) Subscribe(ctx context.Context, handler EventHandler) error {
 	c.subscription = sub
 	c.logger.WithField("subject", c.config.Subject).Info("Subscribed to JetStream")
 
+	if c.metrics != nil {
+		go c.pollConsumerLag(ctx)
+	}
+
 	// Start message processing loop
-	return c.processMessages(ctx, handler)
+	return c.processMessages(ctx, handler, partition)
 }
 
-// processMessages handles the pull-based message processing loop.
-func (c *Client) processMessages(ctx context.Context, handler EventHandler) error {
-	c.logger.Info("Starting JetStream message processing loop")
+// processMessages handles the pull-based message processing loop. Fetched
+// messages are handled by a worker pool that keeps messages sharing a
+// partition key in order; a fetch never requests more messages than there
+// are free in-flight slots, bounded by MaxAckPending.
+func (c *Client) processMessages(ctx context.Context, handler EventHandler, partition PartitionFunc) error {
+	c.logger.WithFields(logrus.Fields{
+		"workers":         c.config.Workers,
+		"max_ack_pending": c.config.MaxAckPending,
+		"partition_key":   c.config.PartitionKey,
+	}).Info("Starting JetStream message processing loop")
+
+	pool := NewWorkerPool(c.config.Workers, c.config.MaxAckPending, partition, func(msg *nats.Msg) {
+		if processErr := c.processMessage(ctx, msg, handler); processErr != nil {
+			c.logger.WithError(processErr).WithFields(logrus.Fields{
+				"subject": msg.Subject,
+				"stream":  msg.Reply,
+			}).Error("Failed to process message")
+		}
+	})
+	// Let dispatched messages finish before returning.
+	defer pool.Close()
 
 	for {
 		select {
@@ -327,34 +478,47 @@ func (c *Client) processMessages(ctx context.Context, handler EventHandler) erro
 			c.logger.Info("Context cancelled, stopping message processing")
 			return ctx.Err()
 		default:
+			batch, err := pool.Acquire(ctx, c.config.PullMaxMessages)
+			if err != nil {
+				continue
+			}
+
+			c.lastFetch.Store(time.Now().UnixNano())
+
 			// Fetch messages
-			msgs, err := c.subscription.Fetch(c.config.PullMaxMessages, nats.MaxWait(c.config.PullTimeout))
+			msgs, err := c.subscription.Fetch(batch, nats.MaxWait(c.config.PullTimeout))
+			pool.Release(batch - len(msgs))
 			if err != nil {
 				if errors.Is(err, nats.ErrTimeout) {
 					// Timeout is expected when no messages are available
+					c.metrics.FetchTimeout()
 					continue
 				}
+				c.metrics.FetchError()
 				c.logger.WithError(err).Error("Failed to fetch messages")
 				// Don't return error, just continue trying
 				time.Sleep(time.Second)
 				continue
 			}
 
-			// Process each message
-			for _, msg := range msgs {
-				if processErr := c.processMessage(msg, handler); processErr != nil {
-					c.logger.WithError(processErr).WithFields(logrus.Fields{
-						"subject": msg.Subject,
-						"stream":  msg.Reply,
-					}).Error("Failed to process message")
+			if c.metrics != nil {
+				subjects := make([]string, len(msgs))
+				for i, msg := range msgs {
+					subjects[i] = msg.Subject
 				}
+				c.metrics.Fetched(subjects)
+			}
+
+			// Hand each message to its partition's worker
+			for _, msg := range msgs {
+				pool.Dispatch(msg)
 			}
 		}
 	}
 }
 
 // processMessage handles individual message processing with acknowledgment.
-func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
+func (c *Client) processMessage(ctx context.Context, msg *nats.Msg, handler EventHandler) error {
 	startTime := time.Now()
 
 	// Get message metadata
@@ -362,6 +526,7 @@ func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
 	if err != nil {
 		c.logger.WithError(err).Error("Failed to get message metadata")
 		// Nak the message so it can be redelivered
+		c.metrics.Nacked(msg.Subject, time.Since(startTime))
 		return msg.Nak()
 	}
 
@@ -376,7 +541,18 @@ func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
 	}).Debug("Processing JetStream message")
 
 	// Call the handler
-	if handlerErr := handler(msg); handlerErr != nil {
+	if delay, handlerErr := c.handle(ctx, msg, meta, handler); handlerErr != nil {
+		// Sinks were still down when processing stopped.
+		if delay > 0 {
+			c.logger.WithError(handlerErr).WithFields(logrus.Fields{
+				"subject":   msg.Subject,
+				"delivered": meta.NumDelivered,
+				"delay":     delay.String(),
+			}).Error("Handler failed, negative acknowledging message with delay")
+			c.metrics.Nacked(msg.Subject, time.Since(startTime))
+			return msg.NakWithDelay(delay)
+		}
+
 		// Check if this message has been delivered too many times
 		if meta.NumDelivered >= uint64(c.config.MaxDeliver) { //nolint:gosec // safe conversion from int to uint64
 			c.logger.WithError(handlerErr).WithFields(logrus.Fields{
@@ -384,13 +560,14 @@ func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
 				"delivered":   meta.NumDelivered,
 				"max_deliver": c.config.MaxDeliver,
 			}).Error("Message exceeded max delivery attempts, sending terminal acknowledgment")
-			return msg.Term()
+			return c.terminate(msg, meta, handlerErr, startTime)
 		}
 
 		c.logger.WithError(handlerErr).WithFields(logrus.Fields{
 			"subject":   msg.Subject,
 			"delivered": meta.NumDelivered,
 		}).Error("Handler failed, negative acknowledging message")
+		c.metrics.Nacked(msg.Subject, time.Since(startTime))
 		return msg.Nak()
 	}
 
@@ -401,6 +578,7 @@ func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
 	}
 
 	processingTime := time.Since(startTime)
+	c.metrics.Acked(msg.Subject, processingTime)
 	c.logger.WithFields(logrus.Fields{
 		"subject":         msg.Subject,
 		"processing_time": processingTime.String(),
@@ -410,25 +588,118 @@ func (c *Client) processMessage(msg *nats.Msg, handler EventHandler) error {
 	return nil
 }
 
-// GetStreamInfo returns information about the stream.
-func (c *Client) GetStreamInfo() (*nats.StreamInfo, error) {
-	if c.js == nil {
+// handle calls the handler. Sinks that are down ask for a delayed retry:
+// the message is then kept in progress and handled again after the delay
+// instead of being redelivered, so an outage of any length does not use up
+// its delivery attempts. When ctx is cancelled while waiting, handle returns
+// the requested delay with the error.
+func (c *Client) handle(ctx context.Context, msg *nats.Msg, meta *nats.MsgMetadata, handler EventHandler) (time.Duration, error) {
+	converted := toSourceMsg(msg, meta)
+	for {
+		handlerErr := handler(converted)
+		delay, ok := source.RetryDelay(handlerErr)
+		if !ok {
+			return 0, handlerErr
+		}
+
+		c.logger.WithError(handlerErr).WithFields(logrus.Fields{
+			"subject": msg.Subject,
+			"delay":   delay.String(),
+		}).Warn("Sinks unavailable, handling message again after delay")
+		if !c.hold(ctx, msg, delay) {
+			return max(delay, time.Nanosecond), handlerErr
+		}
+	}
+}
+
+// hold waits for delay, telling the server the message is still in
+// progress at once and every half AckWait. It reports false when ctx is
+// cancelled.
+func (c *Client) hold(ctx context.Context, msg *nats.Msg, delay time.Duration) bool {
+	interval := c.config.AckWait / 2
+	if interval <= 0 {
+		interval = constants.DefaultAckWait / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		if err := msg.InProgress(); err != nil {
+			c.logger.WithError(err).WithField("subject", msg.Subject).Warn("Failed to extend message ack deadline")
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-ticker.C:
+		}
+	}
+}
+
+// consumerMaxDeliver returns the delivery limit of the durable consumer.
+// With a dead-letter stream the server allows DeadLetterRetries deliveries
+// beyond MaxDeliver, so a message whose dead-letter publish failed is
+// redelivered instead of being dropped by the server, while messages that
+// crash the process or outlive AckWait are still given up eventually.
+func (c *Client) consumerMaxDeliver() int {
+	if c.config.DeadLetterStream != "" && c.config.MaxDeliver > 0 {
+		return c.config.MaxDeliver + max(c.config.DeadLetterRetries, 0)
+	}
+	return c.config.MaxDeliver
+}
+
+// terminate moves a message that exhausted its delivery attempts to the
+// dead-letter stream, if configured, before terminating it. When the
+// dead-letter publish fails the message is negatively acknowledged with
+// DeadLetterRetryDelay, so it is dead-lettered again on its next delivery,
+// until the consumer delivery limit is reached and it is dropped.
+func (c *Client) terminate(msg *nats.Msg, meta *nats.MsgMetadata, handlerErr error, startTime time.Time) error {
+	if c.config.DeadLetterStream != "" {
+		err := c.publishDeadLetter(msg, meta, handlerErr)
+		switch {
+		case err == nil:
+			c.metrics.DeadLettered(msg.Subject)
+		case meta.NumDelivered < uint64(c.consumerMaxDeliver()): //nolint:gosec // the limit is positive here
+			c.metrics.Nacked(msg.Subject, time.Since(startTime))
+			return errors.Join(err, msg.NakWithDelay(c.config.DeadLetterRetryDelay))
+		default:
+			c.logger.WithError(err).WithFields(logrus.Fields{
+				"subject":   msg.Subject,
+				"delivered": meta.NumDelivered,
+			}).Error("Dead-letter publish retries exhausted, dropping message")
+		}
+	}
+
+	c.metrics.Terminated(msg.Subject, time.Since(startTime))
+	return msg.Term()
+}
+
+// GetStreamInfo returns information about the stream. Options such as
+// nats.Context bound the request.
+func (c *Client) GetStreamInfo(opts ...nats.JSOpt) (*nats.StreamInfo, error) {
+	if !c.ready.Load() {
 		return nil, errors.New("JetStream context not initialized")
 	}
-	return c.js.StreamInfo(c.config.StreamName)
+	return c.js.StreamInfo(c.config.StreamName, opts...)
 }
 
-// GetConsumerInfo returns information about the consumer.
-func (c *Client) GetConsumerInfo() (*nats.ConsumerInfo, error) {
-	if c.js == nil {
+// GetConsumerInfo returns information about the consumer. Options such as
+// nats.Context bound the request.
+func (c *Client) GetConsumerInfo(opts ...nats.JSOpt) (*nats.ConsumerInfo, error) {
+	if !c.ready.Load() {
 		return nil, errors.New("JetStream context not initialized")
 	}
-	return c.js.ConsumerInfo(c.config.StreamName, c.config.DurableName)
+	return c.js.ConsumerInfo(c.config.StreamName, c.config.DurableName, opts...)
 }
 
-// Close closes the subscription and NATS connection.
+// Close closes the subscription and NATS connection and stops the embedded
+// server, if any.
 func (c *Client) Close() error {
 	c.logger.Info("Closing JetStream client")
+	c.ready.Store(false)
 
 	if c.subscription != nil {
 		if err := c.subscription.Unsubscribe(); err != nil {
@@ -440,13 +711,31 @@ func (c *Client) Close() error {
 		c.conn.Close()
 		c.logger.Info("NATS connection closed")
 	}
+	c.shutdownEmbedded()
 
 	return nil
 }
 
+func (c *Client) shutdownEmbedded() {
+	if c.embedded != nil {
+		c.embedded.Shutdown()
+		c.embedded = nil
+	}
+}
+
 // IsConnected returns true if connected to NATS.
 func (c *Client) IsConnected() bool {
-	return c.conn != nil && c.conn.IsConnected()
+	return c.ready.Load() && c.conn.IsConnected()
+}
+
+// LastFetch returns when the message processing loop last issued a fetch,
+// or the zero time if it has not started.
+func (c *Client) LastFetch() time.Time {
+	nanos := c.lastFetch.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
 }
 
 // Drain gracefully drains the subscription.
@@ -456,3 +745,71 @@ func (c *Client) Drain() error {
 	}
 	return nil
 }
+
+// ReadinessChecks returns the JetStream specific readiness checks: stream
+// and consumer existence, a recently iterating fetch loop and consumer lag
+// below the configured threshold.
+func (c *Client) ReadinessChecks() []health.Check {
+	return []health.Check{
+		{Name: "stream", Run: c.checkStream},
+		{Name: "consumer", Run: c.checkConsumer},
+		{Name: "fetch_loop", Run: c.checkFetchLoop},
+		{Name: "consumer_lag", Run: c.checkConsumerLag},
+	}
+}
+
+func (c *Client) checkStream(ctx context.Context) error {
+	_, err := c.GetStreamInfo(nats.Context(ctx))
+	return err
+}
+
+func (c *Client) checkConsumer(ctx context.Context) error {
+	_, err := c.GetConsumerInfo(nats.Context(ctx))
+	return err
+}
+
+func (c *Client) checkFetchLoop(_ context.Context) error {
+	last := c.LastFetch()
+	if last.IsZero() {
+		return errors.New("fetch loop has not started")
+	}
+	if since := time.Since(last); since > c.config.FetchStaleAfter {
+		return fmt.Errorf("last fetch was %s ago, limit is %s", since.Round(time.Millisecond), c.config.FetchStaleAfter)
+	}
+	return nil
+}
+
+func (c *Client) checkConsumerLag(ctx context.Context) error {
+	if c.config.MaxConsumerLag == 0 {
+		return nil
+	}
+
+	info, err := c.GetConsumerInfo(nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+	if info.NumPending > c.config.MaxConsumerLag {
+		return fmt.Errorf("consumer has %d pending messages, limit is %d", info.NumPending, c.config.MaxConsumerLag)
+	}
+	return nil
+}
+
+// pollConsumerLag periodically exports consumer pending counts as metrics.
+func (c *Client) pollConsumerLag(ctx context.Context) {
+	ticker := time.NewTicker(constants.DefaultLagPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := c.GetConsumerInfo(nats.Context(ctx))
+			if err != nil {
+				c.logger.WithError(err).Debug("Failed to poll consumer info")
+				continue
+			}
+			c.metrics.SetConsumerLag(info.Stream, info.Name, info.NumPending, info.NumAckPending)
+		}
+	}
+}
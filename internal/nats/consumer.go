@@ -0,0 +1,89 @@
+package nats
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DiffConsumer compares the settings the audit client manages.
+func DiffConsumer(existing, desired *nats.ConsumerConfig) ConfigDiff {
+	diff := newConfigDiff()
+
+	if existing.AckWait != desired.AckWait {
+		diff.Editable["ack_wait"] = fieldDiff(existing.AckWait, desired.AckWait)
+	}
+	if existing.MaxDeliver != desired.MaxDeliver {
+		diff.Editable["max_deliver"] = fieldDiff(existing.MaxDeliver, desired.MaxDeliver)
+	}
+	if existing.MaxAckPending != desired.MaxAckPending {
+		diff.Editable["max_ack_pending"] = fieldDiff(existing.MaxAckPending, desired.MaxAckPending)
+	}
+
+	if existing.FilterSubject != desired.FilterSubject {
+		diff.Fixed["filter_subject"] = fieldDiff(existing.FilterSubject, desired.FilterSubject)
+	}
+	if existing.AckPolicy != desired.AckPolicy {
+		diff.Fixed["ack_policy"] = fieldDiff(existing.AckPolicy, desired.AckPolicy)
+	}
+	if existing.DeliverPolicy != desired.DeliverPolicy {
+		diff.Fixed["deliver_policy"] = fieldDiff(DeliverPolicyName(existing.DeliverPolicy), DeliverPolicyName(desired.DeliverPolicy))
+	}
+	if existing.OptStartSeq != desired.OptStartSeq {
+		diff.Fixed["opt_start_seq"] = fieldDiff(existing.OptStartSeq, desired.OptStartSeq)
+	}
+	if !sameTime(existing.OptStartTime, desired.OptStartTime) {
+		diff.Fixed["opt_start_time"] = fieldDiff(formatTime(existing.OptStartTime), formatTime(desired.OptStartTime))
+	}
+	if existing.ReplayPolicy != desired.ReplayPolicy {
+		diff.Fixed["replay_policy"] = fieldDiff(ReplayPolicyName(existing.ReplayPolicy), ReplayPolicyName(desired.ReplayPolicy))
+	}
+
+	return diff
+}
+
+// reconcileConsumer brings an existing pull consumer in line with the
+// desired configuration. Editable settings are updated in place; differing
+// fixed settings fail unless recreation is enabled.
+func (c *Client) reconcileConsumer(
+	existing *nats.ConsumerInfo,
+	desired *nats.ConsumerConfig,
+) (*nats.ConsumerInfo, error) {
+	diff := DiffConsumer(&existing.Config, desired)
+	logger := c.logger.WithField("consumer", c.config.DurableName)
+
+	if diff.Empty() {
+		logger.Info("Found existing pull-based consumer, reusing")
+		return existing, nil
+	}
+
+	if len(diff.Fixed) > 0 {
+		fixed := logger.WithFields(diff.Fields())
+		if !c.config.ConsumerRecreate {
+			fixed.Error("Existing consumer differs in settings that cannot be updated")
+			return nil, fmt.Errorf("consumer %s differs in settings that cannot be updated (%s), "+
+				"enable consumer recreation to replace it", c.config.DurableName, diffKeys(diff.Fixed))
+		}
+
+		fixed.Warn("Existing consumer differs in settings that cannot be updated, recreating")
+		if err := c.js.DeleteConsumer(c.config.StreamName, c.config.DurableName); err != nil {
+			return nil, fmt.Errorf("failed to delete consumer for recreation: %w", err)
+		}
+		return c.createConsumer(desired)
+	}
+
+	// Only editable settings differ: keep everything else as it is on the
+	// server and apply the desired values.
+	updated := existing.Config
+	updated.AckWait = desired.AckWait
+	updated.MaxDeliver = desired.MaxDeliver
+	updated.MaxAckPending = desired.MaxAckPending
+
+	info, err := c.js.UpdateConsumer(c.config.StreamName, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update consumer %s: %w", c.config.DurableName, err)
+	}
+
+	logger.WithFields(diff.Editable).Info("Updated existing pull-based consumer")
+	return info, nil
+}
@@ -0,0 +1,68 @@
+package nats_test
+
+import (
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffConsumer(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	base := natsgo.ConsumerConfig{
+		Durable:       "audit",
+		DeliverPolicy: natsgo.DeliverAllPolicy,
+		ReplayPolicy:  natsgo.ReplayInstantPolicy,
+		AckPolicy:     natsgo.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxDeliver:    3,
+		FilterSubject: "events.>",
+	}
+
+	t.Run("identical", func(t *testing.T) {
+		desired := base
+		diff := nats.DiffConsumer(&base, &desired)
+		assert.True(t, diff.Empty())
+	})
+
+	t.Run("equal start times in different zones", func(t *testing.T) {
+		existing := base
+		existing.DeliverPolicy = natsgo.DeliverByStartTimePolicy
+		existing.OptStartTime = &start
+		desired := existing
+		local := start.In(time.FixedZone("UTC+3", 3*60*60))
+		desired.OptStartTime = &local
+
+		assert.True(t, nats.DiffConsumer(&existing, &desired).Empty())
+	})
+
+	t.Run("editable only", func(t *testing.T) {
+		desired := base
+		desired.AckWait = time.Minute
+		desired.MaxDeliver = 5
+
+		diff := nats.DiffConsumer(&base, &desired)
+		assert.Empty(t, diff.Fixed)
+		assert.Equal(t, "30s -> 1m0s", diff.Editable["ack_wait"])
+		assert.Equal(t, "3 -> 5", diff.Editable["max_deliver"])
+	})
+
+	t.Run("fixed", func(t *testing.T) {
+		desired := base
+		desired.FilterSubject = "audit.>"
+		desired.DeliverPolicy = natsgo.DeliverByStartSequencePolicy
+		desired.OptStartSeq = 42
+		desired.ReplayPolicy = natsgo.ReplayOriginalPolicy
+
+		diff := nats.DiffConsumer(&base, &desired)
+		assert.Empty(t, diff.Editable)
+		assert.Equal(t, "events.> -> audit.>", diff.Fixed["filter_subject"])
+		assert.Equal(t, "all -> by-start-sequence", diff.Fixed["deliver_policy"])
+		assert.Equal(t, "0 -> 42", diff.Fixed["opt_start_seq"])
+		assert.Equal(t, "instant -> original", diff.Fixed["replay_policy"])
+		assert.NotContains(t, diff.Fixed, "opt_start_time")
+	})
+}
@@ -0,0 +1,234 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers attached to dead-lettered messages.
+const (
+	HeaderOriginalSubject  = "Audit-Original-Subject"
+	HeaderOriginalStream   = "Audit-Original-Stream"
+	HeaderOriginalSequence = "Audit-Original-Sequence"
+	HeaderDeliveryCount    = "Audit-Delivery-Count"
+	HeaderError            = "Audit-Error"
+	HeaderDeadLetteredAt   = "Audit-Dead-Lettered-At"
+	HeaderRedrivenFrom     = "Audit-Redriven-From"
+)
+
+// deadLetterHeaders are stripped from a message when it is re-driven.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var deadLetterHeaders = []string{
+	HeaderOriginalSubject,
+	HeaderOriginalStream,
+	HeaderOriginalSequence,
+	HeaderDeliveryCount,
+	HeaderError,
+	HeaderDeadLetteredAt,
+	nats.MsgIdHdr,
+}
+
+// DeadLetter is a message stored in the dead-letter stream.
+type DeadLetter struct {
+	Sequence         uint64
+	Subject          string
+	OriginalSubject  string
+	OriginalStream   string
+	OriginalSequence uint64
+	Delivered        uint64
+	Error            string
+	DeadLetteredAt   time.Time
+	Header           nats.Header
+	Data             []byte
+}
+
+// deadLetterFromRaw decodes the dead-letter headers of a stored message.
+func deadLetterFromRaw(raw *nats.RawStreamMsg) *DeadLetter {
+	dl := &DeadLetter{
+		Sequence:        raw.Sequence,
+		Subject:         raw.Subject,
+		OriginalSubject: raw.Header.Get(HeaderOriginalSubject),
+		OriginalStream:  raw.Header.Get(HeaderOriginalStream),
+		Error:           raw.Header.Get(HeaderError),
+		Header:          raw.Header,
+		Data:            raw.Data,
+	}
+	dl.OriginalSequence, _ = strconv.ParseUint(raw.Header.Get(HeaderOriginalSequence), 10, 64)
+	dl.Delivered, _ = strconv.ParseUint(raw.Header.Get(HeaderDeliveryCount), 10, 64)
+	dl.DeadLetteredAt, _ = time.Parse(time.RFC3339Nano, raw.Header.Get(HeaderDeadLetteredAt))
+	return dl
+}
+
+// deadLetterSubject returns the dead-letter subject for an original subject.
+func (c *Client) deadLetterSubject(subject string) string {
+	return c.config.DeadLetterSubject + "." + subject
+}
+
+// ensureDeadLetterStream creates or updates the dead-letter stream.
+func (c *Client) ensureDeadLetterStream() error {
+	return c.ensureSideStream("dead-letter", c.config.DeadLetterStream, c.deadLetterSubject(">"), c.config.DeadLetterMaxAge)
+}
+
+// ensureSideStream creates or updates a stream holding messages set aside
+// from the audit stream, such as dead-lettered or quarantined ones. Only its
+// max age is reconciled.
+func (c *Client) ensureSideStream(kind, name, subject string, maxAge time.Duration) error {
+	streamConfig := &nats.StreamConfig{
+		Name:      name,
+		Subjects:  []string{subject},
+		MaxAge:    maxAge,
+		Replicas:  c.config.StreamReplicas,
+		Storage:   nats.FileStorage,
+		Retention: nats.LimitsPolicy,
+		Discard:   nats.DiscardOld,
+	}
+
+	streamInfo, err := c.js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if _, addErr := c.js.AddStream(streamConfig); addErr != nil {
+			return fmt.Errorf("failed to create %s stream %s: %w", kind, name, addErr)
+		}
+
+		c.logger.WithFields(logrus.Fields{
+			"stream":   name,
+			"subjects": streamConfig.Subjects,
+		}).Infof("Created JetStream %s stream", kind)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s stream info: %w", kind, err)
+	}
+
+	if streamInfo.Config.MaxAge != streamConfig.MaxAge {
+		if _, updateErr := c.js.UpdateStream(streamConfig); updateErr != nil {
+			return fmt.Errorf("failed to update %s stream %s: %w", kind, name, updateErr)
+		}
+		c.logger.WithField("stream", name).Infof("Updated JetStream %s stream", kind)
+	}
+
+	return nil
+}
+
+// publishDeadLetter republishes a terminally failed message to the
+// dead-letter stream with its original subject, headers and failure details.
+func (c *Client) publishDeadLetter(msg *nats.Msg, meta *nats.MsgMetadata, handlerErr error) error {
+	dlq := nats.NewMsg(c.deadLetterSubject(msg.Subject))
+	for k, v := range msg.Header {
+		dlq.Header[k] = append([]string(nil), v...)
+	}
+	dlq.Data = msg.Data
+
+	dlq.Header.Set(HeaderOriginalSubject, msg.Subject)
+	dlq.Header.Set(HeaderOriginalStream, meta.Stream)
+	dlq.Header.Set(HeaderOriginalSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	dlq.Header.Set(HeaderDeliveryCount, strconv.FormatUint(meta.NumDelivered, 10))
+	dlq.Header.Set(HeaderError, handlerErr.Error())
+	dlq.Header.Set(HeaderDeadLetteredAt, time.Now().UTC().Format(time.RFC3339Nano))
+
+	// Deduplicate repeated attempts for the same original message.
+	msgID := fmt.Sprintf("%s:%d", meta.Stream, meta.Sequence.Stream)
+	ack, err := c.js.PublishMsg(dlq, nats.MsgId(msgID))
+	if err != nil {
+		return fmt.Errorf("failed to publish to dead-letter stream: %w", err)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"subject":         msg.Subject,
+		"dlq_stream":      ack.Stream,
+		"dlq_sequence":    ack.Sequence,
+		"stream_sequence": meta.Sequence.Stream,
+		"delivered":       meta.NumDelivered,
+	}).Warn("Message moved to dead-letter stream")
+
+	return nil
+}
+
+// ListDeadLetters returns up to limit dead-lettered messages starting at
+// sequence from. A limit of zero returns all of them.
+func (c *Client) ListDeadLetters(from uint64, limit int) ([]*DeadLetter, error) {
+	if c.js == nil {
+		return nil, errors.New("JetStream context not initialized")
+	}
+
+	info, err := c.js.StreamInfo(c.config.DeadLetterStream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter stream info: %w", err)
+	}
+
+	var letters []*DeadLetter
+	for seq := max(from, info.State.FirstSeq); seq <= info.State.LastSeq; seq++ {
+		if limit > 0 && len(letters) >= limit {
+			break
+		}
+
+		raw, getErr := c.js.GetMsg(c.config.DeadLetterStream, seq)
+		if errors.Is(getErr, nats.ErrMsgNotFound) {
+			continue
+		}
+		if getErr != nil {
+			return letters, fmt.Errorf("failed to get dead-letter message %d: %w", seq, getErr)
+		}
+		letters = append(letters, deadLetterFromRaw(raw))
+	}
+
+	return letters, nil
+}
+
+// GetDeadLetter returns a single dead-lettered message.
+func (c *Client) GetDeadLetter(seq uint64) (*DeadLetter, error) {
+	if c.js == nil {
+		return nil, errors.New("JetStream context not initialized")
+	}
+
+	raw, err := c.js.GetMsg(c.config.DeadLetterStream, seq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter message %d: %w", seq, err)
+	}
+	return deadLetterFromRaw(raw), nil
+}
+
+// RedriveDeadLetter republishes a dead-lettered message to its original
+// subject and removes it from the dead-letter stream.
+func (c *Client) RedriveDeadLetter(seq uint64) (*nats.PubAck, error) {
+	dl, err := c.GetDeadLetter(seq)
+	if err != nil {
+		return nil, err
+	}
+	if dl.OriginalSubject == "" {
+		return nil, fmt.Errorf("dead-letter message %d has no %s header", seq, HeaderOriginalSubject)
+	}
+
+	msg := nats.NewMsg(dl.OriginalSubject)
+	for k, v := range dl.Header {
+		msg.Header[k] = v
+	}
+	for _, h := range deadLetterHeaders {
+		msg.Header.Del(h)
+	}
+	msg.Header.Set(HeaderRedrivenFrom, c.config.DeadLetterStream+":"+strconv.FormatUint(seq, 10))
+	msg.Data = dl.Data
+
+	ack, err := c.js.PublishMsg(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redrive dead-letter message %d: %w", seq, err)
+	}
+
+	if deleteErr := c.js.DeleteMsg(c.config.DeadLetterStream, seq); deleteErr != nil {
+		return ack, fmt.Errorf("redriven dead-letter message %d could not be deleted: %w", seq, deleteErr)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"dlq_sequence": seq,
+		"subject":      dl.OriginalSubject,
+		"stream":       ack.Stream,
+		"sequence":     ack.Sequence,
+	}).Info("Redrove dead-letter message")
+
+	return ack, nil
+}
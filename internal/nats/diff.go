@@ -0,0 +1,72 @@
+package nats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConfigDiff lists the settings of an existing stream or consumer that
+// differ from the desired configuration, rendered as "existing -> desired".
+type ConfigDiff struct {
+	// Editable settings can be changed in place.
+	Editable logrus.Fields
+	// Fixed settings can only be changed by recreating the stream or consumer.
+	Fixed logrus.Fields
+}
+
+// Empty reports whether the existing configuration matches.
+func (d ConfigDiff) Empty() bool {
+	return len(d.Editable) == 0 && len(d.Fixed) == 0
+}
+
+// Fields returns all differing settings for structured logging.
+func (d ConfigDiff) Fields() logrus.Fields {
+	fields := make(logrus.Fields, len(d.Editable)+len(d.Fixed))
+	for k, v := range d.Editable {
+		fields[k] = v
+	}
+	for k, v := range d.Fixed {
+		fields[k] = v
+	}
+	return fields
+}
+
+func newConfigDiff() ConfigDiff {
+	return ConfigDiff{
+		Editable: logrus.Fields{},
+		Fixed:    logrus.Fields{},
+	}
+}
+
+// diffKeys returns the sorted setting names of a diff.
+func diffKeys(fields logrus.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// fieldDiff renders an existing and desired value pair for logging.
+func fieldDiff(existing, desired any) string {
+	return fmt.Sprintf("%v -> %v", existing, desired)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unset"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
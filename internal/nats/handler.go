@@ -1,25 +1,42 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/nats-io/nats.go"
+	"events-audit/internal/cloudevent"
+	"events-audit/internal/metrics"
+	"events-audit/internal/redact"
+	"events-audit/internal/sink"
+	"events-audit/internal/source"
+
 	"github.com/sirupsen/logrus"
 )
 
-// Event represents a generic event structure.
+// Event represents the legacy event structure used by producers that do not
+// send CloudEvents.
 type Event struct {
 	ID        string                 `json:"id"`
 	Type      string                 `json:"type"`
 	Source    string                 `json:"source"`
+	Version   string                 `json:"version,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
 	Data      map[string]interface{} `json:"data"`
 }
 
 // EventLogger handles and logs events from NATS JetStream.
 type EventLogger struct {
-	logger *logrus.Logger
+	logger  *logrus.Logger
+	metrics *metrics.Metrics
+	schemas *SchemaPolicy
+	// redactor rewrites event data before it is logged.
+	redactor *redact.Engine
+	// router delivers handled events to the output sinks; events are only
+	// logged when it is nil.
+	router *sink.Router
 }
 
 // NewEventLogger creates a new event logger.
@@ -33,8 +50,54 @@ func NewEventLogger(logger *logrus.Logger) *EventLogger {
 	}
 }
 
-// HandleEvent processes incoming JetStream messages and logs them.
-func (el *EventLogger) HandleEvent(msg *nats.Msg) error {
+// SetMetrics sets the collectors the event logger reports to.
+func (el *EventLogger) SetMetrics(m *metrics.Metrics) {
+	el.metrics = m
+}
+
+// SetSchemaPolicy enables validation of event data against a schema
+// registry. It must be called before events are handled.
+func (el *EventLogger) SetSchemaPolicy(policy SchemaPolicy) {
+	el.schemas = &policy
+}
+
+// SetRedactor enables redaction of event data before it is logged. Schema
+// validation still sees the original data.
+func (el *EventLogger) SetRedactor(engine *redact.Engine) {
+	el.redactor = engine
+}
+
+// SetRouter delivers handled events to output sinks instead of logging
+// them. A failure of a mandatory sink is returned as the handler error.
+func (el *EventLogger) SetRouter(router *sink.Router) {
+	el.router = router
+}
+
+// emit outputs a handled event: it is delivered to the sinks when a router
+// is set and logged otherwise.
+func (el *EventLogger) emit(subject string, level logrus.Level, message string, fields logrus.Fields) error {
+	if el.router == nil {
+		el.logger.WithFields(fields).Log(level, message)
+		return nil
+	}
+
+	eventType, _ := fields["event_type"].(string)
+	return el.router.Deliver(context.Background(), sink.Event{
+		Time:    time.Now(),
+		Subject: subject,
+		Type:    eventType,
+		Level:   level,
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// HandleEvent processes incoming JetStream messages and logs them. CloudEvents
+// in structured or binary mode are validated and logged with their
+// attributes; other JSON is logged as a legacy Event and anything else as a
+// raw message. With a schema policy, event data is also validated against
+// the schema of the event type.
+func (el *EventLogger) HandleEvent(msg *source.Msg) error {
 	// Base fields for all log entries
 	baseFields := logrus.Fields{
 		"subject":   msg.Subject,
@@ -54,13 +117,39 @@ func (el *EventLogger) HandleEvent(msg *nats.Msg) error {
 		baseFields["js_timestamp"] = meta.Timestamp.Format(time.RFC3339)
 	}
 
+	ce, ceErr := cloudevent.Parse(msg.Header, msg.Data)
+	if ceErr == nil {
+		fields := cloudEventFields(baseFields, ce)
+		el.redactCloudEvent(ce.Type, fields)
+		if el.schemas != nil {
+			if validationErr := el.validateCloudEvent(ce); validationErr != nil {
+				return el.rejectEvent(msg, fields, validationErr)
+			}
+		}
+		return el.emit(msg.Subject, logrus.InfoLevel, "Received CloudEvent", fields)
+	}
+	if !errors.Is(ceErr, cloudevent.ErrNotCloudEvent) {
+		el.logger.WithFields(baseFields).WithError(ceErr).Error("Received invalid CloudEvent")
+		return fmt.Errorf("invalid CloudEvent: %w", ceErr)
+	}
+
+	if el.schemas != nil && isJSONObject(msg.Data) {
+		fields := make(logrus.Fields, len(baseFields)+2)
+		for k, v := range baseFields {
+			fields[k] = v
+		}
+		if validationErr := el.validateLegacy(msg.Data, fields); validationErr != nil {
+			return el.rejectEvent(msg, fields, validationErr)
+		}
+	}
+
 	// Try to parse as JSON event
 	var event Event
 	if parseErr := json.Unmarshal(msg.Data, &event); parseErr != nil {
 		// If not JSON, log as raw message
-		baseFields["raw_data"] = string(msg.Data)
-		el.logger.WithFields(baseFields).Info("Received raw event")
-		return nil //nolint:nilerr // raw messages are not errors, just log them
+		baseFields["raw_data"] = el.redactor.RedactString("", string(msg.Data))
+		// Raw messages are not errors, just output them
+		return el.emit(msg.Subject, logrus.InfoLevel, "Received raw event", baseFields)
 	}
 
 	// Log structured event with additional event fields
@@ -71,19 +160,99 @@ func (el *EventLogger) HandleEvent(msg *nats.Msg) error {
 	eventFields["event_id"] = event.ID
 	eventFields["event_type"] = event.Type
 	eventFields["source"] = event.Source
+	if event.Version != "" {
+		eventFields["event_version"] = event.Version
+	}
 	eventFields["event_timestamp"] = event.Timestamp.Format(time.RFC3339)
-	eventFields["event_data"] = event.Data
+	eventFields["event_data"] = el.redactLegacy(event, msg.Data)
+
+	return el.emit(msg.Subject, logrus.InfoLevel, "Received structured event", eventFields)
+}
+
+// cloudEventFields returns the log fields of a CloudEvent with its context
+// attributes.
+func cloudEventFields(baseFields logrus.Fields, ce *cloudevent.Event) logrus.Fields {
+	fields := make(logrus.Fields, len(baseFields)+10)
+	for k, v := range baseFields {
+		fields[k] = v
+	}
+	fields["event_id"] = ce.ID
+	fields["event_type"] = ce.Type
+	fields["source"] = ce.Source
+	fields["specversion"] = ce.SpecVersion
+	fields["content_mode"] = ce.Mode
+	if ce.Subject != "" {
+		fields["event_subject"] = ce.Subject
+	}
+	if ce.DataContentType != "" {
+		fields["datacontenttype"] = ce.DataContentType
+	}
+	if ce.DataSchema != "" {
+		fields["dataschema"] = ce.DataSchema
+	}
+	if ce.Time != nil {
+		fields["event_timestamp"] = ce.Time.Format(time.RFC3339)
+	}
+	if len(ce.Extensions) > 0 {
+		fields["extensions"] = ce.Extensions
+	}
+
+	var data any
+	if ce.IsJSON() && json.Unmarshal(ce.Data, &data) == nil {
+		fields["event_data"] = data
+	} else {
+		fields["event_data"] = string(ce.Data)
+	}
 
-	el.logger.WithFields(eventFields).Info("Received structured event")
+	return fields
+}
+
+// redactLegacy returns the redacted data of a legacy event. Rules address
+// the event as it was published, with its payload under $.data.
+func (el *EventLogger) redactLegacy(event Event, raw []byte) any {
+	if el.redactor == nil {
+		return event.Data
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return event.Data
+	}
+	redacted, _ := el.redactor.Redact(event.Type, doc).(map[string]any)
+	return redacted["data"]
+}
+
+// redactCloudEvent redacts the data and extensions of CloudEvent log fields.
+// Rules address the data under $.data and extensions by their name.
+func (el *EventLogger) redactCloudEvent(eventType string, fields logrus.Fields) {
+	if el.redactor == nil {
+		return
+	}
+	doc := map[string]any{"data": fields["event_data"]}
+	extensions, _ := fields["extensions"].(map[string]any)
+	for name, value := range extensions {
+		doc[name] = value
+	}
 
-	return nil
+	redacted, _ := el.redactor.Redact(eventType, doc).(map[string]any)
+	fields["event_data"] = redacted["data"]
+	if len(extensions) == 0 {
+		return
+	}
+	// The extensions of the parsed event are left untouched for validation.
+	kept := make(map[string]any, len(extensions))
+	for name := range extensions {
+		if value, ok := redacted[name]; ok {
+			kept[name] = value
+		}
+	}
+	fields["extensions"] = kept
 }
 
 // HandleRawEvent processes raw messages without JSON parsing.
-func (el *EventLogger) HandleRawEvent(msg *nats.Msg) error {
+func (el *EventLogger) HandleRawEvent(msg *source.Msg) error {
 	fields := logrus.Fields{
 		"subject":   msg.Subject,
-		"data":      string(msg.Data),
+		"data":      el.redactor.RedactString("", string(msg.Data)),
 		"data_size": len(msg.Data),
 		"reply":     msg.Reply,
 		"timestamp": time.Now().Format(time.RFC3339),
@@ -101,13 +270,11 @@ func (el *EventLogger) HandleRawEvent(msg *nats.Msg) error {
 		fields["js_timestamp"] = meta.Timestamp.Format(time.RFC3339)
 	}
 
-	el.logger.WithFields(fields).Info("Received raw event")
-
-	return nil
+	return el.emit(msg.Subject, logrus.InfoLevel, "Received raw event", fields)
 }
 
 // HandleEventWithCustomFields allows custom field extraction from messages.
-func (el *EventLogger) HandleEventWithCustomFields(msg *nats.Msg) error {
+func (el *EventLogger) HandleEventWithCustomFields(msg *source.Msg) error {
 	fields := logrus.Fields{
 		"subject":   msg.Subject,
 		"data_size": len(msg.Data),
@@ -130,15 +297,16 @@ func (el *EventLogger) HandleEventWithCustomFields(msg *nats.Msg) error {
 	// Try to extract additional fields from JSON
 	var jsonData map[string]interface{}
 	if parseErr := json.Unmarshal(msg.Data, &jsonData); parseErr == nil {
+		eventType, _ := jsonData["type"].(string)
+		jsonData, _ = el.redactor.Redact(eventType, jsonData).(map[string]interface{})
 		// Add JSON fields to log
 		for k, v := range jsonData {
 			fields[k] = v
 		}
 	} else {
 		// If not JSON, add raw data
-		fields["raw_data"] = string(msg.Data)
+		fields["raw_data"] = el.redactor.RedactString("", string(msg.Data))
 	}
 
-	el.logger.WithFields(fields).Info("Received event with custom fields")
-	return nil
+	return el.emit(msg.Subject, logrus.InfoLevel, "Received event with custom fields", fields)
 }
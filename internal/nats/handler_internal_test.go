@@ -1,34 +1,35 @@
 package nats_test
 
 import (
-	"context"
 	"encoding/json"
 	"testing"
 	"time"
 
+	"events-audit/internal/embedded"
 	"events-audit/internal/nats"
+	"events-audit/internal/source"
 
 	natsclient "github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/testcontainers/testcontainers-go"
-	natscontainer "github.com/testcontainers/testcontainers-go/modules/nats"
 )
 
-func setupNATSContainer(t *testing.T) (testcontainers.Container, string) {
-	ctx := context.Background()
+// startNATS runs an embedded NATS server with JetStream for the test and
+// returns its client URL.
+func startNATS(t *testing.T) string {
+	t.Helper()
 
-	natsContainer, err := natscontainer.RunContainer(ctx,
-		testcontainers.WithImage("nats:2.10-alpine"),
-	)
-	require.NoError(t, err, "Failed to start NATS container")
+	logger, _ := test.NewNullLogger()
+	srv, err := embedded.Start(embedded.Config{
+		Addr:     "127.0.0.1:-1",
+		StoreDir: t.TempDir(),
+	}, logger)
+	require.NoError(t, err, "Failed to start embedded NATS server")
+	t.Cleanup(srv.Shutdown)
 
-	connectionString, err := natsContainer.ConnectionString(ctx)
-	require.NoError(t, err, "Failed to get NATS connection string")
-
-	return natsContainer, connectionString
+	return srv.ClientURL()
 }
 
 func TestEventLogger_HandleEvent(t *testing.T) {
@@ -100,7 +101,7 @@ func runHandleEventTest(
 	logger, hook := test.NewNullLogger()
 	eventLogger := nats.NewEventLogger(logger)
 
-	msg := &natsclient.Msg{
+	msg := &source.Msg{
 		Subject: "test.subject",
 		Data:    messageData,
 		Reply:   "test.reply",
@@ -120,6 +121,86 @@ func runHandleEventTest(
 	}
 }
 
+func TestEventLogger_HandleCloudEvent(t *testing.T) {
+	structured := []byte(`{"specversion":"1.0","id":"ce-1","source":"/users","type":"user.created",` +
+		`"subject":"user/42","dataschema":"https://schemas.example.com/user.json","tenant":"acme","data":{"user_id":"42"}}`)
+	binary := &source.Msg{
+		Subject: "test.subject",
+		Header: source.Header{
+			"Ce-Specversion": {"1.0"},
+			"Ce-Id":          {"ce-2"},
+			"Ce-Source":      {"urn:billing"},
+			"Ce-Type":        {"invoice.paid"},
+			"Ce-Tenant":      {"acme"},
+			"Content-Type":   {"text/plain"},
+		},
+		Data: []byte("paid"),
+	}
+
+	tests := []struct {
+		name     string
+		msg      *source.Msg
+		expected map[string]interface{}
+	}{
+		{
+			name: "structured mode",
+			msg:  &source.Msg{Subject: "test.subject", Data: structured},
+			expected: map[string]interface{}{
+				"event_id":      "ce-1",
+				"event_type":    "user.created",
+				"source":        "/users",
+				"specversion":   "1.0",
+				"content_mode":  "structured",
+				"event_subject": "user/42",
+				"dataschema":    "https://schemas.example.com/user.json",
+				"extensions":    map[string]any{"tenant": "acme"},
+				"event_data":    map[string]any{"user_id": "42"},
+			},
+		},
+		{
+			name: "binary mode",
+			msg:  binary,
+			expected: map[string]interface{}{
+				"event_id":        "ce-2",
+				"event_type":      "invoice.paid",
+				"source":          "urn:billing",
+				"content_mode":    "binary",
+				"datacontenttype": "text/plain",
+				"extensions":      map[string]any{"tenant": "acme"},
+				"event_data":      "paid",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			require.NoError(t, nats.NewEventLogger(logger).HandleEvent(tt.msg))
+
+			require.Len(t, hook.Entries, 1)
+			entry := hook.Entries[0]
+			assert.Equal(t, "Received CloudEvent", entry.Message)
+			for key, value := range tt.expected {
+				assert.Equal(t, value, entry.Data[key], key)
+			}
+		})
+	}
+}
+
+func TestEventLogger_RejectsInvalidCloudEvent(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	msg := &source.Msg{
+		Subject: "test.subject",
+		Data:    []byte(`{"specversion":"1.0","id":"ce-1","type":"user.created"}`),
+	}
+
+	err := nats.NewEventLogger(logger).HandleEvent(msg)
+	require.ErrorContains(t, err, "missing required attributes: source")
+
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, logrus.ErrorLevel, hook.Entries[0].Level)
+}
+
 func TestEventLogger_HandleRawEvent(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -158,7 +239,7 @@ func runHandleRawEventTest(t *testing.T, messageData []byte, subject, reply stri
 	logger, hook := test.NewNullLogger()
 	eventLogger := nats.NewEventLogger(logger)
 
-	msg := &natsclient.Msg{
+	msg := &source.Msg{
 		Subject: subject,
 		Data:    messageData,
 		Reply:   reply,
@@ -232,7 +313,7 @@ func runCustomFieldsTest(t *testing.T, messageData []byte, expectedFields []stri
 	logger, hook := test.NewNullLogger()
 	eventLogger := nats.NewEventLogger(logger)
 
-	msg := &natsclient.Msg{
+	msg := &source.Msg{
 		Subject: "test.subject",
 		Data:    messageData,
 		Reply:   "test.reply",
@@ -266,10 +347,7 @@ func TestNewEventLogger(t *testing.T) {
 }
 
 func TestEventLogger_IntegrationWithNATS(t *testing.T) {
-	natsContainer, connectionString := setupNATSContainer(t)
-	defer func() {
-		assert.NoError(t, natsContainer.Terminate(context.Background()))
-	}()
+	connectionString := startNATS(t)
 
 	nc, err := natsclient.Connect(connectionString)
 	require.NoError(t, err)
@@ -284,7 +362,7 @@ func TestEventLogger_IntegrationWithNATS(t *testing.T) {
 
 	subject := "test.integration"
 	sub, err := nc.Subscribe(subject, func(msg *natsclient.Msg) {
-		handlerErr := eventLogger.HandleEvent(msg)
+		handlerErr := eventLogger.HandleEvent(&source.Msg{Subject: msg.Subject, Header: source.Header(msg.Header), Data: msg.Data})
 		assert.NoError(t, handlerErr)
 	})
 	require.NoError(t, err)
@@ -295,10 +373,10 @@ func TestEventLogger_IntegrationWithNATS(t *testing.T) {
 	err = nc.Publish(subject, eventData)
 	require.NoError(t, err)
 
-	time.Sleep(100 * time.Millisecond)
-
-	assert.Len(t, hook.Entries, 1)
-	entry := hook.Entries[0]
+	require.Eventually(t, func() bool {
+		return len(hook.AllEntries()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	entry := hook.AllEntries()[0]
 	assert.Equal(t, logrus.InfoLevel, entry.Level)
 	assert.Equal(t, subject, entry.Data["subject"])
 	assert.Equal(t, testEvent.ID, entry.Data["event_id"])
@@ -320,10 +398,7 @@ func createIntegrationTestEvent() nats.Event {
 }
 
 func TestEventLogger_IntegrationWithRawMessage(t *testing.T) {
-	natsContainer, connectionString := setupNATSContainer(t)
-	defer func() {
-		assert.NoError(t, natsContainer.Terminate(context.Background()))
-	}()
+	connectionString := startNATS(t)
 
 	nc, err := natsclient.Connect(connectionString)
 	require.NoError(t, err)
@@ -336,7 +411,7 @@ func TestEventLogger_IntegrationWithRawMessage(t *testing.T) {
 	subject := "test.raw.integration"
 
 	sub, err := nc.Subscribe(subject, func(msg *natsclient.Msg) {
-		handlerErr := eventLogger.HandleRawEvent(msg)
+		handlerErr := eventLogger.HandleRawEvent(&source.Msg{Subject: msg.Subject, Header: source.Header(msg.Header), Data: msg.Data})
 		assert.NoError(t, handlerErr)
 	})
 	require.NoError(t, err)
@@ -347,10 +422,10 @@ func TestEventLogger_IntegrationWithRawMessage(t *testing.T) {
 	err = nc.Publish(subject, []byte(rawMessage))
 	require.NoError(t, err)
 
-	time.Sleep(100 * time.Millisecond)
-
-	assert.Len(t, hook.Entries, 1)
-	entry := hook.Entries[0]
+	require.Eventually(t, func() bool {
+		return len(hook.AllEntries()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	entry := hook.AllEntries()[0]
 	assert.Equal(t, logrus.InfoLevel, entry.Level)
 	assert.Equal(t, subject, entry.Data["subject"])
 	assert.Equal(t, rawMessage, entry.Data["data"])
@@ -374,7 +449,7 @@ func BenchmarkEventLogger_HandleEvent(b *testing.B) {
 	}
 	eventData, _ := json.Marshal(event)
 
-	msg := &natsclient.Msg{
+	msg := &source.Msg{
 		Subject: "benchmark.subject",
 		Data:    eventData,
 		Reply:   "",
@@ -391,7 +466,7 @@ func BenchmarkEventLogger_HandleRawEvent(b *testing.B) {
 	logger.SetLevel(logrus.ErrorLevel)
 	eventLogger := nats.NewEventLogger(logger)
 
-	msg := &natsclient.Msg{
+	msg := &source.Msg{
 		Subject: "benchmark.subject",
 		Data:    []byte("benchmark raw jetstream message"),
 		Reply:   "",
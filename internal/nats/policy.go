@@ -0,0 +1,125 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Deliver policy names accepted on the command line.
+const (
+	DeliverAll             = "all"
+	DeliverNew             = "new"
+	DeliverLast            = "last"
+	DeliverLastPerSubject  = "last-per-subject"
+	DeliverByStartSequence = "by-start-sequence"
+	DeliverByStartTime     = "by-start-time"
+)
+
+// Replay policy names accepted on the command line.
+const (
+	ReplayInstant  = "instant"
+	ReplayOriginal = "original"
+)
+
+// ParseDeliverPolicy converts a command line deliver policy name.
+func ParseDeliverPolicy(name string) (nats.DeliverPolicy, error) {
+	switch name {
+	case DeliverAll, "":
+		return nats.DeliverAllPolicy, nil
+	case DeliverNew:
+		return nats.DeliverNewPolicy, nil
+	case DeliverLast:
+		return nats.DeliverLastPolicy, nil
+	case DeliverLastPerSubject:
+		return nats.DeliverLastPerSubjectPolicy, nil
+	case DeliverByStartSequence:
+		return nats.DeliverByStartSequencePolicy, nil
+	case DeliverByStartTime:
+		return nats.DeliverByStartTimePolicy, nil
+	default:
+		return 0, fmt.Errorf("unknown deliver policy %q, supported %s, %s, %s, %s, %s, %s", name,
+			DeliverAll, DeliverNew, DeliverLast, DeliverLastPerSubject, DeliverByStartSequence, DeliverByStartTime)
+	}
+}
+
+// ParseReplayPolicy converts a command line replay policy name.
+func ParseReplayPolicy(name string) (nats.ReplayPolicy, error) {
+	switch name {
+	case ReplayInstant, "":
+		return nats.ReplayInstantPolicy, nil
+	case ReplayOriginal:
+		return nats.ReplayOriginalPolicy, nil
+	default:
+		return 0, fmt.Errorf("unknown replay policy %q, supported %s, %s", name, ReplayInstant, ReplayOriginal)
+	}
+}
+
+// DeliverPolicyName returns the command line name of a deliver policy.
+func DeliverPolicyName(policy nats.DeliverPolicy) string {
+	switch policy {
+	case nats.DeliverAllPolicy:
+		return DeliverAll
+	case nats.DeliverNewPolicy:
+		return DeliverNew
+	case nats.DeliverLastPolicy:
+		return DeliverLast
+	case nats.DeliverLastPerSubjectPolicy:
+		return DeliverLastPerSubject
+	case nats.DeliverByStartSequencePolicy:
+		return DeliverByStartSequence
+	case nats.DeliverByStartTimePolicy:
+		return DeliverByStartTime
+	default:
+		return fmt.Sprintf("unknown(%d)", policy)
+	}
+}
+
+// ReplayPolicyName returns the command line name of a replay policy.
+func ReplayPolicyName(policy nats.ReplayPolicy) string {
+	switch policy {
+	case nats.ReplayInstantPolicy:
+		return ReplayInstant
+	case nats.ReplayOriginalPolicy:
+		return ReplayOriginal
+	default:
+		return fmt.Sprintf("unknown(%d)", policy)
+	}
+}
+
+// ValidateStartPosition checks that the start sequence and start time are
+// set exactly when the deliver policy requires them.
+func ValidateStartPosition(policy nats.DeliverPolicy, startSeq uint64, startTime time.Time) error {
+	switch policy {
+	case nats.DeliverByStartSequencePolicy:
+		if startSeq == 0 {
+			return errors.New("deliver policy by-start-sequence requires a start sequence")
+		}
+		if !startTime.IsZero() {
+			return errors.New("deliver policy by-start-sequence does not accept a start time")
+		}
+	case nats.DeliverByStartTimePolicy:
+		if startTime.IsZero() {
+			return errors.New("deliver policy by-start-time requires a start time")
+		}
+		if startSeq != 0 {
+			return errors.New("deliver policy by-start-time does not accept a start sequence")
+		}
+	case nats.DeliverAllPolicy, nats.DeliverLastPolicy, nats.DeliverNewPolicy, nats.DeliverLastPerSubjectPolicy:
+		if startSeq != 0 || !startTime.IsZero() {
+			return fmt.Errorf("deliver policy %s does not accept a start sequence or start time", DeliverPolicyName(policy))
+		}
+	}
+	return nil
+}
+
+// startTimePtr returns nil for the zero time so it is omitted from the
+// consumer configuration.
+func startTimePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,87 @@
+package nats_test
+
+import (
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeliverPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected natsgo.DeliverPolicy
+	}{
+		{name: "", expected: natsgo.DeliverAllPolicy},
+		{name: "all", expected: natsgo.DeliverAllPolicy},
+		{name: "new", expected: natsgo.DeliverNewPolicy},
+		{name: "last", expected: natsgo.DeliverLastPolicy},
+		{name: "last-per-subject", expected: natsgo.DeliverLastPerSubjectPolicy},
+		{name: "by-start-sequence", expected: natsgo.DeliverByStartSequencePolicy},
+		{name: "by-start-time", expected: natsgo.DeliverByStartTimePolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := nats.ParseDeliverPolicy(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+			if tt.name != "" {
+				assert.Equal(t, tt.name, nats.DeliverPolicyName(policy))
+			}
+		})
+	}
+
+	_, err := nats.ParseDeliverPolicy("oldest")
+	require.Error(t, err)
+}
+
+func TestParseReplayPolicy(t *testing.T) {
+	policy, err := nats.ParseReplayPolicy("original")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.ReplayOriginalPolicy, policy)
+
+	policy, err = nats.ParseReplayPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.ReplayInstantPolicy, policy)
+
+	_, err = nats.ParseReplayPolicy("fast")
+	require.Error(t, err)
+}
+
+func TestValidateStartPosition(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		policy  natsgo.DeliverPolicy
+		seq     uint64
+		time    time.Time
+		wantErr bool
+	}{
+		{name: "all", policy: natsgo.DeliverAllPolicy},
+		{name: "new with sequence", policy: natsgo.DeliverNewPolicy, seq: 10, wantErr: true},
+		{name: "last with time", policy: natsgo.DeliverLastPolicy, time: start, wantErr: true},
+		{name: "by sequence", policy: natsgo.DeliverByStartSequencePolicy, seq: 10},
+		{name: "by sequence without sequence", policy: natsgo.DeliverByStartSequencePolicy, wantErr: true},
+		{name: "by sequence with time", policy: natsgo.DeliverByStartSequencePolicy, seq: 10, time: start, wantErr: true},
+		{name: "by time", policy: natsgo.DeliverByStartTimePolicy, time: start},
+		{name: "by time without time", policy: natsgo.DeliverByStartTimePolicy, wantErr: true},
+		{name: "by time with sequence", policy: natsgo.DeliverByStartTimePolicy, seq: 10, time: start, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := nats.ValidateStartPosition(tt.policy, tt.seq, tt.time)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Partition key specifications accepted on the command line.
+const (
+	PartitionSubject   = "subject"
+	PartitionJSONField = "json:"
+)
+
+// PartitionFunc returns the key of a message. Messages sharing a key are
+// handled in the order they were fetched, see WorkerPool for the limits of
+// that guarantee.
+type PartitionFunc func(msg *nats.Msg) string
+
+// SubjectPartition partitions messages by subject.
+func SubjectPartition(msg *nats.Msg) string {
+	return msg.Subject
+}
+
+// JSONFieldPartition partitions messages by a field of their JSON payload,
+// addressed by a dot separated path such as "data.actor_id". Messages
+// without the field fall back to their subject.
+func JSONFieldPartition(path string) PartitionFunc {
+	parts := strings.Split(path, ".")
+	return func(msg *nats.Msg) string {
+		var value any
+		if err := json.Unmarshal(msg.Data, &value); err != nil {
+			return msg.Subject
+		}
+		for _, part := range parts {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return msg.Subject
+			}
+			if value, ok = object[part]; !ok {
+				return msg.Subject
+			}
+		}
+		if value == nil {
+			return msg.Subject
+		}
+		return fmt.Sprint(value)
+	}
+}
+
+// ParsePartitionKey converts a command line partition key: "subject" or
+// "json:<path>".
+func ParsePartitionKey(spec string) (PartitionFunc, error) {
+	switch {
+	case spec == PartitionSubject || spec == "":
+		return SubjectPartition, nil
+	case strings.HasPrefix(spec, PartitionJSONField):
+		path := strings.TrimPrefix(spec, PartitionJSONField)
+		if path == "" || strings.Contains(path, "..") || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return nil, fmt.Errorf("invalid JSON field path in partition key %q", spec)
+		}
+		return JSONFieldPartition(path), nil
+	default:
+		return nil, fmt.Errorf("unknown partition key %q, supported %s or %s<path>", spec, PartitionSubject, PartitionJSONField)
+	}
+}
+
+// WorkerPool handles messages concurrently while keeping messages with the
+// same partition key in order: every key is pinned to one worker, which
+// handles its messages sequentially. The number of messages in flight is
+// bounded so that the caller never fetches more than it can acknowledge.
+//
+// The order is the order of delivery, not of the stream. A message that is
+// negatively acknowledged is redelivered by the server later, after the
+// worker has moved on to the following messages of its key, so a failed
+// message can be handled after messages published behind it. Handled
+// messages carry their stream sequence for consumers that need stream
+// order.
+type WorkerPool struct {
+	queues    []chan *nats.Msg
+	slots     chan struct{}
+	partition PartitionFunc
+	process   func(msg *nats.Msg)
+	wg        sync.WaitGroup
+}
+
+// NewWorkerPool starts workers handling dispatched messages with process.
+func NewWorkerPool(workers, maxInFlight int, partition PartitionFunc, process func(msg *nats.Msg)) *WorkerPool {
+	workers = max(workers, 1)
+	maxInFlight = max(maxInFlight, 1)
+	if partition == nil {
+		partition = SubjectPartition
+	}
+
+	p := &WorkerPool{
+		queues:    make([]chan *nats.Msg, workers),
+		slots:     make(chan struct{}, maxInFlight),
+		partition: partition,
+		process:   process,
+	}
+
+	for i := range p.queues {
+		// A worker never holds more messages than there are in-flight slots.
+		p.queues[i] = make(chan *nats.Msg, maxInFlight)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+
+	return p
+}
+
+func (p *WorkerPool) work(queue <-chan *nats.Msg) {
+	defer p.wg.Done()
+	for msg := range queue {
+		p.process(msg)
+		<-p.slots
+	}
+}
+
+// Acquire reserves up to n in-flight slots, blocking until at least one is
+// free. It returns the number of slots reserved.
+func (p *WorkerPool) Acquire(ctx context.Context, n int) (int, error) {
+	if n < 1 {
+		return 0, errors.New("at least one slot must be acquired")
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	acquired := 1
+	for acquired < n {
+		select {
+		case p.slots <- struct{}{}:
+			acquired++
+		default:
+			return acquired, nil
+		}
+	}
+	return acquired, nil
+}
+
+// Release returns n reserved slots that were not used for a message.
+func (p *WorkerPool) Release(n int) {
+	for range n {
+		<-p.slots
+	}
+}
+
+// Dispatch hands a message to the worker owning its partition key. A slot
+// must have been acquired for it; the worker releases it once the message
+// has been processed.
+func (p *WorkerPool) Dispatch(msg *nats.Msg) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(p.partition(msg)))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- msg //nolint:gosec // worker count is small and positive
+}
+
+// InFlight returns the number of reserved slots.
+func (p *WorkerPool) InFlight() int {
+	return len(p.slots)
+}
+
+// Close stops accepting messages and waits for dispatched ones to finish.
+func (p *WorkerPool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
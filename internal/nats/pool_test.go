@@ -0,0 +1,186 @@
+package nats_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartitionKey(t *testing.T) {
+	msg := &natsgo.Msg{
+		Subject: "events.user",
+		Data:    []byte(`{"id":"1","data":{"actor_id":42}}`),
+	}
+
+	partition, err := nats.ParsePartitionKey("subject")
+	require.NoError(t, err)
+	assert.Equal(t, "events.user", partition(msg))
+
+	partition, err = nats.ParsePartitionKey("json:data.actor_id")
+	require.NoError(t, err)
+	assert.Equal(t, "42", partition(msg))
+
+	for _, spec := range []string{"json:", "json:data..id", "json:.id", "actor"} {
+		_, err = nats.ParsePartitionKey(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestJSONFieldPartition_FallsBackToSubject(t *testing.T) {
+	partition := nats.JSONFieldPartition("data.actor_id")
+
+	for _, data := range []string{`not json`, `{"data":{}}`, `{"data":"flat"}`, `{"data":{"actor_id":null}}`} {
+		msg := &natsgo.Msg{Subject: "events.raw", Data: []byte(data)}
+		assert.Equal(t, "events.raw", partition(msg), data)
+	}
+}
+
+func TestWorkerPool_PreservesPartitionOrder(t *testing.T) {
+	const (
+		partitions = 8
+		perKey     = 200
+	)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+	)
+	pool := nats.NewWorkerPool(4, 16, nats.SubjectPartition, func(msg *natsgo.Msg) {
+		var n int
+		_, _ = fmt.Sscanf(string(msg.Data), "%d", &n)
+		mu.Lock()
+		seen[msg.Subject] = append(seen[msg.Subject], n)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	for i := range perKey {
+		for p := range partitions {
+			_, err := pool.Acquire(ctx, 1)
+			require.NoError(t, err)
+			pool.Dispatch(&natsgo.Msg{
+				Subject: fmt.Sprintf("events.%d", p),
+				Data:    []byte(fmt.Sprint(i)),
+			})
+		}
+	}
+	pool.Close()
+
+	require.Len(t, seen, partitions)
+	for subject, order := range seen {
+		require.Len(t, order, perKey, subject)
+		for i, n := range order {
+			require.Equal(t, i, n, "out of order message on %s", subject)
+		}
+	}
+}
+
+func TestWorkerPool_BoundsInFlight(t *testing.T) {
+	const maxInFlight = 5
+
+	var (
+		current atomic.Int32
+		peak    atomic.Int32
+	)
+	release := make(chan struct{})
+	pool := nats.NewWorkerPool(10, maxInFlight, nats.SubjectPartition, func(*natsgo.Msg) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		current.Add(-1)
+	})
+
+	ctx := context.Background()
+	acquired, err := pool.Acquire(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, maxInFlight, acquired)
+	for i := range acquired {
+		pool.Dispatch(&natsgo.Msg{Subject: fmt.Sprintf("events.%d", i)})
+	}
+
+	// No slot is free until a message finishes.
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(timeout, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	pool.Close()
+	assert.LessOrEqual(t, peak.Load(), int32(maxInFlight))
+	assert.Equal(t, 0, pool.InFlight())
+}
+
+func TestWorkerPool_ReleaseUnused(t *testing.T) {
+	pool := nats.NewWorkerPool(1, 3, nil, func(*natsgo.Msg) {})
+	defer pool.Close()
+
+	acquired, err := pool.Acquire(context.Background(), 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, acquired)
+
+	pool.Release(acquired)
+	assert.Equal(t, 0, pool.InFlight())
+}
+
+func benchmarkWorkerPool(b *testing.B, workers int) {
+	b.Helper()
+
+	pool := nats.NewWorkerPool(workers, 256, nats.SubjectPartition, func(*natsgo.Msg) {
+		// Simulate a handler waiting on I/O such as an archive fsync.
+		time.Sleep(50 * time.Microsecond)
+	})
+
+	msgs := make([]*natsgo.Msg, 64)
+	for i := range msgs {
+		msgs[i] = &natsgo.Msg{Subject: fmt.Sprintf("benchmark.subject.%d", i)}
+	}
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := pool.Acquire(ctx, 1); err != nil {
+			b.Fatal(err)
+		}
+		pool.Dispatch(msgs[i%len(msgs)])
+	}
+	pool.Close()
+}
+
+func BenchmarkWorkerPool_Sequential(b *testing.B) {
+	benchmarkWorkerPool(b, 1)
+}
+
+func BenchmarkWorkerPool_Parallel8(b *testing.B) {
+	benchmarkWorkerPool(b, 8)
+}
+
+func BenchmarkWorkerPool_Parallel32(b *testing.B) {
+	benchmarkWorkerPool(b, 32)
+}
+
+func BenchmarkJSONFieldPartition(b *testing.B) {
+	partition := nats.JSONFieldPartition("data.actor_id")
+	msg := &natsgo.Msg{
+		Subject: "benchmark.subject",
+		Data:    []byte(`{"id":"bench","type":"benchmark","data":{"actor_id":"user-42","iteration":0}}`),
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		_ = partition(msg)
+	}
+}
@@ -0,0 +1,80 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"events-audit/internal/schema"
+	"events-audit/internal/source"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers attached to quarantined messages in addition to the original
+// subject, stream and sequence headers.
+const (
+	HeaderSchema        = "Audit-Schema"
+	HeaderSchemaError   = "Audit-Schema-Error"
+	HeaderQuarantinedAt = "Audit-Quarantined-At"
+)
+
+// quarantineSubject returns the quarantine subject for an original subject.
+func (c *Client) quarantineSubject(subject string) string {
+	return c.config.QuarantineSubject + "." + subject
+}
+
+// ensureQuarantineStream creates or updates the quarantine stream.
+func (c *Client) ensureQuarantineStream() error {
+	return c.ensureSideStream("quarantine", c.config.QuarantineStream, c.quarantineSubject(">"), c.config.QuarantineMaxAge)
+}
+
+// Quarantine republishes an event that failed schema validation to the
+// quarantine stream with its original headers. Every violation is added as
+// a separate Audit-Schema-Error header value.
+func (c *Client) Quarantine(msg *source.Msg, validationErr *schema.ValidationError) error {
+	if c.config.QuarantineStream == "" {
+		return errors.New("quarantine stream is not configured")
+	}
+	if !c.ready.Load() {
+		return errors.New("NATS client not connected")
+	}
+
+	quarantined := nats.NewMsg(c.quarantineSubject(msg.Subject))
+	for k, v := range msg.Header {
+		quarantined.Header[k] = append([]string(nil), v...)
+	}
+	quarantined.Data = msg.Data
+
+	quarantined.Header.Set(HeaderOriginalSubject, msg.Subject)
+	quarantined.Header.Set(HeaderSchema, validationErr.Key.String())
+	quarantined.Header.Del(HeaderSchemaError)
+	for _, violation := range validationErr.Violations {
+		quarantined.Header.Add(HeaderSchemaError, violation)
+	}
+	quarantined.Header.Set(HeaderQuarantinedAt, time.Now().UTC().Format(time.RFC3339Nano))
+
+	var opts []nats.PubOpt
+	if meta, err := msg.Metadata(); err == nil {
+		quarantined.Header.Set(HeaderOriginalStream, meta.Stream)
+		quarantined.Header.Set(HeaderOriginalSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+		// Deduplicate redeliveries of the same original message.
+		opts = append(opts, nats.MsgId(fmt.Sprintf("%s:%d", meta.Stream, meta.Sequence.Stream)))
+	}
+
+	ack, err := c.js.PublishMsg(quarantined, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to publish to quarantine stream: %w", err)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"subject":             msg.Subject,
+		"schema":              validationErr.Key.String(),
+		"quarantine_stream":   ack.Stream,
+		"quarantine_sequence": ack.Sequence,
+	}).Warn("Message moved to quarantine stream")
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"events-audit/internal/source"
+	"events-audit/internal/subject"
+
+	"github.com/nats-io/nats.go"
+)
+
+// readIdleTimeout is how long Read waits for a message before it checks
+// whether the end of the stream was reached.
+const readIdleTimeout = time.Second
+
+// ErrStopReading may be returned by a ReadFunc to end Read without error.
+var ErrStopReading = errors.New("stop reading")
+
+// ReadFunc is called by Read for every message read.
+type ReadFunc func(msg *source.Msg) error
+
+// ReadOptions select the stream messages returned by Read.
+type ReadOptions struct {
+	// Subject filters the messages, NATS wildcards are allowed. All
+	// subjects of the stream are read when empty.
+	Subject string
+	// StartSeq or StartTime select the first message. Without them the
+	// stream is read from its first message, or from the messages published
+	// after Read started when New is set.
+	StartSeq  uint64
+	StartTime time.Time
+	New       bool
+	// EndSeq (inclusive) and EndTime (exclusive) bound the range read.
+	EndSeq  uint64
+	EndTime time.Time
+	// Follow waits for new messages at the end of the stream instead of
+	// returning.
+	Follow bool
+}
+
+// Validate checks that the options select a valid range.
+func (o ReadOptions) Validate() error {
+	if o.Subject != "" {
+		if err := subject.ValidatePattern(o.Subject); err != nil {
+			return err
+		}
+	}
+	starts := 0
+	for _, set := range []bool{o.StartSeq > 0, !o.StartTime.IsZero(), o.New} {
+		if set {
+			starts++
+		}
+	}
+	if starts > 1 {
+		return errors.New("only one of start sequence, start time or new messages may be set")
+	}
+	if o.EndSeq > 0 && o.EndSeq < o.StartSeq {
+		return fmt.Errorf("end sequence %d must not be before start sequence %d", o.EndSeq, o.StartSeq)
+	}
+	if !o.EndTime.IsZero() && !o.StartTime.IsZero() && !o.StartTime.Before(o.EndTime) {
+		return errors.New("start time must be before end time")
+	}
+	return nil
+}
+
+// deliverOption returns the consumer start position of the options.
+func (o ReadOptions) deliverOption() nats.SubOpt {
+	switch {
+	case o.StartSeq > 0:
+		return nats.StartSequence(o.StartSeq)
+	case !o.StartTime.IsZero():
+		return nats.StartTime(o.StartTime)
+	case o.New:
+		return nats.DeliverNew()
+	default:
+		return nats.DeliverAll()
+	}
+}
+
+// Read calls fn with the stream messages selected by opts, in stream order.
+// It reads through an ephemeral ordered consumer, so the durable audit
+// consumer is left untouched. Without Follow, Read returns once the end of
+// the range or of the stream is reached.
+func (c *Client) Read(ctx context.Context, opts ReadOptions, fn ReadFunc) error {
+	if c.js == nil {
+		return errors.New("JetStream context not initialized")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	info, err := c.js.StreamInfo(c.config.StreamName)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	if !opts.Follow && (info.State.Msgs == 0 || opts.New || opts.StartSeq > info.State.LastSeq) {
+		return nil
+	}
+
+	sub, err := c.js.SubscribeSync(opts.Subject,
+		nats.BindStream(c.config.StreamName),
+		nats.OrderedConsumer(),
+		opts.deliverOption(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create ordered consumer: %w", err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, readIdleTimeout)
+		msg, nextErr := sub.NextMsgWithContext(waitCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(nextErr, context.DeadlineExceeded) || errors.Is(nextErr, nats.ErrTimeout):
+			if !opts.Follow && caughtUp(sub) {
+				return nil
+			}
+			continue
+		case nextErr != nil:
+			return fmt.Errorf("failed to read message: %w", nextErr)
+		}
+
+		meta, metaErr := msg.Metadata()
+		if metaErr != nil {
+			return fmt.Errorf("failed to get message metadata: %w", metaErr)
+		}
+		if (opts.EndSeq > 0 && meta.Sequence.Stream > opts.EndSeq) ||
+			(!opts.EndTime.IsZero() && !meta.Timestamp.Before(opts.EndTime)) {
+			return nil
+		}
+
+		if fnErr := fn(toSourceMsg(msg, meta)); fnErr != nil {
+			if errors.Is(fnErr, ErrStopReading) {
+				return nil
+			}
+			return fnErr
+		}
+		if !opts.Follow && (meta.NumPending == 0 || meta.Sequence.Stream == opts.EndSeq) {
+			return nil
+		}
+	}
+}
+
+// caughtUp reports whether an idle ordered consumer has delivered every
+// message it selects.
+func caughtUp(sub *nats.Subscription) bool {
+	if msgs, _, err := sub.Pending(); err != nil || msgs > 0 {
+		return false
+	}
+	info, err := sub.ConsumerInfo()
+	return err == nil && info.NumPending == 0
+}
@@ -0,0 +1,99 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/source"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func read(t *testing.T, client *nats.Client, opts nats.ReadOptions) []string {
+	t.Helper()
+
+	rec := &recorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Read(ctx, opts, rec.handle))
+	return rec.received()
+}
+
+func TestClient_Read(t *testing.T) {
+	url := startNATS(t)
+	client := connectClient(t, newTestConfig(url))
+
+	publish(t, url, "events.user", "one", "two")
+	publish(t, url, "events.order", "three")
+	publish(t, url, "events.user", "four")
+
+	tests := map[string]struct {
+		opts nats.ReadOptions
+		want []string
+	}{
+		"everything":     {nats.ReadOptions{}, []string{"one", "two", "three", "four"}},
+		"subject":        {nats.ReadOptions{Subject: "events.user"}, []string{"one", "two", "four"}},
+		"sequence range": {nats.ReadOptions{StartSeq: 2, EndSeq: 3}, []string{"two", "three"}},
+		"filtered range": {nats.ReadOptions{Subject: "events.order", EndSeq: 2}, nil},
+		"past the end":   {nats.ReadOptions{StartSeq: 9}, nil},
+		"end time":       {nats.ReadOptions{EndTime: time.Now().Add(-time.Hour)}, nil},
+		"start time":     {nats.ReadOptions{StartTime: time.Now().Add(time.Hour)}, nil},
+		"new":            {nats.ReadOptions{New: true}, nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, read(t, client, tt.opts))
+		})
+	}
+
+	// Reading does not create or move the durable consumer.
+	_, err := client.GetConsumerInfo()
+	require.ErrorIs(t, err, natsgo.ErrConsumerNotFound)
+}
+
+func TestClient_ReadFollow(t *testing.T) {
+	url := startNATS(t)
+	client := connectClient(t, newTestConfig(url))
+	publish(t, url, "events.user", "old")
+
+	received := make(chan string, 8)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Read(context.Background(), nats.ReadOptions{New: true, Follow: true}, func(msg *source.Msg) error {
+			received <- string(msg.Data)
+			if len(received) == 2 {
+				return nats.ErrStopReading
+			}
+			return nil
+		})
+	}()
+
+	// The consumer starts in the background, so messages are published
+	// until the first one arrives.
+	require.Eventually(t, func() bool {
+		publish(t, url, "events.user", "new")
+		return len(received) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	publish(t, url, "events.user", "last")
+
+	require.NoError(t, <-done)
+	assert.Equal(t, "new", <-received)
+}
+
+func TestReadOptions_Validate(t *testing.T) {
+	now := time.Now()
+	tests := map[string]nats.ReadOptions{
+		"only one of":              {StartSeq: 1, StartTime: now},
+		"must not be before start": {StartSeq: 5, EndSeq: 4},
+		"must be before end":       {StartTime: now, EndTime: now},
+		"invalid subject pattern":  {Subject: "events.>.user"},
+	}
+	for message, opts := range tests {
+		require.ErrorContains(t, opts.Validate(), message)
+	}
+	require.NoError(t, nats.ReadOptions{Subject: "events.*", StartSeq: 2, EndSeq: 2}.Validate())
+}
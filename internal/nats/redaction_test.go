@@ -0,0 +1,87 @@
+package nats_test
+
+import (
+	"testing"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/redact"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLogger_Redaction(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.password", "$.tenant"}, Mode: redact.ModeMask},
+		{Detectors: []string{redact.DetectEmail}, Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		field    string
+		expected any
+	}{
+		{
+			name:     "legacy event",
+			data:     []byte(`{"id":"1","type":"user.created","data":{"password":"x","email":"a@example.com"}}`),
+			field:    "event_data",
+			expected: map[string]any{"password": redact.DefaultMask, "email": redact.DefaultMask},
+		},
+		{
+			name:     "CloudEvent data",
+			data:     []byte(`{"specversion":"1.0","id":"1","source":"/u","type":"user.created","data":{"password":"x"}}`),
+			field:    "event_data",
+			expected: map[string]any{"password": redact.DefaultMask},
+		},
+		{
+			name:     "CloudEvent extensions",
+			data:     []byte(`{"specversion":"1.0","id":"1","source":"/u","type":"user.created","tenant":"acme"}`),
+			field:    "extensions",
+			expected: map[string]any{"tenant": redact.DefaultMask},
+		},
+		{
+			name:     "raw message",
+			data:     []byte("login by a@example.com"),
+			field:    "raw_data",
+			expected: "login by " + redact.DefaultMask,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			eventLogger := nats.NewEventLogger(logger)
+			eventLogger.SetRedactor(engine)
+
+			require.NoError(t, eventLogger.HandleEvent(&source.Msg{Subject: "test.subject", Data: tt.data}))
+			require.Len(t, hook.Entries, 1)
+			assert.Equal(t, tt.expected, hook.Entries[0].Data[tt.field])
+		})
+	}
+}
+
+func TestEventLogger_RedactionKeepsSchemaInput(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.user_id"}, Mode: redact.ModeDrop},
+	}}, nil)
+	require.NoError(t, err)
+
+	logger, hook := test.NewNullLogger()
+	eventLogger := nats.NewEventLogger(logger)
+	eventLogger.SetSchemaPolicy(nats.SchemaPolicy{Registry: loadTestSchemas(t), Outcome: nats.SchemaOutcomeLog})
+	eventLogger.SetRedactor(engine)
+
+	msg := &source.Msg{
+		Subject: "test.subject",
+		Data:    []byte(`{"id":"1","type":"user.created","data":{"user_id":"42","email":"a@example.com"}}`),
+	}
+	require.NoError(t, eventLogger.HandleEvent(msg))
+
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, "Received structured event", hook.Entries[0].Message)
+	assert.Equal(t, map[string]any{"email": "a@example.com"}, hook.Entries[0].Data["event_data"])
+}
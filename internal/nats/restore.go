@@ -0,0 +1,68 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HeaderOriginalTimestamp is attached to restored messages together with
+// HeaderOriginalStream and HeaderOriginalSequence.
+const HeaderOriginalTimestamp = "Audit-Original-Timestamp"
+
+// expectedHeaderPrefix starts the JetStream publish expectation headers.
+const expectedHeaderPrefix = "Nats-Expected-"
+
+// RestoredMessage is an exported message published to the stream again.
+type RestoredMessage struct {
+	Subject   string
+	Header    nats.Header
+	Data      []byte
+	Stream    string
+	Sequence  uint64
+	Timestamp time.Time
+}
+
+// Restore publishes an exported message to the audit stream with its
+// original subject, headers and body and its origin in the Audit-Original
+// headers. Messages without a Nats-Msg-Id get one from their origin, so
+// importing the same export twice within the duplicate window of the stream
+// does not duplicate them.
+func (c *Client) Restore(restored *RestoredMessage) (*nats.PubAck, error) {
+	if c.js == nil {
+		return nil, errors.New("JetStream context not initialized")
+	}
+
+	msg := nats.NewMsg(restored.Subject)
+	for k, v := range restored.Header {
+		// Publish expectations of the original stream do not hold any more.
+		if strings.HasPrefix(k, expectedHeaderPrefix) {
+			continue
+		}
+		msg.Header[k] = append([]string(nil), v...)
+	}
+	msg.Data = restored.Data
+
+	if restored.Stream != "" {
+		msg.Header.Set(HeaderOriginalStream, restored.Stream)
+	}
+	if restored.Sequence > 0 {
+		msg.Header.Set(HeaderOriginalSequence, strconv.FormatUint(restored.Sequence, 10))
+	}
+	if !restored.Timestamp.IsZero() {
+		msg.Header.Set(HeaderOriginalTimestamp, restored.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	if msg.Header.Get(nats.MsgIdHdr) == "" && restored.Stream != "" && restored.Sequence > 0 {
+		msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s:%d", restored.Stream, restored.Sequence))
+	}
+
+	ack, err := c.js.PublishMsg(msg, nats.ExpectStream(c.config.StreamName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore message %s:%d: %w", restored.Stream, restored.Sequence, err)
+	}
+	return ack, nil
+}
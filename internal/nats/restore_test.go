@@ -0,0 +1,63 @@
+package nats_test
+
+import (
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Restore(t *testing.T) {
+	url := startNATS(t)
+	client := connectClient(t, newTestConfig(url))
+
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	restored := &nats.RestoredMessage{
+		Subject: "events.user",
+		Header: natsgo.Header{
+			"Ce-Type":                 {"user.created"},
+			natsgo.ExpectedLastSeqHdr: {"41"},
+			natsgo.ExpectedStreamHdr:  {"OLD_EVENTS"},
+		},
+		Data:      []byte(`{"id":"1"}`),
+		Stream:    "OLD_EVENTS",
+		Sequence:  42,
+		Timestamp: timestamp,
+	}
+
+	ack, err := client.Restore(restored)
+	require.NoError(t, err)
+	assert.False(t, ack.Duplicate)
+
+	// Restoring the same message again is deduplicated by its origin.
+	ack, err = client.Restore(restored)
+	require.NoError(t, err)
+	assert.True(t, ack.Duplicate)
+
+	info, err := client.GetStreamInfo()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), info.State.Msgs)
+
+	nc, err := natsgo.Connect(url)
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+	raw, err := js.GetMsg(info.Config.Name, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, "events.user", raw.Subject)
+	assert.Equal(t, `{"id":"1"}`, string(raw.Data))
+	assert.Equal(t, "user.created", raw.Header.Get("Ce-Type"))
+	assert.Equal(t, "OLD_EVENTS", raw.Header.Get(nats.HeaderOriginalStream))
+	assert.Equal(t, "42", raw.Header.Get(nats.HeaderOriginalSequence))
+	assert.Equal(t, timestamp.Format(time.RFC3339Nano), raw.Header.Get(nats.HeaderOriginalTimestamp))
+	assert.Equal(t, "OLD_EVENTS:42", raw.Header.Get(natsgo.MsgIdHdr))
+
+	_, err = client.Restore(&nats.RestoredMessage{Subject: "other.user", Data: []byte("x")})
+	require.Error(t, err)
+}
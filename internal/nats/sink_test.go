@@ -0,0 +1,56 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/sink"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captureSink struct {
+	events []sink.Event
+	err    error
+}
+
+func (s *captureSink) Write(_ context.Context, event sink.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *captureSink) Close() error { return nil }
+
+func TestEventLogger_Router(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	captured := &captureSink{}
+	router := sink.NewRouter(logger)
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "capture", Mandatory: true}, captured))
+
+	eventLogger := nats.NewEventLogger(logger)
+	eventLogger.SetRouter(router)
+
+	msg := &source.Msg{Subject: "test.subject", Data: createValidEventData()}
+	require.NoError(t, eventLogger.HandleEvent(msg))
+
+	require.Len(t, captured.events, 1)
+	event := captured.events[0]
+	assert.Equal(t, "test.subject", event.Subject)
+	assert.Equal(t, "user.created", event.Type)
+	assert.Equal(t, logrus.InfoLevel, event.Level)
+	assert.Equal(t, "Received structured event", event.Message)
+	assert.Equal(t, "test-123", event.Fields["event_id"])
+	assert.Empty(t, hook.Entries, "events go to the sinks instead of the log")
+
+	captured.err = errors.New("unavailable")
+	require.ErrorContains(t, eventLogger.HandleEvent(msg), "failed to deliver to mandatory sinks")
+}
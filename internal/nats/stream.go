@@ -0,0 +1,302 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"events-audit/internal/constants"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// Stream management modes.
+const (
+	// StreamModeCreate creates a missing stream and only reports drift.
+	StreamModeCreate = "create"
+	// StreamModeReconcile creates a missing stream and updates drifted settings.
+	StreamModeReconcile = "reconcile"
+	// StreamModeVerifyOnly never changes the stream and fails on drift.
+	StreamModeVerifyOnly = "verify-only"
+)
+
+// ParseStreamMode validates a command line stream management mode.
+func ParseStreamMode(name string) (string, error) {
+	switch name {
+	case "":
+		return StreamModeReconcile, nil
+	case StreamModeCreate, StreamModeReconcile, StreamModeVerifyOnly:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown stream mode %q, supported %s, %s, %s",
+			name, StreamModeCreate, StreamModeReconcile, StreamModeVerifyOnly)
+	}
+}
+
+// ParseStorage converts a command line storage type: file or memory.
+func ParseStorage(name string) (nats.StorageType, error) {
+	switch name {
+	case "file", "":
+		return nats.FileStorage, nil
+	case "memory":
+		return nats.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("unknown stream storage %q, supported file, memory", name)
+	}
+}
+
+// ParseRetention converts a command line retention policy: limits, interest
+// or workqueue.
+func ParseRetention(name string) (nats.RetentionPolicy, error) {
+	switch name {
+	case "limits", "":
+		return nats.LimitsPolicy, nil
+	case "interest":
+		return nats.InterestPolicy, nil
+	case "workqueue":
+		return nats.WorkQueuePolicy, nil
+	default:
+		return 0, fmt.Errorf("unknown stream retention %q, supported limits, interest, workqueue", name)
+	}
+}
+
+// ParseDiscard converts a command line discard policy: old or new.
+func ParseDiscard(name string) (nats.DiscardPolicy, error) {
+	switch name {
+	case "old", "":
+		return nats.DiscardOld, nil
+	case "new":
+		return nats.DiscardNew, nil
+	default:
+		return 0, fmt.Errorf("unknown stream discard policy %q, supported old, new", name)
+	}
+}
+
+// ParseCompression converts a command line compression: none or s2.
+func ParseCompression(name string) (nats.StoreCompression, error) {
+	switch name {
+	case "none", "":
+		return nats.NoCompression, nil
+	case "s2":
+		return nats.S2Compression, nil
+	default:
+		return 0, fmt.Errorf("unknown stream compression %q, supported none, s2", name)
+	}
+}
+
+// streamConfig builds the desired audit stream configuration, normalizing
+// zero values to the defaults the server would store.
+func (c *Client) streamConfig() *nats.StreamConfig {
+	subjects := c.config.StreamSubjects
+	if len(subjects) == 0 {
+		subjects = []string{c.config.Subject}
+	}
+
+	replicas := c.config.StreamReplicas
+	if replicas == 0 {
+		replicas = constants.DefaultStreamReplicas
+	}
+
+	duplicates := c.config.StreamDuplicateWindow
+	if duplicates == 0 {
+		duplicates = constants.DefaultDuplicateWindow
+	}
+
+	maxMsgSize := c.config.StreamMaxMsgSize
+	if maxMsgSize == 0 {
+		maxMsgSize = constants.DefaultStreamMaxMsgSize
+	}
+
+	return &nats.StreamConfig{
+		Name:        c.config.StreamName,
+		Subjects:    subjects,
+		MaxAge:      c.config.StreamMaxAge,
+		MaxBytes:    c.config.StreamMaxBytes,
+		MaxMsgs:     c.config.StreamMaxMsgs,
+		MaxMsgSize:  maxMsgSize,
+		Replicas:    replicas,
+		Storage:     c.config.StreamStorage,
+		Retention:   c.config.StreamRetention,
+		Discard:     c.config.StreamDiscard,
+		Duplicates:  duplicates,
+		Compression: c.config.StreamCompression,
+		DenyDelete:  c.config.StreamDenyDelete,
+		DenyPurge:   c.config.StreamDenyPurge,
+	}
+}
+
+// managedStreamConfig overlays the settings the audit client manages on the
+// existing stream configuration, so an update keeps settings such as
+// placement, mirrors, sources or republish that were configured elsewhere.
+func managedStreamConfig(existing, desired *nats.StreamConfig) *nats.StreamConfig {
+	updated := *existing
+	updated.Subjects = desired.Subjects
+	updated.MaxAge = desired.MaxAge
+	updated.MaxBytes = desired.MaxBytes
+	updated.MaxMsgs = desired.MaxMsgs
+	updated.MaxMsgSize = desired.MaxMsgSize
+	updated.Replicas = desired.Replicas
+	updated.Storage = desired.Storage
+	updated.Retention = desired.Retention
+	updated.Discard = desired.Discard
+	updated.Duplicates = desired.Duplicates
+	updated.Compression = desired.Compression
+	updated.DenyDelete = desired.DenyDelete
+	updated.DenyPurge = desired.DenyPurge
+	return &updated
+}
+
+// DiffStream compares the stream settings the audit client manages.
+func DiffStream(existing, desired *nats.StreamConfig) ConfigDiff {
+	diff := newConfigDiff()
+
+	if !sameSubjects(existing.Subjects, desired.Subjects) {
+		diff.Editable["subjects"] = fieldDiff(existing.Subjects, desired.Subjects)
+	}
+	if existing.MaxAge != desired.MaxAge {
+		diff.Editable["max_age"] = fieldDiff(existing.MaxAge, desired.MaxAge)
+	}
+	if existing.MaxBytes != desired.MaxBytes {
+		diff.Editable["max_bytes"] = fieldDiff(existing.MaxBytes, desired.MaxBytes)
+	}
+	if existing.MaxMsgs != desired.MaxMsgs {
+		diff.Editable["max_msgs"] = fieldDiff(existing.MaxMsgs, desired.MaxMsgs)
+	}
+	if existing.MaxMsgSize != desired.MaxMsgSize {
+		diff.Editable["max_msg_size"] = fieldDiff(existing.MaxMsgSize, desired.MaxMsgSize)
+	}
+	if existing.Replicas != desired.Replicas {
+		diff.Editable["replicas"] = fieldDiff(existing.Replicas, desired.Replicas)
+	}
+	if existing.Discard != desired.Discard {
+		diff.Editable["discard"] = fieldDiff(existing.Discard, desired.Discard)
+	}
+	if existing.Duplicates != desired.Duplicates {
+		diff.Editable["duplicate_window"] = fieldDiff(existing.Duplicates, desired.Duplicates)
+	}
+	if existing.Compression != desired.Compression {
+		diff.Editable["compression"] = fieldDiff(existing.Compression, desired.Compression)
+	}
+
+	if existing.Storage != desired.Storage {
+		diff.Fixed["storage"] = fieldDiff(existing.Storage, desired.Storage)
+	}
+	if existing.Retention != desired.Retention {
+		// Only switching between limits and interest is allowed in place.
+		target := diff.Editable
+		if existing.Retention == nats.WorkQueuePolicy || desired.Retention == nats.WorkQueuePolicy {
+			target = diff.Fixed
+		}
+		target["retention"] = fieldDiff(existing.Retention, desired.Retention)
+	}
+	// Deny delete and deny purge can be enabled but never cancelled.
+	if existing.DenyDelete != desired.DenyDelete {
+		target := diff.Editable
+		if existing.DenyDelete {
+			target = diff.Fixed
+		}
+		target["deny_delete"] = fieldDiff(existing.DenyDelete, desired.DenyDelete)
+	}
+	if existing.DenyPurge != desired.DenyPurge {
+		target := diff.Editable
+		if existing.DenyPurge {
+			target = diff.Fixed
+		}
+		target["deny_purge"] = fieldDiff(existing.DenyPurge, desired.DenyPurge)
+	}
+
+	return diff
+}
+
+// sameSubjects compares subject lists ignoring order.
+func sameSubjects(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = slices.Sorted(slices.Values(a))
+	b = slices.Sorted(slices.Values(b))
+	return slices.Equal(a, b)
+}
+
+// ensureStream creates, reconciles or verifies the JetStream stream
+// according to the configured stream mode.
+func (c *Client) ensureStream() error {
+	mode, err := ParseStreamMode(c.config.StreamMode)
+	if err != nil {
+		return err
+	}
+	streamConfig := c.streamConfig()
+
+	streamInfo, err := c.js.StreamInfo(c.config.StreamName)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		if mode == StreamModeVerifyOnly {
+			return fmt.Errorf("stream %s does not exist", c.config.StreamName)
+		}
+		streamInfo, err = c.createStream(streamConfig)
+	case err != nil:
+		return fmt.Errorf("failed to get stream info: %w", err)
+	default:
+		streamInfo, err = c.reconcileStream(mode, streamInfo, streamConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.logStreamReady(streamInfo)
+	return nil
+}
+
+// createStream creates a new stream.
+func (c *Client) createStream(streamConfig *nats.StreamConfig) (*nats.StreamInfo, error) {
+	streamInfo, err := c.js.AddStream(streamConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", c.config.StreamName, err)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"stream":   c.config.StreamName,
+		"subjects": streamConfig.Subjects,
+	}).Info("Created JetStream stream")
+
+	return streamInfo, nil
+}
+
+// reconcileStream compares an existing stream with the desired
+// configuration and, in reconcile mode, updates drifted editable settings.
+func (c *Client) reconcileStream(
+	mode string,
+	streamInfo *nats.StreamInfo,
+	streamConfig *nats.StreamConfig,
+) (*nats.StreamInfo, error) {
+	diff := DiffStream(&streamInfo.Config, streamConfig)
+	logger := c.logger.WithField("stream", c.config.StreamName).WithField("mode", mode)
+
+	if diff.Empty() {
+		logger.Info("JetStream stream already exists and is up to date")
+		return streamInfo, nil
+	}
+
+	drift := logger.WithFields(diff.Fields())
+	switch {
+	case mode == StreamModeCreate:
+		drift.Warn("JetStream stream configuration drifted, leaving it unchanged")
+		return streamInfo, nil
+	case mode == StreamModeVerifyOnly:
+		drift.Error("JetStream stream configuration drifted")
+		return nil, fmt.Errorf("stream %s configuration drifted (%s)", c.config.StreamName, diffKeys(diff.Fields()))
+	case len(diff.Fixed) > 0:
+		drift.Error("JetStream stream differs in settings that cannot be updated")
+		return nil, fmt.Errorf("stream %s differs in settings that cannot be updated (%s)",
+			c.config.StreamName, diffKeys(diff.Fixed))
+	}
+
+	updated, err := c.js.UpdateStream(managedStreamConfig(&streamInfo.Config, streamConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update stream %s: %w", c.config.StreamName, err)
+	}
+
+	drift.Info("Updated JetStream stream")
+	return updated, nil
+}
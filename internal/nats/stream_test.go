@@ -0,0 +1,111 @@
+package nats_test
+
+import (
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamOptions(t *testing.T) {
+	mode, err := nats.ParseStreamMode("")
+	require.NoError(t, err)
+	assert.Equal(t, nats.StreamModeReconcile, mode)
+	_, err = nats.ParseStreamMode("apply")
+	require.Error(t, err)
+
+	storage, err := nats.ParseStorage("memory")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.MemoryStorage, storage)
+	_, err = nats.ParseStorage("disk")
+	require.Error(t, err)
+
+	retention, err := nats.ParseRetention("workqueue")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.WorkQueuePolicy, retention)
+	_, err = nats.ParseRetention("forever")
+	require.Error(t, err)
+
+	discard, err := nats.ParseDiscard("new")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.DiscardNew, discard)
+	_, err = nats.ParseDiscard("oldest")
+	require.Error(t, err)
+
+	compression, err := nats.ParseCompression("s2")
+	require.NoError(t, err)
+	assert.Equal(t, natsgo.S2Compression, compression)
+	_, err = nats.ParseCompression("gzip")
+	require.Error(t, err)
+}
+
+func TestDiffStream(t *testing.T) {
+	base := natsgo.StreamConfig{
+		Name:       "EVENTS",
+		Subjects:   []string{"events.>", "audit.>"},
+		MaxAge:     24 * time.Hour,
+		MaxBytes:   1 << 30,
+		MaxMsgs:    1000000,
+		MaxMsgSize: -1,
+		Replicas:   1,
+		Storage:    natsgo.FileStorage,
+		Retention:  natsgo.LimitsPolicy,
+		Discard:    natsgo.DiscardOld,
+		Duplicates: 2 * time.Minute,
+	}
+
+	t.Run("identical with reordered subjects", func(t *testing.T) {
+		desired := base
+		desired.Subjects = []string{"audit.>", "events.>"}
+		assert.True(t, nats.DiffStream(&base, &desired).Empty())
+	})
+
+	t.Run("editable", func(t *testing.T) {
+		desired := base
+		desired.Subjects = []string{"events.>"}
+		desired.Replicas = 3
+		desired.Discard = natsgo.DiscardNew
+		desired.Duplicates = time.Minute
+		desired.MaxMsgSize = 1024
+		desired.Compression = natsgo.S2Compression
+		desired.Retention = natsgo.InterestPolicy
+		desired.DenyDelete = true
+		desired.DenyPurge = true
+
+		diff := nats.DiffStream(&base, &desired)
+		assert.Empty(t, diff.Fixed)
+		assert.ElementsMatch(t, []string{
+			"subjects", "replicas", "discard", "duplicate_window", "max_msg_size",
+			"compression", "retention", "deny_delete", "deny_purge",
+		}, keys(diff.Editable))
+		assert.Equal(t, "1 -> 3", diff.Editable["replicas"])
+	})
+
+	t.Run("fixed", func(t *testing.T) {
+		existing := base
+		existing.DenyDelete = true
+		existing.DenyPurge = true
+
+		desired := base
+		desired.Storage = natsgo.MemoryStorage
+		desired.Retention = natsgo.WorkQueuePolicy
+
+		diff := nats.DiffStream(&existing, &desired)
+		assert.Empty(t, diff.Editable)
+		assert.ElementsMatch(t, []string{"storage", "retention", "deny_delete", "deny_purge"}, keys(diff.Fixed))
+		assert.Equal(t, "File -> Memory", diff.Fixed["storage"])
+		assert.Len(t, diff.Fields(), 4)
+	})
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,15 @@
+package nats
+
+import "events-audit/internal/source"
+
+// TapHandler returns a handler passing every message that next handled
+// successfully to tap. Tap runs on the handling goroutine and must not block.
+func TapHandler(tap func(msg *source.Msg), next EventHandler) EventHandler {
+	return func(msg *source.Msg) error {
+		if err := next(msg); err != nil {
+			return err
+		}
+		tap(msg)
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+package nats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"events-audit/internal/cloudevent"
+	"events-audit/internal/schema"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Outcomes for events that fail schema validation.
+const (
+	// SchemaOutcomeLog logs the invalid event and acknowledges it.
+	SchemaOutcomeLog = "log"
+	// SchemaOutcomeQuarantine moves the invalid event to the quarantine
+	// stream and acknowledges it.
+	SchemaOutcomeQuarantine = "quarantine"
+	// SchemaOutcomeNak fails the handler so the event is redelivered and
+	// dead-lettered after its last delivery attempt.
+	SchemaOutcomeNak = "nak"
+)
+
+// VersionExtension is the CloudEvents extension attribute selecting a
+// versioned schema; legacy events use their version field.
+const VersionExtension = "version"
+
+// ParseSchemaOutcome validates a command line schema validation outcome.
+func ParseSchemaOutcome(name string) (string, error) {
+	switch name {
+	case "":
+		return SchemaOutcomeLog, nil
+	case SchemaOutcomeLog, SchemaOutcomeQuarantine, SchemaOutcomeNak:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown schema outcome %q, supported %s, %s, %s",
+			name, SchemaOutcomeLog, SchemaOutcomeQuarantine, SchemaOutcomeNak)
+	}
+}
+
+// SchemaPolicy configures validation of event data against a schema
+// registry.
+type SchemaPolicy struct {
+	Registry *schema.Registry
+	// Outcome is one of the SchemaOutcome* constants.
+	Outcome string
+	// RequireSchema treats events whose type has no schema as invalid.
+	RequireSchema bool
+	// Quarantine sets an invalid message aside; it is required by the
+	// quarantine outcome.
+	Quarantine func(msg *source.Msg, validationErr *schema.ValidationError) error
+}
+
+// legacyEnvelope is a legacy Event with its data left undecoded for schema
+// validation.
+type legacyEnvelope struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Version string          `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// validateLegacy validates a JSON object received as a legacy event. Events
+// without an id or type are invalid as they cannot be audited.
+func (el *EventLogger) validateLegacy(data []byte, fields logrus.Fields) *schema.ValidationError {
+	var envelope legacyEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return &schema.ValidationError{Violations: []string{fmt.Sprintf("invalid event: %v", err)}}
+	}
+	fields["event_id"] = envelope.ID
+	fields["event_type"] = envelope.Type
+
+	key := schema.Key{Type: envelope.Type, Version: envelope.Version}
+	var violations []string
+	if envelope.ID == "" {
+		violations = append(violations, "/id: missing event id")
+	}
+	if envelope.Type == "" {
+		violations = append(violations, "/type: missing event type")
+	}
+	if len(violations) > 0 {
+		return &schema.ValidationError{Key: key, Violations: violations}
+	}
+
+	return el.validateData(key, envelope.Data)
+}
+
+// validateCloudEvent validates the data of a CloudEvent, whose attributes
+// have already been checked when it was parsed.
+func (el *EventLogger) validateCloudEvent(ce *cloudevent.Event) *schema.ValidationError {
+	version, _ := ce.Extensions[VersionExtension].(string)
+	return el.validateData(schema.Key{Type: ce.Type, Version: version}, ce.Data)
+}
+
+// validateData validates event data against the registry. Events without a
+// schema are valid unless a schema is required.
+func (el *EventLogger) validateData(key schema.Key, data []byte) *schema.ValidationError {
+	err := el.schemas.Registry.Validate(key.Type, key.Version, data)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr
+	}
+	if errors.Is(err, schema.ErrNoSchema) {
+		if el.schemas.RequireSchema {
+			return &schema.ValidationError{Key: key, Violations: []string{err.Error()}}
+		}
+		el.logger.WithField("event_type", key.Type).Debug("No schema registered for event type")
+		return nil
+	}
+	return &schema.ValidationError{Key: key, Violations: []string{err.Error()}}
+}
+
+// rejectEvent applies the configured outcome to an event that failed schema
+// validation and returns the handler result.
+func (el *EventLogger) rejectEvent(msg *source.Msg, fields logrus.Fields, validationErr *schema.ValidationError) error {
+	fields["schema"] = validationErr.Key.String()
+	fields["schema_errors"] = validationErr.Violations
+	fields["schema_outcome"] = el.schemas.Outcome
+	el.metrics.SchemaInvalid(msg.Subject, el.schemas.Outcome)
+
+	switch el.schemas.Outcome {
+	case SchemaOutcomeQuarantine:
+		if err := el.schemas.Quarantine(msg, validationErr); err != nil {
+			el.logger.WithFields(fields).WithError(err).Error("Failed to quarantine event that failed schema validation")
+			return fmt.Errorf("failed to quarantine invalid event: %w", err)
+		}
+		el.logger.WithFields(fields).Warn("Quarantined event that failed schema validation")
+		return nil
+	case SchemaOutcomeNak:
+		el.logger.WithFields(fields).Error("Received event that failed schema validation")
+		return fmt.Errorf("invalid event: %w", validationErr)
+	default:
+		return el.emit(msg.Subject, logrus.WarnLevel, "Received event that failed schema validation", fields)
+	}
+}
+
+// isJSONObject reports whether data looks like a JSON object.
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
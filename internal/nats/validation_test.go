@@ -0,0 +1,207 @@
+package nats_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/schema"
+	"events-audit/internal/source"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestSchemas(t *testing.T) *schema.Registry {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.created.json":   `{"type": "object", "required": ["user_id"], "properties": {"user_id": {"type": "string"}}}`,
+		"user.created@2.json": `{"type": "object", "required": ["user_id", "tenant"]}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	reg, err := schema.Load(dir)
+	require.NoError(t, err)
+	return reg
+}
+
+func TestEventLogger_SchemaValidation(t *testing.T) {
+	reg := loadTestSchemas(t)
+
+	tests := []struct {
+		name    string
+		data    string
+		header  source.Header
+		require bool
+		valid   bool
+		schema  string
+	}{
+		{
+			name:  "valid legacy event",
+			data:  `{"id":"1","type":"user.created","data":{"user_id":"42"}}`,
+			valid: true,
+		},
+		{
+			name:   "legacy event with invalid data",
+			data:   `{"id":"1","type":"user.created","data":{"user_id":42}}`,
+			schema: "user.created",
+		},
+		{
+			name:   "legacy event with non-object data",
+			data:   `{"id":"1","type":"user.created","data":"garbage"}`,
+			schema: "user.created",
+		},
+		{
+			name:   "legacy event with versioned schema",
+			data:   `{"id":"1","type":"user.created","version":"2","data":{"user_id":"42"}}`,
+			schema: "user.created@2",
+		},
+		{
+			name:   "legacy event without id",
+			data:   `{"type":"user.created","data":{"user_id":"42"}}`,
+			schema: "user.created",
+		},
+		{
+			name:  "unknown type",
+			data:  `{"id":"1","type":"user.deleted","data":{}}`,
+			valid: true,
+		},
+		{
+			name:    "unknown type with required schema",
+			data:    `{"id":"1","type":"user.deleted","data":{}}`,
+			require: true,
+			schema:  "user.deleted",
+		},
+		{
+			name:   "cloud event with versioned schema",
+			data:   `{"specversion":"1.0","id":"1","source":"/users","type":"user.created","version":"2","data":{"user_id":"42"}}`,
+			schema: "user.created@2",
+		},
+		{
+			name: "binary cloud event",
+			header: source.Header{
+				"Ce-Specversion": {"1.0"},
+				"Ce-Id":          {"1"},
+				"Ce-Source":      {"/users"},
+				"Ce-Type":        {"user.created"},
+			},
+			data:  `{"user_id":"42"}`,
+			valid: true,
+		},
+		{
+			name:  "raw message",
+			data:  `not json`,
+			valid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, outcome := range []string{nats.SchemaOutcomeLog, nats.SchemaOutcomeNak, nats.SchemaOutcomeQuarantine} {
+				logger, hook := test.NewNullLogger()
+				eventLogger := nats.NewEventLogger(logger)
+
+				var quarantined []*schema.ValidationError
+				eventLogger.SetSchemaPolicy(nats.SchemaPolicy{
+					Registry:      reg,
+					Outcome:       outcome,
+					RequireSchema: tt.require,
+					Quarantine: func(_ *source.Msg, validationErr *schema.ValidationError) error {
+						quarantined = append(quarantined, validationErr)
+						return nil
+					},
+				})
+
+				err := eventLogger.HandleEvent(&source.Msg{Subject: "test.subject", Header: tt.header, Data: []byte(tt.data)})
+				entry := hook.LastEntry()
+				require.NotNil(t, entry)
+
+				if tt.valid {
+					require.NoError(t, err, outcome)
+					assert.Equal(t, logrus.InfoLevel, entry.Level, outcome)
+					assert.Empty(t, quarantined, outcome)
+					continue
+				}
+
+				assert.Equal(t, tt.schema, entry.Data["schema"], outcome)
+				assert.NotEmpty(t, entry.Data["schema_errors"], outcome)
+				switch outcome {
+				case nats.SchemaOutcomeNak:
+					require.ErrorContains(t, err, "failed schema validation")
+					assert.Equal(t, logrus.ErrorLevel, entry.Level)
+				case nats.SchemaOutcomeQuarantine:
+					require.NoError(t, err)
+					require.Len(t, quarantined, 1)
+					assert.Equal(t, tt.schema, quarantined[0].Key.String())
+				default:
+					require.NoError(t, err)
+					assert.Equal(t, logrus.WarnLevel, entry.Level)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_Quarantine(t *testing.T) {
+	url := startNATS(t)
+	config := newTestConfig(url)
+	config.QuarantineStream = "EVENTS_QUARANTINE"
+	client := connectClient(t, config)
+
+	logger, _ := test.NewNullLogger()
+	eventLogger := nats.NewEventLogger(logger)
+	eventLogger.SetSchemaPolicy(nats.SchemaPolicy{
+		Registry:   loadTestSchemas(t),
+		Outcome:    nats.SchemaOutcomeQuarantine,
+		Quarantine: client.Quarantine,
+	})
+
+	publish(t, url, "events.user",
+		`{"id":"1","type":"user.created","data":{"user_id":"42"}}`,
+		`{"id":"2","type":"user.created","data":{}}`,
+	)
+	stop := subscribe(client, eventLogger.HandleEvent)
+	require.Eventually(t, func() bool {
+		info, err := client.GetConsumerInfo()
+		return err == nil && info.AckFloor.Stream == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, stop())
+
+	nc, err := natsgo.Connect(url)
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	info, err := js.StreamInfo("EVENTS_QUARANTINE")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), info.State.Msgs)
+
+	raw, err := js.GetMsg("EVENTS_QUARANTINE", info.State.FirstSeq)
+	require.NoError(t, err)
+	assert.Equal(t, "audit.quarantine.events.user", raw.Subject)
+	assert.JSONEq(t, `{"id":"2","type":"user.created","data":{}}`, string(raw.Data))
+	assert.Equal(t, "events.user", raw.Header.Get(nats.HeaderOriginalSubject))
+	assert.Equal(t, "EVENTS", raw.Header.Get(nats.HeaderOriginalStream))
+	assert.Equal(t, "2", raw.Header.Get(nats.HeaderOriginalSequence))
+	assert.Equal(t, "user.created", raw.Header.Get(nats.HeaderSchema))
+	assert.Equal(t, []string{"/: missing property 'user_id'"}, raw.Header.Values(nats.HeaderSchemaError))
+}
+
+func TestParseSchemaOutcome(t *testing.T) {
+	outcome, err := nats.ParseSchemaOutcome("")
+	require.NoError(t, err)
+	assert.Equal(t, nats.SchemaOutcomeLog, outcome)
+
+	_, err = nats.ParseSchemaOutcome("drop")
+	require.ErrorContains(t, err, "unknown schema outcome")
+}
@@ -0,0 +1,452 @@
+package nsq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/health"
+	"events-audit/internal/metrics"
+	"events-audit/internal/source"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers carrying NSQ message attributes to the handler chain.
+const (
+	HeaderMessageID   = "Nsq-Message-Id"
+	HeaderAttempts    = "Nsq-Attempts"
+	HeaderTimestamp   = "Nsq-Timestamp"
+	HeaderNSQDAddress = "Nsq-Nsqd-Address"
+)
+
+// Config holds NSQ consumer configuration.
+type Config struct {
+	// NSQDAddrs are nsqd TCP addresses connected to directly; LookupdAddrs
+	// are nsqlookupd HTTP addresses used to discover nsqd instances.
+	NSQDAddrs    []string
+	LookupdAddrs []string
+	Topic        string
+	Channel      string
+	MaxInFlight  int
+	// Concurrency is the number of goroutines handling messages.
+	Concurrency int
+	// MaxAttempts is the number of deliveries after which a failing message
+	// is finished and counted as terminated.
+	MaxAttempts int
+	// RequeueDelay is the delay of the first requeue; it doubles with every
+	// further attempt up to MaxRequeueDelay.
+	RequeueDelay    time.Duration
+	MaxRequeueDelay time.Duration
+	// TouchInterval is how often a message is touched while the handler
+	// runs, so that nsqd does not time it out and redeliver it. It must be
+	// shorter than the message timeout of nsqd.
+	TouchInterval time.Duration
+	Timeout       time.Duration
+	// DeadLetterTopic is the topic a message exhausting its attempts is
+	// published to, on the nsqd that delivered it. Empty drops such
+	// messages.
+	DeadLetterTopic string
+	// DeadLetterRetryDelay is the requeue delay of a message whose
+	// dead-letter publish failed; it is dead-lettered again on its next
+	// delivery, for up to DeadLetterRetries further attempts.
+	DeadLetterRetryDelay time.Duration
+	DeadLetterRetries    int
+}
+
+// DefaultConfig returns default NSQ configuration.
+func DefaultConfig() Config {
+	return Config{
+		Topic:           "events",
+		Channel:         constants.DefaultNSQChannel,
+		MaxInFlight:     constants.DefaultNSQMaxInFlight,
+		Concurrency:     constants.DefaultWorkers,
+		MaxAttempts:     constants.DefaultMaxDeliver,
+		RequeueDelay:    constants.DefaultNSQRequeueDelay,
+		MaxRequeueDelay: constants.DefaultNSQMaxRequeueDelay,
+		TouchInterval:   constants.DefaultNSQTouchInterval,
+		Timeout:         constants.DefaultTimeout,
+
+		DeadLetterRetryDelay: constants.DefaultDeadLetterRetryDelay,
+		DeadLetterRetries:    constants.DefaultDeadLetterRetries,
+	}
+}
+
+// Validate checks the topic, channel and addresses.
+func (c Config) Validate() error {
+	if !nsq.IsValidTopicName(c.Topic) {
+		return fmt.Errorf("invalid NSQ topic %q", c.Topic)
+	}
+	if !nsq.IsValidChannelName(c.Channel) {
+		return fmt.Errorf("invalid NSQ channel %q", c.Channel)
+	}
+	if len(c.NSQDAddrs) == 0 && len(c.LookupdAddrs) == 0 {
+		return errors.New("at least one nsqd or nsqlookupd address is required")
+	}
+	if c.MaxAttempts < 1 {
+		return errors.New("NSQ max attempts must be positive")
+	}
+	if c.TouchInterval <= 0 {
+		return errors.New("NSQ touch interval must be positive")
+	}
+	if c.DeadLetterTopic != "" {
+		if !nsq.IsValidTopicName(c.DeadLetterTopic) {
+			return fmt.Errorf("invalid NSQ dead-letter topic %q", c.DeadLetterTopic)
+		}
+		if c.DeadLetterTopic == c.Topic {
+			return errors.New("the NSQ dead-letter topic must differ from the consumed topic")
+		}
+	}
+	return nil
+}
+
+// RequeueBackoff returns the requeue delay of a message that failed on its
+// given delivery attempt.
+func (c Config) RequeueBackoff(attempts uint16) time.Duration {
+	delay := c.RequeueDelay
+	for i := uint16(1); i < attempts && delay < c.MaxRequeueDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxRequeueDelay)
+}
+
+// Client consumes an NSQ topic through a channel and hands messages to the
+// audit handler chain.
+type Client struct {
+	config    Config
+	logger    *logrus.Logger
+	nsqConfig *nsq.Config
+	consumer  *nsq.Consumer
+	metrics   *metrics.Metrics
+
+	// producers publish dead letters, one per nsqd address.
+	producersMu sync.Mutex
+	producers   map[string]*nsq.Producer
+
+	handler   atomic.Pointer[source.Handler]
+	connected atomic.Bool
+}
+
+// NewClient creates a new NSQ client.
+func NewClient(config Config, logger *logrus.Logger) (*Client, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	nsqConfig := nsq.NewConfig()
+	nsqConfig.DialTimeout = config.Timeout
+	// No messages are delivered until Subscribe raises the limit.
+	nsqConfig.MaxInFlight = 0
+	// Attempts are counted by the client so exhausted messages are reported.
+	nsqConfig.MaxAttempts = 0
+
+	consumer, err := nsq.NewConsumer(config.Topic, config.Channel, nsqConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NSQ consumer: %w", err)
+	}
+	consumer.SetLogger(nsqLogger{logger.WithField("component", "nsq")}, nsq.LogLevelInfo)
+
+	return &Client{
+		config:    config,
+		logger:    logger,
+		nsqConfig: nsqConfig,
+		consumer:  consumer,
+		producers: make(map[string]*nsq.Producer),
+	}, nil
+}
+
+// SetMetrics sets the collectors the client reports to.
+func (c *Client) SetMetrics(m *metrics.Metrics) {
+	c.metrics = m
+}
+
+// Connect connects to the configured nsqd or nsqlookupd instances. Messages
+// are not delivered before Subscribe.
+func (c *Client) Connect(_ context.Context) error {
+	c.consumer.AddConcurrentHandlers(nsq.HandlerFunc(c.handleMessage), max(c.config.Concurrency, 1))
+	c.connected.Store(true)
+
+	if len(c.config.NSQDAddrs) > 0 {
+		if err := c.consumer.ConnectToNSQDs(c.config.NSQDAddrs); err != nil {
+			return fmt.Errorf("failed to connect to nsqd: %w", err)
+		}
+	}
+	if len(c.config.LookupdAddrs) > 0 {
+		if err := c.consumer.ConnectToNSQLookupds(c.config.LookupdAddrs); err != nil {
+			return fmt.Errorf("failed to connect to nsqlookupd: %w", err)
+		}
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"nsqd":     c.config.NSQDAddrs,
+		"lookupd":  c.config.LookupdAddrs,
+		"topic":    c.config.Topic,
+		"channel":  c.config.Channel,
+		"attempts": c.config.MaxAttempts,
+	}).Info("Connected to NSQ")
+
+	return nil
+}
+
+// Subscribe starts delivering messages to handler and blocks until the
+// context is cancelled or the consumer is stopped.
+func (c *Client) Subscribe(ctx context.Context, handler source.Handler) error {
+	if !c.connected.Load() {
+		return errors.New("NSQ consumer not connected")
+	}
+
+	c.handler.Store(&handler)
+	c.consumer.ChangeMaxInFlight(c.config.MaxInFlight)
+	c.logger.WithFields(logrus.Fields{
+		"topic":         c.config.Topic,
+		"channel":       c.config.Channel,
+		"max_in_flight": c.config.MaxInFlight,
+		"concurrency":   c.config.Concurrency,
+	}).Info("Subscribed to NSQ")
+
+	select {
+	case <-ctx.Done():
+		c.logger.Info("Context cancelled, stopping NSQ consumer")
+		c.consumer.Stop()
+		<-c.consumer.StopChan
+		return ctx.Err()
+	case <-c.consumer.StopChan:
+		return nil
+	}
+}
+
+// handleMessage finishes a message once the handler succeeds and requeues
+// it with an exponential delay otherwise. A message failing its last
+// attempt is dead-lettered and finished so that it is not redelivered
+// forever. The message is
+// touched while the handler runs, so slow sinks do not make nsqd redeliver
+// it to another consumer.
+func (c *Client) handleMessage(m *nsq.Message) error {
+	m.DisableAutoResponse()
+	startTime := time.Now()
+
+	handler := c.handler.Load()
+	if handler == nil {
+		// Delivered before Subscribe; hand it back without delay.
+		m.RequeueWithoutBackoff(0)
+		return nil
+	}
+
+	msg := toMsg(c.config.Topic, m)
+	c.metrics.Fetched([]string{msg.Subject})
+
+	c.logger.WithFields(logrus.Fields{
+		"topic":    c.config.Topic,
+		"channel":  c.config.Channel,
+		"attempts": m.Attempts,
+		"nsqd":     m.NSQDAddress,
+		"size":     len(m.Body),
+	}).Debug("Processing NSQ message")
+
+	stopTouching := c.touch(m)
+	handlerErr := (*handler)(msg)
+	stopTouching()
+
+	if handlerErr != nil {
+		if int(m.Attempts) >= c.config.MaxAttempts {
+			c.logger.WithError(handlerErr).WithFields(logrus.Fields{
+				"topic":        c.config.Topic,
+				"attempts":     m.Attempts,
+				"max_attempts": c.config.MaxAttempts,
+			}).Error("Message exceeded max delivery attempts, finishing it")
+			c.terminate(m, msg, startTime)
+			return nil
+		}
+
+		delay := c.config.RequeueBackoff(m.Attempts)
+		// Sinks that are down may ask for a longer delay.
+		if sinkDelay, ok := source.RetryDelay(handlerErr); ok {
+			delay = max(delay, sinkDelay)
+		}
+		c.logger.WithError(handlerErr).WithFields(logrus.Fields{
+			"topic":    c.config.Topic,
+			"attempts": m.Attempts,
+			"delay":    delay.String(),
+		}).Error("Handler failed, requeueing message")
+		// The delay is the backoff; the consumer keeps receiving other
+		// messages instead of pausing the whole channel.
+		m.RequeueWithoutBackoff(delay)
+		c.metrics.Nacked(msg.Subject, time.Since(startTime))
+		return nil
+	}
+
+	m.Finish()
+	processingTime := time.Since(startTime)
+	c.metrics.Acked(msg.Subject, processingTime)
+	c.logger.WithFields(logrus.Fields{
+		"topic":           c.config.Topic,
+		"processing_time": processingTime.String(),
+		"attempts":        m.Attempts,
+	}).Debug("Message processed and finished")
+
+	return nil
+}
+
+// terminate publishes a message that exhausted its attempts to the
+// dead-letter topic, if configured, and finishes it. When the publish fails
+// the message is requeued with DeadLetterRetryDelay, so it is dead-lettered
+// again on its next delivery, until DeadLetterRetries further attempts have
+// been made and it is dropped.
+func (c *Client) terminate(m *nsq.Message, msg *source.Msg, startTime time.Time) {
+	if c.config.DeadLetterTopic != "" {
+		err := c.publishDeadLetter(m)
+		switch {
+		case err == nil:
+			c.metrics.DeadLettered(msg.Subject)
+		case int(m.Attempts) < c.config.MaxAttempts+max(c.config.DeadLetterRetries, 0):
+			c.logger.WithError(err).WithFields(logrus.Fields{
+				"topic":    c.config.DeadLetterTopic,
+				"attempts": m.Attempts,
+				"delay":    c.config.DeadLetterRetryDelay.String(),
+			}).Error("Failed to publish dead letter, requeueing message")
+			m.RequeueWithoutBackoff(c.config.DeadLetterRetryDelay)
+			c.metrics.Nacked(msg.Subject, time.Since(startTime))
+			return
+		default:
+			c.logger.WithError(err).WithFields(logrus.Fields{
+				"topic":    c.config.DeadLetterTopic,
+				"attempts": m.Attempts,
+			}).Error("Dead-letter publish retries exhausted, dropping message")
+		}
+	}
+
+	m.Finish()
+	c.metrics.Terminated(msg.Subject, time.Since(startTime))
+}
+
+// publishDeadLetter publishes the body of m to the dead-letter topic on the
+// nsqd that delivered it. NSQ messages have no headers, so the delivery
+// attributes are not kept.
+func (c *Client) publishDeadLetter(m *nsq.Message) error {
+	producer, err := c.producer(m.NSQDAddress)
+	if err != nil {
+		return err
+	}
+	if err = producer.Publish(c.config.DeadLetterTopic, m.Body); err != nil {
+		return fmt.Errorf("failed to publish to dead-letter topic %s: %w", c.config.DeadLetterTopic, err)
+	}
+	return nil
+}
+
+// producer returns the producer publishing to the nsqd at addr, creating
+// it on first use.
+func (c *Client) producer(addr string) (*nsq.Producer, error) {
+	c.producersMu.Lock()
+	defer c.producersMu.Unlock()
+
+	if producer, ok := c.producers[addr]; ok {
+		return producer, nil
+	}
+	producer, err := nsq.NewProducer(addr, c.nsqConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NSQ producer: %w", err)
+	}
+	producer.SetLogger(nsqLogger{c.logger.WithField("component", "nsq")}, nsq.LogLevelInfo)
+	c.producers[addr] = producer
+	return producer, nil
+}
+
+// touch touches m every TouchInterval until the returned function is
+// called. The function returns once touching has stopped, so no touch
+// follows the response to the message.
+func (c *Client) touch(m *nsq.Message) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(c.config.TouchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				m.Touch()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// toMsg converts an NSQ message to the message type of the handler chain.
+// The subject is the topic and the NSQ attributes are carried as headers.
+func toMsg(topic string, m *nsq.Message) *source.Msg {
+	msg := &source.Msg{Subject: topic, Header: make(source.Header), Data: m.Body}
+	msg.Header.Set(HeaderMessageID, string(m.ID[:]))
+	msg.Header.Set(HeaderAttempts, strconv.Itoa(int(m.Attempts)))
+	msg.Header.Set(HeaderTimestamp, time.Unix(0, m.Timestamp).UTC().Format(time.RFC3339Nano))
+	msg.Header.Set(HeaderNSQDAddress, m.NSQDAddress)
+	return msg
+}
+
+// Drain stops receiving new messages and lets in-flight ones finish.
+func (c *Client) Drain() error {
+	c.consumer.Stop()
+	return nil
+}
+
+// Close stops the consumer and waits for in-flight messages.
+func (c *Client) Close() error {
+	c.logger.Info("Closing NSQ client")
+	c.consumer.Stop()
+	if c.connected.Load() {
+		<-c.consumer.StopChan
+	}
+
+	c.producersMu.Lock()
+	defer c.producersMu.Unlock()
+	for addr, producer := range c.producers {
+		producer.Stop()
+		delete(c.producers, addr)
+	}
+	return nil
+}
+
+// IsConnected returns true if at least one nsqd connection is open.
+func (c *Client) IsConnected() bool {
+	return c.connected.Load() && c.consumer.Stats().Connections > 0
+}
+
+// ReadinessChecks returns the NSQ specific readiness checks. Connectivity
+// is the only readiness condition of an NSQ consumer.
+func (c *Client) ReadinessChecks() []health.Check {
+	return nil
+}
+
+// nsqLogger routes go-nsq logs to logrus.
+type nsqLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l nsqLogger) Output(_ int, s string) error {
+	level, line, _ := strings.Cut(s, " ")
+	line = strings.TrimSpace(line)
+	switch level {
+	case nsq.LogLevelError.String():
+		l.logger.Error(line)
+	case nsq.LogLevelWarning.String():
+		l.logger.Warn(line)
+	default:
+		l.logger.Debug(line)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package nsq_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"events-audit/internal/nsq"
+	"events-audit/internal/source"
+
+	gonsq "github.com/nsqio/go-nsq"
+	"github.com/nsqio/nsq/nsqd"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTopic = "audit"
+	// msgTimeout exceeds the 250ms output buffer timeout of nsqd, which
+	// would otherwise expire messages before the client receives them.
+	msgTimeout = 500 * time.Millisecond
+)
+
+// startNSQD runs an in-process nsqd and returns its TCP address. Messages
+// that are not finished or requeued time out quickly so that missing
+// responses show up as redeliveries.
+func startNSQD(t *testing.T) string {
+	t.Helper()
+
+	opts := nsqd.NewOptions()
+	opts.TCPAddress = "127.0.0.1:0"
+	opts.HTTPAddress = "127.0.0.1:0"
+	opts.HTTPSAddress = ""
+	opts.DataPath = t.TempDir()
+	opts.MsgTimeout = msgTimeout
+	// Deferred and timed out messages are only found on channels known to
+	// the queue scanner, which refreshes its list every 5s by default.
+	opts.QueueScanRefreshInterval = 50 * time.Millisecond
+	opts.Logger = log.New(io.Discard, "", 0)
+
+	daemon, err := nsqd.New(opts)
+	require.NoError(t, err)
+	go func() { _ = daemon.Main() }()
+	t.Cleanup(daemon.Exit)
+
+	return daemon.RealTCPAddr().String()
+}
+
+func publish(t *testing.T, addr string, payloads ...string) {
+	t.Helper()
+
+	producer, err := gonsq.NewProducer(addr, gonsq.NewConfig())
+	require.NoError(t, err)
+	defer producer.Stop()
+	producer.SetLogger(log.New(io.Discard, "", 0), gonsq.LogLevelError)
+
+	for _, payload := range payloads {
+		require.NoError(t, producer.Publish(testTopic, []byte(payload)))
+	}
+}
+
+func newTestConfig(addr string) nsq.Config {
+	config := nsq.DefaultConfig()
+	config.NSQDAddrs = []string{addr}
+	config.Topic = testTopic
+	config.RequeueDelay = 10 * time.Millisecond
+	config.MaxRequeueDelay = 50 * time.Millisecond
+	return config
+}
+
+// subscribe connects a client and runs Subscribe in the background. The
+// returned function stops it and returns its error.
+func subscribe(t *testing.T, config nsq.Config, handler func(*source.Msg) error) func() error {
+	t.Helper()
+
+	logger, _ := test.NewNullLogger()
+	client, err := nsq.NewClient(config, logger)
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(context.Background()))
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Subscribe(ctx, handler)
+	}()
+	require.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+
+	return func() error {
+		cancel()
+		err := <-done
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+}
+
+type recorder struct {
+	mu   sync.Mutex
+	msgs []*source.Msg
+	fail func(msg *source.Msg) error
+}
+
+func (r *recorder) handle(msg *source.Msg) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+	if r.fail != nil {
+		return r.fail(msg)
+	}
+	return nil
+}
+
+func (r *recorder) received() []*source.Msg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*source.Msg(nil), r.msgs...)
+}
+
+func TestClient_FinishesHandledMessages(t *testing.T) {
+	addr := startNSQD(t)
+	publish(t, addr, "one", "two", "three")
+
+	rec := &recorder{}
+	stop := subscribe(t, newTestConfig(addr), rec.handle)
+
+	require.Eventually(t, func() bool { return len(rec.received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	// Unfinished messages would be redelivered after the message timeout.
+	time.Sleep(2 * msgTimeout)
+	require.NoError(t, stop())
+
+	msgs := rec.received()
+	require.Len(t, msgs, 3)
+	var payloads []string
+	for _, msg := range msgs {
+		payloads = append(payloads, string(msg.Data))
+		assert.Equal(t, testTopic, msg.Subject)
+		assert.Equal(t, "1", msg.Header.Get(nsq.HeaderAttempts))
+		assert.Len(t, msg.Header.Get(nsq.HeaderMessageID), gonsq.MsgIDLength)
+		assert.Equal(t, addr, msg.Header.Get(nsq.HeaderNSQDAddress))
+		_, err := time.Parse(time.RFC3339Nano, msg.Header.Get(nsq.HeaderTimestamp))
+		assert.NoError(t, err)
+	}
+	assert.ElementsMatch(t, []string{"one", "two", "three"}, payloads)
+}
+
+func TestClient_RequeuesFailedMessages(t *testing.T) {
+	addr := startNSQD(t)
+	publish(t, addr, "flaky")
+
+	rec := &recorder{fail: func(msg *source.Msg) error {
+		if msg.Header.Get(nsq.HeaderAttempts) == "1" {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}}
+	stop := subscribe(t, newTestConfig(addr), rec.handle)
+
+	require.Eventually(t, func() bool { return len(rec.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(2 * msgTimeout)
+	require.NoError(t, stop())
+
+	msgs := rec.received()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "2", msgs[1].Header.Get(nsq.HeaderAttempts))
+	assert.Equal(t, msgs[0].Header.Get(nsq.HeaderMessageID), msgs[1].Header.Get(nsq.HeaderMessageID))
+}
+
+func TestClient_FinishesAfterMaxAttempts(t *testing.T) {
+	addr := startNSQD(t)
+	publish(t, addr, "poison")
+
+	config := newTestConfig(addr)
+	config.MaxAttempts = 3
+	rec := &recorder{fail: func(*source.Msg) error { return errors.New("cannot handle") }}
+	stop := subscribe(t, config, rec.handle)
+
+	require.Eventually(t, func() bool { return len(rec.received()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(2 * msgTimeout)
+	require.NoError(t, stop())
+
+	msgs := rec.received()
+	require.Len(t, msgs, 3)
+	for i, msg := range msgs {
+		assert.Equal(t, string(rune('1'+i)), msg.Header.Get(nsq.HeaderAttempts))
+	}
+}
+
+func TestClient_DeadLettersAfterMaxAttempts(t *testing.T) {
+	addr := startNSQD(t)
+	publish(t, addr, "poison")
+
+	const deadLetterTopic = "audit_dlq"
+	deadLetters := make(chan string, 1)
+	consumer, err := gonsq.NewConsumer(deadLetterTopic, "test", gonsq.NewConfig())
+	require.NoError(t, err)
+	consumer.SetLogger(log.New(io.Discard, "", 0), gonsq.LogLevelError)
+	consumer.AddHandler(gonsq.HandlerFunc(func(m *gonsq.Message) error {
+		deadLetters <- string(m.Body)
+		return nil
+	}))
+	require.NoError(t, consumer.ConnectToNSQD(addr))
+	t.Cleanup(consumer.Stop)
+
+	config := newTestConfig(addr)
+	config.MaxAttempts = 2
+	config.DeadLetterTopic = deadLetterTopic
+	rec := &recorder{fail: func(*source.Msg) error { return errors.New("cannot handle") }}
+	stop := subscribe(t, config, rec.handle)
+
+	select {
+	case body := <-deadLetters:
+		assert.Equal(t, "poison", body)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message was not dead-lettered")
+	}
+	time.Sleep(2 * msgTimeout)
+	require.NoError(t, stop())
+	assert.Len(t, rec.received(), 2, "dead-lettered messages are finished")
+}
+
+func TestClient_TouchesSlowMessages(t *testing.T) {
+	addr := startNSQD(t)
+	publish(t, addr, "slow")
+
+	config := newTestConfig(addr)
+	config.TouchInterval = msgTimeout / 5
+	rec := &recorder{fail: func(*source.Msg) error {
+		// Handling takes longer than the nsqd message timeout.
+		time.Sleep(3 * msgTimeout)
+		return nil
+	}}
+	stop := subscribe(t, config, rec.handle)
+
+	require.Eventually(t, func() bool { return len(rec.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(5 * msgTimeout)
+	require.NoError(t, stop())
+
+	msgs := rec.received()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "1", msgs[0].Header.Get(nsq.HeaderAttempts))
+}
+
+func TestConfig_RequeueBackoff(t *testing.T) {
+	config := nsq.Config{RequeueDelay: time.Second, MaxRequeueDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, config.RequeueBackoff(1))
+	assert.Equal(t, 2*time.Second, config.RequeueBackoff(2))
+	assert.Equal(t, 8*time.Second, config.RequeueBackoff(4))
+	assert.Equal(t, 10*time.Second, config.RequeueBackoff(5))
+	assert.Equal(t, 10*time.Second, config.RequeueBackoff(500))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := nsq.DefaultConfig()
+	require.ErrorContains(t, config.Validate(), "address is required")
+
+	config.NSQDAddrs = []string{"127.0.0.1:4150"}
+	require.NoError(t, config.Validate())
+
+	config.Topic = "bad topic"
+	require.ErrorContains(t, config.Validate(), "invalid NSQ topic")
+
+	config.Topic = testTopic
+	config.Channel = ""
+	require.ErrorContains(t, config.Validate(), "invalid NSQ channel")
+
+	config.Channel = testTopic
+	config.TouchInterval = 0
+	require.ErrorContains(t, config.Validate(), "touch interval")
+
+	config.TouchInterval = time.Second
+	config.DeadLetterTopic = "bad topic"
+	require.ErrorContains(t, config.Validate(), "invalid NSQ dead-letter topic")
+
+	config.DeadLetterTopic = config.Topic
+	require.ErrorContains(t, config.Validate(), "must differ")
+
+	config.DeadLetterTopic = "audit.dlq"
+	require.NoError(t, config.Validate())
+}
@@ -0,0 +1,157 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in detectors for common secrets and personal data.
+const (
+	DetectEmail          = "email"
+	DetectCreditCard     = "credit_card"
+	DetectJWT            = "jwt"
+	DetectBearerToken    = "bearer_token"
+	DetectAWSAccessKeyID = "aws_access_key_id"
+	DetectPrivateKey     = "private_key"
+	DetectURLPassword    = "url_password"
+)
+
+// detector finds sensitive substrings in string values.
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	// valid filters out pattern matches that are not sensitive, such as
+	// digit runs failing the card number checksum.
+	valid func(match string) bool
+}
+
+// builtinDetectors returns the built-in detectors by name.
+func builtinDetectors() map[string]detector {
+	return map[string]detector{
+		DetectEmail: {
+			pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		},
+		DetectCreditCard: {
+			pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			valid:   luhnValid,
+		},
+		DetectJWT: {
+			pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		},
+		DetectBearerToken: {
+			pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+		},
+		DetectAWSAccessKeyID: {
+			pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+		},
+		DetectPrivateKey: {
+			pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		},
+		DetectURLPassword: {
+			// Only the password between user: and @ is matched.
+			pattern: regexp.MustCompile(`(?:[a-z][a-z0-9+.\-]*://[^:/@\s]+:)([^@/\s]+)@`),
+		},
+	}
+}
+
+// DetectorNames returns the names of the built-in detectors.
+func DetectorNames() []string {
+	names := make([]string, 0, len(builtinDetectors()))
+	for name := range builtinDetectors() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectorPrecedence lists detectors that must run before others because
+// their matches overlap: the password of user:password@host would otherwise
+// be consumed by the email detector together with the host.
+func detectorPrecedence(name string) int {
+	switch name {
+	case DetectPrivateKey:
+		return 0
+	case DetectURLPassword:
+		return 1
+	default:
+		return 2 //nolint:mnd // every other detector runs last
+	}
+}
+
+// newDetectors resolves built-in detector names and compiles a custom
+// pattern. Built-in detectors run in precedence order whatever the order
+// they are listed in.
+func newDetectors(names []string, pattern string) ([]detector, error) {
+	builtins := builtinDetectors()
+	detectors := make([]detector, 0, len(names)+1)
+	for _, name := range names {
+		d, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q, supported %s", name, strings.Join(DetectorNames(), ", "))
+		}
+		d.name = name
+		detectors = append(detectors, d)
+	}
+	sort.SliceStable(detectors, func(i, j int) bool {
+		return detectorPrecedence(detectors[i].name) < detectorPrecedence(detectors[j].name)
+	})
+
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		detectors = append(detectors, detector{name: "pattern", pattern: re})
+	}
+	return detectors, nil
+}
+
+// replace rewrites every sensitive match in s and reports whether any was
+// found. A pattern with a capture group only rewrites the first group.
+func (d detector) replace(s string, fn func(match string) string) (string, bool) {
+	found := false
+	var b strings.Builder
+	last := 0
+	for _, loc := range d.pattern.FindAllStringSubmatchIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		if len(loc) >= 4 && loc[2] >= 0 {
+			start, end = loc[2], loc[3]
+		}
+		if d.valid != nil && !d.valid(s[start:end]) {
+			continue
+		}
+		found = true
+		b.WriteString(s[last:start])
+		b.WriteString(fn(s[start:end]))
+		last = end
+	}
+	if !found {
+		return s, false
+	}
+	b.WriteString(s[last:])
+	return b.String(), true
+}
+
+// luhnValid checks the card number checksum of a digit run that may
+// contain spaces or dashes.
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 { //nolint:mnd // digit sum of a doubled digit
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// Fixture is an example event with its expected redaction, used to test a
+// rule set.
+type Fixture struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Raw fixtures hold a string input redacted as an unstructured message.
+	Raw      bool            `json:"raw"`
+	Input    json.RawMessage `json:"input"`
+	Expected json.RawMessage `json:"expected"`
+
+	File string `json:"-"`
+}
+
+// LoadFixtures reads every .json fixture file in dir in name order.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redaction fixtures: %w", err)
+	}
+	sort.Strings(files)
+
+	fixtures := make([]Fixture, 0, len(files))
+	for _, file := range files {
+		content, readErr := os.ReadFile(file)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read redaction fixture: %w", readErr)
+		}
+		var fixture Fixture
+		if unmarshalErr := json.Unmarshal(content, &fixture); unmarshalErr != nil {
+			return nil, fmt.Errorf("invalid redaction fixture %s: %w", file, unmarshalErr)
+		}
+		if fixture.Name == "" {
+			fixture.Name = filepath.Base(file)
+		}
+		fixture.File = file
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// Check redacts the fixture input and compares it to the expected output.
+func (f Fixture) Check(e *Engine) error {
+	var input, expected any
+	if err := json.Unmarshal(f.Input, &input); err != nil {
+		return fmt.Errorf("fixture %s has invalid input: %w", f.Name, err)
+	}
+	if err := json.Unmarshal(f.Expected, &expected); err != nil {
+		return fmt.Errorf("fixture %s has invalid expected output: %w", f.Name, err)
+	}
+
+	var got any
+	if f.Raw {
+		s, ok := input.(string)
+		if !ok {
+			return fmt.Errorf("raw fixture %s input must be a string", f.Name)
+		}
+		got = e.RedactString(f.Type, s)
+	} else {
+		got = e.Redact(f.Type, input)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		encoded, _ := json.Marshal(got)
+		return fmt.Errorf("fixture %s: got %s, want %s", f.Name, encoded, f.Expected)
+	}
+	return nil
+}
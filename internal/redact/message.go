@@ -0,0 +1,156 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"events-audit/internal/cloudevent"
+)
+
+// coreAttributes are the CloudEvent context attributes left as they are;
+// the other attributes are extensions and redacted by their name.
+var coreAttributes = map[string]bool{ //nolint:gochecknoglobals // read-only attribute set
+	"id": true, "source": true, "specversion": true, "type": true,
+	"subject": true, "datacontenttype": true, "dataschema": true, "time": true,
+}
+
+// RedactMessage returns redacted copies of the headers and body of a
+// message, addressed the same way as events are redacted before logging:
+// CloudEvents with their data under $.data and extensions by their name,
+// legacy JSON events as published, and raw bodies by the detector rules.
+// Header values are redacted by the detector rules. The message itself is
+// not modified; a body dropped as a whole is returned as nil.
+func (e *Engine) RedactMessage(header map[string][]string, body []byte) (map[string][]string, []byte) {
+	if e == nil {
+		return header, body
+	}
+
+	if event, err := cloudevent.Parse(header, body); err == nil {
+		return e.redactCloudEvent(event, header, body)
+	}
+
+	var doc any
+	if decodeJSON(body, &doc) != nil {
+		return e.redactHeader("", header), []byte(e.RedactString("", string(body)))
+	}
+	object, _ := doc.(map[string]any)
+	eventType, _ := object["type"].(string)
+	return e.redactHeader(eventType, header), encodeJSON(e.Redact(eventType, doc))
+}
+
+// redactCloudEvent redacts the data and extensions of a CloudEvent in the
+// content mode it was published in.
+func (e *Engine) redactCloudEvent(event *cloudevent.Event, header map[string][]string, body []byte) (map[string][]string, []byte) {
+	doc := make(map[string]any, len(event.Extensions)+1)
+	for name, value := range event.Extensions {
+		doc[name] = value
+	}
+	if len(event.Data) > 0 {
+		var data any
+		if !event.IsJSON() || decodeJSON(event.Data, &data) != nil {
+			data = string(event.Data)
+		}
+		doc["data"] = data
+	}
+	redacted, _ := e.Redact(event.Type, doc).(map[string]any)
+	data, hasData := redacted["data"]
+
+	if event.Mode == cloudevent.ModeBinary {
+		out := make(map[string][]string, len(header))
+		for key, values := range header {
+			name, isAttribute := strings.CutPrefix(strings.ToLower(key), cloudevent.HeaderPrefix)
+			switch {
+			case !isAttribute:
+				out[key] = e.redactValues(event.Type, values)
+			case coreAttributes[name]:
+				out[key] = values
+			default:
+				if value, ok := redacted[name]; ok && value != nil {
+					out[key] = []string{scalarString(value)}
+				}
+			}
+		}
+		if !hasData || data == nil {
+			return out, nil
+		}
+		if text, ok := data.(string); ok && !event.IsJSON() {
+			return out, []byte(text)
+		}
+		return out, encodeJSON(data)
+	}
+
+	var members map[string]any
+	if decodeJSON(body, &members) != nil {
+		return e.redactHeader(event.Type, header), nil
+	}
+	for name := range event.Extensions {
+		if value, ok := redacted[name]; ok {
+			members[name] = value
+		} else {
+			delete(members, name)
+		}
+	}
+	_, isBase64 := members["data_base64"]
+	switch {
+	case !hasData || data == nil:
+		delete(members, "data")
+		delete(members, "data_base64")
+	case isBase64:
+		members["data_base64"] = base64.StdEncoding.EncodeToString([]byte(scalarString(data)))
+	default:
+		members["data"] = data
+	}
+	return e.redactHeader(event.Type, header), encodeJSON(members)
+}
+
+// redactHeader returns a copy of header with the values redacted by the
+// detector rules.
+func (e *Engine) redactHeader(eventType string, header map[string][]string) map[string][]string {
+	if header == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(header))
+	for key, values := range header {
+		out[key] = e.redactValues(eventType, values)
+	}
+	return out
+}
+
+func (e *Engine) redactValues(eventType string, values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if redacted := e.RedactString(eventType, value); redacted != "" {
+			out = append(out, redacted)
+		}
+	}
+	return out
+}
+
+// decodeJSON decodes a JSON document keeping numbers as they were written.
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after JSON document")
+	}
+	return nil
+}
+
+// encodeJSON encodes a redacted document; a dropped document is nil.
+func encodeJSON(doc any) []byte {
+	if doc == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
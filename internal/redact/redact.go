@@ -0,0 +1,322 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redaction modes.
+const (
+	// ModeMask replaces a value with the mask string.
+	ModeMask = "mask"
+	// ModeHash replaces a value with its keyed HMAC-SHA256, so equal values
+	// stay joinable without being readable.
+	ModeHash = "hash"
+	// ModeDrop removes a value.
+	ModeDrop = "drop"
+	// ModeTruncate shortens a value to max_length characters.
+	ModeTruncate = "truncate"
+)
+
+const (
+	// DefaultMask replaces masked values.
+	DefaultMask = "[REDACTED]"
+	// HashPrefix marks hashed values.
+	HashPrefix = "hmac:"
+
+	hashHexLength  = 32
+	truncateSuffix = "…"
+)
+
+// Config is the declarative rule set, usually loaded from a YAML file.
+type Config struct {
+	Rules []RuleConfig `yaml:"rules" json:"rules"`
+}
+
+// RuleConfig configures a single rule. Fields select the values a rule
+// applies to, detectors match sensitive substrings of string values. A rule
+// with both only looks for matches within the selected fields; a rule with
+// detectors only looks everywhere.
+type RuleConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// Types limits the rule to event types; path.Match patterns such as
+	// user.* are accepted. An empty list applies to every event.
+	Types     []string `yaml:"types" json:"types"`
+	Fields    []string `yaml:"fields" json:"fields"`
+	Detectors []string `yaml:"detectors" json:"detectors"`
+	// Pattern is a custom detector regular expression. When it has a
+	// capture group only the first group is redacted.
+	Pattern   string `yaml:"pattern" json:"pattern"`
+	Mode      string `yaml:"mode" json:"mode"`
+	Mask      string `yaml:"mask" json:"mask"`
+	MaxLength int    `yaml:"max_length" json:"max_length"`
+}
+
+// rule is a compiled RuleConfig.
+type rule struct {
+	RuleConfig
+
+	selectors []Selector
+	detectors []detector
+}
+
+// Engine redacts event documents before they are output.
+type Engine struct {
+	rules   []rule
+	hmacKey []byte
+}
+
+// Load reads a YAML rule file and creates the engine. The HMAC key is only
+// required by rules in hash mode.
+func Load(file string, hmacKey []byte) (*Engine, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules: %w", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	decoder.KnownFields(true)
+	if decodeErr := decoder.Decode(&config); decodeErr != nil {
+		return nil, fmt.Errorf("failed to parse redaction rules %s: %w", file, decodeErr)
+	}
+
+	return New(config, hmacKey)
+}
+
+// LoadHMACKey reads the HMAC key of the hash mode from a file, ignoring
+// surrounding whitespace.
+func LoadHMACKey(file string) ([]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction HMAC key: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(content)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("redaction HMAC key file %s is empty", file)
+	}
+	return key, nil
+}
+
+// New compiles the rules of config.
+func New(config Config, hmacKey []byte) (*Engine, error) {
+	engine := &Engine{hmacKey: hmacKey}
+	for i, ruleConfig := range config.Rules {
+		compiled, err := compileRule(ruleConfig, len(hmacKey) > 0)
+		if err != nil {
+			name := ruleConfig.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("invalid redaction rule %s: %w", name, err)
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	return engine, nil
+}
+
+func compileRule(config RuleConfig, hasKey bool) (rule, error) {
+	compiled := rule{RuleConfig: config}
+
+	switch config.Mode {
+	case ModeMask:
+		if compiled.Mask == "" {
+			compiled.Mask = DefaultMask
+		}
+	case ModeHash:
+		if !hasKey {
+			return rule{}, errors.New("hash mode requires an HMAC key")
+		}
+	case ModeDrop:
+	case ModeTruncate:
+		if config.MaxLength <= 0 {
+			return rule{}, errors.New("truncate mode requires a positive max_length")
+		}
+		if len(config.Detectors) > 0 || config.Pattern != "" {
+			return rule{}, errors.New("truncate mode does not apply to detectors")
+		}
+	default:
+		return rule{}, fmt.Errorf("unknown mode %q, supported %s, %s, %s, %s",
+			config.Mode, ModeMask, ModeHash, ModeDrop, ModeTruncate)
+	}
+
+	for _, pattern := range config.Types {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule{}, fmt.Errorf("invalid type pattern %q: %w", pattern, err)
+		}
+	}
+
+	for _, field := range config.Fields {
+		sel, err := ParseSelector(field)
+		if err != nil {
+			return rule{}, err
+		}
+		compiled.selectors = append(compiled.selectors, sel)
+	}
+
+	detectors, err := newDetectors(config.Detectors, config.Pattern)
+	if err != nil {
+		return rule{}, err
+	}
+	compiled.detectors = detectors
+
+	if len(compiled.selectors) == 0 && len(compiled.detectors) == 0 {
+		return rule{}, errors.New("rule needs fields, detectors or a pattern")
+	}
+	return compiled, nil
+}
+
+// matches reports whether the rule applies to an event type.
+func (r rule) matches(eventType string) bool {
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, pattern := range r.Types {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact applies the rules matching the event type to a decoded JSON
+// document in order and returns the redacted document. Objects and arrays
+// are modified in place. A document dropped as a whole is returned as nil.
+func (e *Engine) Redact(eventType string, doc any) any {
+	if e == nil {
+		return doc
+	}
+	for _, r := range e.rules {
+		if !r.matches(eventType) {
+			continue
+		}
+		doc = e.applyRule(r, doc)
+	}
+	return doc
+}
+
+// RedactString applies the detector rules matching the event type to a raw
+// message. Field rules do not apply to unstructured text; a dropped message
+// is returned empty.
+func (e *Engine) RedactString(eventType, s string) string {
+	if e == nil {
+		return s
+	}
+	for _, r := range e.rules {
+		if len(r.selectors) > 0 || !r.matches(eventType) {
+			continue
+		}
+		value, keep := e.detect(r, s)
+		if !keep {
+			return ""
+		}
+		s, _ = value.(string)
+	}
+	return s
+}
+
+func (e *Engine) applyRule(r rule, doc any) any {
+	fn := func(value any) (any, bool) { return e.redactValue(r, value) }
+	if len(r.detectors) > 0 {
+		fn = func(value any) (any, bool) { return e.detectAll(r, value) }
+	}
+
+	if len(r.selectors) == 0 {
+		result, keep := fn(doc)
+		if !keep {
+			return nil
+		}
+		return result
+	}
+	for _, sel := range r.selectors {
+		doc = sel.apply(doc, fn)
+	}
+	return doc
+}
+
+// detectAll runs the rule detectors over every string in value.
+func (e *Engine) detectAll(r rule, value any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		return e.detect(r, v)
+	case map[string]any, []any:
+		return applyChildren(v, func(child any) (any, bool) { return e.detectAll(r, child) }), true
+	default:
+		return value, true
+	}
+}
+
+// detect redacts the matches of the rule detectors in s. In drop mode a
+// string containing a match is removed.
+func (e *Engine) detect(r rule, s string) (any, bool) {
+	for _, d := range r.detectors {
+		replaced, found := d.replace(s, func(match string) string {
+			if r.Mode == ModeHash {
+				return e.hash(match)
+			}
+			return r.Mask
+		})
+		if !found {
+			continue
+		}
+		if r.Mode == ModeDrop {
+			return nil, false
+		}
+		s = replaced
+	}
+	return s, true
+}
+
+// redactValue redacts a whole selected value.
+func (e *Engine) redactValue(r rule, value any) (any, bool) {
+	switch r.Mode {
+	case ModeMask:
+		return r.Mask, true
+	case ModeHash:
+		return e.hash(scalarString(value)), true
+	case ModeDrop:
+		return nil, false
+	case ModeTruncate:
+		return truncate(scalarString(value), r.MaxLength), true
+	default:
+		return value, true
+	}
+}
+
+// hash returns the truncated hex HMAC-SHA256 of s.
+func (e *Engine) hash(s string) string {
+	mac := hmac.New(sha256.New, e.hmacKey)
+	mac.Write([]byte(s))
+	return HashPrefix + hex.EncodeToString(mac.Sum(nil))[:hashHexLength]
+}
+
+// scalarString returns strings as they are and other values as JSON, so
+// that objects are hashed or truncated by their encoding.
+func scalarString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func truncate(s string, maxLength int) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxLength]) + truncateSuffix
+}
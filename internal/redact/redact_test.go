@@ -0,0 +1,189 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"events-audit/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("fixture-key") //nolint:gochecknoglobals // test HMAC key shared by the fixtures
+
+func TestFixtures(t *testing.T) {
+	engine, err := redact.Load(filepath.Join("testdata", "rules.yaml"), testKey)
+	require.NoError(t, err)
+
+	fixtures, err := redact.LoadFixtures(filepath.Join("testdata", "fixtures"))
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			require.NoError(t, fixture.Check(engine))
+		})
+	}
+}
+
+func TestFixture_CheckReportsMismatch(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.password"}, Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+
+	fixture := redact.Fixture{
+		Name:     "unmasked",
+		Input:    json.RawMessage(`{"password":"x"}`),
+		Expected: json.RawMessage(`{"password":"x"}`),
+	}
+	require.ErrorContains(t, fixture.Check(engine), `got {"password":"[REDACTED]"}`)
+}
+
+func TestEngine_Modes(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.items[1]"}, Mode: redact.ModeDrop},
+		{Fields: []string{"$.nested"}, Mode: redact.ModeHash},
+		{Fields: []string{"$.count"}, Mode: redact.ModeTruncate, MaxLength: 2},
+		{Detectors: []string{redact.DetectEmail}, Mode: redact.ModeDrop},
+	}}, testKey)
+	require.NoError(t, err)
+
+	doc := map[string]any{
+		"items":  []any{"a", "b", "c"},
+		"nested": map[string]any{"k": "v"},
+		"count":  12345.0,
+		"owner":  "dave@example.com",
+		"list":   []any{"x", "eve@example.com"},
+	}
+	redacted := engine.Redact("", doc).(map[string]any)
+
+	assert.Equal(t, []any{"a", "c"}, redacted["items"])
+	assert.Regexp(t, `^hmac:[0-9a-f]{32}$`, redacted["nested"])
+	assert.Equal(t, "12…", redacted["count"])
+	assert.NotContains(t, redacted, "owner")
+	assert.Equal(t, []any{"x"}, redacted["list"])
+
+	// Hashes are stable for joining and depend on the key.
+	other, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.nested"}, Mode: redact.ModeHash},
+	}}, []byte("other-key"))
+	require.NoError(t, err)
+	again := engine.Redact("", map[string]any{"nested": map[string]any{"k": "v"}}).(map[string]any)
+	otherKey := other.Redact("", map[string]any{"nested": map[string]any{"k": "v"}}).(map[string]any)
+	assert.Equal(t, redacted["nested"], again["nested"])
+	assert.NotEqual(t, redacted["nested"], otherKey["nested"])
+
+	assert.Empty(t, engine.RedactString("", "mail dave@example.com"))
+	assert.Equal(t, "nothing here", engine.RedactString("", "nothing here"))
+}
+
+func TestEngine_Types(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Types: []string{"user.*", "audit.login"}, Fields: []string{"$.ip"}, Mode: redact.ModeMask},
+	}}, nil)
+	require.NoError(t, err)
+
+	for eventType, masked := range map[string]bool{
+		"user.created": true,
+		"audit.login":  true,
+		"order.paid":   false,
+		"":             false,
+	} {
+		doc := engine.Redact(eventType, map[string]any{"ip": "10.0.0.1"}).(map[string]any)
+		if masked {
+			assert.Equal(t, redact.DefaultMask, doc["ip"], eventType)
+		} else {
+			assert.Equal(t, "10.0.0.1", doc["ip"], eventType)
+		}
+	}
+}
+
+func TestEngine_NilIsNoop(t *testing.T) {
+	var engine *redact.Engine
+	doc := map[string]any{"password": "x"}
+	assert.Equal(t, doc, engine.Redact("", doc))
+	assert.Equal(t, "x", engine.RedactString("", "x"))
+}
+
+func TestEngine_RedactMessage(t *testing.T) {
+	engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+		{Fields: []string{"$.data.password", "$.tenant"}, Mode: redact.ModeMask},
+		{Detectors: []string{redact.DetectEmail}, Mode: redact.ModeMask, Mask: "[EMAIL]"},
+	}}, nil)
+	require.NoError(t, err)
+
+	t.Run("legacy event", func(t *testing.T) {
+		body := []byte(`{"id":"1","type":"user.created","data":{"password":"x","count":12345678901234567890}}`)
+		header, redacted := engine.RedactMessage(map[string][]string{"X-User": {"dave@example.com"}}, body)
+		assert.JSONEq(t, `{"id":"1","type":"user.created","data":{"password":"[REDACTED]","count":12345678901234567890}}`, string(redacted))
+		assert.Equal(t, map[string][]string{"X-User": {"[EMAIL]"}}, header)
+		assert.Contains(t, string(body), `"password":"x"`, "the message is not modified")
+	})
+
+	t.Run("binary CloudEvent", func(t *testing.T) {
+		header := map[string][]string{
+			"ce-specversion": {"1.0"},
+			"ce-id":          {"1"},
+			"ce-type":        {"user.created"},
+			"ce-source":      {"dave@example.com"},
+			"ce-tenant":      {"acme"},
+			"Content-Type":   {"application/json"},
+		}
+		redactedHeader, body := engine.RedactMessage(header, []byte(`{"password":"x","owner":"dave@example.com"}`))
+		assert.JSONEq(t, `{"password":"[REDACTED]","owner":"[EMAIL]"}`, string(body))
+		assert.Equal(t, []string{redact.DefaultMask}, redactedHeader["ce-tenant"])
+		assert.Equal(t, []string{"dave@example.com"}, redactedHeader["ce-source"], "context attributes are kept")
+		assert.Equal(t, []string{"acme"}, header["ce-tenant"])
+	})
+
+	t.Run("structured CloudEvent", func(t *testing.T) {
+		body := []byte(`{"specversion":"1.0","id":"1","type":"user.created","source":"svc","tenant":"acme",` +
+			`"datacontenttype":"text/plain","data":"mail dave@example.com"}`)
+		_, redacted := engine.RedactMessage(nil, body)
+		assert.JSONEq(t, `{"specversion":"1.0","id":"1","type":"user.created","source":"svc","tenant":"[REDACTED]",`+
+			`"datacontenttype":"text/plain","data":"mail [EMAIL]"}`, string(redacted))
+	})
+
+	t.Run("raw message", func(t *testing.T) {
+		_, redacted := engine.RedactMessage(nil, []byte("login by dave@example.com"))
+		assert.Equal(t, "login by [EMAIL]", string(redacted))
+	})
+
+	t.Run("nil engine", func(t *testing.T) {
+		var nilEngine *redact.Engine
+		header, body := nilEngine.RedactMessage(nil, []byte(`{"password":"x"}`))
+		assert.Nil(t, header)
+		assert.Equal(t, `{"password":"x"}`, string(body))
+	})
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	tests := map[string]redact.RuleConfig{
+		"unknown mode":                {Fields: []string{"$.a"}, Mode: "erase"},
+		"requires an HMAC key":        {Fields: []string{"$.a"}, Mode: redact.ModeHash},
+		"positive max_length":         {Fields: []string{"$.a"}, Mode: redact.ModeTruncate},
+		"does not apply to detectors": {Detectors: []string{redact.DetectEmail}, Mode: redact.ModeTruncate, MaxLength: 3},
+		"unknown detector":            {Detectors: []string{"ssn"}, Mode: redact.ModeMask},
+		"invalid pattern":             {Pattern: "(", Mode: redact.ModeMask},
+		"must start with $":           {Fields: []string{"a.b"}, Mode: redact.ModeMask},
+		"invalid type pattern":        {Types: []string{"["}, Fields: []string{"$.a"}, Mode: redact.ModeMask},
+		"needs fields":                {Mode: redact.ModeMask},
+	}
+
+	for message, rule := range tests {
+		_, err := redact.New(redact.Config{Rules: []redact.RuleConfig{rule}}, nil)
+		require.ErrorContains(t, err, message)
+	}
+}
+
+func TestLoad_RejectsUnknownKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("rules:\n  - fields: [$.a]\n    mode: mask\n    maks: x\n"), 0o600))
+
+	_, err := redact.Load(file, nil)
+	require.ErrorContains(t, err, "maks")
+}
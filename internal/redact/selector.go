@@ -0,0 +1,199 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one segment of a selector path.
+type step struct {
+	// descendant matches at any depth below the current node, as in ..name.
+	descendant bool
+	wildcard   bool
+	name       string
+	// isIndex marks array element steps selecting index.
+	isIndex bool
+	index   int
+}
+
+// Selector addresses values of a JSON document with a JSONPath subset:
+// $ for the root, .name or ['name'] for object members, [n] for array
+// elements, * or [*] for every member or element and ..name for members at
+// any depth.
+type Selector struct {
+	path  string
+	steps []step
+}
+
+// ParseSelector parses a selector such as $.data.user.email or
+// $..password.
+func ParseSelector(path string) (Selector, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return Selector{}, fmt.Errorf("selector %q must start with $", path)
+	}
+
+	sel := Selector{path: path}
+	for rest != "" {
+		var (
+			s   step
+			err error
+		)
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s, rest, err = parseMember(rest[2:])
+			s.descendant = true
+		case strings.HasPrefix(rest, "."):
+			s, rest, err = parseMember(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			s, rest, err = parseBracket(rest)
+		default:
+			err = fmt.Errorf("unexpected %q", rest)
+		}
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid selector %q: %w", path, err)
+		}
+		sel.steps = append(sel.steps, s)
+	}
+
+	if len(sel.steps) == 0 {
+		return Selector{}, fmt.Errorf("selector %q selects the whole document", path)
+	}
+	return sel, nil
+}
+
+// parseMember parses a dotted member name or wildcard; a bracket may
+// directly follow a descendant step, as in ..['name'].
+func parseMember(rest string) (step, string, error) {
+	if strings.HasPrefix(rest, "[") {
+		return parseBracket(rest)
+	}
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+	name := rest[:end]
+	if name == "" {
+		return step{}, "", errors.New("empty member name")
+	}
+	if name == "*" {
+		return step{wildcard: true}, rest[end:], nil
+	}
+	return step{name: name}, rest[end:], nil
+}
+
+func parseBracket(rest string) (step, string, error) {
+	end := strings.Index(rest, "]")
+	if end < 0 {
+		return step{}, "", errors.New("unterminated [")
+	}
+	inner, rest := rest[1:end], rest[end+1:]
+
+	switch {
+	case inner == "*":
+		return step{wildcard: true}, rest, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return step{name: inner[1 : len(inner)-1]}, rest, nil
+	default:
+		index, err := strconv.Atoi(inner)
+		if err != nil || index < 0 {
+			return step{}, "", fmt.Errorf("invalid index %q", inner)
+		}
+		return step{isIndex: true, index: index}, rest, nil
+	}
+}
+
+func (s Selector) String() string {
+	return s.path
+}
+
+// transform replaces a selected value; returning false removes it.
+type transform func(value any) (any, bool)
+
+// apply runs fn on every value selected in doc and returns the updated
+// document. Objects and arrays are modified in place.
+func (s Selector) apply(doc any, fn transform) any {
+	result, keep := applySteps(doc, s.steps, fn)
+	if !keep {
+		return nil
+	}
+	return result
+}
+
+func applySteps(node any, steps []step, fn transform) (any, bool) {
+	if len(steps) == 0 {
+		return fn(node)
+	}
+
+	current, rest := steps[0], steps[1:]
+	if current.descendant {
+		// Descend first so that the value selected at this level is
+		// transformed after its own members.
+		node = applyChildren(node, func(child any) (any, bool) {
+			return applySteps(child, steps, fn)
+		})
+	}
+
+	switch value := node.(type) {
+	case map[string]any:
+		if current.wildcard {
+			return applyChildren(value, func(child any) (any, bool) { return applySteps(child, rest, fn) }), true
+		}
+		if current.isIndex {
+			return node, true
+		}
+		child, ok := value[current.name]
+		if !ok {
+			return node, true
+		}
+		if updated, keep := applySteps(child, rest, fn); keep {
+			value[current.name] = updated
+		} else {
+			delete(value, current.name)
+		}
+		return value, true
+	case []any:
+		if current.wildcard {
+			return applyChildren(value, func(child any) (any, bool) { return applySteps(child, rest, fn) }), true
+		}
+		if !current.isIndex || current.index >= len(value) {
+			return node, true
+		}
+		updated, keep := applySteps(value[current.index], rest, fn)
+		if !keep {
+			return append(value[:current.index], value[current.index+1:]...), true
+		}
+		value[current.index] = updated
+		return value, true
+	default:
+		return node, true
+	}
+}
+
+// applyChildren runs fn on every member of an object or element of an
+// array, removing those for which it returns false.
+func applyChildren(node any, fn transform) any {
+	switch value := node.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if updated, keep := fn(child); keep {
+				value[key] = updated
+			} else {
+				delete(value, key)
+			}
+		}
+		return value
+	case []any:
+		kept := value[:0]
+		for _, child := range value {
+			if updated, keep := fn(child); keep {
+				kept = append(kept, updated)
+			}
+		}
+		return kept
+	default:
+		return node
+	}
+}
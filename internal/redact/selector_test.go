@@ -0,0 +1,59 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"events-audit/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const selectorDoc = `{
+	"user": {"name": "a", "password": "p1", "keys": [{"password": "p2"}, {"id": 1}]},
+	"list": [1, 2, 3],
+	"odd key": "x"
+}`
+
+func TestParseSelector(t *testing.T) {
+	for _, valid := range []string{"$.a", "$..a", "$.a[0]", "$.a[*].b", "$['odd key']", `$["a"].b`, "$.*", "$..[*]"} {
+		_, err := redact.ParseSelector(valid)
+		require.NoError(t, err, valid)
+	}
+	for _, invalid := range []string{"", "$", "a", "$.", "$..", "$.a[", "$.a[-1]", "$.a[x]", "$a"} {
+		_, err := redact.ParseSelector(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected string
+	}{
+		{"$.user.name", `{"user":{"name":"[REDACTED]","password":"p1","keys":[{"password":"p2"},{"id":1}]},"list":[1,2,3],"odd key":"x"}`},
+		{"$..password", `{"user":{"name":"a","password":"[REDACTED]","keys":[{"password":"[REDACTED]"},{"id":1}]},"list":[1,2,3],"odd key":"x"}`},
+		{"$.user.keys[*].id", `{"user":{"name":"a","password":"p1","keys":[{"password":"p2"},{"id":"[REDACTED]"}]},"list":[1,2,3],"odd key":"x"}`},
+		{"$.list[1]", `{"user":{"name":"a","password":"p1","keys":[{"password":"p2"},{"id":1}]},"list":[1,"[REDACTED]",3],"odd key":"x"}`},
+		{"$['odd key']", `{"user":{"name":"a","password":"p1","keys":[{"password":"p2"},{"id":1}]},"list":[1,2,3],"odd key":"[REDACTED]"}`},
+		{"$.list[7]", `{"user":{"name":"a","password":"p1","keys":[{"password":"p2"},{"id":1}]},"list":[1,2,3],"odd key":"x"}`},
+		{"$.missing.path", `{"user":{"name":"a","password":"p1","keys":[{"password":"p2"},{"id":1}]},"list":[1,2,3],"odd key":"x"}`},
+		{"$.user.*", `{"user":{"name":"[REDACTED]","password":"[REDACTED]","keys":"[REDACTED]"},"list":[1,2,3],"odd key":"x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			engine, err := redact.New(redact.Config{Rules: []redact.RuleConfig{
+				{Fields: []string{tt.selector}, Mode: redact.ModeMask},
+			}}, nil)
+			require.NoError(t, err)
+
+			var doc any
+			require.NoError(t, json.Unmarshal([]byte(selectorDoc), &doc))
+			got, err := json.Marshal(engine.Redact("", doc))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(got))
+		})
+	}
+}
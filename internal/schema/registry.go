@@ -0,0 +1,247 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	// FileExt is the extension of schema files in the registry directory.
+	FileExt = ".json"
+	// VersionSeparator separates the event type from the version in a
+	// schema file name, as in user.created@2.json.
+	VersionSeparator = "@"
+)
+
+// ErrNoSchema is returned by Validate for event types without a schema.
+var ErrNoSchema = errors.New("no schema registered for event type")
+
+// Key identifies a schema by event type and optional version.
+type Key struct {
+	Type    string
+	Version string
+}
+
+func (k Key) String() string {
+	if k.Version == "" {
+		return k.Type
+	}
+	return k.Type + VersionSeparator + k.Version
+}
+
+// ParseFileName derives the schema key from a schema file name.
+func ParseFileName(name string) (Key, error) {
+	base := filepath.Base(name)
+	if !strings.HasSuffix(base, FileExt) {
+		return Key{}, fmt.Errorf("schema file %q must have the %s extension", base, FileExt)
+	}
+	eventType, version, versioned := strings.Cut(strings.TrimSuffix(base, FileExt), VersionSeparator)
+	if eventType == "" {
+		return Key{}, fmt.Errorf("schema file %q has no event type", base)
+	}
+	if versioned && (version == "" || strings.Contains(version, VersionSeparator)) {
+		return Key{}, fmt.Errorf("schema file %q has an invalid version", base)
+	}
+	return Key{Type: eventType, Version: version}, nil
+}
+
+// Problem describes a schema file that cannot be used.
+type Problem struct {
+	File   string
+	Detail string
+}
+
+// ValidationError lists why event data does not match its schema.
+type ValidationError struct {
+	Key        Key
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("event %s failed schema validation: %s", e.Key, strings.Join(e.Violations, "; "))
+}
+
+// Registry holds the compiled schemas of a directory, keyed by event type
+// and version.
+type Registry struct {
+	dir     string
+	schemas map[Key]*jsonschema.Schema
+}
+
+// Load compiles every schema file in dir. It fails when any file has a
+// problem, so that a broken schema is not silently ignored.
+func Load(dir string) (*Registry, error) {
+	reg, problems, err := compile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("schema directory %s has %d problems, first %s: %s",
+			dir, len(problems), problems[0].File, problems[0].Detail)
+	}
+	return reg, nil
+}
+
+// Lint checks every schema file in dir and returns the problems found:
+// invalid file names, invalid JSON, schemas that do not compile and
+// examples that do not match their schema.
+func Lint(dir string) (*Registry, []Problem, error) {
+	return compile(dir)
+}
+
+func compile(dir string) (*Registry, []Problem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema directory: %w", err)
+	}
+
+	reg := &Registry{dir: dir, schemas: make(map[Key]*jsonschema.Schema)}
+	var problems []Problem
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != FileExt {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		key, keyErr := ParseFileName(entry.Name())
+		if keyErr != nil {
+			problems = append(problems, Problem{File: path, Detail: keyErr.Error()})
+			continue
+		}
+
+		sch, fileProblems := compileFile(path)
+		if len(fileProblems) > 0 {
+			problems = append(problems, fileProblems...)
+			continue
+		}
+		reg.schemas[key] = sch
+	}
+
+	if len(reg.schemas) == 0 && len(problems) == 0 {
+		problems = append(problems, Problem{File: dir, Detail: "no schema files found"})
+	}
+	return reg, problems, nil
+}
+
+// compileFile compiles a schema file on its own compiler, so a broken file
+// does not affect the others, and checks its examples.
+func compileFile(path string) (*jsonschema.Schema, []Problem) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []Problem{{File: path, Detail: err.Error()}}
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(content))
+	if err != nil {
+		return nil, []Problem{{File: path, Detail: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if addErr := compiler.AddResource(path, doc); addErr != nil {
+		return nil, []Problem{{File: path, Detail: addErr.Error()}}
+	}
+	sch, err := compiler.Compile(path)
+	if err != nil {
+		return nil, []Problem{{File: path, Detail: fmt.Sprintf("invalid schema: %v", err)}}
+	}
+
+	var problems []Problem
+	if obj, ok := doc.(map[string]any); ok {
+		examples, _ := obj["examples"].([]any)
+		for i, example := range examples {
+			if validateErr := sch.Validate(example); validateErr != nil {
+				problems = append(problems, Problem{
+					File:   path,
+					Detail: fmt.Sprintf("example %d: %s", i, strings.Join(violations(validateErr), "; ")),
+				})
+			}
+		}
+	}
+	return sch, problems
+}
+
+// Dir returns the directory the registry was loaded from.
+func (r *Registry) Dir() string {
+	return r.dir
+}
+
+// Keys returns the keys of the registered schemas in sorted order.
+func (r *Registry) Keys() []Key {
+	keys := make([]Key, 0, len(r.schemas))
+	for key := range r.schemas {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// Lookup returns the schema key for an event type and version. A versioned
+// schema takes precedence over the unversioned one of the same type.
+func (r *Registry) Lookup(eventType, version string) (Key, bool) {
+	if version != "" {
+		key := Key{Type: eventType, Version: version}
+		if _, ok := r.schemas[key]; ok {
+			return key, true
+		}
+	}
+	key := Key{Type: eventType}
+	_, ok := r.schemas[key]
+	return key, ok
+}
+
+// Validate checks JSON event data against the schema of the event type and
+// version. Empty data is validated as null. It returns an error wrapping
+// ErrNoSchema when the type has no schema and a *ValidationError when the
+// data does not match.
+func (r *Registry) Validate(eventType, version string, data []byte) error {
+	key, ok := r.Lookup(eventType, version)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoSchema, Key{Type: eventType, Version: version})
+	}
+
+	var instance any
+	if len(bytes.TrimSpace(data)) > 0 {
+		var err error
+		instance, err = jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return &ValidationError{Key: key, Violations: []string{fmt.Sprintf("data is not valid JSON: %v", err)}}
+		}
+	}
+
+	if err := r.schemas[key].Validate(instance); err != nil {
+		return &ValidationError{Key: key, Violations: violations(err)}
+	}
+	return nil
+}
+
+// violations flattens a schema validation error into one message per
+// failing keyword, prefixed with the JSON pointer of the failing value.
+func violations(err error) []string {
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []string{err.Error()}
+	}
+
+	var result []string
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		result = append(result, location+": "+unit.Error.String())
+	}
+	if len(result) == 0 {
+		result = append(result, validationErr.Error())
+	}
+	return result
+}
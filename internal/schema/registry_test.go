@@ -0,0 +1,121 @@
+package schema_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"events-audit/internal/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userCreatedSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["user_id"],
+	"properties": {
+		"user_id": {"type": "string"},
+		"email": {"type": "string"}
+	},
+	"examples": [{"user_id": "42"}]
+}`
+
+func writeSchemas(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestParseFileName(t *testing.T) {
+	key, err := schema.ParseFileName("user.created.json")
+	require.NoError(t, err)
+	assert.Equal(t, schema.Key{Type: "user.created"}, key)
+
+	key, err = schema.ParseFileName("dir/user.created@2.json")
+	require.NoError(t, err)
+	assert.Equal(t, schema.Key{Type: "user.created", Version: "2"}, key)
+	assert.Equal(t, "user.created@2", key.String())
+
+	for _, name := range []string{"user.yaml", ".json", "@1.json", "user@.json", "user@1@2.json"} {
+		_, err = schema.ParseFileName(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"user.created.json":   userCreatedSchema,
+		"user.created@2.json": `{"type": "object", "required": ["user_id", "tenant"]}`,
+		"README.md":           "not a schema",
+	})
+
+	reg, err := schema.Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []schema.Key{{Type: "user.created"}, {Type: "user.created", Version: "2"}}, reg.Keys())
+
+	require.NoError(t, reg.Validate("user.created", "", []byte(`{"user_id": "42"}`)))
+	// Versions without their own schema fall back to the unversioned one.
+	require.NoError(t, reg.Validate("user.created", "1", []byte(`{"user_id": "42"}`)))
+
+	err = reg.Validate("user.created", "", []byte(`{"user_id": 42, "email": false}`))
+	var validationErr *schema.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, schema.Key{Type: "user.created"}, validationErr.Key)
+	assert.Len(t, validationErr.Violations, 2)
+	assert.Contains(t, err.Error(), "/user_id")
+
+	err = reg.Validate("user.created", "2", []byte(`{"user_id": "42"}`))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, schema.Key{Type: "user.created", Version: "2"}, validationErr.Key)
+	assert.Contains(t, validationErr.Violations[0], "tenant")
+
+	err = reg.Validate("user.created", "", nil)
+	require.ErrorAs(t, err, &validationErr)
+
+	err = reg.Validate("user.created", "", []byte(`{garbage`))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Violations[0], "not valid JSON")
+
+	require.ErrorIs(t, reg.Validate("user.deleted", "", []byte(`{}`)), schema.ErrNoSchema)
+}
+
+func TestLint(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"user.created.json": userCreatedSchema,
+		"broken.json":       `{"type": `,
+		"bad-keyword.json":  `{"type": "unknown-type"}`,
+		"bad-example.json":  `{"type": "object", "required": ["id"], "examples": [{"id": 1}, {}]}`,
+		"@1.json":           `{}`,
+	})
+
+	reg, problems, err := schema.Lint(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []schema.Key{{Type: "user.created"}}, reg.Keys())
+
+	details := make(map[string]string)
+	for _, problem := range problems {
+		details[filepath.Base(problem.File)] = problem.Detail
+	}
+	assert.Len(t, details, 4)
+	assert.Contains(t, details["broken.json"], "invalid JSON")
+	assert.Contains(t, details["bad-keyword.json"], "invalid schema")
+	assert.Contains(t, details["bad-example.json"], "example 1")
+	assert.Contains(t, details["@1.json"], "no event type")
+
+	_, err = schema.Load(dir)
+	require.ErrorContains(t, err, "4 problems")
+
+	_, problems, err = schema.Lint(t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "no schema files found", problems[0].Detail)
+
+	_, _, err = schema.Lint(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
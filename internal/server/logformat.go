@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+
+	"events-audit/internal/siem"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+	LogFormatCEF  = siem.FormatCEF
+	LogFormatLEEF = siem.FormatLEEF
+)
+
+const logTimestampFormat = "02.01.2006 15:04:05.000"
+
+// NewLogFormatter creates the formatter of a log format. The SIEM mapping
+// file is only read by the cef and leef formats; version is the product
+// version used in their header.
+func NewLogFormatter(format, mappingFile, version string) (logrus.Formatter, error) {
+	switch format {
+	case LogFormatText:
+		return &logrus.TextFormatter{
+			TimestampFormat: logTimestampFormat,
+			FullTimestamp:   true,
+		}, nil
+	case LogFormatJSON:
+		return &logrus.JSONFormatter{
+			TimestampFormat: logTimestampFormat,
+		}, nil
+	case LogFormatCEF, LogFormatLEEF:
+		var mapping siem.Mapping
+		if mappingFile != "" {
+			var err error
+			if mapping, err = siem.LoadMapping(mappingFile); err != nil {
+				return nil, err
+			}
+		}
+		formatter, err := siem.NewFormatter(format, mapping, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s formatter: %w", format, err)
+		}
+		return formatter, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, supported text, json, cef or leef", format)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// ReplayOptions select the stream range replayed by Replay.
+type ReplayOptions struct {
+	nats.ReadOptions
+	// DryRun handles the messages without side effects: handled events are
+	// logged instead of delivered to the sinks and invalid events are not
+	// quarantined.
+	DryRun bool
+	// Rate limits the messages replayed per second; zero is unlimited.
+	Rate int
+}
+
+// ReplayReport counts the messages of a replay.
+type ReplayReport struct {
+	Read     int
+	Handled  int
+	Failed   int
+	FirstSeq uint64
+	LastSeq  uint64
+	Duration time.Duration
+}
+
+// Fields returns the report as log fields.
+func (r ReplayReport) Fields() logrus.Fields {
+	return logrus.Fields{
+		"read":      r.Read,
+		"handled":   r.Handled,
+		"failed":    r.Failed,
+		"first_seq": r.FirstSeq,
+		"last_seq":  r.LastSeq,
+		"duration":  r.Duration.String(),
+	}
+}
+
+// Replay runs the messages of a stream range through the handler pipeline
+// of the server: schema validation, redaction and the output sinks. The
+// range is read through an ephemeral ordered consumer, so the durable
+// consumer is left untouched. Replayed messages are not archived or
+// published to the live stream again, and failed ones are counted instead
+// of being redelivered or dead-lettered.
+func Replay(ctx context.Context, config Config, opts ReplayOptions) (ReplayReport, error) {
+	var report ReplayReport
+
+	if config.Audit != "" && config.Audit != SourceNATS {
+		return report, fmt.Errorf("replay requires the %s audit source", SourceNATS)
+	}
+	if opts.Rate < 0 {
+		return report, errors.New("replay rate must not be negative")
+	}
+	if err := opts.Validate(); err != nil {
+		return report, err
+	}
+
+	config.Audit = SourceNATS
+	config.CreateStream = false
+	config.StoreDir = ""
+	config.APITokensFile = ""
+	// Failed messages are reported, not dead-lettered.
+	config.DeadLetterStream = ""
+	if opts.DryRun {
+		config.SinksConfigFile = ""
+		if config.SchemaOutcome == nats.SchemaOutcomeQuarantine {
+			config.SchemaOutcome = nats.SchemaOutcomeLog
+		}
+	}
+
+	s, err := NewServer(config, nil)
+	if err != nil {
+		return report, err
+	}
+	defer s.closeSinks()
+
+	client, ok := s.source.(*nats.Client)
+	if !ok {
+		return report, errors.New("replay source is not a JetStream client")
+	}
+	if err = client.Connect(ctx); err != nil {
+		return report, err
+	}
+	defer client.Close()
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"stream":  config.StreamName,
+		"dry_run": opts.DryRun,
+		"rate":    opts.Rate,
+	}).Info("Starting replay")
+
+	handler := nats.EventHandler(s.eventLogger.HandleEvent)
+	start := time.Now()
+	err = client.Read(ctx, opts.ReadOptions, func(msg *source.Msg) error {
+		if waitErr := limiter.Wait(ctx); waitErr != nil {
+			return fmt.Errorf("replay interrupted: %w", waitErr)
+		}
+
+		report.Read++
+		if meta, metaErr := msg.Metadata(); metaErr == nil {
+			if report.FirstSeq == 0 {
+				report.FirstSeq = meta.Sequence.Stream
+			}
+			report.LastSeq = meta.Sequence.Stream
+		}
+
+		if handlerErr := handler(msg); handlerErr != nil {
+			report.Failed++
+			s.logger.WithError(handlerErr).WithFields(logrus.Fields{
+				"subject":  msg.Subject,
+				"sequence": report.LastSeq,
+			}).Error("Failed to replay message")
+			return nil
+		}
+		report.Handled++
+		return nil
+	})
+	report.Duration = time.Since(start)
+
+	return report, err
+}
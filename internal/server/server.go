@@ -3,20 +3,36 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"events-audit/internal/api"
 	"events-audit/internal/constants"
+	"events-audit/internal/embedded"
+	"events-audit/internal/health"
+	"events-audit/internal/metrics"
 	"events-audit/internal/nats"
+	"events-audit/internal/nsq"
+	"events-audit/internal/redact"
+	"events-audit/internal/schema"
+	"events-audit/internal/sink"
+	"events-audit/internal/store"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
 
 // Config holds server configuration.
 type Config struct {
+	// Audit selects the source, one of the Source* constants.
+	Audit string
+
 	NatsURL         string
+	NatsAuth        nats.Auth
 	NatsSubject     string
 	StreamName      string
 	ConsumerName    string
@@ -26,37 +42,124 @@ type Config struct {
 	AckWait         time.Duration
 	PullMaxMessages int
 	PullTimeout     time.Duration
+	MaxAckPending   int
+	Workers         int
+	PartitionKey    string
 	StreamMaxAge    time.Duration
 	StreamMaxBytes  int64
 	StreamMaxMsgs   int64
 	StreamReplicas  int
-	LogLevel        string
-	LogFormat       string
+
+	// NatsEmbedded runs an in-process NATS server listening on
+	// NatsEmbeddedAddr instead of connecting to NatsURL.
+	NatsEmbedded     bool
+	NatsEmbeddedAddr string
+	NatsEmbeddedDir  string
+
+	StreamSubjects        []string
+	StreamStorage         string
+	StreamRetention       string
+	StreamDiscard         string
+	StreamDuplicateWindow time.Duration
+	StreamMaxMsgSize      int32
+	StreamCompression     string
+	StreamDenyDelete      bool
+	StreamDenyPurge       bool
+	StreamMode            string
+
+	DeliverPolicy    string
+	DeliverStartSeq  uint64
+	DeliverStartTime time.Time
+	ReplayPolicy     string
+	ConsumerRecreate bool
+
+	// NSQ source settings. The topic is NatsSubject and the delivery
+	// attempt limit MaxDeliver.
+	NSQAddrs           []string
+	NSQLookupdAddrs    []string
+	NSQChannel         string
+	NSQMaxInFlight     int
+	NSQRequeueDelay    time.Duration
+	NSQMaxRequeueDelay time.Duration
+	NSQTouchInterval   time.Duration
+
+	DeadLetterStream  string
+	DeadLetterSubject string
+	DeadLetterMaxAge  time.Duration
+
+	// SchemaDir holds JSON Schema files validating event data by event
+	// type; validation is disabled when empty. SchemaOutcome is one of the
+	// nats.SchemaOutcome* constants.
+	SchemaDir     string
+	SchemaOutcome string
+	SchemaRequire bool
+
+	QuarantineStream  string
+	QuarantineSubject string
+	QuarantineMaxAge  time.Duration
+
+	// RedactRulesFile holds the YAML redaction rules applied to event data
+	// before it is logged; redaction is disabled when empty.
+	RedactRulesFile   string
+	RedactHMACKeyFile string
+
+	// SinksConfigFile holds the YAML configuration of the output sinks;
+	// events are only logged when empty.
+	SinksConfigFile string
+
+	LogLevel  string
+	LogFormat string
+	// LogMappingFile holds the YAML mapping of the cef and leef formats.
+	LogMappingFile string
+	// Version is reported as the product version by the cef and leef formats.
+	Version string
+
+	FetchStaleAfter time.Duration
+	MaxConsumerLag  uint64
+
+	StoreDir                string
+	StoreSegmentMaxBytes    int64
+	StoreSegmentMaxAge      time.Duration
+	StoreSigningKeyFile     string
+	StoreCheckpointInterval int
+	StoreIndexFields        []string
+
+	// APITokensFile holds the bearer tokens of the events API; the API is
+	// disabled when empty.
+	APITokensFile string
+	// StreamBuffer is the number of live events buffered per stream client
+	// before events are dropped for it.
+	StreamBuffer int
 }
 
 // Server represents the main server.
 type Server struct {
 	config      Config
 	logger      *logrus.Logger
-	natsClient  *nats.Client
+	source      Source
 	eventLogger *nats.EventLogger
+	redactor    *redact.Engine
+	store       *store.SegmentStore
+	apiTokens   [][]byte
+	hub         *api.Hub
+	router      *sink.Router
+	metrics     *metrics.Metrics
 }
 
-// NewServer creates a new server instance.
-func NewServer(config Config) *Server {
+// NewServer creates a new server instance and its audit source. Metrics may
+// be nil.
+func NewServer(config Config, m *metrics.Metrics) (*Server, error) {
 	logger := logrus.New()
 
 	// Configure logger format
-	if config.LogFormat == "json" {
-		logger.SetFormatter(&logrus.JSONFormatter{
-			TimestampFormat: "02.01.2006 15:04:05.000",
-		})
-	} else {
-		logger.SetFormatter(&logrus.TextFormatter{
-			TimestampFormat: "02.01.2006 15:04:05.000",
-			FullTimestamp:   true,
-		})
+	if config.LogFormat == "" {
+		config.LogFormat = LogFormatText
+	}
+	formatter, err := NewLogFormatter(config.LogFormat, config.LogMappingFile, config.Version)
+	if err != nil {
+		return nil, err
 	}
+	logger.SetFormatter(formatter)
 
 	// Set log level
 	if level, err := logrus.ParseLevel(config.LogLevel); err == nil {
@@ -67,6 +170,9 @@ func NewServer(config Config) *Server {
 	}
 
 	// Set default values if not provided
+	if config.Audit == "" {
+		config.Audit = SourceNATS
+	}
 	if config.StreamName == "" {
 		config.StreamName = "EVENTS"
 	}
@@ -88,6 +194,18 @@ func NewServer(config Config) *Server {
 	if config.PullTimeout == 0 {
 		config.PullTimeout = constants.DefaultPullTimeout
 	}
+	if config.NatsEmbeddedAddr == "" {
+		config.NatsEmbeddedAddr = constants.DefaultEmbeddedAddr
+	}
+	if config.NatsEmbeddedDir == "" {
+		config.NatsEmbeddedDir = constants.DefaultEmbeddedStoreDir
+	}
+	if config.MaxAckPending == 0 {
+		config.MaxAckPending = constants.DefaultMaxAckPending
+	}
+	if config.Workers == 0 {
+		config.Workers = constants.DefaultWorkers
+	}
 	if config.StreamMaxAge == 0 {
 		config.StreamMaxAge = constants.DefaultStreamMaxAge
 	}
@@ -100,72 +218,404 @@ func NewServer(config Config) *Server {
 	if config.StreamReplicas == 0 {
 		config.StreamReplicas = constants.DefaultStreamReplicas
 	}
+	if config.FetchStaleAfter == 0 {
+		config.FetchStaleAfter = constants.DefaultFetchStaleAfter
+	}
+	if config.DeadLetterSubject == "" {
+		config.DeadLetterSubject = constants.DefaultDeadLetterSubject
+	}
+	if config.DeadLetterMaxAge == 0 {
+		config.DeadLetterMaxAge = constants.DefaultDeadLetterMaxAge
+	}
+	if config.StoreSegmentMaxBytes == 0 {
+		config.StoreSegmentMaxBytes = constants.DefaultStoreSegmentMaxBytes
+	}
+	if config.StoreSegmentMaxAge == 0 {
+		config.StoreSegmentMaxAge = constants.DefaultStoreSegmentMaxAge
+	}
+	if config.StoreCheckpointInterval == 0 {
+		config.StoreCheckpointInterval = constants.DefaultStoreCheckpointInterval
+	}
+	if config.SchemaOutcome == "" {
+		config.SchemaOutcome = nats.SchemaOutcomeLog
+	}
+	if config.QuarantineSubject == "" {
+		config.QuarantineSubject = constants.DefaultQuarantineSubject
+	}
+	if config.QuarantineMaxAge == 0 {
+		config.QuarantineMaxAge = constants.DefaultQuarantineMaxAge
+	}
+	if config.NSQChannel == "" {
+		config.NSQChannel = constants.DefaultNSQChannel
+	}
+	if config.NSQMaxInFlight == 0 {
+		config.NSQMaxInFlight = constants.DefaultNSQMaxInFlight
+	}
+	if config.NSQRequeueDelay == 0 {
+		config.NSQRequeueDelay = constants.DefaultNSQRequeueDelay
+	}
+	if config.NSQMaxRequeueDelay == 0 {
+		config.NSQMaxRequeueDelay = constants.DefaultNSQMaxRequeueDelay
+	}
+	if config.NSQTouchInterval == 0 {
+		config.NSQTouchInterval = constants.DefaultNSQTouchInterval
+	}
+	if config.StreamBuffer == 0 {
+		config.StreamBuffer = constants.DefaultStreamBuffer
+	}
 
-	return &Server{
+	s := &Server{
 		config:      config,
 		logger:      logger,
 		eventLogger: nats.NewEventLogger(logger),
+		metrics:     m,
 	}
+
+	if err := s.open(); err != nil {
+		s.release()
+		return nil, err
+	}
+
+	return s, nil
 }
 
-// Run starts the server.
-func (s *Server) Run(ctx context.Context) error {
-	s.logger.Info("Starting JetStream events audit server")
-
-	// Create NATS JetStream client
-	natsConfig := nats.Config{
-		URL:             s.config.NatsURL,
-		Subject:         s.config.NatsSubject,
-		StreamName:      s.config.StreamName,
-		ConsumerName:    s.config.ConsumerName,
-		DurableName:     s.config.DurableName,
-		Timeout:         constants.DefaultTimeout,
-		MaxDeliver:      s.config.MaxDeliver,
-		AckWait:         s.config.AckWait,
-		DeliverPolicy:   0, // DeliverAllPolicy
-		ReplayPolicy:    0, // ReplayInstantPolicy
-		PullMaxMessages: s.config.PullMaxMessages,
-		PullTimeout:     s.config.PullTimeout,
-		CreateStream:    s.config.CreateStream,
-		StreamMaxAge:    s.config.StreamMaxAge,
-		StreamMaxBytes:  s.config.StreamMaxBytes,
-		StreamMaxMsgs:   s.config.StreamMaxMsgs,
-		StreamReplicas:  s.config.StreamReplicas,
-	}
-
-	var err error
-	s.natsClient, err = nats.NewClient(natsConfig, s.logger)
+// open loads the server configuration files and opens the audit source,
+// the output sinks and the local archive.
+func (s *Server) open() error {
+	if err := s.loadRedaction(); err != nil {
+		return err
+	}
+
+	source, err := s.newSource()
 	if err != nil {
 		return err
 	}
+	s.source = source
 
-	// Connect to NATS and initialize JetStream
-	if connectErr := s.natsClient.Connect(ctx); connectErr != nil {
-		return connectErr
+	if err = s.loadSchemas(); err != nil {
+		return err
+	}
+
+	if err = s.openSinks(); err != nil {
+		return err
+	}
+
+	if err = s.openStore(); err != nil {
+		return err
+	}
+
+	if s.config.APITokensFile != "" {
+		if s.apiTokens, err = api.LoadTokens(s.config.APITokensFile); err != nil {
+			return err
+		}
+		s.hub = api.NewHub(s.config.StreamBuffer, s.logger)
+		s.hub.SetMetrics(s.metrics)
+		s.hub.SetRedactor(s.redactor)
+	}
+
+	return nil
+}
+
+// release closes the local archive, the output sinks and their spools and
+// the audit source opened by a NewServer call that failed.
+func (s *Server) release() {
+	if s.store != nil {
+		if err := s.store.Close(); err != nil {
+			s.logger.WithError(err).Error("Failed to close local archive")
+		}
+	}
+	s.closeSinks()
+	if s.source != nil {
+		if err := s.source.Close(); err != nil {
+			s.logger.WithError(err).Error("Failed to close audit source")
+		}
+	}
+}
+
+// loadRedaction loads the redaction rules when a rules file is configured.
+func (s *Server) loadRedaction() error {
+	if s.config.RedactRulesFile == "" {
+		return nil
+	}
+
+	var hmacKey []byte
+	if s.config.RedactHMACKeyFile != "" {
+		key, err := redact.LoadHMACKey(s.config.RedactHMACKeyFile)
+		if err != nil {
+			return err
+		}
+		hmacKey = key
+	}
+
+	engine, err := redact.Load(s.config.RedactRulesFile, hmacKey)
+	if err != nil {
+		return err
+	}
+	s.eventLogger.SetRedactor(engine)
+	s.redactor = engine
+
+	s.logger.WithFields(logrus.Fields{
+		"rules":    s.config.RedactRulesFile,
+		"hmac_key": hmacKey != nil,
+	}).Info("Loaded redaction rules")
+
+	return nil
+}
+
+// openSinks opens the output sinks and routes handled events to them when a
+// sink configuration is set.
+func (s *Server) openSinks() error {
+	if s.config.SinksConfigFile == "" {
+		return nil
+	}
+
+	config, err := sink.LoadConfig(s.config.SinksConfigFile)
+	if err != nil {
+		return err
+	}
+	router, err := sink.Open(config, s.logger)
+	if err != nil {
+		return err
+	}
+	router.SetMetrics(s.metrics)
+	s.router = router
+	s.eventLogger.SetRouter(router)
+
+	names := make([]string, 0, len(config.Sinks))
+	for _, sinkConfig := range config.Sinks {
+		names = append(names, sinkConfig.Name)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"config": s.config.SinksConfigFile,
+		"sinks":  names,
+	}).Info("Opened output sinks")
+
+	return nil
+}
+
+// loadSchemas loads the schema registry and enables event validation when a
+// schema directory is configured.
+func (s *Server) loadSchemas() error {
+	s.eventLogger.SetMetrics(s.metrics)
+	if s.config.SchemaDir == "" {
+		return nil
+	}
+
+	registry, err := schema.Load(s.config.SchemaDir)
+	if err != nil {
+		return err
+	}
+
+	policy := nats.SchemaPolicy{
+		Registry:      registry,
+		Outcome:       s.config.SchemaOutcome,
+		RequireSchema: s.config.SchemaRequire,
+	}
+	if policy.Outcome == nats.SchemaOutcomeQuarantine {
+		client, ok := s.source.(*nats.Client)
+		if !ok {
+			return fmt.Errorf("schema outcome %s requires the %s audit source", nats.SchemaOutcomeQuarantine, SourceNATS)
+		}
+		policy.Quarantine = client.Quarantine
+	}
+	s.eventLogger.SetSchemaPolicy(policy)
+
+	keys := make([]string, 0, len(registry.Keys()))
+	for _, key := range registry.Keys() {
+		keys = append(keys, key.String())
+	}
+	s.logger.WithFields(logrus.Fields{
+		"dir":     s.config.SchemaDir,
+		"schemas": keys,
+		"outcome": policy.Outcome,
+		"require": policy.RequireSchema,
+	}).Info("Loaded event schemas")
+
+	return nil
+}
+
+// Validate checks the source options that are parsed from their command
+// line names.
+func (c Config) Validate() error {
+	outcome, err := nats.ParseSchemaOutcome(c.SchemaOutcome)
+	if err != nil {
+		return err
+	}
+	if c.SchemaDir != "" && outcome == nats.SchemaOutcomeQuarantine {
+		if c.Audit == SourceNSQ {
+			return errors.New("the schema quarantine stream requires JetStream and is not supported with nsq audit")
+		}
+		if c.QuarantineStream == "" {
+			return errors.New("schema outcome quarantine requires a quarantine stream")
+		}
+	}
+
+	if c.StreamBuffer < 0 {
+		return errors.New("stream buffer must not be negative")
+	}
+
+	switch c.Audit {
+	case SourceNATS, "":
+		_, err = c.natsConfig()
+		return err
+	case SourceNSQ:
+		if c.StoreDir != "" {
+			// Archive records are keyed by the JetStream stream sequence.
+			return errors.New("the local archive requires JetStream and is not supported with nsq audit")
+		}
+		return c.nsqConfig().Validate()
+	default:
+		return fmt.Errorf("unsupported audit source %q, supported %s or %s", c.Audit, SourceNATS, SourceNSQ)
+	}
+}
+
+// nsqConfig builds the NSQ client configuration. The dead-letter subject
+// names the dead-letter topic; an empty dead-letter stream disables it, as
+// for JetStream.
+func (c Config) nsqConfig() nsq.Config {
+	config := nsq.Config{
+		NSQDAddrs:       c.NSQAddrs,
+		LookupdAddrs:    c.NSQLookupdAddrs,
+		Topic:           c.NatsSubject,
+		Channel:         c.NSQChannel,
+		MaxInFlight:     c.NSQMaxInFlight,
+		Concurrency:     c.Workers,
+		MaxAttempts:     c.MaxDeliver,
+		RequeueDelay:    c.NSQRequeueDelay,
+		MaxRequeueDelay: c.NSQMaxRequeueDelay,
+		TouchInterval:   c.NSQTouchInterval,
+		Timeout:         constants.DefaultTimeout,
+
+		DeadLetterRetryDelay: constants.DefaultDeadLetterRetryDelay,
+		DeadLetterRetries:    constants.DefaultDeadLetterRetries,
+	}
+	if c.DeadLetterStream != "" {
+		config.DeadLetterTopic = c.DeadLetterSubject
+	}
+	return config
+}
+
+// natsConfig builds the JetStream client configuration.
+func (c Config) natsConfig() (nats.Config, error) {
+	deliverPolicy, err := nats.ParseDeliverPolicy(c.DeliverPolicy)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	if err = nats.ValidateStartPosition(deliverPolicy, c.DeliverStartSeq, c.DeliverStartTime); err != nil {
+		return nats.Config{}, err
+	}
+	replayPolicy, err := nats.ParseReplayPolicy(c.ReplayPolicy)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	if _, err = nats.ParsePartitionKey(c.PartitionKey); err != nil {
+		return nats.Config{}, err
+	}
+	streamMode, err := nats.ParseStreamMode(c.StreamMode)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	storage, err := nats.ParseStorage(c.StreamStorage)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	retention, err := nats.ParseRetention(c.StreamRetention)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	discard, err := nats.ParseDiscard(c.StreamDiscard)
+	if err != nil {
+		return nats.Config{}, err
+	}
+	compression, err := nats.ParseCompression(c.StreamCompression)
+	if err != nil {
+		return nats.Config{}, err
+	}
+
+	config := nats.Config{
+		URL:             c.NatsURL,
+		Subject:         c.NatsSubject,
+		StreamName:      c.StreamName,
+		ConsumerName:    c.ConsumerName,
+		DurableName:     c.DurableName,
+		Timeout:         constants.DefaultTimeout,
+		MaxDeliver:      c.MaxDeliver,
+		AckWait:         c.AckWait,
+		DeliverPolicy:   deliverPolicy,
+		ReplayPolicy:    replayPolicy,
+		PullMaxMessages: c.PullMaxMessages,
+		PullTimeout:     c.PullTimeout,
+		MaxAckPending:   c.MaxAckPending,
+		Workers:         c.Workers,
+		PartitionKey:    c.PartitionKey,
+		CreateStream:    c.CreateStream,
+		StreamMaxAge:    c.StreamMaxAge,
+		StreamMaxBytes:  c.StreamMaxBytes,
+		StreamMaxMsgs:   c.StreamMaxMsgs,
+		StreamReplicas:  c.StreamReplicas,
+
+		StreamSubjects:        c.StreamSubjects,
+		StreamStorage:         storage,
+		StreamRetention:       retention,
+		StreamDiscard:         discard,
+		StreamDuplicateWindow: c.StreamDuplicateWindow,
+		StreamMaxMsgSize:      c.StreamMaxMsgSize,
+		StreamCompression:     compression,
+		StreamDenyDelete:      c.StreamDenyDelete,
+		StreamDenyPurge:       c.StreamDenyPurge,
+		StreamMode:            streamMode,
+
+		ConsumerRecreate: c.ConsumerRecreate,
+		DeliverStartSeq:  c.DeliverStartSeq,
+		DeliverStartTime: c.DeliverStartTime,
+
+		DeadLetterStream:  c.DeadLetterStream,
+		DeadLetterSubject: c.DeadLetterSubject,
+		DeadLetterMaxAge:  c.DeadLetterMaxAge,
+
+		DeadLetterRetryDelay: constants.DefaultDeadLetterRetryDelay,
+		DeadLetterRetries:    constants.DefaultDeadLetterRetries,
+
+		FetchStaleAfter: c.FetchStaleAfter,
+		MaxConsumerLag:  c.MaxConsumerLag,
+
+		Auth: c.NatsAuth,
 	}
-	defer s.natsClient.Close()
 
-	// Log stream and consumer information
-	if streamInfo, streamErr := s.natsClient.GetStreamInfo(); streamErr == nil {
-		s.logger.WithFields(logrus.Fields{
-			"stream":    streamInfo.Config.Name,
-			"subjects":  streamInfo.Config.Subjects,
-			"messages":  streamInfo.State.Msgs,
-			"bytes":     streamInfo.State.Bytes,
-			"consumers": streamInfo.State.Consumers,
-		}).Info("JetStream stream information")
+	if c.SchemaDir != "" && c.SchemaOutcome == nats.SchemaOutcomeQuarantine {
+		config.QuarantineStream = c.QuarantineStream
+		config.QuarantineSubject = c.QuarantineSubject
+		config.QuarantineMaxAge = c.QuarantineMaxAge
 	}
 
-	if consumerInfo, consumerErr := s.natsClient.GetConsumerInfo(); consumerErr == nil {
-		s.logger.WithFields(logrus.Fields{
-			"consumer":    consumerInfo.Config.Name,
-			"durable":     consumerInfo.Config.Durable,
-			"delivered":   consumerInfo.Delivered.Consumer,
-			"ack_pending": consumerInfo.NumAckPending,
-			"redelivered": consumerInfo.NumRedelivered,
-		}).Info("JetStream consumer information")
+	if c.NatsEmbedded {
+		config.Embedded = &embedded.Config{
+			Addr:     c.NatsEmbeddedAddr,
+			StoreDir: c.NatsEmbeddedDir,
+		}
+		// The embedded server has no authentication configured.
+		config.Auth = nats.Auth{}
 	}
 
+	return config, nil
+}
+
+// Run starts the server.
+func (s *Server) Run(ctx context.Context) error {
+	s.logger.WithField("source", s.config.Audit).Info("Starting events audit server")
+
+	handler := s.buildHandler()
+	if s.store != nil {
+		defer s.store.Close()
+	}
+	defer s.closeSinks()
+
+	// Connect the audit source
+	if connectErr := s.source.Connect(ctx); connectErr != nil {
+		return connectErr
+	}
+	defer s.source.Close()
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -178,39 +628,114 @@ func (s *Server) Run(ctx context.Context) error {
 		<-sigChan
 		s.logger.Info("Received shutdown signal, initiating graceful shutdown")
 		// Drain subscription before canceling context
-		if drainErr := s.natsClient.Drain(); drainErr != nil {
+		if drainErr := s.source.Drain(); drainErr != nil {
 			s.logger.WithError(drainErr).Error("Failed to drain subscription")
 		}
 		cancel()
 	}()
 
-	// Start subscribing to JetStream events
+	// Start listening for audit events
 	s.logger.WithFields(logrus.Fields{
-		"subject":  s.config.NatsSubject,
-		"stream":   s.config.StreamName,
-		"consumer": s.config.ConsumerName,
-		"durable":  s.config.DurableName,
-	}).Info("Starting to listen for JetStream events")
+		"source":  s.config.Audit,
+		"subject": s.config.NatsSubject,
+	}).Info("Starting to listen for audit events")
 
-	err = s.natsClient.Subscribe(ctx, s.eventLogger.HandleEvent)
+	err := s.source.Subscribe(ctx, handler)
 	if err != nil && !errors.Is(err, context.Canceled) {
-		s.logger.WithError(err).Error("Error during JetStream subscription")
+		s.logger.WithError(err).Error("Error during audit subscription")
+		return err
+	}
+
+	s.logger.Info("Events audit server stopped")
+	return nil
+}
+
+// ReadinessChecks returns the checks that decide whether the server is
+// ready: connectivity of the audit source followed by its own checks and
+// the mandatory output sinks.
+func (s *Server) ReadinessChecks() []health.Check {
+	checks := []health.Check{{Name: s.config.Audit, Run: s.checkConnection}}
+	checks = append(checks, s.source.ReadinessChecks()...)
+	return append(checks, s.router.ReadinessChecks()...)
+}
+
+// SinkStatusHandler serves the delivery state of the output sinks.
+func (s *Server) SinkStatusHandler() http.HandlerFunc {
+	return s.router.StatusHandler()
+}
+
+func (s *Server) closeSinks() {
+	if err := s.router.Close(); err != nil {
+		s.logger.WithError(err).Error("Failed to close output sinks")
+	}
+}
+
+func (s *Server) checkConnection(_ context.Context) error {
+	if !s.source.IsConnected() {
+		return fmt.Errorf("%s connection is not established", s.config.Audit)
+	}
+	return nil
+}
+
+// buildHandler assembles the event handler chain, archiving messages when
+// the local archive is open and publishing handled messages to the live
+// stream when the events API is enabled. The hub redacts what it streams.
+func (s *Server) buildHandler() nats.EventHandler {
+	handler := nats.EventHandler(s.eventLogger.HandleEvent)
+	if s.store != nil {
+		handler = nats.ArchiveHandler(s.store, handler)
+	}
+	if s.hub != nil {
+		handler = nats.TapHandler(s.hub.Publish, handler)
+	}
+	return handler
+}
+
+// openStore opens the local archive when a store directory is configured.
+func (s *Server) openStore() error {
+	if s.config.StoreDir == "" {
+		return nil
+	}
+
+	segmentStore, err := store.Open(store.Config{
+		Dir:             s.config.StoreDir,
+		MaxSegmentBytes: s.config.StoreSegmentMaxBytes,
+		MaxSegmentAge:   s.config.StoreSegmentMaxAge,
+
+		SigningKeyFile:     s.config.StoreSigningKeyFile,
+		CheckpointInterval: s.config.StoreCheckpointInterval,
+		IndexFields:        s.config.StoreIndexFields,
+	}, s.logger)
+	if err != nil {
 		return err
 	}
+	s.store = segmentStore
 
-	s.logger.Info("JetStream events audit server stopped")
 	return nil
 }
 
+// APIHandler serves the authenticated events API, or returns nil when no
+// API tokens are configured. Archive search requires the local archive.
+func (s *Server) APIHandler() http.Handler {
+	if s.apiTokens == nil {
+		return nil
+	}
+
+	r := chi.NewRouter()
+	r.Use(api.Authenticate(s.apiTokens))
+	if s.store != nil {
+		r.Get("/", api.SearchHandler(s.store, s.redactor, s.logger))
+	}
+	r.Get("/stream", s.hub.StreamHandler())
+	return r
+}
+
 // Stop gracefully stops the server.
 func (s *Server) Stop() error {
-	s.logger.Info("Stopping JetStream server")
-	if s.natsClient != nil {
-		// Drain first, then close
-		if err := s.natsClient.Drain(); err != nil {
-			s.logger.WithError(err).Error("Failed to drain subscription during stop")
-		}
-		return s.natsClient.Close()
+	s.logger.Info("Stopping events audit server")
+	// Drain first, then close
+	if err := s.source.Drain(); err != nil {
+		s.logger.WithError(err).Error("Failed to drain subscription during stop")
 	}
-	return nil
+	return s.source.Close()
 }
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"events-audit/internal/health"
+	"events-audit/internal/nats"
+	"events-audit/internal/nsq"
+	"events-audit/internal/source"
+)
+
+// Supported audit sources.
+const (
+	SourceNATS = "nats"
+	SourceNSQ  = "nsq"
+)
+
+// Source delivers audit messages from a transport to the handler chain
+// following the source.Handler contract.
+type Source interface {
+	// Connect establishes the transport connection.
+	Connect(ctx context.Context) error
+	// Subscribe hands messages to handler until ctx is cancelled or the
+	// source is drained.
+	Subscribe(ctx context.Context, handler source.Handler) error
+	// Drain stops receiving messages and lets in-flight ones finish.
+	Drain() error
+	Close() error
+	IsConnected() bool
+	// ReadinessChecks returns checks specific to the transport.
+	ReadinessChecks() []health.Check
+}
+
+// newSource creates the source selected by the configured audit type.
+func (s *Server) newSource() (Source, error) {
+	switch s.config.Audit {
+	case SourceNATS:
+		natsConfig, err := s.config.natsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JetStream configuration: %w", err)
+		}
+		client, err := nats.NewClient(natsConfig, s.logger)
+		if err != nil {
+			return nil, err
+		}
+		client.SetMetrics(s.metrics)
+		return client, nil
+	case SourceNSQ:
+		client, err := nsq.NewClient(s.config.nsqConfig(), s.logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSQ configuration: %w", err)
+		}
+		client.SetMetrics(s.metrics)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit source %q, supported %s or %s", s.config.Audit, SourceNATS, SourceNSQ)
+	}
+}
@@ -0,0 +1,245 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Supported formats.
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+)
+
+const (
+	// signatureLog identifies entries that are not audit events.
+	signatureLog = "log"
+	// leefTimeFormat is the default devTime format of LEEF,
+	// MMM dd yyyy HH:mm:ss.SSS.
+	leefTimeFormat = "Jan 02 2006 15:04:05.000"
+)
+
+// Formatter renders logrus entries as CEF or LEEF lines. Audit events use
+// their type as the signature, other entries the log signature.
+type Formatter struct {
+	format  string
+	mapping Mapping
+}
+
+// NewFormatter creates a formatter for format. The version is used in the
+// header unless the mapping sets one.
+func NewFormatter(format string, mapping Mapping, version string) (*Formatter, error) {
+	var defaults []Extension
+	switch format {
+	case FormatCEF:
+		defaults = defaultCEFExtensions()
+	case FormatLEEF:
+		defaults = defaultLEEFExtensions()
+	default:
+		return nil, fmt.Errorf("unknown SIEM format %q, supported %s or %s", format, FormatCEF, FormatLEEF)
+	}
+
+	mapping = mapping.withDefaults(defaults, version)
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid SIEM mapping: %w", err)
+	}
+	return &Formatter{format: format, mapping: mapping}, nil
+}
+
+// defaultCEFExtensions maps the common event fields to CEF keys.
+func defaultCEFExtensions() []Extension {
+	return []Extension{
+		{Key: "externalId", Field: "event_id"},
+		{Key: "cs1", Label: "subject", Field: "subject"},
+		{Key: "cs2", Label: "source", Field: "source"},
+		{Key: "cn1", Label: "sequence", Field: "sequence"},
+		{Key: "msg", Field: "error"},
+	}
+}
+
+// defaultLEEFExtensions maps the common event fields to LEEF attributes.
+// The header of LEEF has no name, so the message is an attribute.
+func defaultLEEFExtensions() []Extension {
+	return []Extension{
+		{Key: "cat", Field: "event_type"},
+		{Key: "msg", Field: "msg"},
+		{Key: "externalId", Field: "event_id"},
+		{Key: "subject", Field: "subject"},
+		{Key: "source", Field: "source"},
+		{Key: "sequence", Field: "sequence"},
+		{Key: "reason", Field: "error"},
+	}
+}
+
+// Format renders the entry as a single line.
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	signature := signatureLog
+	if eventType, ok := entry.Data["event_type"].(string); ok && eventType != "" {
+		signature = eventType
+	}
+	severity := f.severity(signature, entry.Level)
+
+	var b strings.Builder
+	switch f.format {
+	case FormatLEEF:
+		fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|",
+			escapeLEEFHeader(f.mapping.Vendor),
+			escapeLEEFHeader(f.mapping.Product),
+			escapeLEEFHeader(f.mapping.Version),
+			escapeLEEFHeader(signature),
+		)
+		attrs := []string{
+			"devTime=" + entry.Time.Format(leefTimeFormat),
+			"sev=" + strconv.Itoa(max(severity, 1)),
+		}
+		for _, ext := range f.mapping.Extensions {
+			if value, ok := f.value(ext, entry); ok {
+				if ext.Label != "" {
+					attrs = append(attrs, ext.Key+"Label="+escapeLEEFValue(ext.Label))
+				}
+				attrs = append(attrs, ext.Key+"="+escapeLEEFValue(value))
+			}
+		}
+		b.WriteString(strings.Join(attrs, "\t"))
+	default:
+		fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+			escapeCEFHeader(f.mapping.Vendor),
+			escapeCEFHeader(f.mapping.Product),
+			escapeCEFHeader(f.mapping.Version),
+			escapeCEFHeader(signature),
+			escapeCEFHeader(entry.Message),
+			severity,
+		)
+		exts := []string{"rt=" + strconv.FormatInt(entry.Time.UnixMilli(), 10)}
+		for _, ext := range f.mapping.Extensions {
+			if value, ok := f.value(ext, entry); ok {
+				if ext.Label != "" {
+					exts = append(exts, ext.Key+"Label="+escapeCEFValue(ext.Label))
+				}
+				exts = append(exts, ext.Key+"="+escapeCEFValue(value))
+			}
+		}
+		b.WriteString(strings.Join(exts, " "))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String()), nil
+}
+
+// severity applies the first matching type rule, then the default.
+func (f *Formatter) severity(eventType string, level logrus.Level) int {
+	for _, rule := range f.mapping.Severity.Rules {
+		for _, pattern := range rule.Types {
+			if ok, _ := path.Match(pattern, eventType); ok {
+				return rule.Severity
+			}
+		}
+	}
+	if f.mapping.Severity.Default != nil {
+		return *f.mapping.Severity.Default
+	}
+	return levelSeverity(level)
+}
+
+// levelSeverity maps log levels to the 0-10 severity scale.
+func levelSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 10 //nolint:mnd // very high
+	case logrus.ErrorLevel:
+		return 8 //nolint:mnd // high
+	case logrus.WarnLevel:
+		return 6 //nolint:mnd // medium
+	case logrus.InfoLevel:
+		return 3 //nolint:mnd // low
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return 1
+	default:
+		return 1
+	}
+}
+
+// value resolves the source of an extension for the entry.
+func (f *Formatter) value(ext Extension, entry *logrus.Entry) (string, bool) {
+	switch {
+	case ext.Value != "":
+		return ext.Value, true
+	case ext.Field == "msg":
+		return entry.Message, true
+	case ext.Field != "":
+		value, ok := entry.Data[ext.Field]
+		if !ok {
+			return "", false
+		}
+		if err, isErr := value.(error); isErr {
+			return err.Error(), true
+		}
+		return stringify(value), true
+	default:
+		value, ok := lookupData(entry.Data["event_data"], ext.Data)
+		if !ok {
+			return "", false
+		}
+		return stringify(value), true
+	}
+}
+
+// lookupData follows a dot separated path through nested objects.
+func lookupData(data any, dataPath string) (any, bool) {
+	current := data
+	for _, key := range strings.Split(dataPath, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// stringify renders scalars as text and objects or arrays as JSON.
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]any, []any, []string:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeCEFHeader escapes pipes and backslashes; header fields cannot hold
+// line breaks, which are replaced by spaces.
+func escapeCEFHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+// escapeCEFValue escapes backslashes, equal signs and line breaks of an
+// extension value.
+func escapeCEFValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// escapeLEEFHeader escapes pipes and backslashes of a header field.
+func escapeLEEFHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+// escapeLEEFValue escapes backslashes, the tab delimiter and line breaks of
+// an attribute value.
+func escapeLEEFValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
@@ -0,0 +1,140 @@
+package siem_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"events-audit/internal/siem"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files") //nolint:gochecknoglobals // test flag
+
+// entries covers audit events, special characters and operational logs.
+func entries() []*logrus.Entry {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	return []*logrus.Entry{
+		{
+			Time:    at,
+			Level:   logrus.InfoLevel,
+			Message: "Received structured event",
+			Data: logrus.Fields{
+				"subject":    "audit.users",
+				"sequence":   uint64(42),
+				"event_id":   "e1",
+				"event_type": "user.created",
+				"source":     "user-service",
+				"event_data": map[string]any{
+					"user":   map[string]any{"name": "alice"},
+					"tenant": map[string]any{"id": 7},
+				},
+			},
+		},
+		{
+			Time:    at,
+			Level:   logrus.WarnLevel,
+			Message: "Received | piped\nevent",
+			Data: logrus.Fields{
+				"subject":    "audit.auth",
+				"event_id":   "e2",
+				"event_type": "auth.failed",
+				"source":     `a=b\c` + "\tline\r\nnext",
+				"event_data": map[string]any{
+					"user":    map[string]any{"name": "bob=admin\\root"},
+					"request": map[string]any{"ip": "10.0.0.1", "tags": []any{"a", "b"}},
+				},
+			},
+		},
+		{
+			Time:    at,
+			Level:   logrus.InfoLevel,
+			Message: "Received event",
+			Data: logrus.Fields{
+				"subject":    "audit.auth",
+				"event_type": "auth.login",
+			},
+		},
+		{
+			Time:    at,
+			Level:   logrus.ErrorLevel,
+			Message: "Failed to fetch messages",
+			Data:    logrus.Fields{"error": errors.New("nats: timeout")},
+		},
+	}
+}
+
+func render(t *testing.T, formatter logrus.Formatter) string {
+	t.Helper()
+
+	var b strings.Builder
+	for _, entry := range entries() {
+		line, err := formatter.Format(entry)
+		require.NoError(t, err)
+		b.Write(line)
+	}
+	return b.String()
+}
+
+func TestFormatter_Golden(t *testing.T) {
+	mapping, err := siem.LoadMapping(filepath.Join("testdata", "mapping.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		format  string
+		mapping siem.Mapping
+	}{
+		"default.cef":  {format: siem.FormatCEF},
+		"default.leef": {format: siem.FormatLEEF},
+		"mapped.cef":   {format: siem.FormatCEF, mapping: mapping},
+		"mapped.leef":  {format: siem.FormatLEEF, mapping: mapping},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatter, err := siem.NewFormatter(tt.format, tt.mapping, "1.2.3")
+			require.NoError(t, err)
+			actual := render(t, formatter)
+
+			golden := filepath.Join("testdata", name)
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(actual), 0o600))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), actual)
+		})
+	}
+}
+
+func TestNewFormatter_Invalid(t *testing.T) {
+	severity := 11
+	tests := map[string]siem.Mapping{
+		"invalid extension key": {Extensions: []siem.Extension{{Key: "bad key", Value: "x"}}},
+		"exactly one of field":  {Extensions: []siem.Extension{{Key: "cs1", Field: "a", Data: "b"}}},
+		"default severity":      {Severity: siem.SeverityMapping{Default: &severity}},
+		"severity of types":     {Severity: siem.SeverityMapping{Rules: []siem.SeverityRule{{Types: []string{"a"}, Severity: -1}}}},
+		"invalid type pattern":  {Severity: siem.SeverityMapping{Rules: []siem.SeverityRule{{Types: []string{"["}, Severity: 1}}}},
+	}
+	for message, mapping := range tests {
+		_, err := siem.NewFormatter(siem.FormatCEF, mapping, "")
+		require.ErrorContains(t, err, message)
+	}
+
+	_, err := siem.NewFormatter("xml", siem.Mapping{}, "")
+	require.ErrorContains(t, err, "unknown SIEM format")
+}
+
+func TestLoadMapping_RejectsUnknownKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("vendor: a\nseverities: {}\n"), 0o600))
+
+	_, err := siem.LoadMapping(file)
+	require.ErrorContains(t, err, "field severities not found")
+}
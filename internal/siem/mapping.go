@@ -0,0 +1,136 @@
+package siem
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultVendor  = "events-audit"
+	defaultProduct = "events-audit"
+	defaultVersion = "dev"
+
+	maxSeverity = 10
+)
+
+// Mapping configures how log entries are rendered as CEF or LEEF.
+type Mapping struct {
+	Vendor  string `yaml:"vendor"`
+	Product string `yaml:"product"`
+	Version string `yaml:"version"`
+	// Extensions replace the default extensions of the format when set.
+	Extensions []Extension     `yaml:"extensions"`
+	Severity   SeverityMapping `yaml:"severity"`
+}
+
+// Extension sets an extension key from a log field, a key of the event
+// data or a literal value; exactly one of them must be set.
+type Extension struct {
+	Key string `yaml:"key"`
+	// Label is written as <key>Label next to the value, as used by the
+	// custom cs and cn keys of CEF.
+	Label string `yaml:"label"`
+	Field string `yaml:"field"`
+	// Data is a dot separated path into the event data, such as user.id.
+	Data  string `yaml:"data"`
+	Value string `yaml:"value"`
+}
+
+// SeverityMapping derives the 0-10 severity of an entry. The first rule
+// matching the event type applies; otherwise Default, or when it is unset
+// the severity of the log level.
+type SeverityMapping struct {
+	Default *int           `yaml:"default"`
+	Rules   []SeverityRule `yaml:"rules"`
+}
+
+// SeverityRule sets the severity of event types matching path.Match
+// patterns such as auth.*.
+type SeverityRule struct {
+	Types    []string `yaml:"types"`
+	Severity int      `yaml:"severity"`
+}
+
+// LoadMapping reads a YAML mapping file.
+func LoadMapping(file string) (Mapping, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return Mapping{}, fmt.Errorf("failed to read SIEM mapping: %w", err)
+	}
+
+	var mapping Mapping
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if decodeErr := decoder.Decode(&mapping); decodeErr != nil {
+		return Mapping{}, fmt.Errorf("failed to parse SIEM mapping %s: %w", file, decodeErr)
+	}
+	return mapping, nil
+}
+
+// extensionKeyPattern restricts keys to those valid in both formats.
+func extensionKeyPattern() *regexp.Regexp {
+	return regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+}
+
+// withDefaults fills unset header values and the default extensions.
+func (m Mapping) withDefaults(defaults []Extension, version string) Mapping {
+	if m.Vendor == "" {
+		m.Vendor = defaultVendor
+	}
+	if m.Product == "" {
+		m.Product = defaultProduct
+	}
+	if m.Version == "" {
+		m.Version = version
+	}
+	if m.Version == "" {
+		m.Version = defaultVersion
+	}
+	if len(m.Extensions) == 0 {
+		m.Extensions = defaults
+	}
+	return m
+}
+
+func (m Mapping) validate() error {
+	keyPattern := extensionKeyPattern()
+	for _, ext := range m.Extensions {
+		if !keyPattern.MatchString(ext.Key) {
+			return fmt.Errorf("invalid extension key %q", ext.Key)
+		}
+		sources := 0
+		for _, source := range []string{ext.Field, ext.Data, ext.Value} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("extension %s needs exactly one of field, data or value", ext.Key)
+		}
+	}
+
+	if m.Severity.Default != nil && !validSeverity(*m.Severity.Default) {
+		return errors.New("default severity must be between 0 and 10")
+	}
+	for _, rule := range m.Severity.Rules {
+		if !validSeverity(rule.Severity) {
+			return fmt.Errorf("severity of types %v must be between 0 and 10", rule.Types)
+		}
+		for _, pattern := range rule.Types {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid type pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validSeverity(severity int) bool {
+	return severity >= 0 && severity <= maxSeverity
+}
@@ -0,0 +1,188 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/subject"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported sink types.
+const (
+	TypeLog     = "log"
+	TypeFile    = "file"
+	TypeNATS    = "nats"
+	TypeSyslog  = "syslog"
+	TypeWebhook = "webhook"
+)
+
+// Config lists the sinks events are routed to, usually loaded from a YAML
+// file.
+type Config struct {
+	Sinks []SinkConfig `yaml:"sinks"`
+}
+
+// SinkConfig configures a single sink and the events routed to it. An event
+// is routed to the sink when its subject matches one of Subjects and its
+// type matches one of Types; an empty list matches every event.
+type SinkConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// Mandatory sinks must accept an event before it is acknowledged;
+	// failures of optional sinks are only logged.
+	Mandatory bool `yaml:"mandatory"`
+	// Subjects are NATS subject patterns with * and > wildcards.
+	Subjects []string `yaml:"subjects"`
+	// Types are path.Match patterns such as user.*.
+	Types []string `yaml:"types"`
+	// Timeout bounds a single write.
+	Timeout time.Duration `yaml:"timeout"`
+	// Spool queues events on disk before they are written to the sink.
+	Spool SpoolConfig `yaml:"spool"`
+
+	File    FileConfig    `yaml:"file"`
+	NATS    NATSConfig    `yaml:"nats"`
+	Syslog  SyslogConfig  `yaml:"syslog"`
+	Webhook WebhookConfig `yaml:"webhook"`
+}
+
+// LoadConfig reads a YAML sink configuration file.
+func LoadConfig(file string) (Config, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read sink configuration: %w", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if decodeErr := decoder.Decode(&config); decodeErr != nil {
+		return Config{}, fmt.Errorf("failed to parse sink configuration %s: %w", file, decodeErr)
+	}
+	return config, nil
+}
+
+// validate checks the routing options shared by every sink type.
+func (c SinkConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("sink name is required")
+	}
+	for _, pattern := range c.Subjects {
+		if err := subject.ValidatePattern(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range c.Types {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid type pattern %q: %w", pattern, err)
+		}
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// matches reports whether an event is routed to the sink.
+func (c SinkConfig) matches(eventSubject, eventType string) bool {
+	return matchAny(c.Subjects, eventSubject, subject.Match) && matchAny(c.Types, eventType, matchType)
+}
+
+func matchAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchType(pattern, eventType string) bool {
+	ok, _ := path.Match(pattern, eventType)
+	return ok
+}
+
+// Open creates the sinks of config and a router delivering to them. The log
+// sink writes with logger.
+func Open(config Config, logger *logrus.Logger) (*Router, error) {
+	if len(config.Sinks) == 0 {
+		return nil, errors.New("sink configuration has no sinks")
+	}
+
+	router := NewRouter(logger)
+	spoolDirs := make(map[string]string)
+	for _, sinkConfig := range config.Sinks {
+		if dir := sinkConfig.Spool.Dir; dir != "" {
+			if other, ok := spoolDirs[filepath.Clean(dir)]; ok {
+				_ = router.Close()
+				return nil, fmt.Errorf("invalid sink %s: spool directory is used by sink %s", sinkConfig.Name, other)
+			}
+			spoolDirs[filepath.Clean(dir)] = sinkConfig.Name
+		}
+
+		s, err := newSpooledSink(sinkConfig, logger)
+		if err != nil {
+			_ = router.Close()
+			return nil, fmt.Errorf("invalid sink %s: %w", sinkConfig.Name, err)
+		}
+		if addErr := router.Add(sinkConfig, s); addErr != nil {
+			_ = s.Close()
+			_ = router.Close()
+			return nil, fmt.Errorf("invalid sink %s: %w", sinkConfig.Name, addErr)
+		}
+	}
+	return router, nil
+}
+
+// newSpooledSink creates a sink, behind a spool when one is configured.
+func newSpooledSink(config SinkConfig, logger *logrus.Logger) (Sink, error) {
+	s, err := newSink(config, logger)
+	if err != nil || config.Spool.Dir == "" {
+		return s, err
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = constants.DefaultSinkTimeout
+	}
+	spool, err := NewSpool(config.Name, s, config.Spool, timeout, logger)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+func newSink(config SinkConfig, logger *logrus.Logger) (Sink, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	switch config.Type {
+	case TypeLog:
+		return NewLogSink(logger), nil
+	case TypeFile:
+		return NewFileSink(config.File)
+	case TypeNATS:
+		return NewNATSSink(config.Name, config.NATS)
+	case TypeSyslog:
+		return NewSyslogSink(config.Syslog)
+	case TypeWebhook:
+		return NewWebhookSink(config.Webhook)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q, supported %s, %s, %s, %s or %s",
+			config.Type, TypeLog, TypeFile, TypeNATS, TypeSyslog, TypeWebhook)
+	}
+}
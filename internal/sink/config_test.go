@@ -0,0 +1,98 @@
+package sink_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/embedded"
+	"events-audit/internal/sink"
+
+	natsclient "github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "sinks.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestOpen(t *testing.T) {
+	serverLogger, _ := test.NewNullLogger()
+	srv, err := embedded.Start(embedded.Config{Addr: "127.0.0.1:-1", StoreDir: t.TempDir()}, serverLogger)
+	require.NoError(t, err)
+	t.Cleanup(srv.Shutdown)
+
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	config, err := sink.LoadConfig(writeConfig(t, `
+sinks:
+  - name: stdout
+    type: log
+    mandatory: true
+  - name: file
+    type: file
+    types: ["user.*"]
+    timeout: 2s
+    file:
+      path: `+events+`
+      max_bytes: 1048576
+  - name: forward
+    type: nats
+    subjects: ["audit.>"]
+    nats:
+      url: `+srv.ClientURL()+`
+      subject: audit.forwarded
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, config.Sinks[1].Timeout)
+
+	logger, hook := test.NewNullLogger()
+	router, err := sink.Open(config, logger)
+	require.NoError(t, err)
+
+	nc, err := natsclient.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	forwarded, err := nc.SubscribeSync("audit.forwarded")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	require.NoError(t, router.Deliver(context.Background(), newEvent("audit.users", "user.created")))
+
+	msg, err := forwarded.NextMsg(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "audit.users", msg.Header.Get(sink.HeaderOriginalSubject))
+	assert.Equal(t, "user.created", msg.Header.Get(sink.HeaderEventType))
+	assert.Contains(t, string(msg.Data), `"event_type":"user.created"`)
+
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, "Received structured event", hook.Entries[0].Message)
+
+	require.Len(t, router.ReadinessChecks(), 1)
+	require.NoError(t, router.Close())
+	assert.Len(t, readLines(t, events), 1)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := sink.LoadConfig(writeConfig(t, "sinks:\n  - name: a\n    type: log\n    mandatroy: true\n"))
+	require.ErrorContains(t, err, "mandatroy")
+
+	tests := map[string]sink.Config{
+		"has no sinks":            {},
+		"unknown sink type":       {Sinks: []sink.SinkConfig{{Name: "a", Type: "kafka"}}},
+		"file sink requires":      {Sinks: []sink.SinkConfig{{Name: "a", Type: sink.TypeFile}}},
+		"nats sink requires":      {Sinks: []sink.SinkConfig{{Name: "a", Type: sink.TypeNATS}}},
+		"duplicate sink name":     {Sinks: []sink.SinkConfig{{Name: "a", Type: sink.TypeLog}, {Name: "a", Type: sink.TypeLog}}},
+		"invalid subject pattern": {Sinks: []sink.SinkConfig{{Name: "a", Type: sink.TypeLog, Subjects: []string{">.a"}}}},
+	}
+	for message, config := range tests {
+		_, openErr := sink.Open(config, nil)
+		require.ErrorContains(t, openErr, message)
+	}
+}
@@ -0,0 +1,171 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files so that they sort by age.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// FileConfig configures a file sink writing JSON lines.
+type FileConfig struct {
+	Path string `yaml:"path"`
+	// MaxBytes and MaxAge rotate the file once it grows beyond the size or
+	// was opened longer ago than the age; zero disables the limit.
+	MaxBytes int64         `yaml:"max_bytes"`
+	MaxAge   time.Duration `yaml:"max_age"`
+	// MaxBackups is the number of rotated files kept; zero keeps all.
+	MaxBackups int `yaml:"max_backups"`
+	// NoSync skips the fsync after every write. Writes are faster, but
+	// acknowledged events still in the page cache are lost on a crash.
+	NoSync bool `yaml:"no_sync"`
+}
+
+// FileSink appends events as JSON lines to a file and rotates it by size
+// and age. Rotated files are renamed to <path>.<timestamp>.
+type FileSink struct {
+	config FileConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileSink opens or creates the file of the sink.
+func NewFileSink(config FileConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, errors.New("file sink requires a path")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create file sink directory: %w", err)
+	}
+
+	s := &FileSink{config: config}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write appends the event and rotates the file first when a limit is hit.
+// The file is synced before Write returns unless NoSync is set, so an
+// acknowledged event survives a crash.
+func (s *FileSink) Write(_ context.Context, event Event) error {
+	line, err := Encode(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("file sink is closed")
+	}
+	if s.shouldRotate(int64(len(line))) {
+		if rotateErr := s.rotate(); rotateErr != nil {
+			return rotateErr
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to file sink: %w", err)
+	}
+	if !s.config.NoSync {
+		if syncErr := s.file.Sync(); syncErr != nil {
+			return fmt.Errorf("failed to sync file sink: %w", syncErr)
+		}
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close file sink: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open file sink: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat file sink: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *FileSink) shouldRotate(next int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.config.MaxBytes > 0 && s.size+next > s.config.MaxBytes {
+		return true
+	}
+	return s.config.MaxAge > 0 && time.Since(s.openedAt) >= s.config.MaxAge
+}
+
+// rotate renames the current file to a backup, opens a new one and prunes
+// backups beyond MaxBackups.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close file sink for rotation: %w", err)
+	}
+	s.file = nil
+
+	backup := s.config.Path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(s.config.Path, backup); err != nil {
+		return fmt.Errorf("failed to rotate file sink: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+func (s *FileSink) prune() error {
+	if s.config.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(s.config.Path + ".*")
+	if err != nil {
+		return fmt.Errorf("failed to list file sink backups: %w", err)
+	}
+	sort.Strings(backups)
+
+	for len(backups) > s.config.MaxBackups {
+		if removeErr := os.Remove(backups[0]); removeErr != nil {
+			return fmt.Errorf("failed to remove file sink backup: %w", removeErr)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
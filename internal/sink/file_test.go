@@ -0,0 +1,115 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLines(t *testing.T, file string) []map[string]any {
+	t.Helper()
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestFileSink_Write(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logs", "events.jsonl")
+	s, err := sink.NewFileSink(sink.FileConfig{Path: file})
+	require.NoError(t, err)
+
+	event := newEvent("audit.users", "user.created")
+	event.Time = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, s.Write(context.Background(), event))
+	require.NoError(t, s.Close())
+
+	lines := readLines(t, file)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]any{
+		"time":       "2026-01-02T03:04:05Z",
+		"level":      "info",
+		"msg":        "Received structured event",
+		"event_type": "user.created",
+	}, lines[0])
+
+	require.ErrorContains(t, s.Write(context.Background(), event), "closed")
+}
+
+func TestFileSink_WriteWithoutSync(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := sink.NewFileSink(sink.FileConfig{Path: file, NoSync: true})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(context.Background(), newEvent("audit.users", "user.created")))
+	require.NoError(t, s.Close())
+	assert.Len(t, readLines(t, file), 1)
+}
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "events.jsonl")
+
+	// A fixed time keeps the encoded events the same length.
+	event := newEvent("audit", "user.created")
+	event.Time = time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	line, err := sink.Encode(event)
+	require.NoError(t, err)
+
+	// Two events fit in a file, the third rotates it.
+	s, err := sink.NewFileSink(sink.FileConfig{
+		Path:       file,
+		MaxBytes:   int64(2 * (len(line) + 1)),
+		MaxBackups: 2,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for range 7 {
+		require.NoError(t, s.Write(context.Background(), event))
+	}
+
+	backups, err := filepath.Glob(file + ".*")
+	require.NoError(t, err)
+	assert.Len(t, backups, 2, "older backups are pruned")
+	for _, backup := range backups {
+		assert.Len(t, readLines(t, backup), 2)
+	}
+	assert.Len(t, readLines(t, file), 1)
+}
+
+func TestFileSink_RotatesByAge(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := sink.NewFileSink(sink.FileConfig{Path: file, MaxAge: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Write(context.Background(), newEvent("audit", "a")))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, s.Write(context.Background(), newEvent("audit", "b")))
+
+	backups, err := filepath.Glob(file + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "a", readLines(t, backups[0])[0]["event_type"])
+	assert.Equal(t, "b", readLines(t, file)[0]["event_type"])
+}
@@ -0,0 +1,111 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers set on events forwarded to NATS.
+const (
+	HeaderOriginalSubject = "Audit-Original-Subject"
+	HeaderEventType       = "Audit-Event-Type"
+)
+
+// NATSConfig configures a sink publishing events to a NATS subject.
+type NATSConfig struct {
+	URL       string `yaml:"url"`
+	Subject   string `yaml:"subject"`
+	CredsFile string `yaml:"creds_file"`
+	// JetStream waits for the stream to acknowledge each event instead of
+	// only flushing it to the server.
+	JetStream bool `yaml:"jetstream"`
+}
+
+// NATSSink publishes events as JSON to another NATS subject.
+type NATSSink struct {
+	config NATSConfig
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+}
+
+// NewNATSSink connects to the NATS server of the sink.
+func NewNATSSink(name string, config NATSConfig) (*NATSSink, error) {
+	if config.Subject == "" {
+		return nil, errors.New("nats sink requires a subject")
+	}
+	if config.URL == "" {
+		config.URL = nats.DefaultURL
+	}
+
+	opts := []nats.Option{
+		nats.Name("events-audit sink " + name),
+		nats.MaxReconnects(-1),
+	}
+	if config.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(config.CredsFile))
+	}
+	conn, err := nats.Connect(config.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect nats sink: %w", err)
+	}
+
+	s := &NATSSink{config: config, conn: conn}
+	if config.JetStream {
+		js, jsErr := conn.JetStream()
+		if jsErr != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create JetStream context for nats sink: %w", jsErr)
+		}
+		s.js = js
+	}
+	return s, nil
+}
+
+// Write publishes the event and waits until the server, or the stream with
+// JetStream, has received it.
+func (s *NATSSink) Write(ctx context.Context, event Event) error {
+	data, err := Encode(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.config.Subject)
+	msg.Data = data
+	msg.Header.Set(HeaderOriginalSubject, event.Subject)
+	if event.Type != "" {
+		msg.Header.Set(HeaderEventType, event.Type)
+	}
+
+	if s.js != nil {
+		if _, pubErr := s.js.PublishMsg(msg, nats.Context(ctx)); pubErr != nil {
+			return fmt.Errorf("failed to publish to nats sink: %w", pubErr)
+		}
+		return nil
+	}
+
+	if pubErr := s.conn.PublishMsg(msg); pubErr != nil {
+		return fmt.Errorf("failed to publish to nats sink: %w", pubErr)
+	}
+	if flushErr := s.conn.FlushWithContext(ctx); flushErr != nil {
+		return fmt.Errorf("failed to flush nats sink: %w", flushErr)
+	}
+	return nil
+}
+
+// Check reports whether the connection is established.
+func (s *NATSSink) Check(_ context.Context) error {
+	if !s.conn.IsConnected() {
+		return errors.New("nats sink connection is not established")
+	}
+	return nil
+}
+
+// Close drains the connection.
+func (s *NATSSink) Close() error {
+	if err := s.conn.Drain(); err != nil {
+		return fmt.Errorf("failed to drain nats sink: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package sink
+
+import (
+	"fmt"
+	"time"
+)
+
+// DelayError reports a failure that is expected to persist for a while,
+// such as an endpoint being down; sources redeliver the event after Delay
+// rather than at once.
+type DelayError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *DelayError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", e.Err, e.Delay)
+}
+
+func (e *DelayError) Unwrap() error {
+	return e.Err
+}
+
+// RetryDelay returns the requested redelivery delay.
+func (e *DelayError) RetryDelay() time.Duration {
+	return e.Delay
+}
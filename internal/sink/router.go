@@ -0,0 +1,263 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/health"
+	"events-audit/internal/metrics"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink statuses.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusIdle is reported until the first write.
+	StatusIdle = "idle"
+)
+
+// Status is the delivery state of a sink.
+type Status struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Mandatory    bool       `json:"mandatory"`
+	Status       string     `json:"status"`
+	Delivered    uint64     `json:"delivered"`
+	Failed       uint64     `json:"failed"`
+	LastDelivery *time.Time `json:"last_delivery,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	// Spool is set for sinks with a spool; the other fields then describe
+	// writes to the spool.
+	Spool *SpoolStatus `json:"spool,omitempty"`
+}
+
+// route is a sink with its routing options and delivery state.
+type route struct {
+	config SinkConfig
+	sink   Sink
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Router fans events out to the sinks they are routed to. Deliver, the
+// status methods and Close are safe to call on a nil receiver, which
+// delivers nothing.
+type Router struct {
+	routes  []*route
+	logger  *logrus.Logger
+	metrics *metrics.Metrics
+}
+
+// NewRouter creates a router without sinks.
+func NewRouter(logger *logrus.Logger) *Router {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Router{logger: logger}
+}
+
+// SetMetrics sets the collectors the router and its spools report to.
+func (r *Router) SetMetrics(m *metrics.Metrics) {
+	r.metrics = m
+	for _, rt := range r.routes {
+		if spool, ok := rt.sink.(*Spool); ok {
+			spool.SetMetrics(m)
+		}
+	}
+}
+
+// Add registers a sink with its routing options. The router closes the sink.
+func (r *Router) Add(config SinkConfig, s Sink) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	for _, existing := range r.routes {
+		if existing.config.Name == config.Name {
+			return fmt.Errorf("duplicate sink name %q", config.Name)
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = constants.DefaultSinkTimeout
+	}
+
+	r.routes = append(r.routes, &route{
+		config: config,
+		sink:   s,
+		status: Status{
+			Name:      config.Name,
+			Type:      config.Type,
+			Mandatory: config.Mandatory,
+			Status:    StatusIdle,
+		},
+	})
+	return nil
+}
+
+// Deliver writes the event to every sink it is routed to concurrently and
+// waits for them. It returns an error when a mandatory sink fails, in which
+// case the event is redelivered to every sink; failures of optional sinks
+// are logged.
+func (r *Router) Deliver(ctx context.Context, event Event) error {
+	if r == nil {
+		return nil
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		routed int
+	)
+	for _, rt := range r.routes {
+		if !rt.config.matches(event.Subject, event.Type) {
+			continue
+		}
+		routed++
+		wg.Add(1)
+		go func(rt *route) {
+			defer wg.Done()
+			if err := r.write(ctx, rt, event); err != nil && rt.config.Mandatory {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(rt)
+	}
+	wg.Wait()
+
+	if routed == 0 {
+		r.logger.WithFields(logrus.Fields{
+			"subject":    event.Subject,
+			"event_type": event.Type,
+		}).Debug("Event is not routed to any sink")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to deliver to mandatory sinks: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// write delivers the event to a single sink and records the outcome.
+func (r *Router) write(ctx context.Context, rt *route, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := rt.sink.Write(ctx, event)
+	elapsed := time.Since(start)
+	r.metrics.SinkWrite(rt.config.Name, elapsed, err == nil)
+
+	now := time.Now()
+	rt.mu.Lock()
+	if err == nil {
+		rt.status.Status = StatusOK
+		rt.status.Delivered++
+		rt.status.LastDelivery = &now
+	} else {
+		rt.status.Status = StatusFail
+		rt.status.Failed++
+		rt.status.LastFailure = &now
+		rt.status.LastError = err.Error()
+	}
+	rt.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("sink %s: %w", rt.config.Name, err)
+	entry := r.logger.WithFields(logrus.Fields{
+		"sink":       rt.config.Name,
+		"mandatory":  rt.config.Mandatory,
+		"subject":    event.Subject,
+		"event_type": event.Type,
+	}).WithError(err)
+	if rt.config.Mandatory {
+		entry.Error("Failed to deliver event to mandatory sink")
+	} else {
+		entry.Warn("Failed to deliver event to optional sink")
+	}
+	return err
+}
+
+// Statuses returns the delivery state of every sink in configuration order.
+func (r *Router) Statuses() []Status {
+	if r == nil {
+		return []Status{}
+	}
+	statuses := make([]Status, 0, len(r.routes))
+	for _, rt := range r.routes {
+		rt.mu.Lock()
+		status := rt.status
+		rt.mu.Unlock()
+		if spool, ok := rt.sink.(*Spool); ok {
+			spoolStatus := spool.Status()
+			status.Spool = &spoolStatus
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// StatusHandler serves the delivery state of every sink.
+func (r *Router) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		render.JSON(w, req, map[string]any{"sinks": r.Statuses()})
+	}
+}
+
+// ReadinessChecks returns a check per mandatory sink, failing while its
+// last write failed or its destination is unreachable. Optional sinks do
+// not affect readiness.
+func (r *Router) ReadinessChecks() []health.Check {
+	if r == nil {
+		return nil
+	}
+	var checks []health.Check
+	for _, rt := range r.routes {
+		if !rt.config.Mandatory {
+			continue
+		}
+		checks = append(checks, health.Check{Name: "sink:" + rt.config.Name, Run: rt.check})
+	}
+	return checks
+}
+
+func (rt *route) check(ctx context.Context) error {
+	rt.mu.Lock()
+	status, lastError := rt.status.Status, rt.status.LastError
+	rt.mu.Unlock()
+
+	if checker, ok := rt.sink.(Checker); ok {
+		if err := checker.Check(ctx); err != nil {
+			return err
+		}
+	}
+	if status == StatusFail {
+		return fmt.Errorf("last write failed: %s", lastError)
+	}
+	return nil
+}
+
+// Close closes every sink.
+func (r *Router) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, rt := range r.routes {
+		if err := rt.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", rt.config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
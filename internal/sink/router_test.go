@@ -0,0 +1,170 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink collects written events and fails while err is set.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []sink.Event
+	err    error
+	closed bool
+}
+
+func (s *recordingSink) Write(_ context.Context, event sink.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func newEvent(subject, eventType string) sink.Event {
+	return sink.Event{
+		Time:    time.Now(),
+		Subject: subject,
+		Type:    eventType,
+		Level:   logrus.InfoLevel,
+		Message: "Received structured event",
+		Fields:  logrus.Fields{"event_type": eventType},
+	}
+}
+
+func TestRouter_Routing(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	router := sink.NewRouter(logger)
+
+	all, users, orders := &recordingSink{}, &recordingSink{}, &recordingSink{}
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "all", Type: "test"}, all))
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "users", Type: "test", Types: []string{"user.*"}}, users))
+	require.NoError(t, router.Add(sink.SinkConfig{
+		Name:     "orders",
+		Type:     "test",
+		Subjects: []string{"audit.orders.>"},
+		Types:    []string{"order.paid"},
+	}, orders))
+
+	ctx := context.Background()
+	require.NoError(t, router.Deliver(ctx, newEvent("audit.users", "user.created")))
+	require.NoError(t, router.Deliver(ctx, newEvent("audit.orders.eu", "order.paid")))
+	require.NoError(t, router.Deliver(ctx, newEvent("audit.orders", "order.paid")))
+	require.NoError(t, router.Deliver(ctx, newEvent("audit.orders.eu", "order.created")))
+
+	assert.Equal(t, 4, all.count())
+	assert.Equal(t, 1, users.count())
+	assert.Equal(t, 1, orders.count())
+}
+
+func TestRouter_MandatoryAndOptional(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	router := sink.NewRouter(logger)
+
+	mandatory, optional := &recordingSink{}, &recordingSink{err: errors.New("connection refused")}
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "archive", Type: "test", Mandatory: true}, mandatory))
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "siem", Type: "test"}, optional))
+
+	// Optional failures do not fail the delivery.
+	require.NoError(t, router.Deliver(context.Background(), newEvent("audit", "user.created")))
+	assert.Equal(t, 1, mandatory.count())
+	require.Len(t, hook.Entries, 1)
+	assert.Equal(t, logrus.WarnLevel, hook.Entries[0].Level)
+
+	mandatory.err = errors.New("disk full")
+	err := router.Deliver(context.Background(), newEvent("audit", "user.created"))
+	require.ErrorContains(t, err, "sink archive: disk full")
+	assert.NotContains(t, err.Error(), "siem")
+
+	statuses := router.Statuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, sink.StatusFail, statuses[0].Status)
+	assert.Equal(t, uint64(1), statuses[0].Delivered)
+	assert.Equal(t, uint64(1), statuses[0].Failed)
+	assert.Equal(t, "disk full", statuses[0].LastError)
+	assert.Equal(t, sink.StatusFail, statuses[1].Status)
+	assert.Equal(t, uint64(2), statuses[1].Failed)
+
+	// Only mandatory sinks decide readiness.
+	checks := router.ReadinessChecks()
+	require.Len(t, checks, 1)
+	assert.Equal(t, "sink:archive", checks[0].Name)
+	require.ErrorContains(t, checks[0].Run(context.Background()), "disk full")
+
+	mandatory.err = nil
+	require.NoError(t, router.Deliver(context.Background(), newEvent("audit", "user.created")))
+	require.NoError(t, checks[0].Run(context.Background()))
+
+	require.NoError(t, router.Close())
+	assert.True(t, mandatory.closed)
+	assert.True(t, optional.closed)
+}
+
+func TestRouter_StatusHandler(t *testing.T) {
+	router := sink.NewRouter(nil)
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "stdout", Type: sink.TypeLog, Mandatory: true}, &recordingSink{}))
+
+	rec := httptest.NewRecorder()
+	router.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sinks", nil))
+
+	var body struct {
+		Sinks []sink.Status `json:"sinks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Sinks, 1)
+	assert.Equal(t, "stdout", body.Sinks[0].Name)
+	assert.Equal(t, sink.StatusIdle, body.Sinks[0].Status)
+	assert.True(t, body.Sinks[0].Mandatory)
+}
+
+func TestRouter_Nil(t *testing.T) {
+	var router *sink.Router
+	require.NoError(t, router.Deliver(context.Background(), newEvent("audit", "")))
+	assert.Empty(t, router.Statuses())
+	assert.Empty(t, router.ReadinessChecks())
+	require.NoError(t, router.Close())
+}
+
+func TestRouter_AddRejectsInvalidConfig(t *testing.T) {
+	router := sink.NewRouter(nil)
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "a"}, &recordingSink{}))
+
+	tests := map[string]sink.SinkConfig{
+		"duplicate sink name":          {Name: "a"},
+		"sink name is required":        {},
+		"> must be the last token":     {Name: "b", Subjects: []string{"audit.>.x"}},
+		"wildcards must be whole":      {Name: "b", Subjects: []string{"audit.us*"}},
+		"empty token":                  {Name: "b", Subjects: []string{"audit..x"}},
+		"invalid type pattern":         {Name: "b", Types: []string{"["}},
+		"timeout must not be negative": {Name: "b", Timeout: -time.Second},
+	}
+	for message, config := range tests {
+		require.ErrorContains(t, router.Add(config, &recordingSink{}), message)
+	}
+}
@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event is a handled audit event as it is delivered to sinks. Fields are the
+// log fields built by the event logger, with event data already redacted.
+type Event struct {
+	Time    time.Time
+	Subject string
+	Type    string
+	Level   logrus.Level
+	Message string
+	Fields  logrus.Fields
+}
+
+// Sink is an output destination for audit events. Write returns nil only
+// once the event is accepted by the destination; the router then counts it
+// as delivered. Write may be called concurrently for different events.
+type Sink interface {
+	Write(ctx context.Context, event Event) error
+	Close() error
+}
+
+// Checker is implemented by sinks that can report whether their destination
+// is reachable without writing an event.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Encode returns the event as a single JSON object in the layout of the
+// logrus JSON formatter: the fields with time, level and msg.
+func Encode(event Event) ([]byte, error) {
+	doc := make(map[string]any, len(event.Fields)+3) //nolint:mnd // time, level and msg
+	for k, v := range event.Fields {
+		doc[k] = v
+	}
+	doc["time"] = event.Time.Format(time.RFC3339Nano)
+	doc["level"] = event.Level.String()
+	doc["msg"] = event.Message
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return encoded, nil
+}
+
+// LogSink writes events to a logrus logger, the output of the service when
+// no sinks are configured.
+type LogSink struct {
+	logger *logrus.Logger
+}
+
+// NewLogSink creates a sink logging events with logger.
+func NewLogSink(logger *logrus.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Write logs the event.
+func (s *LogSink) Write(_ context.Context, event Event) error {
+	s.logger.WithFields(event.Fields).WithTime(event.Time).Log(event.Level, event.Message)
+	return nil
+}
+
+// Close does nothing; the logger is owned by the caller.
+func (s *LogSink) Close() error {
+	return nil
+}
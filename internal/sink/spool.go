@@ -0,0 +1,432 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"events-audit/internal/metrics"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Spool full policies.
+const (
+	// SpoolBlock makes writes wait for space, up to the sink timeout, which
+	// holds the worker and so stops fetching.
+	SpoolBlock = "block"
+	// SpoolReject fails writes so the event is redelivered later.
+	SpoolReject = "reject"
+)
+
+const (
+	defaultSpoolMaxBytes        = 1 << 30
+	defaultSpoolSegmentBytes    = 16 << 20
+	defaultSpoolBatchSize       = 100
+	defaultSpoolRetryBackoff    = time.Second
+	defaultSpoolMaxRetryBackoff = time.Minute
+	defaultSpoolRejectDelay     = 30 * time.Second
+)
+
+// ErrSpoolFull is returned by writes when the spool has no space left.
+var ErrSpoolFull = errors.New("spool is full") //nolint:gochecknoglobals // sentinel error
+
+// SpoolConfig configures a disk spool in front of a sink. Spooling is
+// enabled when Dir is set.
+type SpoolConfig struct {
+	Dir string `yaml:"dir"`
+	// MaxBytes limits the undelivered events on disk.
+	MaxBytes     int64 `yaml:"max_bytes"`
+	SegmentBytes int64 `yaml:"segment_bytes"`
+	// FullPolicy is block or reject.
+	FullPolicy  string        `yaml:"full_policy"`
+	RejectDelay time.Duration `yaml:"reject_delay"`
+	// BatchSize limits the events delivered to the sink at once.
+	BatchSize       int           `yaml:"batch_size"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+}
+
+// BatchWriter is implemented by sinks that deliver several events at once;
+// spools drain through it.
+type BatchWriter interface {
+	WriteBatch(ctx context.Context, events []Event) error
+}
+
+// SpoolStatus is the state of the spool of a sink.
+type SpoolStatus struct {
+	Events      int        `json:"events"`
+	Bytes       int64      `json:"bytes"`
+	Full        bool       `json:"full"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Spool is a sink that durably queues events on disk and delivers them to
+// the sink it wraps in the background, in the order they were written. A
+// write succeeds once the event is on disk, so events are acknowledged
+// while the sink is unavailable and delivered when it recovers.
+type Spool struct {
+	name    string
+	config  SpoolConfig
+	sink    Sink
+	timeout time.Duration
+	logger  *logrus.Logger
+
+	mu          sync.Mutex
+	queue       *spoolQueue
+	changed     chan struct{}
+	closed      bool
+	metrics     *metrics.Metrics
+	lastFailure *time.Time
+	lastError   string
+
+	ctx    context.Context //nolint:containedctx // cancels deliveries on close
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// spoolEvent is the stored form of an event.
+type spoolEvent struct {
+	Time    time.Time     `json:"time"`
+	Subject string        `json:"subject"`
+	Type    string        `json:"type,omitempty"`
+	Level   logrus.Level  `json:"level"`
+	Message string        `json:"message"`
+	Fields  logrus.Fields `json:"fields,omitempty"`
+}
+
+// NewSpool opens the spool of a sink and starts delivering the events
+// already spooled. Each write to the sink is bounded by timeout.
+func NewSpool(name string, s Sink, config SpoolConfig, timeout time.Duration, logger *logrus.Logger) (*Spool, error) {
+	if config.Dir == "" {
+		return nil, errors.New("spool requires a directory")
+	}
+	if config.FullPolicy == "" {
+		config.FullPolicy = SpoolBlock
+	}
+	if config.FullPolicy != SpoolBlock && config.FullPolicy != SpoolReject {
+		return nil, fmt.Errorf("unknown spool full policy %q, supported %s or %s", config.FullPolicy, SpoolBlock, SpoolReject)
+	}
+	if config.MaxBytes < 0 || config.SegmentBytes < 0 || config.BatchSize < 0 {
+		return nil, errors.New("spool limits must not be negative")
+	}
+	setDefault(&config.MaxBytes, defaultSpoolMaxBytes)
+	setDefault(&config.SegmentBytes, min(defaultSpoolSegmentBytes, config.MaxBytes))
+	setDefault(&config.BatchSize, defaultSpoolBatchSize)
+	setDefault(&config.RetryBackoff, defaultSpoolRetryBackoff)
+	setDefault(&config.MaxRetryBackoff, defaultSpoolMaxRetryBackoff)
+	setDefault(&config.RejectDelay, defaultSpoolRejectDelay)
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	queue, err := openSpoolQueue(config.Dir, config.SegmentBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	spool := &Spool{
+		name:    name,
+		config:  config,
+		sink:    s,
+		timeout: timeout,
+		logger:  logger,
+		queue:   queue,
+		changed: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if queue.events > 0 {
+		logger.WithFields(logrus.Fields{
+			"sink":   name,
+			"events": queue.events,
+			"bytes":  queue.bytes,
+		}).Info("Resuming delivery of spooled events")
+	}
+
+	go spool.run()
+	return spool, nil
+}
+
+// SetMetrics sets the collectors the spool reports its depth to.
+func (s *Spool) SetMetrics(m *metrics.Metrics) {
+	s.mu.Lock()
+	s.metrics = m
+	s.mu.Unlock()
+	s.reportDepth()
+}
+
+// Write appends the event to the spool. When the spool is full it waits
+// for space or fails, according to the full policy.
+func (s *Spool) Write(ctx context.Context, event Event) error {
+	payload, err := encodeSpoolEvent(event)
+	if err != nil {
+		return err
+	}
+	size := recordSize(payload)
+	if size > s.config.MaxBytes {
+		return fmt.Errorf("event of %d bytes exceeds the spool size", size)
+	}
+
+	s.mu.Lock()
+	counted := false
+	for !s.closed && s.queue.bytes+size > s.config.MaxBytes {
+		if !counted {
+			s.metrics.SpoolFull(s.name, s.config.FullPolicy)
+			counted = true
+		}
+		if s.config.FullPolicy == SpoolReject {
+			s.mu.Unlock()
+			return &DelayError{Err: ErrSpoolFull, Delay: s.config.RejectDelay}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrSpoolFull, ctx.Err())
+		}
+		s.mu.Lock()
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("spool is closed")
+	}
+
+	err = s.queue.append(payload)
+	s.notifyLocked()
+	s.mu.Unlock()
+	s.reportDepth()
+	return err
+}
+
+// notifyLocked wakes up the drain loop and writers waiting for space.
+func (s *Spool) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// run delivers spooled events until the spool is closed, retrying with
+// backoff while the sink fails.
+func (s *Spool) run() {
+	defer close(s.done)
+
+	attempt := 0
+	for {
+		s.mu.Lock()
+		records, err := s.queue.read(s.config.BatchSize)
+		changed := s.changed
+		s.mu.Unlock()
+
+		if err == nil && len(records) == 0 {
+			select {
+			case <-changed:
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		if err == nil {
+			var delivered int
+			delivered, err = s.deliver(records)
+			if commitErr := s.commit(records[:delivered]); commitErr != nil {
+				err = errors.Join(err, commitErr)
+			}
+		}
+		if err == nil {
+			attempt = 0
+			continue
+		}
+
+		delay := s.backoff(attempt, err)
+		attempt++
+		s.fail(err, delay)
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver writes records to the sink and returns how many were delivered.
+// Records that cannot be decoded are dropped.
+func (s *Spool) deliver(records []spoolRecord) (int, error) {
+	batcher, batched := s.sink.(BatchWriter)
+	events := make([]Event, 0, len(records))
+	for i, record := range records {
+		event, err := decodeSpoolEvent(record.payload)
+		if err != nil {
+			s.logger.WithError(err).WithField("sink", s.name).Error("Dropping undecodable spooled event")
+			continue
+		}
+		if batched {
+			events = append(events, event)
+			continue
+		}
+		if writeErr := s.write(func(ctx context.Context) error { return s.sink.Write(ctx, event) }); writeErr != nil {
+			return i, writeErr
+		}
+	}
+
+	if batched && len(events) > 0 {
+		if err := s.write(func(ctx context.Context) error { return batcher.WriteBatch(ctx, events) }); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
+
+// write bounds a write to the sink by the sink timeout.
+func (s *Spool) write(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+func (s *Spool) commit(records []spoolRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	err := s.queue.commit(records)
+	if err == nil {
+		s.lastError = ""
+		s.notifyLocked()
+	}
+	s.mu.Unlock()
+	s.reportDepth()
+	return err
+}
+
+// backoff doubles the retry delay up to the maximum; the sink may ask for
+// a longer one.
+func (s *Spool) backoff(attempt int, err error) time.Duration {
+	delay := s.config.RetryBackoff
+	for range attempt {
+		if delay >= s.config.MaxRetryBackoff {
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, s.config.MaxRetryBackoff)
+	if requested, ok := source.RetryDelay(err); ok {
+		delay = max(delay, requested)
+	}
+	return delay
+}
+
+func (s *Spool) fail(err error, delay time.Duration) {
+	now := time.Now()
+	s.mu.Lock()
+	s.lastFailure = &now
+	s.lastError = err.Error()
+	events := s.queue.events
+	s.mu.Unlock()
+
+	s.logger.WithError(err).WithFields(logrus.Fields{
+		"sink":   s.name,
+		"events": events,
+		"retry":  delay.String(),
+	}).Warn("Failed to deliver spooled events")
+}
+
+func (s *Spool) reportDepth() {
+	s.mu.Lock()
+	m, events, size := s.metrics, s.queue.events, s.queue.bytes
+	s.mu.Unlock()
+	m.SetSpoolDepth(s.name, events, size)
+}
+
+// Status returns the state of the spool.
+func (s *Spool) Status() SpoolStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpoolStatus{
+		Events:      s.queue.events,
+		Bytes:       s.queue.bytes,
+		Full:        s.queue.bytes >= s.config.MaxBytes,
+		LastFailure: s.lastFailure,
+		LastError:   s.lastError,
+	}
+}
+
+// Check fails while the spool is full. An unavailable sink does not fail
+// it, as its events are spooled.
+func (s *Spool) Check(_ context.Context) error {
+	if status := s.Status(); status.Full {
+		return fmt.Errorf("%w with %d events", ErrSpoolFull, status.Events)
+	}
+	return nil
+}
+
+// Close stops delivery and closes the spool and the sink. Undelivered
+// events stay on disk for the next start.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	queueErr := s.queue.close()
+	s.mu.Unlock()
+	return errors.Join(queueErr, s.sink.Close())
+}
+
+func encodeSpoolEvent(event Event) ([]byte, error) {
+	fields := make(logrus.Fields, len(event.Fields))
+	for k, v := range event.Fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		fields[k] = v
+	}
+	payload, err := json.Marshal(spoolEvent{
+		Time:    event.Time,
+		Subject: event.Subject,
+		Type:    event.Type,
+		Level:   event.Level,
+		Message: event.Message,
+		Fields:  fields,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spooled event: %w", err)
+	}
+	return payload, nil
+}
+
+// decodeSpoolEvent keeps numbers as json.Number so large sequences keep
+// their precision.
+func decodeSpoolEvent(payload []byte) (Event, error) {
+	var stored spoolEvent
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&stored); err != nil {
+		return Event{}, fmt.Errorf("failed to decode spooled event: %w", err)
+	}
+	return Event{
+		Time:    stored.Time,
+		Subject: stored.Subject,
+		Type:    stored.Type,
+		Level:   stored.Level,
+		Message: stored.Message,
+		Fields:  stored.Fields,
+	}, nil
+}
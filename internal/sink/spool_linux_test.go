@@ -0,0 +1,49 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSpool_FailedWriteLeavesNoPartialRecord lowers the file size limit of
+// the process so that a spool record is only written in part.
+func TestSpool_FailedWriteLeavesNoPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	target := &recordingSink{err: errors.New("down")}
+	spool := openSpool(t, target, sink.SpoolConfig{Dir: dir, RetryBackoff: time.Millisecond})
+
+	require.NoError(t, spool.Write(context.Background(), newEvent("audit", "user.0")))
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	info, err := os.Stat(segments[0])
+	require.NoError(t, err)
+
+	var limit syscall.Rlimit
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit))
+	lowered := limit
+	lowered.Cur = uint64(info.Size()) + 8 //nolint:gosec // file sizes are positive
+	require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_FSIZE, &lowered))
+	err = spool.Write(context.Background(), newEvent("audit", "user.1"))
+	require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit))
+	require.Error(t, err)
+
+	after, err := os.Stat(segments[0])
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+
+	require.NoError(t, spool.Write(context.Background(), newEvent("audit", "user.2")))
+	target.setErr(nil)
+	require.Eventually(t, func() bool { return target.count() == 2 }, 2*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"user.0", "user.2"}, target.types())
+}
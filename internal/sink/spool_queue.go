@@ -0,0 +1,391 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	spoolSegmentExt  = ".seg"
+	spoolCursorFile  = "cursor.json"
+	spoolHeaderBytes = 8 // payload length and CRC-32C
+)
+
+// spoolCursor is the position of the first undelivered record.
+type spoolCursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// spoolRecord is a record read from the queue with the position after it.
+type spoolRecord struct {
+	payload []byte
+	size    int64
+	next    spoolCursor
+}
+
+// spoolQueue is an append-only queue of length-prefixed, checksummed
+// records in numbered segment files. A cursor file records the first
+// undelivered record; segments before it are removed. The queue is not
+// safe for concurrent use.
+type spoolQueue struct {
+	dir          string
+	segmentBytes int64
+
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+
+	cursor spoolCursor
+	events int
+	bytes  int64
+}
+
+func spoolCRC() *crc32.Table {
+	return crc32.MakeTable(crc32.Castagnoli)
+}
+
+// openSpoolQueue opens the queue in dir, counting the undelivered records.
+// A record torn by a crash at the end of the last segment is truncated.
+func openSpoolQueue(dir string, segmentBytes int64) (*spoolQueue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	q := &spoolQueue{dir: dir, segmentBytes: segmentBytes}
+	if err := q.loadCursor(); err != nil {
+		return nil, err
+	}
+
+	segments, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	var live []uint64
+	for _, seq := range segments {
+		if seq < q.cursor.Segment {
+			// Delivered before the segment could be removed.
+			if removeErr := os.Remove(q.segmentPath(seq)); removeErr != nil {
+				return nil, fmt.Errorf("failed to remove spool segment: %w", removeErr)
+			}
+			continue
+		}
+		live = append(live, seq)
+	}
+
+	if len(live) == 0 {
+		q.cursor = spoolCursor{Segment: max(q.cursor.Segment, 1)}
+		live = []uint64{q.cursor.Segment}
+	} else if q.cursor.Segment < live[0] {
+		q.cursor = spoolCursor{Segment: live[0]}
+	}
+
+	for i, seq := range live {
+		offset := int64(0)
+		if seq == q.cursor.Segment {
+			offset = q.cursor.Offset
+		}
+		if scanErr := q.scan(seq, offset, i == len(live)-1); scanErr != nil {
+			return nil, scanErr
+		}
+	}
+
+	q.activeSeq = live[len(live)-1]
+	if openErr := q.openActive(); openErr != nil {
+		return nil, openErr
+	}
+	return q, nil
+}
+
+func (q *spoolQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%016d%s", seq, spoolSegmentExt))
+}
+
+// segments returns the sequence numbers of the segment files in order.
+func (q *spoolQueue) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool directory: %w", err)
+	}
+	var segments []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), spoolSegmentExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		seq, parseErr := strconv.ParseUint(name, 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		segments = append(segments, seq)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (q *spoolQueue) loadCursor() error {
+	content, err := os.ReadFile(filepath.Join(q.dir, spoolCursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read spool cursor: %w", err)
+	}
+	if unmarshalErr := json.Unmarshal(content, &q.cursor); unmarshalErr != nil {
+		return fmt.Errorf("failed to parse spool cursor: %w", unmarshalErr)
+	}
+	return nil
+}
+
+// scan counts the records of a segment from offset. A damaged tail of the
+// last segment is truncated; damage elsewhere is an error.
+func (q *spoolQueue) scan(seq uint64, offset int64, last bool) error {
+	path := q.segmentPath(seq)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, seekErr := f.Seek(offset, io.SeekStart); seekErr != nil {
+		return fmt.Errorf("failed to seek spool segment: %w", seekErr)
+	}
+	reader := bufio.NewReader(f)
+	for {
+		payload, readErr := readSpoolRecord(reader)
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			if !last {
+				return fmt.Errorf("corrupt spool segment %s at offset %d: %w", path, offset, readErr)
+			}
+			if truncErr := os.Truncate(path, offset); truncErr != nil {
+				return fmt.Errorf("failed to truncate spool segment: %w", truncErr)
+			}
+			return nil
+		}
+		size := int64(spoolHeaderBytes + len(payload))
+		offset += size
+		q.events++
+		q.bytes += size
+	}
+}
+
+func (q *spoolQueue) openActive() error {
+	f, err := os.OpenFile(q.segmentPath(q.activeSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat spool segment: %w", err)
+	}
+	q.active = f
+	q.activeSize = info.Size()
+	return syncDir(q.dir)
+}
+
+// readSpoolRecord reads one record; a partial or damaged record is
+// io.ErrUnexpectedEOF or a checksum error.
+func readSpoolRecord(reader io.Reader) ([]byte, error) {
+	var header [spoolHeaderBytes]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, err //nolint:wrapcheck // io.EOF marks the end of the segment
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:4]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if crc32.Checksum(payload, spoolCRC()) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errors.New("spool record checksum mismatch")
+	}
+	return payload, nil
+}
+
+// recordSize returns the size of a record holding payload.
+func recordSize(payload []byte) int64 {
+	return int64(spoolHeaderBytes + len(payload))
+}
+
+// append durably writes a record, starting a new segment when the active
+// one would exceed the segment size.
+func (q *spoolQueue) append(payload []byte) error {
+	size := recordSize(payload)
+	if q.activeSize > 0 && q.activeSize+size > q.segmentBytes {
+		if err := q.active.Close(); err != nil {
+			return fmt.Errorf("failed to close spool segment: %w", err)
+		}
+		q.activeSeq++
+		if err := q.openActive(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload))) //nolint:gosec // events are far below 4 GiB
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, spoolCRC()))
+	copy(record[spoolHeaderBytes:], payload)
+	if err := q.write(record); err != nil {
+		// Drop a partially written record so that later records stay
+		// readable and the failed one is not replayed.
+		if truncErr := q.active.Truncate(q.activeSize); truncErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to truncate spool segment: %w", truncErr))
+		}
+		return err
+	}
+
+	q.activeSize += size
+	q.events++
+	q.bytes += size
+	return nil
+}
+
+func (q *spoolQueue) write(record []byte) error {
+	if _, err := q.active.Write(record); err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+	if err := q.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	return nil
+}
+
+// read returns up to limit records from the cursor without consuming them.
+func (q *spoolQueue) read(limit int) ([]spoolRecord, error) {
+	var records []spoolRecord
+	cur := q.cursor
+	for len(records) < limit {
+		batch, err := q.readSegment(cur, limit-len(records))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, batch...)
+		if len(batch) > 0 {
+			cur = batch[len(batch)-1].next
+		}
+		if len(records) >= limit || cur.Segment >= q.activeSeq {
+			break
+		}
+		// The segment is exhausted; continue in the next one. It is removed
+		// once a record after it is committed.
+		cur = spoolCursor{Segment: cur.Segment + 1}
+	}
+	return records, nil
+}
+
+func (q *spoolQueue) readSegment(cur spoolCursor, limit int) ([]spoolRecord, error) {
+	f, err := os.Open(q.segmentPath(cur.Segment))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, seekErr := f.Seek(cur.Offset, io.SeekStart); seekErr != nil {
+		return nil, fmt.Errorf("failed to seek spool segment: %w", seekErr)
+	}
+	reader := bufio.NewReader(f)
+	var records []spoolRecord
+	for len(records) < limit {
+		payload, readErr := readSpoolRecord(reader)
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read spool segment %d: %w", cur.Segment, readErr)
+		}
+		size := recordSize(payload)
+		cur.Offset += size
+		records = append(records, spoolRecord{payload: payload, size: size, next: cur})
+	}
+	return records, nil
+}
+
+// commit consumes the records up to and including last, persists the
+// cursor and removes delivered segments.
+func (q *spoolQueue) commit(records []spoolRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	next := records[len(records)-1].next
+
+	content, err := json.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool cursor: %w", err)
+	}
+	if writeErr := writeFileSync(filepath.Join(q.dir, spoolCursorFile), content); writeErr != nil {
+		return writeErr
+	}
+
+	for seq := q.cursor.Segment; seq < next.Segment; seq++ {
+		if removeErr := os.Remove(q.segmentPath(seq)); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove spool segment: %w", removeErr)
+		}
+	}
+
+	q.cursor = next
+	for _, record := range records {
+		q.events--
+		q.bytes -= record.size
+	}
+	return nil
+}
+
+// writeFileSync atomically replaces a file and syncs it.
+func writeFileSync(path string, content []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if renameErr := os.Rename(tmp, path); renameErr != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, renameErr)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir persists the creation, removal or renaming of files in dir.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %w", dir, err)
+	}
+	defer func() { _ = d.Close() }()
+	if syncErr := d.Sync(); syncErr != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, syncErr)
+	}
+	return nil
+}
+
+func (q *spoolQueue) close() error {
+	if err := q.active.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+	return nil
+}
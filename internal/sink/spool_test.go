@@ -0,0 +1,248 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *recordingSink) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *recordingSink) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// batchSink records the batches written to it.
+type batchSink struct {
+	recordingSink
+
+	batches []int
+}
+
+func (s *batchSink) WriteBatch(ctx context.Context, events []sink.Event) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(events))
+	s.mu.Unlock()
+	for _, event := range events {
+		if err := s.Write(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openSpool(t *testing.T, s sink.Sink, config sink.SpoolConfig) *sink.Spool {
+	t.Helper()
+
+	logger, _ := test.NewNullLogger()
+	spool, err := sink.NewSpool("test", s, config, time.Second, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = spool.Close() })
+	return spool
+}
+
+func eventTypes(n int) []string {
+	types := make([]string, n)
+	for i := range types {
+		types[i] = fmt.Sprintf("user.%d", i)
+	}
+	return types
+}
+
+func TestSpool_DeliversInOrderAfterOutage(t *testing.T) {
+	target := &recordingSink{err: errors.New("down")}
+	spool := openSpool(t, target, sink.SpoolConfig{
+		Dir:             t.TempDir(),
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: 10 * time.Millisecond,
+	})
+
+	for _, eventType := range eventTypes(5) {
+		require.NoError(t, spool.Write(context.Background(), newEvent("audit", eventType)))
+	}
+	require.Eventually(t, func() bool { return spool.Status().LastError != "" }, time.Second, time.Millisecond)
+	assert.Equal(t, 5, spool.Status().Events)
+	assert.Equal(t, "down", spool.Status().LastError)
+
+	target.setErr(nil)
+	require.Eventually(t, func() bool { return target.count() == 5 }, 2*time.Second, time.Millisecond)
+	assert.Equal(t, eventTypes(5), target.types())
+
+	status := spool.Status()
+	assert.Zero(t, status.Events)
+	assert.Zero(t, status.Bytes)
+	assert.Empty(t, status.LastError)
+}
+
+func TestSpool_ResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	logger, _ := test.NewNullLogger()
+
+	spool, err := sink.NewSpool("test", &recordingSink{err: errors.New("down")}, sink.SpoolConfig{Dir: dir, SegmentBytes: 256}, time.Second, logger)
+	require.NoError(t, err)
+	for _, eventType := range eventTypes(4) {
+		require.NoError(t, spool.Write(context.Background(), newEvent("audit", eventType)))
+	}
+	require.NoError(t, spool.Close())
+
+	// A record torn by a crash at the end of the last segment is dropped.
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 1, "segments are rolled")
+	last, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = last.Write([]byte{0, 0, 1, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, last.Close())
+
+	target := &recordingSink{}
+	spool = openSpool(t, target, sink.SpoolConfig{Dir: dir, SegmentBytes: 256})
+	require.Eventually(t, func() bool { return target.count() == 4 }, 2*time.Second, time.Millisecond)
+	assert.Equal(t, eventTypes(4), target.types())
+
+	// Delivered segments are removed and new events still follow.
+	require.NoError(t, spool.Write(context.Background(), newEvent("audit", "user.4")))
+	require.Eventually(t, func() bool { return target.count() == 5 }, 2*time.Second, time.Millisecond)
+	segments, err = filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
+
+func TestSpool_DrainsThroughBatchWriter(t *testing.T) {
+	target := &batchSink{}
+	target.err = errors.New("down")
+	spool := openSpool(t, target, sink.SpoolConfig{Dir: t.TempDir(), BatchSize: 3, RetryBackoff: time.Millisecond})
+
+	for _, eventType := range eventTypes(7) {
+		require.NoError(t, spool.Write(context.Background(), newEvent("audit", eventType)))
+	}
+	target.mu.Lock()
+	target.err = nil
+	target.batches = nil
+	target.mu.Unlock()
+
+	require.Eventually(t, func() bool { return spool.Status().Events == 0 }, 2*time.Second, time.Millisecond)
+	assert.Equal(t, eventTypes(7), target.types())
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	for _, size := range target.batches {
+		assert.LessOrEqual(t, size, 3)
+	}
+}
+
+func TestSpool_FullPolicies(t *testing.T) {
+	event := newEvent("audit", "user.created")
+	event.Time = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Find the size of a record to fit exactly two events.
+	probeDir := t.TempDir()
+	probe := openSpool(t, &recordingSink{err: errors.New("down")}, sink.SpoolConfig{Dir: probeDir})
+	require.NoError(t, probe.Write(context.Background(), event))
+	recordBytes := probe.Status().Bytes
+
+	t.Run("reject", func(t *testing.T) {
+		spool := openSpool(t, &recordingSink{err: errors.New("down")}, sink.SpoolConfig{
+			Dir:         t.TempDir(),
+			MaxBytes:    2 * recordBytes,
+			FullPolicy:  sink.SpoolReject,
+			RejectDelay: time.Minute,
+		})
+		for range 2 {
+			require.NoError(t, spool.Write(context.Background(), event))
+		}
+		assert.True(t, spool.Status().Full)
+		require.ErrorContains(t, spool.Check(context.Background()), "spool is full")
+
+		err := spool.Write(context.Background(), event)
+		require.ErrorIs(t, err, sink.ErrSpoolFull)
+		delay, ok := source.RetryDelay(err)
+		require.True(t, ok)
+		assert.Equal(t, time.Minute, delay)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		target := &recordingSink{err: errors.New("down")}
+		spool := openSpool(t, target, sink.SpoolConfig{
+			Dir:          t.TempDir(),
+			MaxBytes:     2 * recordBytes,
+			RetryBackoff: time.Millisecond,
+		})
+		for range 2 {
+			require.NoError(t, spool.Write(context.Background(), event))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, spool.Write(ctx, event), sink.ErrSpoolFull)
+
+		// A blocked write proceeds once the sink recovers.
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, spool.Write(context.Background(), event))
+		}()
+		time.Sleep(20 * time.Millisecond)
+		target.setErr(nil)
+		wg.Wait()
+		require.Eventually(t, func() bool { return target.count() == 3 }, 2*time.Second, time.Millisecond)
+		require.NoError(t, spool.Check(context.Background()))
+	})
+}
+
+func TestNewSpool_Invalid(t *testing.T) {
+	tests := map[string]sink.SpoolConfig{
+		"requires a directory":      {},
+		"unknown spool full policy": {Dir: t.TempDir(), FullPolicy: "drop"},
+		"must not be negative":      {Dir: t.TempDir(), MaxBytes: -1},
+	}
+	for message, config := range tests {
+		_, err := sink.NewSpool("test", &recordingSink{}, config, time.Second, nil)
+		require.ErrorContains(t, err, message)
+	}
+}
+
+func TestOpen_Spool(t *testing.T) {
+	dir := t.TempDir()
+	logger, _ := test.NewNullLogger()
+
+	_, err := sink.Open(sink.Config{Sinks: []sink.SinkConfig{
+		{Name: "a", Type: sink.TypeLog, Spool: sink.SpoolConfig{Dir: dir}},
+		{Name: "b", Type: sink.TypeLog, Spool: sink.SpoolConfig{Dir: dir + "/"}},
+	}}, logger)
+	require.ErrorContains(t, err, "spool directory is used by sink a")
+
+	router, err := sink.Open(sink.Config{Sinks: []sink.SinkConfig{
+		{Name: "a", Type: sink.TypeLog, Mandatory: true, Spool: sink.SpoolConfig{Dir: dir}},
+	}}, logger)
+	require.NoError(t, err)
+	defer router.Close()
+
+	require.NoError(t, router.Deliver(context.Background(), newEvent("audit", "user.created")))
+	statuses := router.Statuses()
+	require.Len(t, statuses, 1)
+	require.NotNil(t, statuses[0].Spool)
+	require.Eventually(t, func() bool { return router.Statuses()[0].Spool.Events == 0 }, 2*time.Second, time.Millisecond)
+}
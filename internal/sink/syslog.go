@@ -0,0 +1,435 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Syslog transports.
+const (
+	SyslogUDP = "udp"
+	SyslogTCP = "tcp"
+	SyslogTLS = "tls"
+)
+
+const (
+	defaultSyslogFacility     = "local0"
+	defaultSyslogAppName      = "events-audit"
+	defaultSyslogEnterpriseID = 32473 // RFC 5612 documentation enterprise number
+	defaultSyslogDialTimeout  = 5 * time.Second
+
+	syslogVersion      = 1
+	syslogTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+	syslogNil          = "-"
+	syslogBOM          = "\ufeff"
+	maxSyslogHostname  = 255
+	maxSyslogAppName   = 48
+	maxSyslogMsgID     = 32
+	maxSyslogParamName = 32
+)
+
+// SyslogConfig configures a sink sending RFC 5424 messages.
+type SyslogConfig struct {
+	// Network is udp, tcp or tls; stream transports use octet-counted
+	// framing.
+	Network  string `yaml:"network"`
+	Address  string `yaml:"address"`
+	Facility string `yaml:"facility"`
+	// Hostname defaults to the host name of the machine.
+	Hostname string `yaml:"hostname"`
+	// AppName is used for events without a source.
+	AppName string `yaml:"app_name"`
+	// EnterpriseID is the private enterprise number of the SD-IDs.
+	EnterpriseID int           `yaml:"enterprise_id"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	TLS          TLSConfig     `yaml:"tls"`
+}
+
+// SyslogSink sends events as RFC 5424 messages. The connection is dialled
+// on the first write and re-established after it breaks.
+type SyslogSink struct {
+	config    SyslogConfig
+	facility  int
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+	// closed is closed once the server closed a stream connection.
+	closed chan struct{}
+}
+
+// NewSyslogSink validates the configuration of a syslog sink. The
+// connection is established lazily.
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	if config.Address == "" {
+		return nil, errors.New("syslog sink requires an address")
+	}
+	if config.Facility == "" {
+		config.Facility = defaultSyslogFacility
+	}
+	facility, ok := syslogFacility(config.Facility)
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", config.Facility)
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.AppName == "" {
+		config.AppName = defaultSyslogAppName
+	}
+	if config.EnterpriseID == 0 {
+		config.EnterpriseID = defaultSyslogEnterpriseID
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultSyslogDialTimeout
+	}
+
+	s := &SyslogSink{config: config, facility: facility}
+	switch config.Network {
+	case SyslogUDP, SyslogTCP:
+	case SyslogTLS:
+		tlsConfig, err := config.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = tlsConfig
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q, supported %s, %s or %s",
+			config.Network, SyslogUDP, SyslogTCP, SyslogTLS)
+	}
+	return s, nil
+}
+
+// Write sends the event. A broken connection is re-established and the
+// message sent once more before the write fails.
+func (s *SyslogSink) Write(ctx context.Context, event Event) error {
+	frame := s.frame(s.Format(event))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && !s.alive() {
+		s.closeConn()
+	}
+	err := s.send(ctx, frame)
+	if err == nil {
+		return nil
+	}
+	s.closeConn()
+	if retryErr := s.send(ctx, frame); retryErr != nil {
+		return fmt.Errorf("failed to send syslog message: %w", retryErr)
+	}
+	return nil
+}
+
+// Check dials the syslog server when there is no connection.
+func (s *SyslogSink) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && !s.alive() {
+		s.closeConn()
+	}
+	if s.conn != nil {
+		return nil
+	}
+	return s.dial(ctx)
+}
+
+// Close closes the connection.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	if err != nil {
+		return fmt.Errorf("failed to close syslog connection: %w", err)
+	}
+	return nil
+}
+
+func (s *SyslogSink) send(ctx context.Context, frame []byte) error {
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+	if _, err := s.conn.Write(frame); err != nil {
+		return fmt.Errorf("failed to write syslog message: %w", err)
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.DialTimeout)
+	defer cancel()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch s.config.Network {
+	case SyslogTLS:
+		dialer := &tls.Dialer{Config: s.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", s.config.Address)
+	default:
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, s.config.Network, s.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog server %s: %w", s.config.Address, err)
+	}
+	s.conn = conn
+	s.closed = make(chan struct{})
+	if s.config.Network != SyslogUDP {
+		go watchConn(conn, s.closed)
+	}
+	return nil
+}
+
+// watchConn reads from a stream connection until it fails and then closes
+// closed. Syslog servers never send data, so the read only returns once the
+// server closed the connection or it was closed locally.
+func watchConn(conn net.Conn, closed chan<- struct{}) {
+	defer close(closed)
+	var buf [1]byte
+	for {
+		if _, err := conn.Read(buf[:]); err != nil {
+			return
+		}
+	}
+}
+
+// alive reports whether the connection is still open, so that a write is
+// not lost on a connection the server already closed.
+func (s *SyslogSink) alive() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *SyslogSink) closeConn() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// frame applies octet-counted framing on stream transports.
+func (s *SyslogSink) frame(msg string) []byte {
+	if s.config.Network == SyslogUDP {
+		return []byte(msg)
+	}
+	return []byte(strconv.Itoa(len(msg)) + " " + msg)
+}
+
+// Format renders the event as an RFC 5424 message: the event source is the
+// APP-NAME, the event type the MSGID, JetStream metadata and the other
+// scalar fields are SD-ELEMENTs and the event data is the MSG.
+func (s *SyslogSink) Format(event Event) string {
+	var b strings.Builder
+
+	pri := s.facility*8 + syslogSeverity(event.Level) //nolint:mnd // PRI is facility * 8 + severity
+	appName, _ := event.Fields["source"].(string)
+	if appName == "" {
+		appName = s.config.AppName
+	}
+
+	fmt.Fprintf(&b, "<%d>%d %s %s %s %s %s ",
+		pri,
+		syslogVersion,
+		event.Time.Format(syslogTimeFormat),
+		headerField(s.config.Hostname, maxSyslogHostname),
+		headerField(appName, maxSyslogAppName),
+		strconv.Itoa(os.Getpid()),
+		headerField(event.Type, maxSyslogMsgID),
+	)
+	b.WriteString(s.structuredData(event.Fields))
+	b.WriteString(" ")
+	b.WriteString(syslogBOM)
+	b.WriteString(syslogMessage(event))
+	return b.String()
+}
+
+// jetStreamParams maps JetStream metadata fields to SD-PARAM names.
+func jetStreamParams() [][2]string {
+	return [][2]string{
+		{"stream", "stream"},
+		{"consumer", "consumer"},
+		{"sequence", "sequence"},
+		{"delivered", "delivered"},
+		{"pending", "pending"},
+		{"js_timestamp", "timestamp"},
+	}
+}
+
+// isDataField reports whether a field holds the event payload sent as MSG.
+func isDataField(key string) bool {
+	return key == "event_data" || key == "raw_data" || key == "data"
+}
+
+func (s *SyslogSink) structuredData(fields logrus.Fields) string {
+	var b strings.Builder
+
+	jetStream := make(map[string]bool)
+	var params []string
+	for _, param := range jetStreamParams() {
+		jetStream[param[0]] = true
+		if value, ok := fields[param[0]]; ok {
+			params = append(params, sdParam(param[1], value))
+		}
+	}
+	if len(params) > 0 {
+		fmt.Fprintf(&b, "[jetstream@%d %s]", s.config.EnterpriseID, strings.Join(params, " "))
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !jetStream[key] && !isDataField(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	params = params[:0]
+	for _, key := range keys {
+		params = append(params, sdParam(key, fields[key]))
+	}
+	if len(params) > 0 {
+		fmt.Fprintf(&b, "[event@%d %s]", s.config.EnterpriseID, strings.Join(params, " "))
+	}
+
+	if b.Len() == 0 {
+		return syslogNil
+	}
+	return b.String()
+}
+
+// sdParam renders a PARAM-NAME="PARAM-VALUE" pair; objects and arrays are
+// encoded as JSON.
+func sdParam(name string, value any) string {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case map[string]any, []any, []string, logrus.Fields:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprint(v)
+		} else {
+			text = string(encoded)
+		}
+	default:
+		text = fmt.Sprint(v)
+	}
+	return paramName(name) + `="` + escapeParamValue(text) + `"`
+}
+
+// paramName keeps the characters allowed in an SD-NAME.
+func paramName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if b.Len() == maxSyslogParamName {
+			break
+		}
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func escapeParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// headerField returns a header field of printable ASCII cut to its
+// maximum length, or the nil value when empty.
+func headerField(value string, maxLength int) string {
+	if value == "" {
+		return syslogNil
+	}
+	var b strings.Builder
+	for _, r := range value {
+		if b.Len() == maxLength {
+			break
+		}
+		if r <= ' ' || r > '~' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// syslogMessage returns the event data, JSON encoded unless it is a
+// string, or the log message when the event has no data.
+func syslogMessage(event Event) string {
+	for _, key := range []string{"event_data", "raw_data", "data"} {
+		value, ok := event.Fields[key]
+		if !ok {
+			continue
+		}
+		if text, isString := value.(string); isString {
+			return text
+		}
+		if encoded, err := json.Marshal(value); err == nil {
+			return string(encoded)
+		}
+	}
+	return event.Message
+}
+
+// syslogSeverity maps log levels to syslog severities.
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 //nolint:mnd // critical
+	case logrus.ErrorLevel:
+		return 3 //nolint:mnd // error
+	case logrus.WarnLevel:
+		return 4 //nolint:mnd // warning
+	case logrus.InfoLevel:
+		return 6 //nolint:mnd // informational
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return 7 //nolint:mnd // debug
+	default:
+		return 7 //nolint:mnd // debug
+	}
+}
+
+// syslogFacility returns the code of a facility name.
+func syslogFacility(name string) (int, bool) {
+	facilities := map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+		"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+	code, ok := facilities[name]
+	return code, ok
+}
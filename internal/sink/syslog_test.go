@@ -0,0 +1,237 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func syslogEvent() sink.Event {
+	return sink.Event{
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Subject: "audit.users",
+		Type:    "user.created",
+		Level:   logrus.WarnLevel,
+		Message: "Received structured event",
+		Fields: logrus.Fields{
+			"subject":    "audit.users",
+			"stream":     "EVENTS",
+			"sequence":   uint64(42),
+			"delivered":  1,
+			"event_id":   "e1",
+			"event_type": "user.created",
+			"source":     "user service",
+			"note":       `a "quoted" [value] \ here`,
+			"event_data": map[string]any{"user_id": "42"},
+		},
+	}
+}
+
+func TestSyslogSink_Format(t *testing.T) {
+	s, err := sink.NewSyslogSink(sink.SyslogConfig{Network: sink.SyslogUDP, Address: "127.0.0.1:514", Hostname: "audit-1"})
+	require.NoError(t, err)
+
+	expected := `<132>1 2026-01-02T03:04:05.123456Z audit-1 user_service ` + strconv.Itoa(os.Getpid()) + ` user.created ` +
+		`[jetstream@32473 stream="EVENTS" sequence="42" delivered="1"]` +
+		`[event@32473 event_id="e1" event_type="user.created" note="a \"quoted\" [value\] \\ here" ` +
+		`source="user service" subject="audit.users"] ` +
+		"\ufeff" + `{"user_id":"42"}`
+	assert.Equal(t, expected, s.Format(syslogEvent()))
+
+	// Events without metadata, type or data use nil values and the message.
+	event := sink.Event{Time: time.Unix(0, 0).UTC(), Level: logrus.InfoLevel, Message: "hello"}
+	assert.Equal(t, "<134>1 1970-01-01T00:00:00.000000Z audit-1 events-audit "+strconv.Itoa(os.Getpid())+
+		" - - \ufeffhello", s.Format(event))
+}
+
+func TestNewSyslogSink_Invalid(t *testing.T) {
+	tests := map[string]sink.SyslogConfig{
+		"requires an address":     {Network: sink.SyslogUDP},
+		"unknown syslog network":  {Network: "http", Address: "a:1"},
+		"unknown syslog facility": {Network: sink.SyslogUDP, Address: "a:1", Facility: "local9"},
+		"both TLS certificate":    {Network: sink.SyslogTLS, Address: "a:1", TLS: sink.TLSConfig{CertFile: "cert.pem"}},
+	}
+	for message, config := range tests {
+		_, err := sink.NewSyslogSink(config)
+		require.ErrorContains(t, err, message)
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, err := sink.NewSyslogSink(sink.SyslogConfig{Network: sink.SyslogUDP, Address: conn.LocalAddr().String()})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Write(context.Background(), syslogEvent()))
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, s.Format(syslogEvent()), string(buf[:n]))
+}
+
+// syslogServer accepts stream connections and reads octet-counted frames.
+type syslogServer struct {
+	listener net.Listener
+	frames   chan string
+	conns    chan net.Conn
+}
+
+func startSyslogServer(t *testing.T, listener net.Listener) *syslogServer {
+	t.Helper()
+
+	srv := &syslogServer{listener: listener, frames: make(chan string, 10), conns: make(chan net.Conn, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			srv.conns <- conn
+			go srv.read(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *syslogServer) read(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			return
+		}
+		frame := make([]byte, n)
+		if _, err = io.ReadFull(reader, frame); err != nil {
+			return
+		}
+		srv.frames <- string(frame)
+	}
+}
+
+func (srv *syslogServer) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case frame := <-srv.frames:
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatal("no syslog frame received")
+		return ""
+	}
+}
+
+func TestSyslogSink_TCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := startSyslogServer(t, listener)
+
+	s, err := sink.NewSyslogSink(sink.SyslogConfig{Network: sink.SyslogTCP, Address: listener.Addr().String()})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Check(context.Background()))
+	require.NoError(t, s.Write(context.Background(), syslogEvent()))
+	assert.Equal(t, s.Format(syslogEvent()), srv.next(t))
+
+	// The server drops the connection; the next event arrives on a new one.
+	(<-srv.conns).Close()
+	time.Sleep(50 * time.Millisecond)
+
+	event := syslogEvent()
+	event.Type = "user.deleted"
+	require.NoError(t, s.Write(context.Background(), event))
+	assert.Contains(t, srv.next(t), " user.deleted ")
+	assert.Len(t, srv.conns, 1)
+
+	// Health checks notice a dropped connection as well.
+	(<-srv.conns).Close()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, s.Check(context.Background()))
+	select {
+	case <-srv.conns:
+	case <-time.After(2 * time.Second):
+		t.Fatal("check did not reconnect")
+	}
+}
+
+func TestSyslogSink_TLS(t *testing.T) {
+	cert, caFile := selfSignedCert(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	srv := startSyslogServer(t, listener)
+
+	s, err := sink.NewSyslogSink(sink.SyslogConfig{
+		Network: sink.SyslogTLS,
+		Address: listener.Addr().String(),
+		TLS:     sink.TLSConfig{CAFile: caFile},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for range 2 {
+		require.NoError(t, s.Write(context.Background(), syslogEvent()))
+		assert.Equal(t, s.Format(syslogEvent()), srv.next(t))
+	}
+	assert.Len(t, srv.conns, 1, "the connection is reused")
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1 and writes it as the
+// CA bundle.
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
@@ -0,0 +1,436 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook body formats.
+const (
+	WebhookJSON   = "json"
+	WebhookNDJSON = "ndjson"
+)
+
+// Headers set on signed webhook requests.
+const (
+	HeaderWebhookTimestamp = "X-Audit-Timestamp"
+	HeaderWebhookSignature = "X-Audit-Signature"
+)
+
+const (
+	defaultWebhookBatchSize       = 100
+	defaultWebhookBatchBytes      = 1 << 20
+	defaultWebhookBatchWait       = time.Second
+	defaultWebhookMaxRetries      = 3
+	defaultWebhookRetryBackoff    = 500 * time.Millisecond
+	defaultWebhookMaxRetryBackoff = 30 * time.Second
+	defaultWebhookRequestTimeout  = 10 * time.Second
+	defaultWebhookNakDelay        = 30 * time.Second
+
+	webhookErrorBodyBytes  = 512
+	webhookSignaturePrefix = "sha256="
+)
+
+// WebhookConfig configures a sink posting batches of events to an HTTP
+// endpoint.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Format is json for an array of events or ndjson for one event per
+	// line.
+	Format  string            `yaml:"format"`
+	Headers map[string]string `yaml:"headers"`
+	// HMACKeyFile enables signing of request bodies.
+	HMACKeyFile string `yaml:"hmac_key_file"`
+
+	// A batch is posted when it holds BatchSize events or BatchBytes of
+	// encoded events, or BatchWait after its first event.
+	BatchSize  int           `yaml:"batch_size"`
+	BatchBytes int           `yaml:"batch_bytes"`
+	BatchWait  time.Duration `yaml:"batch_wait"`
+
+	// MaxRetries failed posts are retried with exponential backoff starting
+	// at RetryBackoff, or after the Retry-After of the response.
+	MaxRetries      int           `yaml:"max_retries"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`
+	// NakDelay is the redelivery delay of events once retries are
+	// exhausted.
+	NakDelay time.Duration `yaml:"nak_delay"`
+
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// WebhookSink posts events to an HTTP endpoint in batches. A write returns
+// once the batch holding its event has been accepted or has failed. Batches
+// are posted concurrently, so a batch that is being retried does not hold
+// back the next one.
+type WebhookSink struct {
+	config WebhookConfig
+	client *http.Client
+	key    []byte
+
+	queue     chan *webhookItem
+	stop      chan struct{}
+	done      chan struct{}
+	sending   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// webhookItem is a queued event. ctx is the context of its writer; events
+// whose writer gave up are not posted any more, since the source redelivers
+// them.
+type webhookItem struct {
+	ctx    context.Context //nolint:containedctx // the writer waiting for the item
+	data   []byte
+	result chan error
+}
+
+// NewWebhookSink validates the configuration and starts the batching loop
+// of a webhook sink.
+func NewWebhookSink(config WebhookConfig) (*WebhookSink, error) {
+	if config.URL == "" {
+		return nil, errors.New("webhook sink requires a url")
+	}
+	if config.Format == "" {
+		config.Format = WebhookJSON
+	}
+	if config.Format != WebhookJSON && config.Format != WebhookNDJSON {
+		return nil, fmt.Errorf("unknown webhook format %q, supported %s or %s", config.Format, WebhookJSON, WebhookNDJSON)
+	}
+	if config.BatchSize < 0 || config.BatchBytes < 0 || config.MaxRetries < 0 {
+		return nil, errors.New("webhook batch and retry limits must not be negative")
+	}
+	setDefault(&config.BatchSize, defaultWebhookBatchSize)
+	setDefault(&config.BatchBytes, defaultWebhookBatchBytes)
+	setDefault(&config.BatchWait, defaultWebhookBatchWait)
+	setDefault(&config.RetryBackoff, defaultWebhookRetryBackoff)
+	setDefault(&config.MaxRetryBackoff, defaultWebhookMaxRetryBackoff)
+	setDefault(&config.RequestTimeout, defaultWebhookRequestTimeout)
+	setDefault(&config.NakDelay, defaultWebhookNakDelay)
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultWebhookMaxRetries
+	}
+
+	s := &WebhookSink{
+		config: config,
+		queue:  make(chan *webhookItem),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if config.HMACKeyFile != "" {
+		content, err := os.ReadFile(config.HMACKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook HMAC key: %w", err)
+		}
+		s.key = []byte(strings.TrimSpace(string(content)))
+		if len(s.key) == 0 {
+			return nil, fmt.Errorf("webhook HMAC key file %s is empty", config.HMACKeyFile)
+		}
+	}
+
+	tlsConfig, err := config.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // the default transport
+	transport.TLSClientConfig = tlsConfig
+	s.client = &http.Client{Transport: transport, Timeout: config.RequestTimeout}
+
+	go s.run()
+	return s, nil
+}
+
+func setDefault[T int | int64 | time.Duration](value *T, fallback T) {
+	if *value <= 0 {
+		*value = fallback
+	}
+}
+
+// Write queues the event and waits for its batch to be posted. When ctx
+// ends first the event is dropped from its batch, unless it is being
+// posted already, and the write asks for a delayed redelivery.
+func (s *WebhookSink) Write(ctx context.Context, event Event) error {
+	data, err := Encode(event)
+	if err != nil {
+		return err
+	}
+
+	item := &webhookItem{ctx: ctx, data: data, result: make(chan error, 1)}
+	select {
+	case s.queue <- item:
+	case <-s.stop:
+		return errors.New("webhook sink is closed")
+	case <-ctx.Done():
+		return &DelayError{Err: ctx.Err(), Delay: s.config.NakDelay}
+	}
+
+	select {
+	case err = <-item.result:
+		return err
+	case <-ctx.Done():
+		return &DelayError{Err: ctx.Err(), Delay: s.config.NakDelay}
+	}
+}
+
+// WriteBatch posts events right away in batches within the size limits;
+// spools drain through it.
+func (s *WebhookSink) WriteBatch(ctx context.Context, events []Event) error {
+	var (
+		batch []*webhookItem
+		size  int
+	)
+	for _, event := range events {
+		data, err := Encode(event)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 && (len(batch) >= s.config.BatchSize || size+len(data) > s.config.BatchBytes) {
+			if sendErr := s.send(batch); sendErr != nil {
+				return sendErr
+			}
+			batch, size = nil, 0
+		}
+		batch = append(batch, &webhookItem{ctx: ctx, data: data})
+		size += len(data)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.send(batch)
+}
+
+// run collects queued events into batches and posts them.
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	var (
+		batch   []*webhookItem
+		size    int
+		timeout <-chan time.Time
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.sending.Add(1)
+		go func(batch []*webhookItem) {
+			defer s.sending.Done()
+			err := s.send(batch)
+			for _, item := range batch {
+				item.result <- err
+			}
+		}(batch)
+		batch, size, timeout = nil, 0, nil
+	}
+
+	for {
+		select {
+		case item := <-s.queue:
+			if len(batch) > 0 && size+len(item.data) > s.config.BatchBytes {
+				flush()
+			}
+			batch = append(batch, item)
+			size += len(item.data)
+			if len(batch) == 1 {
+				timeout = time.After(s.config.BatchWait)
+			}
+			if len(batch) >= s.config.BatchSize || size >= s.config.BatchBytes {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		case <-s.stop:
+			flush()
+			return
+		}
+	}
+}
+
+// send posts a batch, retrying failures that may be temporary for as long
+// as one of its writers waits. Events whose writer gave up are dropped
+// before every attempt. When the endpoint stays unavailable the error asks
+// for a delayed redelivery.
+func (s *WebhookSink) send(batch []*webhookItem) error {
+	for attempt := 0; ; attempt++ {
+		live := waiting(batch)
+		if len(live) == 0 {
+			return &DelayError{Err: batch[0].ctx.Err(), Delay: s.config.NakDelay}
+		}
+		batch = live
+
+		ctx, cancel := batchContext(batch)
+		retryAfter, retryable, err := s.post(ctx, s.encodeBatch(batch))
+		cancel()
+		if err == nil || !retryable {
+			return err
+		}
+		if attempt >= s.config.MaxRetries || retryAfter > s.config.MaxRetryBackoff {
+			return &DelayError{Err: err, Delay: max(s.config.NakDelay, retryAfter)}
+		}
+
+		delay := retryAfter
+		if delay == 0 {
+			delay = s.backoff(attempt)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return &DelayError{Err: err, Delay: s.config.NakDelay}
+		}
+		select {
+		case <-time.After(delay):
+		case <-s.stop:
+			return &DelayError{Err: err, Delay: s.config.NakDelay}
+		}
+	}
+}
+
+// waiting returns the items whose writer still waits for them.
+func waiting(batch []*webhookItem) []*webhookItem {
+	var live []*webhookItem
+	for _, item := range batch {
+		if item.ctx.Err() == nil {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+// batchContext returns a context ending with the latest deadline of the
+// writers of a batch, or without a deadline when one of them has none.
+func batchContext(batch []*webhookItem) (context.Context, context.CancelFunc) {
+	var latest time.Time
+	for _, item := range batch {
+		deadline, ok := item.ctx.Deadline()
+		if !ok {
+			return context.WithCancel(context.Background())
+		}
+		if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	return context.WithDeadline(context.Background(), latest)
+}
+
+func (s *WebhookSink) encodeBatch(batch []*webhookItem) []byte {
+	var body bytes.Buffer
+	if s.config.Format == WebhookJSON {
+		body.WriteByte('[')
+	}
+	for i, item := range batch {
+		if i > 0 && s.config.Format == WebhookJSON {
+			body.WriteByte(',')
+		}
+		body.Write(item.data)
+		if s.config.Format == WebhookNDJSON {
+			body.WriteByte('\n')
+		}
+	}
+	if s.config.Format == WebhookJSON {
+		body.WriteByte(']')
+	}
+	return body.Bytes()
+}
+
+// post sends one request. Network errors, 408, 429 and 5xx responses are
+// retryable; the Retry-After of the response is returned when set.
+func (s *WebhookSink) post(ctx context.Context, body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	if s.config.Format == WebhookNDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range s.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.key != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderWebhookTimestamp, timestamp)
+		req.Header.Set(HeaderWebhookSignature, SignWebhook(s.key, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyBytes))
+	// Drain the rest so the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return 0, false, nil
+	}
+
+	retryable := resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= http.StatusInternalServerError
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), retryable,
+		fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+}
+
+// backoff doubles the delay with every attempt, up to MaxRetryBackoff,
+// and picks a random delay in its upper half.
+func (s *WebhookSink) backoff(attempt int) time.Duration {
+	delay := s.config.RetryBackoff
+	for range attempt {
+		if delay >= s.config.MaxRetryBackoff {
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, s.config.MaxRetryBackoff)
+	half := delay / 2            //nolint:mnd // equal jitter
+	return half + rand.N(half+1) //nolint:gosec // jitter needs no secure randomness
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// SignWebhook returns the signature header of a body: the hex HMAC-SHA256
+// of the timestamp, a dot and the body. Receivers should reject requests
+// with old timestamps to prevent replays.
+func SignWebhook(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close posts the pending batch and stops the sink.
+func (s *WebhookSink) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	s.sending.Wait()
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,323 @@
+package sink_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"events-audit/internal/sink"
+	"events-audit/internal/source"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer records requests and answers with the queued responses,
+// then with 200.
+type webhookServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []*http.Request
+	bodies    [][]byte
+	times     []time.Time
+	responses []func(w http.ResponseWriter)
+}
+
+func startWebhookServer(t *testing.T, responses ...func(w http.ResponseWriter)) *webhookServer {
+	t.Helper()
+
+	srv := &webhookServer{responses: responses}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		srv.mu.Lock()
+		srv.requests = append(srv.requests, r)
+		srv.bodies = append(srv.bodies, body)
+		srv.times = append(srv.times, time.Now())
+		var respond func(w http.ResponseWriter)
+		if len(srv.responses) > 0 {
+			respond, srv.responses = srv.responses[0], srv.responses[1:]
+		}
+		srv.mu.Unlock()
+
+		if respond != nil {
+			respond(w)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *webhookServer) count() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.requests)
+}
+
+func status(code int, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func newWebhookSink(t *testing.T, config sink.WebhookConfig) *sink.WebhookSink {
+	t.Helper()
+
+	s, err := sink.NewWebhookSink(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestWebhookSink_BatchesAndSigns(t *testing.T) {
+	srv := startWebhookServer(t)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("secret\n"), 0o600))
+
+	s := newWebhookSink(t, sink.WebhookConfig{
+		URL:         srv.URL,
+		HMACKeyFile: keyFile,
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		BatchSize:   3,
+		BatchWait:   time.Minute,
+	})
+
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Write(context.Background(), newEvent("audit.users", fmt.Sprintf("user.%d", i))))
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, srv.count())
+	req, body := srv.requests[0], srv.bodies[0]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+	var events []map[string]any
+	require.NoError(t, json.Unmarshal(body, &events))
+	assert.Len(t, events, 3)
+
+	timestamp := req.Header.Get(sink.HeaderWebhookTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(unix, 0), 5*time.Second)
+	assert.Equal(t, sink.SignWebhook([]byte("secret"), timestamp, body), req.Header.Get(sink.HeaderWebhookSignature))
+}
+
+func TestWebhookSink_FlushesNDJSONAfterBatchWait(t *testing.T) {
+	srv := startWebhookServer(t)
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, Format: sink.WebhookNDJSON, BatchWait: 20 * time.Millisecond})
+
+	require.NoError(t, s.Write(context.Background(), newEvent("audit.users", "user.created")))
+
+	require.Equal(t, 1, srv.count())
+	assert.Equal(t, "application/x-ndjson", srv.requests[0].Header.Get("Content-Type"))
+	assert.Empty(t, srv.requests[0].Header.Get(sink.HeaderWebhookSignature))
+
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(srv.bodies[0]))
+	for scanner.Scan() {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, "user.created", event["event_type"])
+		lines++
+	}
+	assert.Equal(t, 1, lines)
+}
+
+func TestWebhookSink_FlushesByBytes(t *testing.T) {
+	srv := startWebhookServer(t)
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchBytes: 1, BatchWait: time.Minute})
+
+	for range 2 {
+		require.NoError(t, s.Write(context.Background(), newEvent("audit.users", "user.created")))
+	}
+	assert.Equal(t, 2, srv.count())
+}
+
+func TestWebhookSink_Retries(t *testing.T) {
+	srv := startWebhookServer(t,
+		status(http.StatusInternalServerError),
+		status(http.StatusTooManyRequests, "Retry-After", "1"),
+	)
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchWait: time.Millisecond, RetryBackoff: time.Millisecond})
+
+	require.NoError(t, s.Write(context.Background(), newEvent("audit.users", "user.created")))
+
+	require.Equal(t, 3, srv.count())
+	assert.Equal(t, srv.bodies[0], srv.bodies[2], "the same batch is retried")
+	assert.GreaterOrEqual(t, srv.times[2].Sub(srv.times[1]), time.Second, "Retry-After is honoured")
+}
+
+func TestWebhookSink_DelaysRedeliveryWhenDown(t *testing.T) {
+	down := func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }
+	srv := startWebhookServer(t, down, down, down)
+	s := newWebhookSink(t, sink.WebhookConfig{
+		URL:          srv.URL,
+		BatchWait:    time.Millisecond,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		NakDelay:     time.Minute,
+	})
+
+	logger, _ := test.NewNullLogger()
+	router := sink.NewRouter(logger)
+	require.NoError(t, router.Add(sink.SinkConfig{Name: "webhook", Type: sink.TypeWebhook, Mandatory: true}, s))
+
+	err := router.Deliver(context.Background(), newEvent("audit.users", "user.created"))
+	require.ErrorContains(t, err, "503 Service Unavailable")
+	assert.Equal(t, 3, srv.count())
+
+	delay, ok := source.RetryDelay(err)
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+}
+
+func TestWebhookSink_LongRetryAfterDelaysRedelivery(t *testing.T) {
+	srv := startWebhookServer(t, status(http.StatusServiceUnavailable, "Retry-After", "120"))
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchWait: time.Millisecond})
+
+	err := s.Write(context.Background(), newEvent("audit.users", "user.created"))
+	delay, ok := source.RetryDelay(err)
+	require.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+	assert.Equal(t, 1, srv.count(), "retries beyond the backoff limit are left to redelivery")
+}
+
+func TestWebhookSink_DropsEventsOfWritersThatGaveUp(t *testing.T) {
+	srv := startWebhookServer(t)
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchWait: 200 * time.Millisecond, NakDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.Write(ctx, newEvent("audit.users", "user.created"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	delay, ok := source.RetryDelay(err)
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+
+	// The redelivered event must not be posted twice.
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, 0, srv.count())
+}
+
+func TestWebhookSink_RetriesWithinWriterDeadline(t *testing.T) {
+	down := func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }
+	srv := startWebhookServer(t, down, down, down, down)
+	s := newWebhookSink(t, sink.WebhookConfig{
+		URL:          srv.URL,
+		BatchWait:    time.Millisecond,
+		RetryBackoff: time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := s.Write(ctx, newEvent("audit.users", "user.created"))
+	_, ok := source.RetryDelay(err)
+	require.True(t, ok)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The batch is not retried after its writer gave up, so Close returns.
+	require.NoError(t, s.Close())
+	assert.Equal(t, 1, srv.count())
+}
+
+func TestWebhookSink_PostsBatchesConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	srv := startWebhookServer(t, func(http.ResponseWriter) { <-release })
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchSize: 1})
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- s.Write(context.Background(), newEvent("audit.users", "user.created")) }()
+	require.Eventually(t, func() bool { return srv.count() == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	// A slow batch does not hold back the next one.
+	require.NoError(t, s.Write(context.Background(), newEvent("audit.users", "user.deleted")))
+	close(release)
+	require.NoError(t, <-blocked)
+}
+
+func TestWebhookSink_DoesNotRetryClientErrors(t *testing.T) {
+	srv := startWebhookServer(t, func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("bad event"))
+	})
+	s := newWebhookSink(t, sink.WebhookConfig{URL: srv.URL, BatchWait: time.Millisecond})
+
+	err := s.Write(context.Background(), newEvent("audit.users", "user.created"))
+	require.ErrorContains(t, err, "400 Bad Request: bad event")
+	_, ok := source.RetryDelay(err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, srv.count())
+}
+
+func TestWebhookSink_CloseFlushesPendingBatch(t *testing.T) {
+	srv := startWebhookServer(t)
+	s, err := sink.NewWebhookSink(sink.WebhookConfig{URL: srv.URL, BatchWait: time.Minute})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Write(context.Background(), newEvent("audit.users", "user.created")) }()
+	// Let the event join the batch.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, s.Close())
+
+	select {
+	case writeErr := <-done:
+		require.NoError(t, writeErr)
+	case <-time.After(2 * time.Second):
+		t.Fatal("write did not return after close")
+	}
+	assert.Equal(t, 1, srv.count())
+	require.ErrorContains(t, s.Write(context.Background(), newEvent("audit.users", "user.created")), "closed")
+}
+
+func TestNewWebhookSink_Invalid(t *testing.T) {
+	tests := map[string]sink.WebhookConfig{
+		"requires a url":           {},
+		"unknown webhook format":   {URL: "http://a", Format: "xml"},
+		"must not be negative":     {URL: "http://a", MaxRetries: -1},
+		"failed to read webhook":   {URL: "http://a", HMACKeyFile: "missing"},
+		"both TLS certificate and": {URL: "https://a", TLS: sink.TLSConfig{KeyFile: "key.pem"}},
+	}
+	for message, config := range tests {
+		_, err := sink.NewWebhookSink(config)
+		require.ErrorContains(t, err, message)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	_, ok := source.RetryDelay(errors.New("plain"))
+	assert.False(t, ok)
+
+	err := fmt.Errorf("failed: %w", errors.Join(
+		errors.New("plain"),
+		fmt.Errorf("sink a: %w", &sink.DelayError{Err: errors.New("down"), Delay: time.Second}),
+		fmt.Errorf("sink b: %w", &sink.DelayError{Err: errors.New("down"), Delay: time.Minute}),
+	))
+	delay, ok := source.RetryDelay(err)
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+	assert.Contains(t, err.Error(), "down, retry in 1m0s")
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoMetadata is returned by Msg.Metadata for messages of transports
+// without stream positions.
+var ErrNoMetadata = errors.New("message has no stream metadata")
+
+// Msg is a message delivered by a transport to the handler chain.
+type Msg struct {
+	Subject string
+	// Reply is the reply subject of JetStream messages, empty for other
+	// transports.
+	Reply  string
+	Header Header
+	Data   []byte
+	// Meta holds the stream position and delivery state of JetStream
+	// messages; it is nil for other transports.
+	Meta *Metadata
+}
+
+// Metadata returns the stream metadata of the message.
+func (m *Msg) Metadata() (*Metadata, error) {
+	if m.Meta == nil {
+		return nil, ErrNoMetadata
+	}
+	return m.Meta, nil
+}
+
+// Metadata is the stream position and delivery state of a message.
+type Metadata struct {
+	Stream       string
+	Consumer     string
+	Sequence     SequencePair
+	NumDelivered uint64
+	NumPending   uint64
+	Timestamp    time.Time
+}
+
+// SequencePair is the position of a message in its stream and consumer.
+type SequencePair struct {
+	Consumer uint64
+	Stream   uint64
+}
+
+// Header holds message headers. Keys are case sensitive, as in NATS.
+type Header map[string][]string
+
+// Get returns the first value of key, or an empty string.
+func (h Header) Get(key string) string {
+	if values := h[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns all values of key.
+func (h Header) Values(key string) []string {
+	return h[key]
+}
+
+// Set replaces the values of key with value.
+func (h Header) Set(key, value string) {
+	h[key] = []string{value}
+}
+
+// Add appends value to the values of key.
+func (h Header) Add(key, value string) {
+	h[key] = append(h[key], value)
+}
+
+// Del removes the values of key.
+func (h Header) Del(key string) {
+	delete(h, key)
+}
@@ -0,0 +1,51 @@
+// Package source defines the contract between audit transports and the
+// handler chain, so that transports do not depend on each other or on the
+// sinks behind the handlers.
+package source
+
+import (
+	"time"
+)
+
+// Handler handles a message delivered by a transport. Transports without
+// stream metadata carry their message attributes as headers. A message is
+// acknowledged when the handler returns nil and redelivered after an error
+// until its delivery attempts are exhausted.
+type Handler = func(msg *Msg) error
+
+// Delayer is implemented by handler errors asking for the message to be
+// redelivered after a delay rather than at once.
+type Delayer interface {
+	RetryDelay() time.Duration
+}
+
+// RetryDelay returns the longest redelivery delay requested by err or any
+// error joined or wrapped into it.
+func RetryDelay(err error) (time.Duration, bool) {
+	var delay time.Duration
+	found := false
+	walkErrors(err, func(e error) {
+		if delayer, ok := e.(Delayer); ok && delayer.RetryDelay() > delay { //nolint:errorlint // walkErrors unwraps
+			delay = delayer.RetryDelay()
+			found = true
+		}
+	})
+	return delay, found
+}
+
+// walkErrors calls fn for err and every error joined or wrapped into it,
+// so that a delay of any mandatory sink is seen.
+func walkErrors(err error, fn func(error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+	switch e := err.(type) { //nolint:errorlint // walks the tree itself
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			walkErrors(inner, fn)
+		}
+	case interface{ Unwrap() error }:
+		walkErrors(e.Unwrap(), fn)
+	}
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const checkpointFile = "checkpoints.jsonl"
+
+// Checkpoint is a signed statement of the archive chain head after a given
+// number of records.
+type Checkpoint struct {
+	Records   uint64    `json:"records"`
+	StreamSeq uint64    `json:"stream_seq"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `json:"key_id"`
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+// signedContent returns the bytes covered by the checkpoint signature.
+func (c *Checkpoint) signedContent() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = nil
+	unsigned.CreatedAt = unsigned.CreatedAt.UTC()
+
+	content, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	return content, nil
+}
+
+// KeyID returns a short fingerprint of an Ed25519 public key.
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoadSigningKey reads a PEM encoded PKCS#8 Ed25519 private key.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+// LoadVerifyKey reads an Ed25519 public key from a PEM file holding either
+// a PKIX public key or a PKCS#8 private key.
+func LoadVerifyKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type == "PRIVATE KEY" {
+		privateKey, loadErr := LoadSigningKey(path)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		publicKey, _ := privateKey.Public().(ed25519.PublicKey)
+		return publicKey, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", path)
+	}
+	return publicKey, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("key file %s does not contain PEM data", path)
+	}
+	return block, nil
+}
+
+// checkpointWriter signs and appends checkpoints to the checkpoint log.
+type checkpointWriter struct {
+	key  ed25519.PrivateKey
+	file *os.File
+	last uint64
+}
+
+func openCheckpointWriter(dir string, key ed25519.PrivateKey) (*checkpointWriter, error) {
+	checkpoints, err := readCheckpoints(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, checkpointFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint log: %w", err)
+	}
+
+	w := &checkpointWriter{key: key, file: file}
+	if len(checkpoints) > 0 {
+		w.last = checkpoints[len(checkpoints)-1].Records
+	}
+	return w, nil
+}
+
+// write signs a checkpoint for the given chain head and syncs it to disk.
+func (w *checkpointWriter) write(records, streamSeq uint64, head string) error {
+	publicKey, _ := w.key.Public().(ed25519.PublicKey)
+	checkpoint := Checkpoint{
+		Records:   records,
+		StreamSeq: streamSeq,
+		Hash:      head,
+		CreatedAt: time.Now().UTC(),
+		KeyID:     KeyID(publicKey),
+	}
+
+	content, err := checkpoint.signedContent()
+	if err != nil {
+		return err
+	}
+	checkpoint.Signature = ed25519.Sign(w.key, content)
+
+	line, err := json.Marshal(&checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, writeErr := w.file.Write(line); writeErr != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", writeErr)
+	}
+	if syncErr := w.file.Sync(); syncErr != nil {
+		return fmt.Errorf("failed to sync checkpoint log: %w", syncErr)
+	}
+
+	w.last = records
+	return nil
+}
+
+func (w *checkpointWriter) close() error {
+	return w.file.Close()
+}
+
+// readCheckpoints loads every complete checkpoint from the checkpoint log.
+func readCheckpoints(dir string) ([]Checkpoint, error) {
+	f, err := os.Open(filepath.Join(dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint log: %w", err)
+	}
+	defer f.Close()
+
+	var checkpoints []Checkpoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var checkpoint Checkpoint
+		if decodeErr := json.Unmarshal(scanner.Bytes(), &checkpoint); decodeErr != nil {
+			return checkpoints, fmt.Errorf("corrupt checkpoint %d: %w", len(checkpoints)+1, decodeErr)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return checkpoints, fmt.Errorf("failed to read checkpoint log: %w", scanErr)
+	}
+
+	return checkpoints, nil
+}
@@ -0,0 +1,42 @@
+package store
+
+import "os"
+
+// BreakSearchTerms reopens the search terms file of the active segment read
+// only, so the next append fails after the record and index entry were
+// written.
+func BreakSearchTerms(s *SegmentStore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terms, err := os.Open(s.active.termsPath())
+	if err != nil {
+		return err
+	}
+	_ = s.active.terms.Close()
+	s.active.terms = terms
+	return nil
+}
+
+// SetSearchScanLimit sets the number of terms entries a search reads
+// before it returns a page.
+func SetSearchScanLimit(s *SegmentStore, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanLimit = limit
+}
+
+// RepairSearchTerms reopens the search terms file of the active segment for
+// appending.
+func RepairSearchTerms(s *SegmentStore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terms, err := os.OpenFile(s.active.termsPath(), os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return err
+	}
+	_ = s.active.terms.Close()
+	s.active.terms = terms
+	return nil
+}
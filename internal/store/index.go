@@ -0,0 +1,310 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"events-audit/internal/subject"
+)
+
+// termsEntry is a line of a segment terms file: the searchable attributes
+// of a record.
+type termsEntry struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Terms   []string  `json:"terms,omitempty"`
+}
+
+func newTermsEntry(rec *Record) termsEntry {
+	return termsEntry{
+		Seq:     rec.StreamSeq,
+		Time:    rec.Timestamp,
+		Subject: rec.Subject,
+		Terms:   recordTerms(rec),
+	}
+}
+
+// matches reports whether the entry is selected by the query, given the
+// terms its filter requires.
+func (e *termsEntry) matches(query Query, required []string) bool {
+	if query.After > 0 {
+		if query.Order == OrderDesc && e.Seq >= query.After {
+			return false
+		}
+		if query.Order != OrderDesc && e.Seq <= query.After {
+			return false
+		}
+	}
+	if !query.From.IsZero() && e.Time.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !e.Time.Before(query.To) {
+		return false
+	}
+	if query.Subject != "" && !subject.Match(query.Subject, e.Subject) {
+		return false
+	}
+	for _, term := range required {
+		if _, found := slices.BinarySearch(e.Terms, term); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTermsEntry encodes a terms file line.
+func appendTermsEntry(dst []byte, entry termsEntry) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return dst, fmt.Errorf("failed to encode search terms: %w", err)
+	}
+	dst = append(dst, line...)
+	return append(dst, '\n'), nil
+}
+
+// readTermsFile calls fn with the entries of a segment terms file up to
+// limit bytes (or the whole file when limit is negative), numbered in
+// append order like the index entries. It returns the number of entries.
+func readTermsFile(path string, limit int64, fn func(ordinal int, entry *termsEntry) error) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read search terms: %w", err)
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if limit >= 0 {
+		src = io.LimitReader(f, limit)
+	}
+	reader := bufio.NewReader(src)
+
+	for ordinal := 0; ; ordinal++ {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			return ordinal, nil
+		}
+		if readErr != nil {
+			return ordinal, fmt.Errorf("failed to read search terms: %w", readErr)
+		}
+
+		var entry termsEntry
+		if decodeErr := json.Unmarshal(line, &entry); decodeErr != nil {
+			return ordinal, fmt.Errorf("failed to decode search terms: %w", decodeErr)
+		}
+		if fnErr := fn(ordinal, &entry); fnErr != nil {
+			return ordinal, fnErr
+		}
+	}
+}
+
+// segmentSummary describes the records of a segment well enough to skip
+// segments a search can not match: their sequence and time ranges, their
+// subjects, and the event types, sources and indexed data field values
+// they have. Its size depends on the distinct values in the segment, not
+// on the number of records; ids and other data fields are only found by
+// reading the terms file.
+type segmentSummary struct {
+	records  int
+	minSeq   uint64
+	maxSeq   uint64
+	minTime  time.Time
+	maxTime  time.Time
+	subjects map[string]struct{}
+	terms    map[string]struct{}
+}
+
+func newSegmentSummary() *segmentSummary {
+	return &segmentSummary{
+		subjects: make(map[string]struct{}),
+		terms:    make(map[string]struct{}),
+	}
+}
+
+// add summarizes a record; fields are the indexed data field paths.
+func (m *segmentSummary) add(entry *termsEntry, fields map[string]bool) {
+	if m.records == 0 || entry.Seq < m.minSeq {
+		m.minSeq = entry.Seq
+	}
+	if m.records == 0 || entry.Seq > m.maxSeq {
+		m.maxSeq = entry.Seq
+	}
+	if m.records == 0 || entry.Time.Before(m.minTime) {
+		m.minTime = entry.Time
+	}
+	if m.records == 0 || entry.Time.After(m.maxTime) {
+		m.maxTime = entry.Time
+	}
+	m.records++
+
+	m.subjects[entry.Subject] = struct{}{}
+	for _, term := range entry.Terms {
+		if summarized(term, fields) {
+			m.terms[term] = struct{}{}
+		}
+	}
+}
+
+// mayMatch reports whether the segment may hold records selected by the
+// query, given the terms its filter requires.
+func (m *segmentSummary) mayMatch(query Query, required []string, fields map[string]bool) bool {
+	if m.records == 0 {
+		return false
+	}
+	if query.After > 0 {
+		if query.Order == OrderDesc && m.minSeq >= query.After {
+			return false
+		}
+		if query.Order != OrderDesc && m.maxSeq <= query.After {
+			return false
+		}
+	}
+	if !query.From.IsZero() && m.maxTime.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !m.minTime.Before(query.To) {
+		return false
+	}
+	if query.Subject != "" && !m.hasSubject(query.Subject) {
+		return false
+	}
+	for _, term := range required {
+		if _, found := m.terms[term]; !found && summarized(term, fields) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *segmentSummary) hasSubject(pattern string) bool {
+	if !subject.HasWildcards(pattern) {
+		_, found := m.subjects[pattern]
+		return found
+	}
+	for subj := range m.subjects {
+		if subject.Match(pattern, subj) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarized reports whether a term is kept in segment summaries: event
+// types, sources and the terms of the indexed data fields.
+func summarized(term string, fields map[string]bool) bool {
+	if field, ok := strings.CutPrefix(term, "data."); ok {
+		path, _, _ := strings.Cut(field, "=")
+		return fields[path]
+	}
+	return strings.HasPrefix(term, "type=") || strings.HasPrefix(term, "source=")
+}
+
+// indexFields returns the set of data field paths kept in summaries.
+func indexFields(paths []string) map[string]bool {
+	fields := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		fields[strings.TrimPrefix(path, "data.")] = true
+	}
+	return fields
+}
+
+// summaryFile is a saved sealed segment summary. It records the indexed
+// fields it was computed for and the sizes of the files it describes, so a
+// stale summary is recomputed from the terms file.
+type summaryFile struct {
+	Fields     []string  `json:"fields"`
+	Records    int       `json:"records"`
+	TermsSize  int64     `json:"terms_size"`
+	LastOffset int64     `json:"last_offset"`
+	MinSeq     uint64    `json:"min_seq"`
+	MaxSeq     uint64    `json:"max_seq"`
+	MinTime    time.Time `json:"min_time"`
+	MaxTime    time.Time `json:"max_time"`
+	Subjects   []string  `json:"subjects"`
+	Terms      []string  `json:"terms"`
+}
+
+// writeSummary saves the summary of a sealed segment. Summaries are only
+// a shortcut for opening the archive, so failures are logged and the
+// summary is recomputed on the next start.
+func (s *SegmentStore) writeSummary(seg *segment) {
+	file := summaryFile{
+		Fields:     sortedKeys(s.fields),
+		Records:    seg.summary.records,
+		TermsSize:  seg.termsSize,
+		LastOffset: seg.lastOffset,
+		MinSeq:     seg.summary.minSeq,
+		MaxSeq:     seg.summary.maxSeq,
+		MinTime:    seg.summary.minTime,
+		MaxTime:    seg.summary.maxTime,
+		Subjects:   sortedKeys(seg.summary.subjects),
+		Terms:      sortedKeys(seg.summary.terms),
+	}
+
+	if err := saveSummary(seg.summaryPath(), file); err != nil {
+		s.logger.WithError(err).WithField("segment", seg.id).Warn("Failed to save segment summary")
+	}
+}
+
+// saveSummary atomically replaces a summary file.
+func saveSummary(path string, file summaryFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename summary: %w", err)
+	}
+	return nil
+}
+
+// readSummary loads the saved summary of a sealed segment with records
+// index entries. It reports false when the summary is missing or stale.
+func (s *SegmentStore) readSummary(seg *segment, records int) bool {
+	data, err := os.ReadFile(seg.summaryPath())
+	if err != nil {
+		return false
+	}
+	var file summaryFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return false
+	}
+	if file.Records != records || file.TermsSize != seg.termsSize ||
+		!slices.Equal(file.Fields, sortedKeys(s.fields)) {
+		return false
+	}
+
+	summary := newSegmentSummary()
+	summary.records = file.Records
+	summary.minSeq, summary.maxSeq = file.MinSeq, file.MaxSeq
+	summary.minTime, summary.maxTime = file.MinTime, file.MaxTime
+	for _, subj := range file.Subjects {
+		summary.subjects[subj] = struct{}{}
+	}
+	for _, term := range file.Terms {
+		summary.terms[term] = struct{}{}
+	}
+	seg.summary = summary
+	seg.lastOffset = file.LastOffset
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"events-audit/internal/cloudevent"
+	"events-audit/internal/source"
+)
+
+// Record is a single archived event together with its JetStream metadata.
+// Event holds the attributes of messages that are valid CloudEvents.
+type Record struct {
+	Stream      string                 `json:"stream"`
+	Consumer    string                 `json:"consumer"`
+	StreamSeq   uint64                 `json:"stream_seq"`
+	ConsumerSeq uint64                 `json:"consumer_seq"`
+	Delivered   uint64                 `json:"delivered"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Subject     string                 `json:"subject"`
+	Header      map[string][]string    `json:"header,omitempty"`
+	Data        []byte                 `json:"data"`
+	Event       *cloudevent.Attributes `json:"event,omitempty"`
+	StoredAt    time.Time              `json:"stored_at"`
+	PrevHash    string                 `json:"prev_hash"`
+	Hash        string                 `json:"hash,omitempty"`
+}
+
+// computeHash returns the hex encoded SHA-256 of the canonical record
+// encoding. The encoding covers every field except Hash itself, so it binds
+// the record to its predecessor through PrevHash.
+func (r *Record) computeHash() (string, error) {
+	canonical := *r
+	canonical.Hash = ""
+	canonical.Timestamp = canonical.Timestamp.UTC()
+	canonical.StoredAt = canonical.StoredAt.UTC()
+
+	content, err := json.Marshal(&canonical)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RecordFromMsg builds a record from a JetStream message, keeping the
+// attributes of messages that are valid CloudEvents.
+func RecordFromMsg(msg *source.Msg) (*Record, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message metadata: %w", err)
+	}
+
+	rec := &Record{
+		Stream:      meta.Stream,
+		Consumer:    meta.Consumer,
+		StreamSeq:   meta.Sequence.Stream,
+		ConsumerSeq: meta.Sequence.Consumer,
+		Delivered:   meta.NumDelivered,
+		Timestamp:   meta.Timestamp.UTC(),
+		Subject:     msg.Subject,
+		Data:        msg.Data,
+	}
+
+	if len(msg.Header) > 0 {
+		rec.Header = make(map[string][]string, len(msg.Header))
+		for k, v := range msg.Header {
+			rec.Header[k] = append([]string(nil), v...)
+		}
+	}
+
+	if event, eventErr := cloudevent.Parse(msg.Header, msg.Data); eventErr == nil {
+		rec.Event = &event.Attributes
+	}
+
+	return rec, nil
+}
+
+// Envelope holds the identifying attributes and JSON data of an archived
+// event, taken from the CloudEvent or from the legacy event structure.
+type Envelope struct {
+	ID     string
+	Type   string
+	Source string
+	// Data is the JSON event data; it is nil for raw messages and data
+	// that is not JSON.
+	Data json.RawMessage
+}
+
+// Envelope decodes the event attributes and data of the record. Raw
+// messages have an empty envelope.
+func (r *Record) Envelope() Envelope {
+	if event, err := cloudevent.Parse(r.Header, r.Data); err == nil {
+		envelope := Envelope{ID: event.ID, Type: event.Type, Source: event.Source}
+		if event.IsJSON() && json.Valid(event.Data) {
+			envelope.Data = event.Data
+		}
+		return envelope
+	}
+
+	var legacy struct {
+		ID     string          `json:"id"`
+		Type   string          `json:"type"`
+		Source string          `json:"source"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(r.Data, &legacy); err != nil {
+		return Envelope{}
+	}
+	return Envelope{ID: legacy.ID, Type: legacy.Type, Source: legacy.Source, Data: legacy.Data}
+}
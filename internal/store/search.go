@@ -0,0 +1,312 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/subject"
+)
+
+// Sort orders of search results.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const (
+	// maxIndexedValueBytes bounds the data values added to the search
+	// index; longer strings can not be searched for.
+	maxIndexedValueBytes = 256
+	// maxIndexedDepth bounds the nesting of indexed data fields.
+	maxIndexedDepth = 16
+)
+
+// Searcher finds archived records through the search index.
+type Searcher interface {
+	Search(query Query) (*Page, error)
+}
+
+// Filter selects records by subject and event attributes. Empty fields
+// match every record.
+type Filter struct {
+	// Subject is a NATS subject pattern: * matches one token and > one or
+	// more trailing tokens.
+	Subject string
+	Type    string
+	Source  string
+	ID      string
+	// Data maps dot separated paths into the event data to the value the
+	// field must equal. Values are compared as text, so 42 matches both the
+	// number and the string.
+	Data map[string]string
+}
+
+// Validate checks the subject pattern.
+func (f Filter) Validate() error {
+	if f.Subject == "" {
+		return nil
+	}
+	return subject.ValidatePattern(f.Subject)
+}
+
+// Matches reports whether the record is selected by the filter.
+func (f Filter) Matches(rec *Record) bool {
+	if f.Subject != "" && !subject.Match(f.Subject, rec.Subject) {
+		return false
+	}
+	required := f.terms()
+	if len(required) == 0 {
+		return true
+	}
+	terms := recordTerms(rec)
+	for _, term := range required {
+		if _, found := slices.BinarySearch(terms, term); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// terms returns the index terms a record must have to match the filter.
+func (f Filter) terms() []string {
+	var terms []string
+	for _, attr := range []struct{ name, value string }{
+		{"type", f.Type},
+		{"source", f.Source},
+		{"id", f.ID},
+	} {
+		if attr.value != "" {
+			terms = append(terms, attr.name+"="+attr.value)
+		}
+	}
+	for path, value := range f.Data {
+		terms = append(terms, "data."+path+"="+value)
+	}
+	return terms
+}
+
+// Query is a search of the archive.
+type Query struct {
+	Filter
+
+	// From and To bound the stream timestamp of the records; From is
+	// inclusive, To exclusive and zero values are unbounded.
+	From time.Time
+	To   time.Time
+	// Order is OrderAsc or OrderDesc by stream sequence, ascending when empty.
+	Order string
+	// After continues a previous search after the record with this
+	// sequence, see Page.Next.
+	After uint64
+	// Limit is the maximum number of records, constants.DefaultQueryLimit
+	// when zero.
+	Limit int
+}
+
+// Validate checks the query.
+func (q Query) Validate() error {
+	switch q.Order {
+	case "", OrderAsc, OrderDesc:
+	default:
+		return fmt.Errorf("unknown order %q, supported %s or %s", q.Order, OrderAsc, OrderDesc)
+	}
+	if q.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return errors.New("the start of the time range must be before its end")
+	}
+	return q.Filter.Validate()
+}
+
+// Page is a page of search results.
+type Page struct {
+	Records []*Record
+	// Next is the Query.After of the following page, zero on the last page.
+	Next uint64
+}
+
+// recordTerms returns the sorted index terms of a record: its event type,
+// source and id and every scalar field of its JSON data.
+func recordTerms(rec *Record) []string {
+	envelope := rec.Envelope()
+
+	var terms []string
+	for _, attr := range []struct{ name, value string }{
+		{"type", envelope.Type},
+		{"source", envelope.Source},
+		{"id", envelope.ID},
+	} {
+		if attr.value != "" {
+			terms = append(terms, attr.name+"="+attr.value)
+		}
+	}
+
+	if len(envelope.Data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(envelope.Data))
+		decoder.UseNumber()
+		var data any
+		if err := decoder.Decode(&data); err == nil {
+			terms = appendDataTerms(terms, "data", data, 0)
+		}
+	}
+
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+// appendDataTerms adds a term per scalar field of value. Array elements are
+// indexed under the path of the array.
+func appendDataTerms(terms []string, path string, value any, depth int) []string {
+	if depth > maxIndexedDepth {
+		return terms
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			terms = appendDataTerms(terms, path+"."+key, child, depth+1)
+		}
+	case []any:
+		for _, child := range v {
+			terms = appendDataTerms(terms, path, child, depth+1)
+		}
+	case string:
+		if len(v) <= maxIndexedValueBytes {
+			terms = append(terms, path+"="+v)
+		}
+	case json.Number:
+		terms = append(terms, path+"="+v.String())
+	case bool:
+		terms = append(terms, path+"="+strconv.FormatBool(v))
+	}
+	return terms
+}
+
+// searchCandidate is a segment that may hold records matching a search.
+type searchCandidate struct {
+	id        uint64
+	termsSize int64
+	minSeq    uint64
+	maxSeq    uint64
+}
+
+// searchMatch is a matching record found in a segment terms file.
+type searchMatch struct {
+	seq     uint64
+	segment uint64
+	ordinal int
+}
+
+// Search returns a page of the records matching the query. Segments are
+// skipped by their summaries and the terms files of the others are read
+// in the order of the query, so only the returned records are read from
+// the segments. A search reads at most constants.MaxQueryScan terms
+// entries: when it stops early, the page may hold fewer records than the
+// limit, or none, and Next continues after the part already searched.
+func (s *SegmentStore) Search(query Query) (*Page, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if query.Limit == 0 {
+		query.Limit = constants.DefaultQueryLimit
+	}
+	required := query.terms()
+	desc := query.Order == OrderDesc
+
+	s.mu.Lock()
+	var candidates []searchCandidate
+	for _, seg := range s.segments {
+		if seg.summary.mayMatch(query, required, s.fields) {
+			candidates = append(candidates, searchCandidate{
+				id:        seg.id,
+				termsSize: seg.termsSize,
+				minSeq:    seg.summary.minSeq,
+				maxSeq:    seg.summary.maxSeq,
+			})
+		}
+	}
+	scanLimit := s.scanLimit
+	s.mu.Unlock()
+
+	// Sequences of neighbouring segments may overlap, so a match is only
+	// settled once no later candidate can hold a sequence before it.
+	if desc {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].maxSeq > candidates[j].maxSeq })
+	} else {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].minSeq < candidates[j].minSeq })
+	}
+
+	page := &Page{}
+	var matches []searchMatch
+	scanned := 0
+	for i, candidate := range candidates {
+		seg := &segment{id: candidate.id, dir: s.config.Dir}
+		n, err := readTermsFile(seg.termsPath(), candidate.termsSize, func(ordinal int, entry *termsEntry) error {
+			if entry.matches(query, required) {
+				matches = append(matches, searchMatch{seq: entry.Seq, segment: candidate.id, ordinal: ordinal})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		scanned += n
+
+		sort.Slice(matches, func(a, b int) bool {
+			if desc {
+				return matches[a].seq > matches[b].seq
+			}
+			return matches[a].seq < matches[b].seq
+		})
+		if i == len(candidates)-1 {
+			break
+		}
+
+		next := candidates[i+1]
+		settled := len(matches)
+		var resume uint64
+		if desc {
+			settled = sort.Search(len(matches), func(j int) bool { return matches[j].seq <= next.maxSeq })
+			resume = next.maxSeq + 1
+		} else {
+			settled = sort.Search(len(matches), func(j int) bool { return matches[j].seq >= next.minSeq })
+			resume = next.minSeq - 1
+		}
+		if settled > query.Limit {
+			break
+		}
+		progress := query.After == 0 || (desc && resume < query.After) || (!desc && resume > query.After)
+		if scanned >= scanLimit && progress && resume > 0 {
+			matches = matches[:settled]
+			page.Next = resume
+			break
+		}
+	}
+
+	if len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+		page.Next = matches[len(matches)-1].seq
+	}
+
+	page.Records = make([]*Record, 0, len(matches))
+	for _, match := range matches {
+		offset, err := s.indexOffset(match.segment, match.ordinal)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := s.readAt(location{segment: match.segment, offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, rec)
+	}
+	return page, nil
+}
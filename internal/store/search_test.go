@@ -0,0 +1,246 @@
+package store_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var searchStart = time.Date(2025, 1, 11, 10, 0, 0, 0, time.UTC) //nolint:gochecknoglobals // shared test fixture
+
+// searchRecord returns a record at searchStart plus seq minutes. Odd
+// sequences are legacy events, even ones binary CloudEvents.
+func searchRecord(seq uint64, subject, eventType string, userID int) *store.Record {
+	rec := newRecord(seq)
+	rec.Timestamp = searchStart.Add(time.Duration(seq) * time.Minute)
+	rec.Subject = subject
+	data := fmt.Sprintf(`{"user":{"id":%d,"roles":["admin","dev"]},"ok":true}`, userID)
+	if seq%2 == 1 {
+		rec.Data = []byte(fmt.Sprintf(`{"id":"event-%d","type":%q,"source":"svc","data":%s}`, seq, eventType, data))
+		return rec
+	}
+	rec.Header = map[string][]string{
+		"ce-specversion": {"1.0"},
+		"ce-id":          {fmt.Sprintf("event-%d", seq)},
+		"ce-type":        {eventType},
+		"ce-source":      {"svc"},
+		"content-type":   {"application/json"},
+	}
+	rec.Data = []byte(data)
+	return rec
+}
+
+// searchConfig indexes the data fields filtered on by the search tests.
+func searchConfig(dir string) store.Config {
+	return store.Config{Dir: dir, MaxSegmentBytes: 1024, IndexFields: []string{"user.id", "user.roles", "ok"}}
+}
+
+func seqsOf(page *store.Page) []uint64 {
+	seqs := make([]uint64, 0, len(page.Records))
+	for _, rec := range page.Records {
+		seqs = append(seqs, rec.StreamSeq)
+	}
+	return seqs
+}
+
+func fillSearchStore(t *testing.T, dir string) *store.SegmentStore {
+	t.Helper()
+
+	st := openTestStore(t, searchConfig(dir))
+	records := []*store.Record{
+		searchRecord(1, "audit.users.created", "user.created", 1),
+		searchRecord(2, "audit.users.deleted", "user.deleted", 1),
+		searchRecord(3, "audit.orders.created", "order.created", 2),
+		searchRecord(4, "audit.users.created", "user.created", 2),
+		searchRecord(5, "billing.created", "invoice.created", 1),
+		searchRecord(6, "audit.users.created", "user.created", 3),
+	}
+	for _, rec := range records {
+		require.NoError(t, st.Append(rec))
+	}
+	return st
+}
+
+func TestSegmentStore_Search(t *testing.T) {
+	st := fillSearchStore(t, t.TempDir())
+	defer st.Close()
+
+	tests := map[string]struct {
+		query store.Query
+		want  []uint64
+	}{
+		"everything":       {store.Query{}, []uint64{1, 2, 3, 4, 5, 6}},
+		"descending":       {store.Query{Order: store.OrderDesc}, []uint64{6, 5, 4, 3, 2, 1}},
+		"subject":          {store.Query{Filter: store.Filter{Subject: "audit.users.created"}}, []uint64{1, 4, 6}},
+		"subject wildcard": {store.Query{Filter: store.Filter{Subject: "audit.*.created"}}, []uint64{1, 3, 4, 6}},
+		"subject tail":     {store.Query{Filter: store.Filter{Subject: "audit.>"}}, []uint64{1, 2, 3, 4, 6}},
+		"type":             {store.Query{Filter: store.Filter{Type: "user.created"}}, []uint64{1, 4, 6}},
+		"id":               {store.Query{Filter: store.Filter{ID: "event-5"}}, []uint64{5}},
+		"source":           {store.Query{Filter: store.Filter{Source: "svc", Type: "order.created"}}, []uint64{3}},
+		"data": {store.Query{Filter: store.Filter{
+			Data: map[string]string{"user.id": "1"},
+		}}, []uint64{1, 2, 5}},
+		"data array and bool": {store.Query{Filter: store.Filter{
+			Subject: "audit.users.*",
+			Data:    map[string]string{"user.roles": "dev", "ok": "true"},
+		}}, []uint64{1, 2, 4, 6}},
+		"time range": {store.Query{
+			From: searchStart.Add(2 * time.Minute),
+			To:   searchStart.Add(5 * time.Minute),
+		}, []uint64{2, 3, 4}},
+		"time range and type": {store.Query{
+			Filter: store.Filter{Type: "user.created"},
+			From:   searchStart.Add(2 * time.Minute),
+		}, []uint64{4, 6}},
+		"unknown value": {store.Query{Filter: store.Filter{Type: "user.updated"}}, []uint64{}},
+		"empty range":   {store.Query{From: searchStart.Add(time.Hour)}, []uint64{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := st.Search(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, seqsOf(page))
+			assert.Zero(t, page.Next)
+		})
+	}
+}
+
+func TestSegmentStore_SearchPages(t *testing.T) {
+	st := fillSearchStore(t, t.TempDir())
+	defer st.Close()
+
+	for _, order := range []string{store.OrderAsc, store.OrderDesc} {
+		query := store.Query{Filter: store.Filter{Subject: "audit.>"}, Order: order, Limit: 2}
+		var pages [][]uint64
+		for {
+			page, err := st.Search(query)
+			require.NoError(t, err)
+			pages = append(pages, seqsOf(page))
+			if page.Next == 0 {
+				break
+			}
+			query.After = page.Next
+		}
+		if order == store.OrderAsc {
+			assert.Equal(t, [][]uint64{{1, 2}, {3, 4}, {6}}, pages)
+		} else {
+			assert.Equal(t, [][]uint64{{6, 4}, {3, 2}, {1}}, pages)
+		}
+	}
+}
+
+func TestSegmentStore_SearchIndexSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fillSearchStore(t, dir).Close())
+
+	terms, err := filepath.Glob(filepath.Join(dir, "*.terms"))
+	require.NoError(t, err)
+	require.Greater(t, len(terms), 2, "segments are rotated")
+	// A sealed segment without terms is reindexed from its records.
+	require.NoError(t, os.Remove(terms[0]))
+
+	st := openTestStore(t, searchConfig(dir))
+	defer st.Close()
+	require.FileExists(t, terms[0])
+
+	page, err := st.Search(store.Query{Filter: store.Filter{Type: "user.created"}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 4, 6}, seqsOf(page))
+
+	require.NoError(t, st.Append(searchRecord(7, "audit.users.created", "user.created", 4)))
+	page, err = st.Search(store.Query{Filter: store.Filter{Data: map[string]string{"user.id": "4"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7}, seqsOf(page))
+}
+
+func TestSegmentStore_SearchIndexFields(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fillSearchStore(t, dir).Close())
+
+	// Fields left out of the summaries are found by reading the terms files.
+	for _, fields := range [][]string{nil, {"data.user.id"}} {
+		st := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 1024, IndexFields: fields})
+		page, err := st.Search(store.Query{Filter: store.Filter{Data: map[string]string{"user.id": "2"}}})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{3, 4}, seqsOf(page), fields)
+		require.NoError(t, st.Close())
+	}
+}
+
+// searchAll follows the pages of a query to its last page.
+func searchAll(t *testing.T, st *store.SegmentStore, query store.Query) []uint64 {
+	t.Helper()
+
+	seqs := []uint64{}
+	for {
+		page, err := st.Search(query)
+		require.NoError(t, err)
+		seqs = append(seqs, seqsOf(page)...)
+		if page.Next == 0 {
+			return seqs
+		}
+		query.After = page.Next
+	}
+}
+
+func TestSegmentStore_SearchScanLimit(t *testing.T) {
+	st := fillSearchStore(t, t.TempDir())
+	defer st.Close()
+	store.SetSearchScanLimit(st, 1)
+
+	query := store.Query{Filter: store.Filter{Data: map[string]string{"user.id": "1"}}}
+	page, err := st.Search(query)
+	require.NoError(t, err)
+	assert.NotZero(t, page.Next, "the search stops after the first segment")
+
+	assert.Equal(t, []uint64{1, 2, 5}, searchAll(t, st, query))
+	query.Order = store.OrderDesc
+	assert.Equal(t, []uint64{5, 2, 1}, searchAll(t, st, query))
+}
+
+func TestSegmentStore_SearchOverlappingSegments(t *testing.T) {
+	st := openTestStore(t, store.Config{Dir: t.TempDir(), MaxSegmentBytes: 1500})
+	defer st.Close()
+
+	// Concurrent workers archive records out of sequence order, so the
+	// sequence ranges of neighbouring segments overlap.
+	for _, seq := range []uint64{1, 4, 2, 6, 3, 5, 8, 7} {
+		require.NoError(t, st.Append(searchRecord(seq, "audit.users.created", "user.created", 1)))
+	}
+
+	for _, limit := range []int{1, 3, 10} {
+		query := store.Query{Filter: store.Filter{Type: "user.created"}, Limit: limit}
+		assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6, 7, 8}, searchAll(t, st, query), limit)
+		query.Order = store.OrderDesc
+		assert.Equal(t, []uint64{8, 7, 6, 5, 4, 3, 2, 1}, searchAll(t, st, query), limit)
+	}
+}
+
+func TestQuery_Validate(t *testing.T) {
+	tests := map[string]store.Query{
+		"unknown order":        {Order: "random"},
+		"must not be negative": {Limit: -1},
+		"must be before":       {From: searchStart, To: searchStart},
+		"invalid subject":      {Filter: store.Filter{Subject: "audit.>.users"}},
+	}
+	for message, query := range tests {
+		require.ErrorContains(t, query.Validate(), message)
+	}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	rec := searchRecord(2, "audit.users.deleted", "user.deleted", 7)
+
+	assert.True(t, store.Filter{}.Matches(rec))
+	assert.True(t, store.Filter{Subject: "audit.users.*", Type: "user.deleted"}.Matches(rec))
+	assert.True(t, store.Filter{Data: map[string]string{"user.id": "7"}}.Matches(rec))
+	assert.False(t, store.Filter{Subject: "audit.orders.*"}.Matches(rec))
+	assert.False(t, store.Filter{Data: map[string]string{"user.id": "1"}}.Matches(rec))
+}
@@ -0,0 +1,858 @@
+package store
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"events-audit/internal/constants"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	segmentExt     = ".seg"
+	indexExt       = ".idx"
+	termsExt       = ".terms"
+	summaryExt     = ".sum"
+	indexEntrySize = 16
+	dirPerm        = 0o750
+	filePerm       = 0o640
+)
+
+// location points at a record inside a segment file.
+type location struct {
+	segment uint64
+	offset  int64
+}
+
+// segment is a single append-only data file with its sequence index and
+// search terms. Only the active segment keeps the offsets of its records
+// by sequence in memory.
+type segment struct {
+	id         uint64
+	dir        string
+	size       int64
+	lastOffset int64
+	termsSize  int64
+	createdAt  time.Time
+	summary    *segmentSummary
+	seqs       map[uint64]int64
+	data       *os.File
+	index      *os.File
+	terms      *os.File
+}
+
+func (s *segment) dataPath() string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.id, segmentExt))
+}
+
+func (s *segment) indexPath() string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.id, indexExt))
+}
+
+func (s *segment) termsPath() string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.id, termsExt))
+}
+
+func (s *segment) summaryPath() string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.id, summaryExt))
+}
+
+// SegmentStore is a Store backed by size and time rotated append-only
+// segment files. Every segment has a companion index file mapping stream
+// sequences to record offsets and a terms file with the searchable
+// attributes of its records, summarized in memory to narrow searches.
+// The summaries of sealed segments are saved next to them, so opening the
+// archive reads neither their records nor their terms.
+// Records are hash chained in append order and
+// the chain head is periodically checkpointed with an Ed25519 signature.
+type SegmentStore struct {
+	mu          sync.Mutex
+	config      Config
+	logger      *logrus.Logger
+	segments    []*segment
+	active      *segment
+	records     uint64
+	fields      map[string]bool
+	scanLimit   int
+	head        string
+	lastSeq     uint64
+	checkpoints *checkpointWriter
+}
+
+// Open opens or creates a segment store in config.Dir.
+func Open(config Config, logger *logrus.Logger) (*SegmentStore, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if config.Dir == "" {
+		return nil, errors.New("store directory is required")
+	}
+	if config.MaxSegmentBytes <= 0 {
+		config.MaxSegmentBytes = constants.DefaultStoreSegmentMaxBytes
+	}
+
+	if err := os.MkdirAll(config.Dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	s := &SegmentStore{
+		config:    config,
+		logger:    logger,
+		fields:    indexFields(config.IndexFields),
+		scanLimit: constants.MaxQueryScan,
+	}
+
+	ids, err := segmentIDs(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		seg := &segment{id: id, dir: config.Dir, lastOffset: -1, summary: newSegmentSummary()}
+		if i == len(ids)-1 {
+			if recoverErr := s.recoverActive(seg); recoverErr != nil {
+				return nil, recoverErr
+			}
+		} else if loadErr := s.loadIndex(seg); loadErr != nil {
+			return nil, loadErr
+		}
+		s.segments = append(s.segments, seg)
+		s.records += uint64(seg.summary.records) //nolint:gosec // record counts are never negative
+	}
+
+	if len(s.segments) == 0 {
+		if createErr := s.createSegment(1); createErr != nil {
+			return nil, createErr
+		}
+	} else {
+		s.active = s.segments[len(s.segments)-1]
+		if openErr := s.openActive(); openErr != nil {
+			return nil, openErr
+		}
+	}
+
+	if headErr := s.loadHead(); headErr != nil {
+		_ = s.active.close()
+		return nil, headErr
+	}
+
+	if config.SigningKeyFile != "" {
+		if signErr := s.openCheckpoints(); signErr != nil {
+			_ = s.active.close()
+			return nil, signErr
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"dir":      config.Dir,
+		"segments": len(s.segments),
+		"records":  s.records,
+		"head":     s.head,
+	}).Info("Audit store opened")
+
+	return s, nil
+}
+
+// loadHead restores the chain head from the last stored record.
+func (s *SegmentStore) loadHead() error {
+	for i := len(s.segments) - 1; i >= 0; i-- {
+		seg := s.segments[i]
+		if seg.lastOffset < 0 {
+			continue
+		}
+
+		rec, err := s.readAt(location{segment: seg.id, offset: seg.lastOffset})
+		if err != nil {
+			return err
+		}
+		s.head = rec.Hash
+		s.lastSeq = rec.StreamSeq
+		return nil
+	}
+
+	return nil
+}
+
+// openCheckpoints loads the signing key and opens the checkpoint log.
+func (s *SegmentStore) openCheckpoints() error {
+	key, err := LoadSigningKey(s.config.SigningKeyFile)
+	if err != nil {
+		return err
+	}
+
+	s.checkpoints, err = openCheckpointWriter(s.config.Dir, key)
+	if err != nil {
+		return err
+	}
+
+	publicKey, _ := key.Public().(ed25519.PublicKey)
+	s.logger.WithFields(logrus.Fields{
+		"key_id":   KeyID(publicKey),
+		"interval": s.config.CheckpointInterval,
+	}).Info("Audit store checkpoint signing enabled")
+
+	return nil
+}
+
+// segmentIDs returns the ids of all segment files in dir in ascending order.
+func segmentIDs(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, parseErr := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// loadIndex loads the summary of a sealed segment, recomputing it from the
+// terms file when it is missing or stale and rebuilding the index and terms
+// files if either is missing or damaged.
+func (s *SegmentStore) loadIndex(seg *segment) error {
+	info, err := os.Stat(seg.dataPath())
+	if err != nil {
+		return fmt.Errorf("failed to stat segment %d: %w", seg.id, err)
+	}
+	seg.size = info.Size()
+
+	index, err := os.Stat(seg.indexPath())
+	if err != nil || index.Size()%indexEntrySize != 0 {
+		s.logger.WithField("segment", seg.id).Warn("Segment index missing or damaged, rebuilding")
+		return s.rebuildSealed(seg)
+	}
+	records := int(index.Size() / indexEntrySize)
+
+	terms, err := os.Stat(seg.termsPath())
+	if err != nil {
+		s.logger.WithField("segment", seg.id).Warn("Segment search terms missing or damaged, rebuilding")
+		return s.rebuildSealed(seg)
+	}
+	seg.termsSize = terms.Size()
+	if s.readSummary(seg, records) {
+		return nil
+	}
+
+	summary := newSegmentSummary()
+	entries, err := readTermsFile(seg.termsPath(), -1, func(_ int, entry *termsEntry) error {
+		summary.add(entry, s.fields)
+		return nil
+	})
+	if err != nil || entries != records {
+		s.logger.WithField("segment", seg.id).Warn("Segment search terms missing or damaged, rebuilding")
+		return s.rebuildSealed(seg)
+	}
+	seg.summary = summary
+	if records > 0 {
+		// Records are appended in offset order, so the last entry is the
+		// last record.
+		if seg.lastOffset, err = s.indexOffset(seg.id, records-1); err != nil {
+			return err
+		}
+	}
+
+	s.writeSummary(seg)
+	return nil
+}
+
+// rebuildSealed rebuilds the index and terms files of a sealed segment and
+// saves its summary.
+func (s *SegmentStore) rebuildSealed(seg *segment) error {
+	if err := s.rebuildIndex(seg); err != nil {
+		return err
+	}
+	seg.seqs = nil
+	s.writeSummary(seg)
+	return nil
+}
+
+// recoverActive rebuilds the index of the last segment and truncates any
+// partially written trailing record left behind by a crash.
+func (s *SegmentStore) recoverActive(seg *segment) error {
+	if err := s.rebuildIndex(seg); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(seg.dataPath())
+	if err != nil {
+		return fmt.Errorf("failed to stat segment %d: %w", seg.id, err)
+	}
+
+	if info.Size() != seg.size {
+		s.logger.WithFields(logrus.Fields{
+			"segment":   seg.id,
+			"size":      info.Size(),
+			"truncated": info.Size() - seg.size,
+		}).Warn("Truncating incomplete record at end of segment")
+		if truncErr := os.Truncate(seg.dataPath(), seg.size); truncErr != nil {
+			return fmt.Errorf("failed to truncate segment %d: %w", seg.id, truncErr)
+		}
+	}
+
+	return nil
+}
+
+// rebuildIndex scans a segment and rewrites its index and terms files.
+func (s *SegmentStore) rebuildIndex(seg *segment) error {
+	var entries, terms []byte
+	seg.summary = newSegmentSummary()
+	seg.seqs = make(map[uint64]int64)
+	end, err := readSegment(seg.dataPath(), -1, func(offset int64, rec *Record) error {
+		if seg.createdAt.IsZero() {
+			seg.createdAt = rec.StoredAt
+		}
+		seg.seqs[rec.StreamSeq] = offset
+		seg.lastOffset = offset
+		entries = appendIndexEntry(entries, rec.StreamSeq, offset)
+
+		entry := newTermsEntry(rec)
+		seg.summary.add(&entry, s.fields)
+		var encodeErr error
+		terms, encodeErr = appendTermsEntry(terms, entry)
+		return encodeErr
+	})
+	if err != nil {
+		return err
+	}
+	seg.size = end
+	seg.termsSize = int64(len(terms))
+
+	if writeErr := os.WriteFile(seg.indexPath(), entries, filePerm); writeErr != nil {
+		return fmt.Errorf("failed to write index for segment %d: %w", seg.id, writeErr)
+	}
+	if writeErr := os.WriteFile(seg.termsPath(), terms, filePerm); writeErr != nil {
+		return fmt.Errorf("failed to write search terms for segment %d: %w", seg.id, writeErr)
+	}
+
+	return nil
+}
+
+// openActive opens the active segment files for appending.
+func (s *SegmentStore) openActive() error {
+	seg := s.active
+
+	data, err := os.OpenFile(seg.dataPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open segment %d: %w", seg.id, err)
+	}
+
+	index, err := os.OpenFile(seg.indexPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		_ = data.Close()
+		return fmt.Errorf("failed to open index for segment %d: %w", seg.id, err)
+	}
+
+	terms, err := os.OpenFile(seg.termsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		_ = data.Close()
+		_ = index.Close()
+		return fmt.Errorf("failed to open search terms for segment %d: %w", seg.id, err)
+	}
+
+	seg.data = data
+	seg.index = index
+	seg.terms = terms
+	if seg.createdAt.IsZero() {
+		seg.createdAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// createSegment creates a new empty segment and makes it active.
+func (s *SegmentStore) createSegment(id uint64) error {
+	s.active = &segment{
+		id:         id,
+		dir:        s.config.Dir,
+		lastOffset: -1,
+		summary:    newSegmentSummary(),
+		seqs:       make(map[uint64]int64),
+	}
+	if err := s.openActive(); err != nil {
+		return err
+	}
+	s.segments = append(s.segments, s.active)
+
+	return syncDir(s.config.Dir)
+}
+
+// Append links the record to the chain head and durably writes it to the
+// active segment. Records whose stream sequence is already stored are
+// skipped, so redelivered messages are archived exactly once.
+func (s *SegmentStore) Append(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return errors.New("store is closed")
+	}
+
+	_, exists, err := s.locate(rec.StreamSeq)
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.logger.WithField("sequence", rec.StreamSeq).Debug("Record already archived, skipping")
+		return nil
+	}
+
+	if rec.StoredAt.IsZero() {
+		rec.StoredAt = time.Now().UTC()
+	}
+
+	rec.PrevHash = s.head
+	hash, err := rec.computeHash()
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	line = append(line, '\n')
+
+	entry := newTermsEntry(rec)
+	terms, err := appendTermsEntry(nil, entry)
+	if err != nil {
+		return err
+	}
+
+	if s.needsRotation(int64(len(line))) {
+		if rotateErr := s.rotate(); rotateErr != nil {
+			return rotateErr
+		}
+	}
+
+	seg := s.active
+	offset := seg.size
+
+	if writeErr := seg.append(line, appendIndexEntry(nil, rec.StreamSeq, offset), terms); writeErr != nil {
+		return writeErr
+	}
+
+	seg.size += int64(len(line))
+	seg.termsSize += int64(len(terms))
+	seg.lastOffset = offset
+	seg.summary.add(&entry, s.fields)
+	seg.seqs[rec.StreamSeq] = offset
+	s.records++
+	s.head = rec.Hash
+	s.lastSeq = rec.StreamSeq
+
+	return s.maybeCheckpoint(false)
+}
+
+// append durably writes a record line with its index entry and search terms
+// to the segment files. On failure the files are truncated back to their
+// previous sizes, so partially written bytes do not shift the offsets of
+// later records.
+func (seg *segment) append(line, entry, terms []byte) error {
+	indexSize, err := fileSize(seg.index)
+	if err != nil {
+		return fmt.Errorf("failed to stat index: %w", err)
+	}
+	termsSize, err := fileSize(seg.terms)
+	if err != nil {
+		return fmt.Errorf("failed to stat search terms: %w", err)
+	}
+
+	err = seg.write(line, entry, terms)
+	if err == nil {
+		return nil
+	}
+	for _, f := range []struct {
+		file *os.File
+		size int64
+	}{{seg.data, seg.size}, {seg.index, indexSize}, {seg.terms, termsSize}} {
+		if truncErr := f.file.Truncate(f.size); truncErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to truncate %s: %w", f.file.Name(), truncErr))
+		}
+	}
+	return err
+}
+
+func (seg *segment) write(line, entry, terms []byte) error {
+	if _, err := seg.data.Write(line); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	if _, err := seg.index.Write(entry); err != nil {
+		return fmt.Errorf("failed to write index entry: %w", err)
+	}
+	if _, err := seg.terms.Write(terms); err != nil {
+		return fmt.Errorf("failed to write search terms: %w", err)
+	}
+	if err := seg.data.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	if err := seg.index.Sync(); err != nil {
+		return fmt.Errorf("failed to sync index: %w", err)
+	}
+	if err := seg.terms.Sync(); err != nil {
+		return fmt.Errorf("failed to sync search terms: %w", err)
+	}
+	return nil
+}
+
+func fileSize(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err //nolint:wrapcheck // wrapped by the caller
+	}
+	return info.Size(), nil
+}
+
+// maybeCheckpoint signs the chain head once CheckpointInterval records were
+// appended since the last checkpoint, or unconditionally when force is set
+// and there is anything new to sign.
+func (s *SegmentStore) maybeCheckpoint(force bool) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+
+	records := s.records
+	pending := records - s.checkpoints.last
+	if pending == 0 {
+		return nil
+	}
+	if !force && (s.config.CheckpointInterval <= 0 || pending < uint64(s.config.CheckpointInterval)) {
+		return nil
+	}
+
+	if err := s.checkpoints.write(records, s.lastSeq, s.head); err != nil {
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"records":  records,
+		"sequence": s.lastSeq,
+		"hash":     s.head,
+	}).Debug("Wrote signed audit store checkpoint")
+
+	return nil
+}
+
+// needsRotation reports whether the active segment must be sealed before
+// writing n more bytes.
+func (s *SegmentStore) needsRotation(n int64) bool {
+	seg := s.active
+	if seg.size == 0 {
+		return false
+	}
+	if seg.size+n > s.config.MaxSegmentBytes {
+		return true
+	}
+	return s.config.MaxSegmentAge > 0 && time.Since(seg.createdAt) >= s.config.MaxSegmentAge
+}
+
+// rotate seals the active segment and starts a new one.
+func (s *SegmentStore) rotate() error {
+	sealed := s.active
+	if err := sealed.close(); err != nil {
+		return err
+	}
+
+	sealed.seqs = nil
+	s.writeSummary(sealed)
+
+	if err := s.createSegment(sealed.id + 1); err != nil {
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"sealed":  sealed.id,
+		"size":    sealed.size,
+		"segment": s.active.id,
+	}).Info("Rotated audit store segment")
+
+	return nil
+}
+
+// Get returns the record stored for the given stream sequence.
+func (s *SegmentStore) Get(seq uint64) (*Record, error) {
+	s.mu.Lock()
+	loc, ok, err := s.locate(seq)
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return s.readAt(loc)
+}
+
+// locate finds the record stored for a stream sequence. The active segment
+// is looked up in memory, sealed segments whose sequence range covers seq
+// by reading their index files. Redeliveries come from the recent tail of
+// the stream, so appends rarely read an index file.
+func (s *SegmentStore) locate(seq uint64) (location, bool, error) {
+	for i := len(s.segments) - 1; i >= 0; i-- {
+		seg := s.segments[i]
+		if seg.seqs != nil {
+			if offset, ok := seg.seqs[seq]; ok {
+				return location{segment: seg.id, offset: offset}, true, nil
+			}
+			continue
+		}
+		if seg.summary.records == 0 || seq < seg.summary.minSeq || seq > seg.summary.maxSeq {
+			continue
+		}
+
+		offset, found, err := findIndexEntry(seg.indexPath(), seq)
+		if err != nil {
+			return location{}, false, fmt.Errorf("failed to read index for segment %d: %w", seg.id, err)
+		}
+		if found {
+			return location{segment: seg.id, offset: offset}, true, nil
+		}
+	}
+	return location{}, false, nil
+}
+
+// findIndexEntry scans an index file for the offset of a stream sequence.
+func findIndexEntry(path string, seq uint64) (int64, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err //nolint:wrapcheck // wrapped by the caller
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var entry [indexEntrySize]byte
+	for {
+		if _, readErr := io.ReadFull(reader, entry[:]); readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return 0, false, nil
+			}
+			return 0, false, readErr //nolint:wrapcheck // wrapped by the caller
+		}
+		if binary.BigEndian.Uint64(entry[:8]) == seq {
+			return int64(binary.BigEndian.Uint64(entry[8:])), true, nil //nolint:gosec // offsets are written by us and fit int64
+		}
+	}
+}
+
+// indexOffset reads the offset of the record with the given append ordinal
+// from a segment index file.
+func (s *SegmentStore) indexOffset(id uint64, ordinal int) (int64, error) {
+	seg := &segment{id: id, dir: s.config.Dir}
+	f, err := os.Open(seg.indexPath())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open index for segment %d: %w", id, err)
+	}
+	defer f.Close()
+
+	var entry [indexEntrySize]byte
+	if _, readErr := f.ReadAt(entry[:], int64(ordinal)*indexEntrySize); readErr != nil {
+		return 0, fmt.Errorf("failed to read index entry %d of segment %d: %w", ordinal, id, readErr)
+	}
+	return int64(binary.BigEndian.Uint64(entry[8:])), nil //nolint:gosec // offsets are written by us and fit int64
+}
+
+// readAt decodes the record at the given location.
+func (s *SegmentStore) readAt(loc location) (*Record, error) {
+	seg := &segment{id: loc.segment, dir: s.config.Dir}
+	f, err := os.Open(seg.dataPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %d: %w", loc.segment, err)
+	}
+	defer f.Close()
+
+	if _, seekErr := f.Seek(loc.offset, io.SeekStart); seekErr != nil {
+		return nil, fmt.Errorf("failed to seek segment %d: %w", loc.segment, seekErr)
+	}
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read record at %d:%d: %w", loc.segment, loc.offset, err)
+	}
+
+	var rec Record
+	if decodeErr := json.Unmarshal(line, &rec); decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode record at %d:%d: %w", loc.segment, loc.offset, decodeErr)
+	}
+
+	return &rec, nil
+}
+
+// Scan calls fn for every stored record in append order. Records appended
+// while the scan is running are not visited.
+func (s *SegmentStore) Scan(fn func(rec *Record) error) error {
+	type snapshot struct {
+		path string
+		size int64
+	}
+
+	s.mu.Lock()
+	snapshots := make([]snapshot, 0, len(s.segments))
+	for _, seg := range s.segments {
+		snapshots = append(snapshots, snapshot{path: seg.dataPath(), size: seg.size})
+	}
+	s.mu.Unlock()
+
+	for _, snap := range snapshots {
+		_, err := readSegment(snap.path, snap.size, func(_ int64, rec *Record) error {
+			return fn(rec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close signs a final checkpoint, then syncs and closes the active segment.
+func (s *SegmentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+
+	var errs []error
+	if s.checkpoints != nil {
+		errs = append(errs, s.maybeCheckpoint(true), s.checkpoints.close())
+		s.checkpoints = nil
+	}
+
+	errs = append(errs, s.active.close())
+	s.active = nil
+	err := errors.Join(errs...)
+
+	s.logger.WithField("dir", s.config.Dir).Info("Audit store closed")
+	return err
+}
+
+// close syncs and closes the segment files.
+func (s *segment) close() error {
+	var errs []error
+	for _, f := range []*os.File{s.data, s.index, s.terms} {
+		if f == nil {
+			continue
+		}
+		if err := f.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.data = nil
+	s.index = nil
+	s.terms = nil
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to close segment %d: %w", s.id, err)
+	}
+	return nil
+}
+
+// ReadDir calls fn with every record of the archive in dir, in append
+// order. It only reads the segment files, so the archive may be open for
+// appending by a running server at the same time.
+func ReadDir(dir string, fn func(rec *Record) error) error {
+	ids, err := segmentIDs(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		seg := &segment{id: id, dir: dir}
+		if _, err = readSegment(seg.dataPath(), -1, func(_ int64, rec *Record) error {
+			return fn(rec)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSegment decodes records from a segment file up to limit bytes (or the
+// whole file when limit is negative). It returns the offset just past the
+// last complete record.
+func readSegment(path string, limit int64, fn func(offset int64, rec *Record) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if limit >= 0 {
+		src = io.LimitReader(f, limit)
+	}
+	reader := bufio.NewReader(src)
+
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			// A trailing line without newline is an incomplete write.
+			return offset, nil
+		}
+		if readErr != nil {
+			return offset, fmt.Errorf("failed to read segment %s: %w", path, readErr)
+		}
+
+		var rec Record
+		if decodeErr := json.Unmarshal(line, &rec); decodeErr != nil {
+			return offset, fmt.Errorf("corrupt record in %s at offset %d: %w", path, offset, decodeErr)
+		}
+
+		if fnErr := fn(offset, &rec); fnErr != nil {
+			return offset, fnErr
+		}
+		offset += int64(len(line))
+	}
+}
+
+// appendIndexEntry encodes a sequence to offset mapping.
+func appendIndexEntry(dst []byte, seq uint64, offset int64) []byte {
+	dst = binary.BigEndian.AppendUint64(dst, seq)
+	return binary.BigEndian.AppendUint64(dst, uint64(offset)) //nolint:gosec // offsets are never negative
+}
+
+// syncDir fsyncs a directory so newly created files survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open store directory: %w", err)
+	}
+	defer d.Close()
+
+	if syncErr := d.Sync(); syncErr != nil {
+		return fmt.Errorf("failed to sync store directory: %w", syncErr)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package store_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"events-audit/internal/source"
+	"events-audit/internal/store"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T, config store.Config) *store.SegmentStore {
+	t.Helper()
+	logger, _ := test.NewNullLogger()
+
+	st, err := store.Open(config, logger)
+	require.NoError(t, err)
+	return st
+}
+
+func newRecord(seq uint64) *store.Record {
+	return &store.Record{
+		Stream:    "EVENTS",
+		Consumer:  "events-audit-durable",
+		StreamSeq: seq,
+		Delivered: 1,
+		Timestamp: time.Date(2025, 1, 11, 10, 30, 0, 0, time.UTC),
+		Subject:   "events.test",
+		Header:    map[string][]string{"Nats-Msg-Id": {fmt.Sprintf("msg-%d", seq)}},
+		Data:      []byte(fmt.Sprintf(`{"id":"event-%d"}`, seq)),
+	}
+}
+
+func collect(t *testing.T, st store.Store) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	require.NoError(t, st.Scan(func(rec *store.Record) error {
+		seqs = append(seqs, rec.StreamSeq)
+		return nil
+	}))
+	return seqs
+}
+
+func TestSegmentStore_AppendAndGet(t *testing.T) {
+	st := openTestStore(t, store.Config{Dir: t.TempDir()})
+	defer st.Close()
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+
+	rec, err := st.Get(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), rec.StreamSeq)
+	assert.Equal(t, "events.test", rec.Subject)
+	assert.JSONEq(t, `{"id":"event-2"}`, string(rec.Data))
+	assert.Equal(t, []string{"msg-2"}, rec.Header["Nats-Msg-Id"])
+	assert.False(t, rec.StoredAt.IsZero())
+
+	_, err = st.Get(42)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	assert.Equal(t, []uint64{1, 2, 3}, collect(t, st))
+}
+
+func TestSegmentStore_SkipsDuplicateSequence(t *testing.T) {
+	st := openTestStore(t, store.Config{Dir: t.TempDir()})
+	defer st.Close()
+
+	require.NoError(t, st.Append(newRecord(7)))
+	require.NoError(t, st.Append(newRecord(7)))
+
+	assert.Equal(t, []uint64{7}, collect(t, st))
+}
+
+func TestSegmentStore_SkipsDuplicateOfSealedSegment(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	for seq := uint64(1); seq <= 5; seq++ {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+	require.NoError(t, st.Append(newRecord(2)))
+	require.NoError(t, st.Close())
+
+	st = openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	defer st.Close()
+	require.NoError(t, st.Append(newRecord(3)))
+	require.NoError(t, st.Append(newRecord(5)))
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, collect(t, st))
+	rec, err := st.Get(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), rec.StreamSeq)
+}
+
+func TestSegmentStore_SegmentSummaries(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	for seq := uint64(1); seq <= 5; seq++ {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+	require.NoError(t, st.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	summaries, err := filepath.Glob(filepath.Join(dir, "*.sum"))
+	require.NoError(t, err)
+	require.Len(t, summaries, len(segments)-1, "sealed segments are summarized")
+
+	// A damaged summary is recomputed from the terms file.
+	require.NoError(t, os.WriteFile(summaries[0], []byte("{"), 0o600))
+
+	st = openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	defer st.Close()
+	page, err := st.Search(store.Query{Filter: store.Filter{ID: "event-1"}})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, uint64(1), page.Records[0].StreamSeq)
+
+	raw, err := os.ReadFile(summaries[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"records":1`)
+}
+
+func TestSegmentStore_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+	require.NoError(t, st.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+
+	reopened := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	defer reopened.Close()
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, collect(t, reopened))
+	rec, err := reopened.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), rec.StreamSeq)
+}
+
+func TestReadDir_WhileOpen(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir, MaxSegmentBytes: 256})
+	defer st.Close()
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+
+	var seqs []uint64
+	require.NoError(t, store.ReadDir(dir, func(rec *store.Record) error {
+		seqs = append(seqs, rec.StreamSeq)
+		return nil
+	}))
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, seqs)
+
+	errStop := errors.New("stop")
+	err := store.ReadDir(dir, func(*store.Record) error { return errStop })
+	require.ErrorIs(t, err, errStop)
+}
+
+func TestSegmentStore_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir, MaxSegmentAge: time.Nanosecond})
+	defer st.Close()
+
+	require.NoError(t, st.Append(newRecord(1)))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, st.Append(newRecord(2)))
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 2)
+}
+
+func TestSegmentStore_FailedAppendLeavesNoPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir})
+
+	require.NoError(t, st.Append(newRecord(1)))
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	before, err := os.ReadFile(segments[0])
+	require.NoError(t, err)
+
+	require.NoError(t, store.BreakSearchTerms(st))
+	require.Error(t, st.Append(newRecord(2)))
+
+	after, err := os.ReadFile(segments[0])
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	require.NoError(t, store.RepairSearchTerms(st))
+	require.NoError(t, st.Append(newRecord(2)))
+	rec, err := st.Get(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), rec.StreamSeq)
+	require.NoError(t, st.Close())
+
+	// The index written for the record matches the data after a reopen.
+	st = openTestStore(t, store.Config{Dir: dir})
+	defer st.Close()
+	assert.Equal(t, []uint64{1, 2}, collect(t, st))
+	report, err := store.Verify(dir, nil)
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+}
+
+func TestSegmentStore_RecoversTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, store.Config{Dir: dir})
+	require.NoError(t, st.Append(newRecord(1)))
+	require.NoError(t, st.Append(newRecord(2)))
+	require.NoError(t, st.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"stream":"EVENTS","stream_seq":3,"da`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// Drop the index to force a rebuild as well.
+	require.NoError(t, os.Remove(segments[0][:len(segments[0])-len(".seg")]+".idx"))
+
+	reopened := openTestStore(t, store.Config{Dir: dir})
+	defer reopened.Close()
+
+	assert.Equal(t, []uint64{1, 2}, collect(t, reopened))
+	require.NoError(t, reopened.Append(newRecord(3)))
+	assert.Equal(t, []uint64{1, 2, 3}, collect(t, reopened))
+}
+
+func TestRecordFromMsg(t *testing.T) {
+	ts := time.Date(2025, 1, 11, 10, 30, 0, 0, time.UTC)
+	msg := &source.Msg{
+		Subject: "events.user.created",
+		Header:  source.Header{"Ce-Type": {"user.created"}},
+		Data:    []byte(`{"id":"1"}`),
+		Meta: &source.Metadata{
+			Stream:       "EVENTS",
+			Consumer:     "events-audit-durable",
+			Sequence:     source.SequencePair{Consumer: 9, Stream: 17},
+			NumDelivered: 2,
+			NumPending:   4,
+			Timestamp:    ts,
+		},
+	}
+
+	rec, err := store.RecordFromMsg(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "EVENTS", rec.Stream)
+	assert.Equal(t, "events-audit-durable", rec.Consumer)
+	assert.Equal(t, uint64(17), rec.StreamSeq)
+	assert.Equal(t, uint64(9), rec.ConsumerSeq)
+	assert.Equal(t, uint64(2), rec.Delivered)
+	assert.True(t, ts.Equal(rec.Timestamp))
+	assert.Equal(t, []string{"user.created"}, rec.Header["Ce-Type"])
+
+	_, err = store.RecordFromMsg(&source.Msg{Subject: "plain"})
+	require.Error(t, err)
+}
+
+func TestRecordFromMsg_CloudEvent(t *testing.T) {
+	msg := &source.Msg{
+		Subject: "events.user.created",
+		Header: source.Header{
+			"Ce-Specversion": {"1.0"},
+			"Ce-Id":          {"evt-1"},
+			"Ce-Source":      {"/users"},
+			"Ce-Type":        {"user.created"},
+			"Ce-Tenant":      {"acme"},
+		},
+		Data: []byte(`{"id":"1"}`),
+		Meta: &source.Metadata{
+			Stream:       "EVENTS",
+			Consumer:     "events-audit-durable",
+			Sequence:     source.SequencePair{Consumer: 3, Stream: 3},
+			NumDelivered: 1,
+			Timestamp:    time.Unix(0, 1736591400000000000),
+		},
+	}
+
+	rec, err := store.RecordFromMsg(msg)
+	require.NoError(t, err)
+	require.NotNil(t, rec.Event)
+	assert.Equal(t, "binary", rec.Event.Mode)
+	assert.Equal(t, "evt-1", rec.Event.ID)
+	assert.Equal(t, "user.created", rec.Event.Type)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, rec.Event.Extensions)
+
+	// Invalid CloudEvents are archived without attributes.
+	delete(msg.Header, "Ce-Source")
+	rec, err = store.RecordFromMsg(msg)
+	require.NoError(t, err)
+	assert.Nil(t, rec.Event)
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"events-audit/internal/constants"
+)
+
+// ErrNotFound is returned when no record is stored for the requested sequence.
+var ErrNotFound = errors.New("record not found")
+
+// Store persists audit records.
+type Store interface {
+	// Append durably persists the record. It returns only after the record
+	// has been synced to stable storage.
+	Append(rec *Record) error
+	// Get returns the record stored for the given stream sequence.
+	Get(seq uint64) (*Record, error)
+	// Scan calls fn for every stored record in append order.
+	Scan(fn func(rec *Record) error) error
+	// Close flushes and closes the store.
+	Close() error
+}
+
+// Config holds local archive configuration.
+type Config struct {
+	Dir                string
+	MaxSegmentBytes    int64
+	MaxSegmentAge      time.Duration
+	SigningKeyFile     string
+	CheckpointInterval int
+	// IndexFields are the dot separated event data paths, such as user.id,
+	// whose values are kept in the in-memory segment summaries, so searches
+	// on them skip segments without the value. Subjects, event types and
+	// sources are always summarized. The terms files on disk hold every
+	// data field, so other fields are searched by reading them and a
+	// changed list applies to all records after a restart.
+	IndexFields []string
+}
+
+// DefaultConfig returns default archive configuration.
+func DefaultConfig() Config {
+	return Config{
+		Dir:             "data",
+		MaxSegmentBytes: constants.DefaultStoreSegmentMaxBytes,
+		MaxSegmentAge:   constants.DefaultStoreSegmentMaxAge,
+
+		CheckpointInterval: constants.DefaultStoreCheckpointInterval,
+	}
+}
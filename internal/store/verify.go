@@ -0,0 +1,200 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+)
+
+// ProblemKind classifies an archive integrity problem.
+type ProblemKind string
+
+// Archive integrity problem kinds.
+const (
+	ProblemCorruptRecord      ProblemKind = "corrupt_record"
+	ProblemBrokenLink         ProblemKind = "broken_link"
+	ProblemHashMismatch       ProblemKind = "hash_mismatch"
+	ProblemSequenceGap        ProblemKind = "sequence_gap"
+	ProblemBadSignature       ProblemKind = "bad_signature"
+	ProblemCheckpointMismatch ProblemKind = "checkpoint_mismatch"
+	ProblemTruncated          ProblemKind = "truncated"
+)
+
+// Problem describes a single integrity violation found by Verify.
+type Problem struct {
+	Kind     ProblemKind `json:"kind"`
+	Segment  uint64      `json:"segment,omitempty"`
+	Offset   int64       `json:"offset,omitempty"`
+	Sequence uint64      `json:"sequence,omitempty"`
+	Detail   string      `json:"detail"`
+}
+
+// VerifyReport summarizes an archive verification run.
+type VerifyReport struct {
+	Segments            int       `json:"segments"`
+	Records             uint64    `json:"records"`
+	FirstSeq            uint64    `json:"first_seq"`
+	LastSeq             uint64    `json:"last_seq"`
+	Head                string    `json:"head"`
+	Checkpoints         int       `json:"checkpoints"`
+	VerifiedCheckpoints int       `json:"verified_checkpoints"`
+	Problems            []Problem `json:"problems"`
+	// Gaps lists missing stream sequences. They are informational: subject
+	// filters, start policies and messages still in flight all leave gaps
+	// in a healthy archive, while removed records break the hash chain.
+	Gaps []Problem `json:"gaps,omitempty"`
+}
+
+// OK reports whether the archive passed verification.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify walks the archive in dir, recomputing the hash chain, listing gaps
+// in stream sequence and validating checkpoint signatures. Chain
+// checking stops at the first broken link since every later record would be
+// reported as well. Signatures are not checked when publicKey is nil.
+func Verify(dir string, publicKey ed25519.PublicKey) (*VerifyReport, error) {
+	ids, err := segmentIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := readCheckpoints(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byCount := make(map[uint64]Checkpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		byCount[checkpoint.Records] = checkpoint
+	}
+
+	report := &VerifyReport{Segments: len(ids), Checkpoints: len(checkpoints)}
+	walker := &chainWalker{report: report, checkpoints: byCount}
+
+	for _, id := range ids {
+		seg := &segment{id: id, dir: dir}
+		_, readErr := readSegment(seg.dataPath(), -1, func(offset int64, rec *Record) error {
+			walker.visit(id, offset, rec)
+			return nil
+		})
+		if readErr != nil {
+			walker.fail(Problem{Kind: ProblemCorruptRecord, Segment: id, Detail: readErr.Error()})
+		}
+	}
+
+	report.Head = walker.prev
+	verifyCheckpoints(report, checkpoints, publicKey)
+
+	sort.Slice(walker.seqs, func(i, j int) bool { return walker.seqs[i] < walker.seqs[j] })
+	if len(walker.seqs) > 0 {
+		report.FirstSeq = walker.seqs[0]
+		report.LastSeq = walker.seqs[len(walker.seqs)-1]
+	}
+	report.Gaps = sequenceGaps(walker.seqs)
+
+	return report, nil
+}
+
+// chainWalker recomputes the hash chain record by record.
+type chainWalker struct {
+	report      *VerifyReport
+	checkpoints map[uint64]Checkpoint
+	prev        string
+	broken      bool
+	seqs        []uint64
+}
+
+func (w *chainWalker) visit(segmentID uint64, offset int64, rec *Record) {
+	w.report.Records++
+	w.seqs = append(w.seqs, rec.StreamSeq)
+
+	if w.broken {
+		w.prev = rec.Hash
+		return
+	}
+
+	problem := Problem{Segment: segmentID, Offset: offset, Sequence: rec.StreamSeq}
+	if rec.PrevHash != w.prev {
+		problem.Kind = ProblemBrokenLink
+		problem.Detail = fmt.Sprintf("expected previous hash %q, record has %q", w.prev, rec.PrevHash)
+		w.fail(problem)
+		return
+	}
+
+	hash, err := rec.computeHash()
+	if err != nil || hash != rec.Hash {
+		problem.Kind = ProblemHashMismatch
+		problem.Detail = fmt.Sprintf("record content hashes to %q, record has %q", hash, rec.Hash)
+		w.fail(problem)
+		return
+	}
+	w.prev = rec.Hash
+
+	if checkpoint, ok := w.checkpoints[w.report.Records]; ok && checkpoint.Hash != rec.Hash {
+		problem.Kind = ProblemCheckpointMismatch
+		problem.Detail = fmt.Sprintf("checkpoint at %d records signs %q, chain has %q",
+			checkpoint.Records, checkpoint.Hash, rec.Hash)
+		w.fail(problem)
+	}
+}
+
+func (w *chainWalker) fail(problem Problem) {
+	w.broken = true
+	w.report.Problems = append(w.report.Problems, problem)
+}
+
+// verifyCheckpoints checks checkpoint signatures and that no signed
+// records are missing from the archive.
+func verifyCheckpoints(report *VerifyReport, checkpoints []Checkpoint, publicKey ed25519.PublicKey) {
+	keyID := ""
+	if publicKey != nil {
+		keyID = KeyID(publicKey)
+	}
+
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+
+		if checkpoint.Records > report.Records {
+			report.Problems = append(report.Problems, Problem{
+				Kind:     ProblemTruncated,
+				Sequence: checkpoint.StreamSeq,
+				Detail: fmt.Sprintf("checkpoint %d covers %d records, archive holds %d",
+					i+1, checkpoint.Records, report.Records),
+			})
+		}
+
+		if publicKey == nil {
+			continue
+		}
+
+		content, err := checkpoint.signedContent()
+		if err != nil || checkpoint.KeyID != keyID || !ed25519.Verify(publicKey, content, checkpoint.Signature) {
+			report.Problems = append(report.Problems, Problem{
+				Kind:     ProblemBadSignature,
+				Sequence: checkpoint.StreamSeq,
+				Detail:   fmt.Sprintf("checkpoint %d signed by key %q does not verify", i+1, checkpoint.KeyID),
+			})
+			continue
+		}
+		report.VerifiedCheckpoints++
+	}
+}
+
+// sequenceGaps reports missing stream sequences between the first and last
+// archived sequence. The input must be sorted.
+func sequenceGaps(sorted []uint64) []Problem {
+	var problems []Problem
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] <= sorted[i-1]+1 {
+			continue
+		}
+		problems = append(problems, Problem{
+			Kind:     ProblemSequenceGap,
+			Sequence: sorted[i-1] + 1,
+			Detail:   fmt.Sprintf("missing stream sequences %d-%d", sorted[i-1]+1, sorted[i]-1),
+		})
+	}
+	return problems
+}
@@ -0,0 +1,165 @@
+package store_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"events-audit/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSigningKey(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	return path, publicKey
+}
+
+func writeSignedArchive(t *testing.T, seqs ...uint64) (string, ed25519.PublicKey) {
+	t.Helper()
+
+	dir := t.TempDir()
+	keyFile, publicKey := writeSigningKey(t)
+	config := store.Config{Dir: dir, SigningKeyFile: keyFile, CheckpointInterval: 2}
+
+	st := openTestStore(t, config)
+	for _, seq := range seqs {
+		require.NoError(t, st.Append(newRecord(seq)))
+	}
+	require.NoError(t, st.Close())
+
+	return dir, publicKey
+}
+
+func segmentFile(t *testing.T, dir string) string {
+	t.Helper()
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	return segments[0]
+}
+
+func problemKinds(report *store.VerifyReport) []store.ProblemKind {
+	kinds := make([]store.ProblemKind, 0, len(report.Problems))
+	for _, problem := range report.Problems {
+		kinds = append(kinds, problem.Kind)
+	}
+	return kinds
+}
+
+func TestVerify_IntactArchive(t *testing.T) {
+	dir, publicKey := writeSignedArchive(t, 1, 2, 3, 4, 5)
+
+	report, err := store.Verify(dir, publicKey)
+	require.NoError(t, err)
+
+	assert.True(t, report.OK(), "unexpected problems: %+v", report.Problems)
+	assert.Equal(t, uint64(5), report.Records)
+	assert.Equal(t, uint64(1), report.FirstSeq)
+	assert.Equal(t, uint64(5), report.LastSeq)
+	// Two interval checkpoints plus the final one written on close.
+	assert.Equal(t, 3, report.Checkpoints)
+	assert.Equal(t, 3, report.VerifiedCheckpoints)
+	assert.NotEmpty(t, report.Head)
+}
+
+func TestVerify_ChainContinuesAcrossReopen(t *testing.T) {
+	dir, publicKey := writeSignedArchive(t, 1, 2)
+
+	st := openTestStore(t, store.Config{Dir: dir})
+	require.NoError(t, st.Append(newRecord(3)))
+	require.NoError(t, st.Close())
+
+	report, err := store.Verify(dir, publicKey)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "unexpected problems: %+v", report.Problems)
+	assert.Equal(t, uint64(3), report.Records)
+}
+
+func TestVerify_DetectsEditedRecord(t *testing.T) {
+	dir, publicKey := writeSignedArchive(t, 1, 2, 3)
+	path := segmentFile(t, dir)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	edited := bytes.Replace(raw, []byte(`"subject":"events.test"`), []byte(`"subject":"events.edit"`), 1)
+	require.NoError(t, os.WriteFile(path, edited, 0o600))
+
+	report, err := store.Verify(dir, publicKey)
+	require.NoError(t, err)
+
+	require.False(t, report.OK())
+	assert.Equal(t, store.ProblemHashMismatch, report.Problems[0].Kind)
+	assert.Equal(t, uint64(1), report.Problems[0].Sequence)
+}
+
+func TestVerify_DetectsDeletedRecord(t *testing.T) {
+	dir, publicKey := writeSignedArchive(t, 1, 2, 3)
+	path := segmentFile(t, dir)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	require.NoError(t, os.WriteFile(path, append(append([]byte(nil), lines[0]...), lines[2]...), 0o600))
+
+	report, err := store.Verify(dir, publicKey)
+	require.NoError(t, err)
+
+	kinds := problemKinds(report)
+	assert.Equal(t, store.ProblemBrokenLink, kinds[0])
+	assert.Equal(t, uint64(3), report.Problems[0].Sequence)
+	assert.Contains(t, kinds, store.ProblemTruncated)
+	require.Len(t, report.Gaps, 1)
+	assert.Equal(t, uint64(2), report.Gaps[0].Sequence)
+}
+
+func TestVerify_SequenceGapsAreInformational(t *testing.T) {
+	// A consumer filtering subjects of a wider stream archives every other
+	// sequence.
+	dir, publicKey := writeSignedArchive(t, 2, 4, 5, 9)
+
+	report, err := store.Verify(dir, publicKey)
+	require.NoError(t, err)
+
+	assert.True(t, report.OK(), "unexpected problems: %+v", report.Problems)
+	require.Len(t, report.Gaps, 2)
+	assert.Equal(t, store.ProblemSequenceGap, report.Gaps[0].Kind)
+	assert.Equal(t, "missing stream sequences 3-3", report.Gaps[0].Detail)
+	assert.Equal(t, "missing stream sequences 6-8", report.Gaps[1].Detail)
+}
+
+func TestVerify_DetectsForeignSignature(t *testing.T) {
+	dir, _ := writeSignedArchive(t, 1, 2)
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	report, err := store.Verify(dir, otherKey)
+	require.NoError(t, err)
+
+	assert.Contains(t, problemKinds(report), store.ProblemBadSignature)
+	assert.Equal(t, 0, report.VerifiedCheckpoints)
+}
+
+func TestLoadVerifyKey_FromPrivateKey(t *testing.T) {
+	keyFile, publicKey := writeSigningKey(t)
+
+	loaded, err := store.LoadVerifyKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, loaded)
+}
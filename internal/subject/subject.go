@@ -0,0 +1,68 @@
+// Package subject matches NATS subjects against subject patterns.
+package subject
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidatePattern checks that wildcards are whole tokens and that > is the
+// last token.
+func ValidatePattern(pattern string) error {
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid subject pattern %q: empty token", pattern)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf("invalid subject pattern %q: > must be the last token", pattern)
+		case token != "*" && token != ">" && strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("invalid subject pattern %q: wildcards must be whole tokens", pattern)
+		}
+	}
+	return nil
+}
+
+// HasWildcards reports whether the pattern matches more than one subject.
+func HasWildcards(pattern string) bool {
+	for _, token := range strings.Split(pattern, ".") {
+		if token == "*" || token == ">" {
+			return true
+		}
+	}
+	return false
+}
+
+// Match matches a subject against a NATS subject pattern: * matches one
+// token and > one or more trailing tokens.
+func Match(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// Overlap reports whether some subject matches both patterns.
+func Overlap(a, b string) bool {
+	aTokens := strings.Split(a, ".")
+	bTokens := strings.Split(b, ".")
+	for i := 0; i < len(aTokens) && i < len(bTokens); i++ {
+		if aTokens[i] == ">" || bTokens[i] == ">" {
+			return true
+		}
+		if aTokens[i] != "*" && bTokens[i] != "*" && aTokens[i] != bTokens[i] {
+			return false
+		}
+	}
+	return len(aTokens) == len(bTokens)
+}
@@ -0,0 +1,59 @@
+package subject_test
+
+import (
+	"testing"
+
+	"events-audit/internal/subject"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"audit.users", "audit.users", true},
+		{"audit.users", "audit.orders", false},
+		{"audit.*", "audit.users", true},
+		{"audit.*", "audit.users.created", false},
+		{"audit.>", "audit.users.created", true},
+		{"audit.>", "audit", false},
+		{"*.users", "audit.users", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, subject.Match(tt.pattern, tt.subject), "%s %s", tt.pattern, tt.subject)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	require.NoError(t, subject.ValidatePattern("audit.*.created"))
+	require.NoError(t, subject.ValidatePattern("audit.>"))
+	require.ErrorContains(t, subject.ValidatePattern("audit..users"), "empty token")
+	require.ErrorContains(t, subject.ValidatePattern("audit.>.users"), "must be the last token")
+	require.ErrorContains(t, subject.ValidatePattern("audit.us*"), "whole tokens")
+
+	assert.True(t, subject.HasWildcards("audit.*"))
+	assert.False(t, subject.HasWildcards("audit.users"))
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{">", "audit.dlq.>", true},
+		{"events.>", "audit.dlq.>", false},
+		{"audit.*", "audit.dlq.>", false},
+		{"audit.*.users", "audit.dlq.>", true},
+		{"audit.users", "audit.dlq.>", false},
+		{"audit", "audit.>", false},
+		{"*.*", "audit.users", true},
+		{"audit.*", "audit.users.created", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, subject.Overlap(tt.a, tt.b), "%s %s", tt.a, tt.b)
+		assert.Equal(t, tt.want, subject.Overlap(tt.b, tt.a), "%s %s", tt.b, tt.a)
+	}
+}
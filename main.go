@@ -4,12 +4,15 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"time"
 
 	"events-audit/internal/constants"
+	"events-audit/internal/health"
+	"events-audit/internal/metrics"
+	"events-audit/internal/nats"
 	"events-audit/internal/server"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/render"
 	"github.com/juju/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
@@ -21,22 +24,11 @@ var (
 
 func beforeAction(ctx context.Context, c *cli.Command) (context.Context, error) {
 	levelParam := c.String("log-level")
-	formatParam := c.String("log-format")
-	if formatParam != "text" && formatParam != "json" {
-		return ctx, errors.New("log format suppoorte only json or text")
-	}
-
-	if formatParam == "text" {
-		logrus.SetFormatter(&logrus.TextFormatter{
-			TimestampFormat: "02.01.2006 15:04:05.000",
-			FullTimestamp:   true,
-		})
-	}
-	if formatParam == "json" {
-		logrus.SetFormatter(&logrus.JSONFormatter{
-			TimestampFormat: "02.01.2006 15:04:05.000",
-		})
+	formatter, err := server.NewLogFormatter(c.String("log-format"), c.String("log-siem-mapping"), version)
+	if err != nil {
+		return ctx, err
 	}
+	logrus.SetFormatter(formatter)
 
 	level, err := logrus.ParseLevel(levelParam)
 	if err != nil {
@@ -48,20 +40,18 @@ func beforeAction(ctx context.Context, c *cli.Command) (context.Context, error)
 	return ctx, nil
 }
 
-func healthHandler() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		render.JSON(w, r, map[string]any{
-			"Status": "OK",
-		})
-	}
-}
-func startHealth(addr string) error {
+func startHealth(addr string, m *metrics.Metrics, checker *health.Checker, sinks, events http.Handler) error {
 
 	r := chi.NewRouter()
 
-	r.Get("/health", healthHandler())
+	r.Get("/health", health.LiveHandler())
+	r.Get("/livez", health.LiveHandler())
+	r.Get("/readyz", checker.ReadyHandler())
+	r.Handle("/sinks", sinks)
+	r.Handle("/metrics", m.Handler())
+	if events != nil {
+		r.Mount("/events", events)
+	}
 
 	srv := &http.Server{
 		Addr:    addr,
@@ -77,28 +67,14 @@ func startHealth(addr string) error {
 	return nil
 
 }
-func mainAction(ctx context.Context, c *cli.Command) error {
-
-	listenAddr := c.String("health-addr")
-	// Check if audit is enabled
-	auditType := c.String("audit")
-	if auditType == "nope" {
-		logrus.Info("Audit is disabled, exiting")
-		return nil
-	}
 
-	if auditType != "nats" {
-		return errors.New("only NATS audit is currently supported")
-	}
-
-	natsAddr := c.String("audit-nats-addr")
-	if natsAddr == "" {
-		return errors.New("NATS address is required when audit type is 'nats'")
-	}
+// newServerConfig builds the server configuration from the flags.
+func newServerConfig(c *cli.Command) server.Config {
+	return server.Config{
+		Audit: c.String("audit"),
 
-	// Create server configuration
-	config := server.Config{
-		NatsURL:         natsAddr,
+		NatsURL:         c.String("audit-nats-addr"),
+		NatsAuth:        newNatsAuth(c),
 		NatsSubject:     c.String("audit-topic"),
 		StreamName:      c.String("audit-stream-name"),
 		ConsumerName:    c.String("audit-consumer-name"),
@@ -108,20 +84,121 @@ func mainAction(ctx context.Context, c *cli.Command) error {
 		AckWait:         c.Duration("audit-ack-wait"),
 		PullMaxMessages: c.Int("audit-pull-max-messages"),
 		PullTimeout:     c.Duration("audit-pull-timeout"),
+		MaxAckPending:   c.Int("audit-max-ack-pending"),
+		Workers:         c.Int("audit-workers"),
+		PartitionKey:    c.String("audit-partition-key"),
 		StreamMaxAge:    c.Duration("audit-stream-max-age"),
 		StreamMaxBytes:  c.Int64("audit-stream-max-bytes"),
 		StreamMaxMsgs:   c.Int64("audit-stream-max-msgs"),
 		StreamReplicas:  c.Int("audit-stream-replicas"),
-		LogLevel:        c.String("log-level"),
-		LogFormat:       c.String("log-format"),
+
+		NatsEmbedded:     c.Bool("audit-nats-embedded"),
+		NatsEmbeddedAddr: c.String("audit-nats-embedded-addr"),
+		NatsEmbeddedDir:  c.String("audit-nats-embedded-dir"),
+
+		StreamSubjects:        c.StringSlice("audit-stream-subjects"),
+		StreamStorage:         c.String("audit-stream-storage"),
+		StreamRetention:       c.String("audit-stream-retention"),
+		StreamDiscard:         c.String("audit-stream-discard"),
+		StreamDuplicateWindow: c.Duration("audit-stream-duplicate-window"),
+		StreamMaxMsgSize:      c.Int32("audit-stream-max-msg-size"),
+		StreamCompression:     c.String("audit-stream-compression"),
+		StreamDenyDelete:      c.Bool("audit-stream-deny-delete"),
+		StreamDenyPurge:       c.Bool("audit-stream-deny-purge"),
+		StreamMode:            c.String("audit-stream-mode"),
+
+		DeliverPolicy:    c.String("audit-deliver-policy"),
+		DeliverStartSeq:  c.Uint64("audit-deliver-start-seq"),
+		DeliverStartTime: c.Timestamp("audit-deliver-start-time"),
+		ReplayPolicy:     c.String("audit-replay-policy"),
+		ConsumerRecreate: c.Bool("audit-consumer-recreate"),
+
+		NSQAddrs:           c.StringSlice("audit-nsq-addr"),
+		NSQLookupdAddrs:    c.StringSlice("audit-nsq-lookupd-addr"),
+		NSQChannel:         c.String("audit-nsq-channel"),
+		NSQMaxInFlight:     c.Int("audit-nsq-max-in-flight"),
+		NSQRequeueDelay:    c.Duration("audit-nsq-requeue-delay"),
+		NSQMaxRequeueDelay: c.Duration("audit-nsq-max-requeue-delay"),
+		NSQTouchInterval:   c.Duration("audit-nsq-touch-interval"),
+
+		DeadLetterStream:  c.String("audit-dlq-stream"),
+		DeadLetterSubject: c.String("audit-dlq-subject"),
+		DeadLetterMaxAge:  c.Duration("audit-dlq-max-age"),
+
+		SchemaDir:         c.String("audit-schema-dir"),
+		SchemaOutcome:     c.String("audit-schema-outcome"),
+		SchemaRequire:     c.Bool("audit-schema-require"),
+		QuarantineStream:  c.String("audit-schema-quarantine-stream"),
+		QuarantineSubject: c.String("audit-schema-quarantine-subject"),
+		QuarantineMaxAge:  c.Duration("audit-schema-quarantine-max-age"),
+		RedactRulesFile:   c.String("audit-redact-rules"),
+		RedactHMACKeyFile: c.String("audit-redact-hmac-key-file"),
+		SinksConfigFile:   c.String("audit-sinks-config"),
+
+		LogLevel:       c.String("log-level"),
+		LogFormat:      c.String("log-format"),
+		LogMappingFile: c.String("log-siem-mapping"),
+		Version:        version,
+
+		FetchStaleAfter: c.Duration("health-fetch-stale-after"),
+		MaxConsumerLag:  c.Uint64("health-max-lag"),
+
+		StoreDir:                c.String("audit-store-dir"),
+		StoreSegmentMaxBytes:    c.Int64("audit-store-segment-max-bytes"),
+		StoreSegmentMaxAge:      c.Duration("audit-store-segment-max-age"),
+		StoreSigningKeyFile:     c.String("audit-store-signing-key"),
+		StoreCheckpointInterval: c.Int("audit-store-checkpoint-interval"),
+		StoreIndexFields:        c.StringSlice("audit-store-index-field"),
+
+		APITokensFile: c.String("api-tokens-file"),
+		StreamBuffer:  c.Int("api-stream-buffer"),
 	}
-	err := startHealth(listenAddr)
+}
+
+func mainAction(ctx context.Context, c *cli.Command) error {
+
+	listenAddr := c.String("health-addr")
+	// Check if audit is enabled
+	auditType := c.String("audit")
+	if auditType == "nope" {
+		logrus.Info("Audit is disabled, exiting")
+		return nil
+	}
+
+	natsAddr := c.String("audit-nats-addr")
+	embedded := c.Bool("audit-nats-embedded")
+	nsqAddrs := c.StringSlice("audit-nsq-addr")
+	nsqLookupdAddrs := c.StringSlice("audit-nsq-lookupd-addr")
+	switch auditType {
+	case server.SourceNATS:
+		if natsAddr == "" && !embedded {
+			return errors.New("NATS address is required when audit type is 'nats'")
+		}
+	case server.SourceNSQ:
+		if len(nsqAddrs) == 0 && len(nsqLookupdAddrs) == 0 {
+			return errors.New("nsqd or nsqlookupd address is required when audit type is 'nsq'")
+		}
+	default:
+		return errors.Errorf("unsupported audit type %q", auditType)
+	}
+
+	// Create server configuration
+	config := newServerConfig(c)
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	// Create the server and expose its health and metrics
+	m := metrics.New()
+	srv, err := server.NewServer(config, m)
+	if err != nil {
+		return err
+	}
+	err = startHealth(listenAddr, m, health.NewChecker(srv.ReadinessChecks()...), srv.SinkStatusHandler(), srv.APIHandler())
 	if err != nil {
 		return nil
 	}
 
-	// Create and run the server
-	srv := server.NewServer(config)
 	return srv.Run(ctx)
 }
 
@@ -136,11 +213,17 @@ func createBaseFlags() []cli.Flag {
 		},
 		&cli.StringFlag{
 			Name:     "log-format",
-			Usage:    "logger format `text` or `json`",
+			Usage:    "logger format `text`, `json`, `cef` or `leef`",
 			Value:    "text",
 			Sources:  cli.EnvVars("AUDIT_LISTNER_LOG_FORMAT"),
 			Category: "base",
 		},
+		&cli.StringFlag{
+			Name:     "log-siem-mapping",
+			Usage:    "YAML `FILE` mapping event fields and data to CEF or LEEF extensions and severities",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_LOG_SIEM_MAPPING"),
+			Category: "base",
+		},
 
 		&cli.StringFlag{
 			Name:     "health-addr",
@@ -149,6 +232,19 @@ func createBaseFlags() []cli.Flag {
 			Sources:  cli.EnvVars("AUDIT_LISTNER_HEALTH_ADDR"),
 			Category: "base",
 		},
+		&cli.DurationFlag{
+			Name:     "health-fetch-stale-after",
+			Usage:    "readiness fails when the fetch loop has not iterated for `DURATION`",
+			Value:    constants.DefaultFetchStaleAfter,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_HEALTH_FETCH_STALE_AFTER"),
+			Category: "base",
+		},
+		&cli.Uint64Flag{
+			Name:     "health-max-lag",
+			Usage:    "readiness fails when the consumer has more pending messages than `COUNT`, 0 disables the check",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_HEALTH_MAX_LAG"),
+			Category: "base",
+		},
 	}
 }
 
@@ -174,6 +270,85 @@ func createAuditFlags() []cli.Flag {
 			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_ADDR"),
 			Category: "audit",
 		},
+		&cli.BoolFlag{
+			Name:     "audit-nats-embedded",
+			Usage:    "run an in-process NATS server with JetStream instead of connecting to audit-nats-addr",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_EMBEDDED"),
+			Category: "audit",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-embedded-addr",
+			Usage:    "client listen address of the embedded NATS server `ADDRESS`",
+			Value:    constants.DefaultEmbeddedAddr,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_EMBEDDED_ADDR"),
+			Category: "audit",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-embedded-dir",
+			Usage:    "JetStream storage directory of the embedded NATS server `DIR`",
+			Value:    constants.DefaultEmbeddedStoreDir,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_EMBEDDED_DIR"),
+			Category: "audit",
+		},
+	}
+}
+
+func createNatsAuthFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "audit-nats-creds",
+			Usage:    "NATS user credentials `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_CREDS"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-nkey",
+			Usage:    "NATS NKey seed `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_NKEY"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-token-file",
+			Usage:    "NATS authentication token `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_TOKEN_FILE"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-user-file",
+			Usage:    "NATS user name `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_USER_FILE"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-password-file",
+			Usage:    "NATS password `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_PASSWORD_FILE"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-tls-cert",
+			Usage:    "TLS client certificate `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_TLS_CERT"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-tls-key",
+			Usage:    "TLS client key `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_TLS_KEY"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-tls-ca",
+			Usage:    "TLS CA bundle `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_TLS_CA"),
+			Category: "nats auth",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nats-tls-server-name",
+			Usage:    "TLS server name used to verify the NATS server certificate `NAME`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NATS_TLS_SERVER_NAME"),
+			Category: "nats auth",
+		},
 	}
 }
 
@@ -235,6 +410,28 @@ func createJetStreamFlags() []cli.Flag {
 			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_PULL_TIMEOUT"),
 			Category: "jetstream",
 		},
+		&cli.IntFlag{
+			Name:     "audit-max-ack-pending",
+			Usage:    "maximum unacknowledged messages, also bounds messages in flight `COUNT`",
+			Value:    constants.DefaultMaxAckPending,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_MAX_ACK_PENDING"),
+			Category: "jetstream",
+		},
+		&cli.IntFlag{
+			Name:     "audit-workers",
+			Usage:    "number of workers handling messages concurrently `COUNT`",
+			Value:    constants.DefaultWorkers,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_WORKERS"),
+			Category: "jetstream",
+		},
+		&cli.StringFlag{
+			Name:      "audit-partition-key",
+			Usage:     "key keeping messages in order across workers: subject or json:<field.path> `KEY`",
+			Value:     nats.PartitionSubject,
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_PARTITION_KEY"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParsePartitionKey),
+		},
 		&cli.DurationFlag{
 			Name:     "audit-stream-max-age",
 			Usage:    "maximum age for messages in stream `DURATION`",
@@ -263,6 +460,338 @@ func createJetStreamFlags() []cli.Flag {
 			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_REPLICAS"),
 			Category: "jetstream",
 		},
+		&cli.StringSliceFlag{
+			Name:     "audit-stream-subjects",
+			Usage:    "stream subjects, defaults to the audit topic `SUBJECT`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_SUBJECTS"),
+			Category: "jetstream",
+		},
+		&cli.StringFlag{
+			Name:      "audit-stream-storage",
+			Usage:     "stream storage `file` or `memory`",
+			Value:     "file",
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_STORAGE"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseStorage),
+		},
+		&cli.StringFlag{
+			Name:      "audit-stream-retention",
+			Usage:     "stream retention policy limits, interest or workqueue `POLICY`",
+			Value:     "limits",
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_RETENTION"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseRetention),
+		},
+		&cli.StringFlag{
+			Name:      "audit-stream-discard",
+			Usage:     "stream discard policy `old` or `new`",
+			Value:     "old",
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_DISCARD"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseDiscard),
+		},
+		&cli.DurationFlag{
+			Name:     "audit-stream-duplicate-window",
+			Usage:    "stream duplicate detection window `DURATION`",
+			Value:    constants.DefaultDuplicateWindow,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_DUPLICATE_WINDOW"),
+			Category: "jetstream",
+		},
+		&cli.Int32Flag{
+			Name:     "audit-stream-max-msg-size",
+			Usage:    "maximum message size accepted by the stream, -1 for unlimited `BYTES`",
+			Value:    constants.DefaultStreamMaxMsgSize,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_MAX_MSG_SIZE"),
+			Category: "jetstream",
+		},
+		&cli.StringFlag{
+			Name:      "audit-stream-compression",
+			Usage:     "stream compression `none` or `s2`",
+			Value:     "none",
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_COMPRESSION"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseCompression),
+		},
+		&cli.BoolFlag{
+			Name:     "audit-stream-deny-delete",
+			Usage:    "deny deleting individual messages from the stream",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_DENY_DELETE"),
+			Category: "jetstream",
+		},
+		&cli.BoolFlag{
+			Name:     "audit-stream-deny-purge",
+			Usage:    "deny purging the stream",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_DENY_PURGE"),
+			Category: "jetstream",
+		},
+		&cli.StringFlag{
+			Name: "audit-stream-mode",
+			Usage: "stream management: create a missing stream, reconcile drifted settings " +
+				"or verify-only and fail on drift `MODE`",
+			Value:     nats.StreamModeReconcile,
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_STREAM_MODE"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseStreamMode),
+		},
+		&cli.StringFlag{
+			Name: "audit-deliver-policy",
+			Usage: "where a new durable consumer starts: all, new, last, last-per-subject, " +
+				"by-start-sequence or by-start-time `POLICY`",
+			Value:     nats.DeliverAll,
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_DELIVER_POLICY"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseDeliverPolicy),
+		},
+		&cli.Uint64Flag{
+			Name:     "audit-deliver-start-seq",
+			Usage:    "stream sequence to start from with the by-start-sequence policy `SEQ`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_DELIVER_START_SEQ"),
+			Category: "jetstream",
+		},
+		&cli.TimestampFlag{
+			Name:     "audit-deliver-start-time",
+			Usage:    "RFC 3339 time to start from with the by-start-time policy `TIME`",
+			Config:   cli.TimestampConfig{Layouts: []string{time.RFC3339}},
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_DELIVER_START_TIME"),
+			Category: "jetstream",
+		},
+		&cli.StringFlag{
+			Name:      "audit-replay-policy",
+			Usage:     "replay speed of stored messages: instant or original `POLICY`",
+			Value:     nats.ReplayInstant,
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_REPLAY_POLICY"),
+			Category:  "jetstream",
+			Validator: validateWith(nats.ParseReplayPolicy),
+		},
+		&cli.BoolFlag{
+			Name:     "audit-consumer-recreate",
+			Usage:    "delete and recreate an existing consumer whose filter subject, deliver or replay policy differ",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_CONSUMER_RECREATE"),
+			Category: "jetstream",
+		},
+	}
+}
+
+// validateWith adapts a command line value parser into a flag validator.
+func validateWith[T any](parse func(string) (T, error)) func(string) error {
+	return func(v string) error {
+		_, err := parse(v)
+		return err
+	}
+}
+
+func createDeadLetterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "audit-dlq-stream",
+			Usage:    "JetStream dead-letter stream `NAME`, dead-lettering (also for nsq) is disabled when empty",
+			Value:    constants.DefaultDeadLetterStream,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_DLQ_STREAM"),
+			Category: "dlq",
+		},
+		&cli.StringFlag{
+			Name:     "audit-dlq-subject",
+			Usage:    "subject prefix for dead-lettered messages, the dead-letter topic for nsq `PREFIX`",
+			Value:    constants.DefaultDeadLetterSubject,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_DLQ_SUBJECT"),
+			Category: "dlq",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-dlq-max-age",
+			Usage:    "maximum age for messages in dead-letter stream `DURATION`",
+			Value:    constants.DefaultDeadLetterMaxAge,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_DLQ_MAX_AGE"),
+			Category: "dlq",
+		},
+	}
+}
+
+func createSchemaFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name: "audit-schema-dir",
+			Usage: "directory of JSON Schema files named <type>.json or <type>@<version>.json `DIR`, " +
+				"validation is disabled when empty",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_DIR"),
+			Category: "schema",
+		},
+		&cli.StringFlag{
+			Name:      "audit-schema-outcome",
+			Usage:     "handling of events failing validation: log, quarantine or nak `OUTCOME`",
+			Value:     nats.SchemaOutcomeLog,
+			Sources:   cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_OUTCOME"),
+			Category:  "schema",
+			Validator: validateWith(nats.ParseSchemaOutcome),
+		},
+		&cli.BoolFlag{
+			Name:     "audit-schema-require",
+			Usage:    "treat events whose type has no schema as invalid",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_REQUIRE"),
+			Category: "schema",
+		},
+		&cli.StringFlag{
+			Name:     "audit-schema-quarantine-stream",
+			Usage:    "JetStream stream holding quarantined events `NAME`",
+			Value:    constants.DefaultQuarantineStream,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_QUARANTINE_STREAM"),
+			Category: "schema",
+		},
+		&cli.StringFlag{
+			Name:     "audit-schema-quarantine-subject",
+			Usage:    "subject prefix for quarantined events `PREFIX`",
+			Value:    constants.DefaultQuarantineSubject,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_QUARANTINE_SUBJECT"),
+			Category: "schema",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-schema-quarantine-max-age",
+			Usage:    "maximum age for messages in quarantine stream `DURATION`",
+			Value:    constants.DefaultQuarantineMaxAge,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SCHEMA_QUARANTINE_MAX_AGE"),
+			Category: "schema",
+		},
+	}
+}
+
+func createStoreFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "audit-store-dir",
+			Usage:    "local archive directory `DIR`, archiving is disabled when empty",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_DIR"),
+			Category: "store",
+		},
+		&cli.Int64Flag{
+			Name:     "audit-store-segment-max-bytes",
+			Usage:    "maximum archive segment size before rotation `BYTES`",
+			Value:    constants.DefaultStoreSegmentMaxBytes,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_SEGMENT_MAX_BYTES"),
+			Category: "store",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-store-segment-max-age",
+			Usage:    "maximum archive segment age before rotation `DURATION`",
+			Value:    constants.DefaultStoreSegmentMaxAge,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_SEGMENT_MAX_AGE"),
+			Category: "store",
+		},
+		&cli.StringFlag{
+			Name:     "audit-store-signing-key",
+			Usage:    "PEM encoded Ed25519 private key used to sign archive checkpoints `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_SIGNING_KEY"),
+			Category: "store",
+		},
+		&cli.IntFlag{
+			Name:     "audit-store-checkpoint-interval",
+			Usage:    "number of archived records between signed checkpoints `COUNT`",
+			Value:    constants.DefaultStoreCheckpointInterval,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_CHECKPOINT_INTERVAL"),
+			Category: "store",
+		},
+		&cli.StringSliceFlag{
+			Name:     "audit-store-index-field",
+			Usage:    "event data path summarized per segment to speed up archive search, such as user.id, may be repeated `PATH`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_STORE_INDEX_FIELD"),
+			Category: "store",
+		},
+	}
+}
+
+func createAPIFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "api-tokens-file",
+			Usage:    "file with the bearer tokens of the events API, one per line `FILE`, the API is disabled when empty",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_API_TOKENS_FILE"),
+			Category: "api",
+		},
+		&cli.IntFlag{
+			Name:     "api-stream-buffer",
+			Usage:    "live events buffered per stream client before events are dropped for it `COUNT`",
+			Value:    constants.DefaultStreamBuffer,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_API_STREAM_BUFFER"),
+			Category: "api",
+		},
+	}
+}
+
+func createNSQFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "audit-nsq-addr",
+			Usage:    "nsqd TCP address, may be repeated `ADDRESS`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_ADDR"),
+			Category: "nsq",
+		},
+		&cli.StringSliceFlag{
+			Name:     "audit-nsq-lookupd-addr",
+			Usage:    "nsqlookupd HTTP address used to discover nsqd, may be repeated `ADDRESS`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_LOOKUPD_ADDR"),
+			Category: "nsq",
+		},
+		&cli.StringFlag{
+			Name:     "audit-nsq-channel",
+			Usage:    "channel consuming audit-topic `CHANNEL`",
+			Value:    constants.DefaultNSQChannel,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_CHANNEL"),
+			Category: "nsq",
+		},
+		&cli.IntFlag{
+			Name:     "audit-nsq-max-in-flight",
+			Usage:    "maximum messages in flight `COUNT`",
+			Value:    constants.DefaultNSQMaxInFlight,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_MAX_IN_FLIGHT"),
+			Category: "nsq",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-nsq-requeue-delay",
+			Usage:    "delay of the first requeue after a failure, doubled on every attempt `DURATION`",
+			Value:    constants.DefaultNSQRequeueDelay,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_REQUEUE_DELAY"),
+			Category: "nsq",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-nsq-max-requeue-delay",
+			Usage:    "maximum requeue delay `DURATION`",
+			Value:    constants.DefaultNSQMaxRequeueDelay,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_MAX_REQUEUE_DELAY"),
+			Category: "nsq",
+		},
+		&cli.DurationFlag{
+			Name:     "audit-nsq-touch-interval",
+			Usage:    "interval of touching a message while it is handled, shorter than the nsqd message timeout `DURATION`",
+			Value:    constants.DefaultNSQTouchInterval,
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_NSQ_TOUCH_INTERVAL"),
+			Category: "nsq",
+		},
+	}
+}
+
+func createRedactFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "audit-redact-rules",
+			Usage:    "YAML file of redaction rules applied to event data before output `FILE`, disabled when empty",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_REDACT_RULES"),
+			Category: "redact",
+		},
+		&cli.StringFlag{
+			Name:     "audit-redact-hmac-key-file",
+			Usage:    "file with the HMAC key of the hash redaction mode `FILE`",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_REDACT_HMAC_KEY_FILE"),
+			Category: "redact",
+		},
+	}
+}
+
+func createSinkFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "audit-sinks-config",
+			Usage:    "YAML file of output sinks and their routing `FILE`, events are only logged when empty",
+			Sources:  cli.EnvVars("AUDIT_LISTNER_AUDIT_SINKS_CONFIG"),
+			Category: "sinks",
+		},
 	}
 }
 
@@ -270,7 +799,15 @@ func createAllFlags() []cli.Flag {
 	var flags []cli.Flag
 	flags = append(flags, createBaseFlags()...)
 	flags = append(flags, createAuditFlags()...)
+	flags = append(flags, createNatsAuthFlags()...)
 	flags = append(flags, createJetStreamFlags()...)
+	flags = append(flags, createNSQFlags()...)
+	flags = append(flags, createDeadLetterFlags()...)
+	flags = append(flags, createSchemaFlags()...)
+	flags = append(flags, createRedactFlags()...)
+	flags = append(flags, createSinkFlags()...)
+	flags = append(flags, createStoreFlags()...)
+	flags = append(flags, createAPIFlags()...)
 	return flags
 }
 
@@ -282,6 +819,17 @@ func runApplication() error {
 		Action:  mainAction,
 		Version: version,
 		Flags:   createAllFlags(),
+		Commands: []*cli.Command{
+			createVerifyCommand(),
+			createTailCommand(),
+			createSearchCommand(),
+			createReplayCommand(),
+			createExportCommand(),
+			createImportCommand(),
+			createDeadLetterCommand(),
+			createSchemaCommand(),
+			createRedactCommand(),
+		},
 	}
 
 	return cmd.Run(context.Background(), os.Args)
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"events-audit/internal/redact"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// loadRedactor loads the redaction rules of the redact flags, returning nil
+// when no rules file is set.
+func loadRedactor(c *cli.Command) (*redact.Engine, error) {
+	rules := c.String("audit-redact-rules")
+	if rules == "" {
+		return nil, nil //nolint:nilnil // redaction is disabled
+	}
+
+	var hmacKey []byte
+	if keyFile := c.String("audit-redact-hmac-key-file"); keyFile != "" {
+		key, err := redact.LoadHMACKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		hmacKey = key
+	}
+
+	return redact.Load(rules, hmacKey)
+}
+
+func redactTestAction(_ context.Context, c *cli.Command) error {
+	dir := c.Args().First()
+	if dir == "" {
+		return errors.New("fixtures directory is required")
+	}
+	rules := c.String("audit-redact-rules")
+	if rules == "" {
+		return errors.New("redaction rules are required, set --audit-redact-rules")
+	}
+
+	engine, err := loadRedactor(c)
+	if err != nil {
+		return err
+	}
+	fixtures, err := redact.LoadFixtures(dir)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, fixture := range fixtures {
+		if checkErr := fixture.Check(engine); checkErr != nil {
+			failed++
+			fmt.Fprintf(c.Root().Writer, "%s: %v\n", fixture.File, checkErr)
+		}
+	}
+
+	fields := logrus.Fields{
+		"rules":    rules,
+		"fixtures": len(fixtures),
+		"failed":   failed,
+	}
+	if failed > 0 {
+		logrus.WithFields(fields).Error("Redaction test failed")
+		return fmt.Errorf("%d of %d redaction fixtures failed", failed, len(fixtures))
+	}
+
+	logrus.WithFields(fields).Info("Redaction rules match the fixtures")
+	return nil
+}
+
+func createRedactCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "redact",
+		Usage: "manage event redaction rules",
+		Commands: []*cli.Command{
+			{
+				Name: "test",
+				Usage: "redact the input of every JSON fixture in DIR with --audit-redact-rules " +
+					"and compare it to the expected output",
+				ArgsUsage: "DIR",
+				Action:    redactTestAction,
+			},
+		},
+	}
+}
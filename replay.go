@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"events-audit/internal/nats"
+	"events-audit/internal/server"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+func replayAction(ctx context.Context, c *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if c.String("audit-nats-addr") == "" && !c.Bool("audit-nats-embedded") {
+		return errors.New("NATS address is required, set --audit-nats-addr")
+	}
+
+	opts := server.ReplayOptions{
+		ReadOptions: nats.ReadOptions{
+			Subject:   c.String("subject"),
+			StartSeq:  c.Uint64("start-seq"),
+			EndSeq:    c.Uint64("end-seq"),
+			StartTime: c.Timestamp("from"),
+			EndTime:   c.Timestamp("to"),
+		},
+		DryRun: c.Bool("dry-run"),
+		Rate:   c.Int("rate"),
+	}
+	if opts.StartSeq == 0 && opts.StartTime.IsZero() {
+		return errors.New("replay start is required, set --start-seq or --from")
+	}
+
+	config := newServerConfig(c)
+	config.Audit = server.SourceNATS
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	report, err := server.Replay(ctx, config, opts)
+	logrus.WithFields(report.Fields()).WithField("dry_run", opts.DryRun).Info("Replay finished")
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("failed to replay %d of %d messages", report.Failed, report.Read)
+	}
+	return nil
+}
+
+func createReplayCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "replay",
+		Usage:  "run a range of the audit stream through the handler pipeline and output sinks again",
+		Action: replayAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "subject",
+				Usage: "replay only messages of the subject, NATS wildcards allowed `SUBJECT`",
+			},
+			&cli.Uint64Flag{
+				Name:  "start-seq",
+				Usage: "first stream sequence to replay `SEQ`",
+			},
+			&cli.Uint64Flag{
+				Name:  "end-seq",
+				Usage: "last stream sequence to replay `SEQ`",
+			},
+			&cli.TimestampFlag{
+				Name:  "from",
+				Usage: "replay messages published at or after the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.TimestampFlag{
+				Name:  "to",
+				Usage: "replay messages published before the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "log the handled events instead of delivering them to the sinks or quarantining them",
+			},
+			&cli.IntFlag{
+				Name:  "rate",
+				Usage: "maximum number of messages replayed per second, 0 for unlimited `RATE`",
+			},
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"events-audit/internal/schema"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+func schemaLintAction(_ context.Context, c *cli.Command) error {
+	dir := c.Args().First()
+	if dir == "" {
+		dir = c.String("audit-schema-dir")
+	}
+	if dir == "" {
+		return errors.New("schema directory is required, pass DIR or set --audit-schema-dir")
+	}
+
+	registry, problems, err := schema.Lint(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(c.Root().Writer, "%s: %s\n", problem.File, problem.Detail)
+	}
+
+	keys := make([]string, 0, len(registry.Keys()))
+	for _, key := range registry.Keys() {
+		keys = append(keys, key.String())
+	}
+	fields := logrus.Fields{
+		"dir":      dir,
+		"schemas":  keys,
+		"problems": len(problems),
+	}
+	if len(problems) > 0 {
+		logrus.WithFields(fields).Error("Schema lint failed")
+		return fmt.Errorf("schema lint found %d problems", len(problems))
+	}
+
+	logrus.WithFields(fields).Info("Schemas are valid")
+	return nil
+}
+
+func createSchemaCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "schema",
+		Usage: "manage the event schema registry",
+		Commands: []*cli.Command{
+			{
+				Name: "lint",
+				Usage: "check file names, JSON syntax, schema keywords and examples of the schemas in DIR " +
+					"or --audit-schema-dir",
+				ArgsUsage: "[DIR]",
+				Action:    schemaLintAction,
+			},
+		},
+	}
+}
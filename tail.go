@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"events-audit/internal/constants"
+	"events-audit/internal/nats"
+	"events-audit/internal/source"
+	"events-audit/internal/store"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// Output formats of the tail and search subcommands.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// connectReader connects a client that only reads the audit stream.
+func connectReader(ctx context.Context, c *cli.Command) (*nats.Client, error) {
+	config, err := newNatsConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return connectClient(ctx, config)
+}
+
+// eventPrinter prints a message read from the audit stream and reports
+// whether it was printed or skipped by the filter.
+type eventPrinter func(msg *source.Msg) (bool, error)
+
+// newEventPrinter returns a printer of the messages of the event type
+// selected by the type flag with the parsing and redaction of the server.
+func newEventPrinter(c *cli.Command) (eventPrinter, error) {
+	logger := logrus.New()
+	logger.SetOutput(c.Root().Writer)
+	switch output := c.String("output"); output {
+	case outputText:
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case outputJSON:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, errors.Errorf("unknown output %q, supported %s or %s", output, outputText, outputJSON)
+	}
+
+	eventLogger := nats.NewEventLogger(logger)
+	engine, err := loadRedactor(c)
+	if err != nil {
+		return nil, err
+	}
+	eventLogger.SetRedactor(engine)
+
+	filter := store.Filter{Type: c.String("type")}
+	return func(msg *source.Msg) (bool, error) {
+		if filter.Type != "" {
+			rec, recErr := store.RecordFromMsg(msg)
+			if recErr != nil || !filter.Matches(rec) {
+				return false, nil //nolint:nilerr // messages without metadata have no type to match
+			}
+		}
+		// Invalid events are printed as errors by the handler itself.
+		_ = eventLogger.HandleEvent(msg)
+		return true, nil
+	}, nil
+}
+
+// limitReads stops reading after limit events were printed; zero is no
+// limit. Messages skipped by the printer do not count.
+func limitReads(limit int, printEvent eventPrinter) nats.ReadFunc {
+	printed := 0
+	return func(msg *source.Msg) error {
+		ok, err := printEvent(msg)
+		if err != nil || !ok {
+			return err
+		}
+		printed++
+		if limit > 0 && printed >= limit {
+			return nats.ErrStopReading
+		}
+		return nil
+	}
+}
+
+func tailAction(ctx context.Context, c *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := nats.ReadOptions{
+		Subject:  c.String("subject"),
+		StartSeq: c.Uint64("start-seq"),
+		New:      true,
+		Follow:   true,
+	}
+	if since := c.Duration("since"); since > 0 {
+		opts.StartTime = time.Now().Add(-since)
+	}
+	if opts.StartSeq > 0 || !opts.StartTime.IsZero() {
+		opts.New = false
+	}
+
+	printEvent, err := newEventPrinter(c)
+	if err != nil {
+		return err
+	}
+	client, err := connectReader(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Read(ctx, opts, limitReads(c.Int("limit"), printEvent))
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func searchAction(ctx context.Context, c *cli.Command) error {
+	opts := nats.ReadOptions{
+		Subject:   c.String("subject"),
+		StartSeq:  c.Uint64("start-seq"),
+		EndSeq:    c.Uint64("end-seq"),
+		StartTime: c.Timestamp("from"),
+		EndTime:   c.Timestamp("to"),
+	}
+	if since := c.Duration("since"); since > 0 {
+		if !opts.StartTime.IsZero() {
+			return errors.New("--since and --from can not be combined")
+		}
+		opts.StartTime = time.Now().Add(-since)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	printEvent, err := newEventPrinter(c)
+	if err != nil {
+		return err
+	}
+	client, err := connectReader(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Read(ctx, opts, limitReads(c.Int("limit"), printEvent))
+}
+
+// readFlags returns the filter and output flags shared by tail and search.
+func readFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "subject",
+			Usage: "read only messages of the subject, NATS wildcards allowed `SUBJECT`",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "print only events of the type `TYPE`",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "output format: text or json `FORMAT`",
+			Value: outputText,
+		},
+		&cli.Uint64Flag{
+			Name:  "start-seq",
+			Usage: "first stream sequence to read `SEQ`",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Usage: "read messages published within the `DURATION` before now",
+		},
+	}
+}
+
+func createTailCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "tail",
+		Usage:  "print new events of the audit stream as they arrive",
+		Action: tailAction,
+		Flags: append(readFlags(),
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "exit after printing `COUNT` events, 0 to follow until interrupted",
+			},
+		),
+	}
+}
+
+func createSearchCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "search",
+		Usage:  "print the events of the audit stream in a time window or sequence range",
+		Action: searchAction,
+		Flags: append(readFlags(),
+			&cli.Uint64Flag{
+				Name:  "end-seq",
+				Usage: "last stream sequence to read `SEQ`",
+			},
+			&cli.TimestampFlag{
+				Name:  "from",
+				Usage: "read messages published at or after the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.TimestampFlag{
+				Name:  "to",
+				Usage: "read messages published before the RFC 3339 `TIME`",
+				Config: cli.TimestampConfig{
+					Layouts: []string{time.RFC3339Nano},
+				},
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "maximum number of events to print, 0 for all `COUNT`",
+				Value: constants.DefaultQueryLimit,
+			},
+		),
+	}
+}
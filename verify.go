@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+
+	"events-audit/internal/store"
+
+	"github.com/juju/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+func verifyAction(_ context.Context, c *cli.Command) error {
+	dir := c.String("audit-store-dir")
+	if dir == "" {
+		return errors.New("archive directory is required, set --audit-store-dir")
+	}
+
+	var publicKey ed25519.PublicKey
+	keyFile := c.String("public-key")
+	if keyFile == "" {
+		keyFile = c.String("audit-store-signing-key")
+	}
+	if keyFile != "" {
+		var err error
+		publicKey, err = store.LoadVerifyKey(keyFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		logrus.Warn("No verification key given, checkpoint signatures are not checked")
+	}
+
+	report, err := store.Verify(dir, publicKey)
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range report.Problems {
+		logrus.WithFields(logrus.Fields{
+			"kind":     problem.Kind,
+			"segment":  problem.Segment,
+			"offset":   problem.Offset,
+			"sequence": problem.Sequence,
+		}).Error(problem.Detail)
+	}
+	for _, gap := range report.Gaps {
+		logrus.WithField("sequence", gap.Sequence).Info(gap.Detail)
+	}
+
+	fields := logrus.Fields{
+		"dir":                  dir,
+		"segments":             report.Segments,
+		"records":              report.Records,
+		"first_seq":            report.FirstSeq,
+		"last_seq":             report.LastSeq,
+		"head":                 report.Head,
+		"checkpoints":          report.Checkpoints,
+		"verified_checkpoints": report.VerifiedCheckpoints,
+		"problems":             len(report.Problems),
+		"sequence_gaps":        len(report.Gaps),
+	}
+	if !report.OK() {
+		logrus.WithFields(fields).Error("Archive verification failed")
+		return fmt.Errorf("archive verification found %d problems", len(report.Problems))
+	}
+
+	logrus.WithFields(fields).Info("Archive verified")
+	return nil
+}
+
+func createVerifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "verify",
+		Usage:  "verify hash chain and checkpoint signatures of the local archive and list stream sequence gaps",
+		Action: verifyAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "public-key",
+				Usage:   "PEM encoded Ed25519 public or private key used to check checkpoint signatures `FILE`",
+				Sources: cli.EnvVars("AUDIT_LISTNER_VERIFY_PUBLIC_KEY"),
+			},
+		},
+	}
+}